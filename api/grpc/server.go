@@ -119,7 +119,10 @@ func (s *GRPCAPIServer) ExecuteStatement(in *service.ExecuteStatementRequest,
 	}
 	var schema *common.Schema
 	if in.Schema != "" {
-		schema = s.metaController.GetOrCreateSchema(strings.ToLower(in.Schema))
+		var err error
+		if schema, err = s.ce.UseSchema(strings.ToLower(in.Schema)); err != nil {
+			return api.MaybeConvertError(err)
+		}
 	}
 	execCtx := s.ce.CreateExecutionContext(stream.Context(), schema)
 	defer func() {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/squareup/pranadb/cluster/fake"
+	"github.com/squareup/pranadb/command"
 	"github.com/squareup/pranadb/common"
 	"github.com/squareup/pranadb/conf"
 	"github.com/squareup/pranadb/execctx"
@@ -209,6 +210,67 @@ func TestMissingSchema(t *testing.T) {
 		"missing 'schema' query parameter\n", http.StatusBadRequest, true, false)
 }
 
+func TestLineage(t *testing.T) {
+	ex, err := createTestSQLExec()
+	require.NoError(t, err)
+	server := startServer(t, ex)
+	defer func() {
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+	client := createClient(t, true)
+
+	resp, err := client.Get(fmt.Sprintf("https://localhost:6888/pranadb/lineage?schema=%s&name=totals", testSchemaName))
+	require.NoError(t, err)
+	defer closeRespBody(t, resp)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	lineage := &command.Lineage{}
+	err = json.NewDecoder(resp.Body).Decode(lineage)
+	require.NoError(t, err)
+	require.Equal(t, testLineage(testSchemaName), lineage)
+	require.Equal(t, "totals", ex.getLineageName())
+}
+
+func TestLineageDOT(t *testing.T) {
+	ex, err := createTestSQLExec()
+	require.NoError(t, err)
+	server := startServer(t, ex)
+	defer func() {
+		err := server.Stop()
+		require.NoError(t, err)
+	}()
+	client := createClient(t, true)
+
+	resp, err := client.Get(fmt.Sprintf("https://localhost:6888/pranadb/lineage?schema=%s&format=dot", testSchemaName))
+	require.NoError(t, err)
+	defer closeRespBody(t, resp)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/vnd.graphviz", resp.Header.Get("Content-Type"))
+	bodyBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	expected := `digraph "test_schema" {
+  "payments" [shape=cylinder, label="payments\n(source)"];
+  "totals" [shape=box, label="totals\n(materialized_view)"];
+  "totals-aggtable-0" [shape=note, label="totals-aggtable-0\n(internal)"];
+  "payments" -> "totals";
+  "totals-aggtable-0" -> "totals";
+}
+`
+	require.Equal(t, expected, string(bodyBytes))
+	require.Equal(t, "", ex.getLineageName())
+}
+
+func TestLineageMissingSchema(t *testing.T) {
+	testErrorResponse(t, "https://localhost:6888/pranadb/lineage",
+		"missing 'schema' query parameter\n", http.StatusBadRequest, true, false)
+}
+
+func TestLineageInvalidFormat(t *testing.T) {
+	testErrorResponse(t, "https://localhost:6888/pranadb/lineage?schema=test_schema&format=png",
+		"invalid format png\n", http.StatusBadRequest, true, false)
+}
+
 func TestHttp2Only(t *testing.T) {
 	testErrorResponse(t, "https://localhost:6888/pranadb?schema=test_schema",
 		"the pranadb HTTP API supports HTTP2 only\n", http.StatusHTTPVersionNotSupported, false, false)
@@ -499,6 +561,12 @@ type testSQLExecutor struct {
 	schema   string
 	pe       exec.PullExecutor
 	rows     *common.Rows
+	// lineageName is the name the lineage was last requested for
+	lineageName string
+}
+
+func (t *testSQLExecutor) UseSchema(schemaName string) (*common.Schema, error) {
+	return common.NewSchema(schemaName), nil
 }
 
 func (t *testSQLExecutor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql string, argTypes []common.ColumnType, args []interface{}) (exec.PullExecutor, error) {
@@ -521,6 +589,34 @@ func (t *testSQLExecutor) GetState() (sql string, argTypes []common.ColumnType,
 	return t.sql, t.argTypes, t.args, t.schema, t.rows
 }
 
+func (t *testSQLExecutor) GetLineage(schemaName string, name string) (*command.Lineage, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.lineageName = name
+	return testLineage(schemaName), nil
+}
+
+func (t *testSQLExecutor) getLineageName() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.lineageName
+}
+
+func testLineage(schemaName string) *command.Lineage {
+	return &command.Lineage{
+		SchemaName: schemaName,
+		Nodes: []*command.LineageNode{
+			{Name: "payments", Kind: "source"},
+			{Name: "totals", Kind: "materialized_view"},
+			{Name: "totals-aggtable-0", Kind: "internal"},
+		},
+		Edges: []*command.LineageEdge{
+			{From: "payments", To: "totals"},
+			{From: "totals-aggtable-0", To: "totals"},
+		},
+	}
+}
+
 func TestFoo(t *testing.T) {
 	s := "[123,3.45,\"foo\"]"
 
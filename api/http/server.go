@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -17,6 +18,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	log "github.com/sirupsen/logrus"
 	"github.com/squareup/pranadb/api"
+	"github.com/squareup/pranadb/command"
 	"github.com/squareup/pranadb/command/parser"
 	"github.com/squareup/pranadb/common"
 	"github.com/squareup/pranadb/conf"
@@ -32,6 +34,7 @@ type HTTPAPIServer struct {
 	listenAddress   string
 	apiPath         string
 	protoUploadPath string
+	lineagePath     string
 	listener        net.Listener
 	httpServer      *http.Server
 	closeWg         sync.WaitGroup
@@ -50,6 +53,8 @@ type sqlExecutor interface {
 	ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql string, argTypes []common.ColumnType,
 		args []interface{}) (exec.PullExecutor, error)
 	CreateExecutionContext(ctx context.Context, schema *common.Schema) *execctx.ExecutionContext
+	UseSchema(schemaName string) (*common.Schema, error)
+	GetLineage(schemaName string, name string) (*command.Lineage, error)
 }
 
 const rowBatchSize = 1000
@@ -60,6 +65,7 @@ func NewHTTPAPIServer(listenAddress string, apiPath string, executor sqlExecutor
 		listenAddress:   listenAddress,
 		apiPath:         apiPath,
 		protoUploadPath: fmt.Sprintf("%s/protoupload", apiPath),
+		lineagePath:     fmt.Sprintf("%s/lineage", apiPath),
 		executor:        executor,
 		metaController:  metaController,
 		protoRegistry:   protoRegistry,
@@ -119,6 +125,10 @@ func (s *HTTPAPIServer) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 		s.handleProtoUpload(writer, request)
 		return
 	}
+	if uri.Path == s.lineagePath {
+		s.handleLineage(writer, request, uri.Query())
+		return
+	}
 	if uri.Path != s.apiPath {
 		http.NotFound(writer, request)
 		return
@@ -136,8 +146,7 @@ func (s *HTTPAPIServer) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 	schemaName := query.Get("schema")
 	if schemaName == "" {
 		ast, err := parser.Parse(statement)
-		isShowSchemas := err == nil && ast.Show != nil && ast.Show.Schemas
-		if !isShowSchemas {
+		if err != nil || ast.NeedsSchema() {
 			http.Error(writer, "missing 'schema' query parameter", http.StatusBadRequest)
 			return
 		}
@@ -160,7 +169,11 @@ func (s *HTTPAPIServer) ServeHTTP(writer http.ResponseWriter, request *http.Requ
 	}
 	var schema *common.Schema
 	if schemaName != "" {
-		schema = s.metaController.GetOrCreateSchema(strings.ToLower(schemaName))
+		var err error
+		if schema, err = s.executor.UseSchema(strings.ToLower(schemaName)); err != nil {
+			maybeConvertAndSendError(err, writer)
+			return
+		}
 	}
 	execCtx := s.executor.CreateExecutionContext(request.Context(), schema)
 	defer func() {
@@ -340,6 +353,41 @@ func (s *HTTPAPIServer) handleProtoUpload(writer http.ResponseWriter, request *h
 	}
 }
 
+// handleLineage writes the lineage graph of a schema, or of the source, materialized view or sink given by the 'name'
+// query parameter, as JSON or, if the 'format' query parameter is 'dot', in the Graphviz DOT language
+func (s *HTTPAPIServer) handleLineage(writer http.ResponseWriter, request *http.Request, query url.Values) {
+	if request.Method != http.MethodGet {
+		http.Error(writer, "the pranadb lineage HTTP API only supports GET method", http.StatusMethodNotAllowed)
+		return
+	}
+	schemaName := query.Get("schema")
+	if schemaName == "" {
+		http.Error(writer, "missing 'schema' query parameter", http.StatusBadRequest)
+		return
+	}
+	format := strings.ToLower(query.Get("format"))
+	if format != "" && format != "json" && format != "dot" {
+		http.Error(writer, fmt.Sprintf("invalid format %s", format), http.StatusBadRequest)
+		return
+	}
+	lineage, err := s.executor.GetLineage(strings.ToLower(schemaName), query.Get("name"))
+	if err != nil {
+		maybeConvertAndSendError(err, writer)
+		return
+	}
+	if format == "dot" {
+		writer.Header().Add("Content-Type", "text/vnd.graphviz")
+		if _, err := writer.Write([]byte(lineage.DOT())); err != nil {
+			log.Errorf("failed to write lineage %v", err)
+		}
+		return
+	}
+	writer.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(writer).Encode(lineage); err != nil {
+		log.Errorf("failed to write lineage %v", err)
+	}
+}
+
 func maybeConvertAndSendError(err error, writer http.ResponseWriter) {
 	perr := api.MaybeConvertError(err)
 	var statusCode int
@@ -340,3 +340,48 @@ func TestClientNotExistentCertFile(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, "open nothing/here/client.crt: no such file or directory", err.Error())
 }
+
+func TestStrictSchemas(t *testing.T) {
+	cfg := conf.NewTestConfig(1)
+	serverAddress := "localhost:6584"
+	cfg.GRPCAPIServerEnabled = true
+	cfg.GRPCAPIServerListenAddresses = []string{serverAddress}
+	cfg.StrictSchemas = true
+	s, err := server.NewServer(*cfg)
+	require.NoError(t, err)
+	err = s.Start()
+	require.NoError(t, err)
+	defer func() {
+		err = s.Stop()
+		require.NoError(t, err)
+	}()
+	cli := NewClientUsingGRPC(serverAddress, TLSConfig{})
+	cli.SetExitOnError(false)
+	err = cli.Start()
+	require.NoError(t, err)
+	defer func() {
+		err = cli.Stop()
+		require.NoError(t, err)
+	}()
+
+	execute := func(statement string) string {
+		ch, err := cli.ExecuteStatement(statement, nil, nil)
+		require.NoError(t, err)
+		var out strings.Builder
+		for line := range ch {
+			out.WriteString(line)
+			out.WriteRune('\n')
+		}
+		return out.String()
+	}
+
+	require.Equal(t, "Failed to execute statement: PDB1022 - Unknown schema: test\n", execute("use test"))
+	require.Equal(t, "Failed to execute statement: PDB1001 - No schema in use\n", execute("show tables"))
+	require.Equal(t, "0 rows returned\n", execute("create schema test"))
+	require.Equal(t, "Failed to execute statement: PDB1023 - Schema test already exists\n", execute("create schema test"))
+	require.Equal(t, "0 rows returned\n", execute("use test"))
+	require.Equal(t, "0 rows returned\n", execute("show tables"))
+	require.Equal(t, "0 rows returned\n", execute("drop schema test"))
+	require.Equal(t, "Failed to execute statement: PDB1022 - Unknown schema: test\n", execute("show tables"))
+	require.Equal(t, "Failed to execute statement: PDB1022 - Unknown schema: test\n", execute("drop schema test"))
+}
@@ -195,22 +195,30 @@ func (c *Client) doExecuteStatementWithError(statement string, argTypes []string
 	if err != nil {
 		return 0, errors.NewPranaErrorf(errors.InvalidStatement, err.Error())
 	}
-	if ast.Use != "" {
-		c.currentSchema = strings.ToLower(ast.Use)
-		return 0, nil
-	}
-	if c.currentSchema == "" && !(ast.Show != nil && ast.Show.Schemas) {
+	if c.currentSchema == "" && ast.NeedsSchema() {
 		return 0, errors.NewSchemaNotInUseError()
 	}
+	// Show schemas lists the schema in use, so it's sent along with the statements which need it
+	schema := c.currentSchema
+	if !ast.NeedsSchema() && !(ast.Show != nil && ast.Show.Schemas) {
+		schema = ""
+	}
+	var numRows int
 	if c.useHTTPAPI {
-		return c.executeWithHTTPClient(statement, argTypes, args, ch)
+		numRows, err = c.executeWithHTTPClient(statement, schema, argTypes, args, ch)
+	} else {
+		numRows, err = c.executeWithGRPClient(statement, schema, argTypes, args, ch)
+	}
+	// The server checks the schema exists before it's used
+	if err == nil && ast.Use != "" {
+		c.currentSchema = strings.ToLower(ast.Use)
 	}
-	return c.executeWithGRPClient(statement, argTypes, args, ch)
+	return numRows, err
 }
 
-func (c *Client) executeWithHTTPClient(statement string, argTypes []string, args []string, ch chan string) (int, error) {
+func (c *Client) executeWithHTTPClient(statement string, schema string, argTypes []string, args []string, ch chan string) (int, error) {
 	statement = url.QueryEscape(statement)
-	schema := url.QueryEscape(c.currentSchema)
+	schema = url.QueryEscape(schema)
 	var argsParams string
 	if len(argTypes) > 0 {
 		quotedArgTypes := common.CSVQuote(argTypes...)
@@ -327,7 +335,7 @@ func (s *stringValUnmarshaller) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
-func (c *Client) executeWithGRPClient(statement string, argTypes []string, args []string, ch chan string) (int, error) { //nolint:gocyclo
+func (c *Client) executeWithGRPClient(statement string, schema string, argTypes []string, args []string, ch chan string) (int, error) { //nolint:gocyclo
 	psArgs := make([]*service.Arg, len(args))
 	for i, sargType := range argTypes {
 		sarg := args[i]
@@ -398,7 +406,7 @@ func (c *Client) executeWithGRPClient(statement string, argTypes []string, args
 	}
 
 	stream, err := c.grpcClient.ExecuteStatement(context.Background(), &service.ExecuteStatementRequest{
-		Schema:    c.currentSchema,
+		Schema:    schema,
 		Statement: &service.ExecuteStatementRequest_Sql{statement},
 		BatchSize: int32(c.batchSize),
 		Args:      psArgs,
@@ -51,6 +51,9 @@ type Cluster interface {
 	// GetLocalShardIDs returns the ids of the shards on the local node - this includes replicas
 	GetLocalShardIDs() []uint64
 
+	// GetReplicaNodeIDs returns the ids of the nodes which hold a replica of the shard
+	GetReplicaNodeIDs(shardID uint64) []int
+
 	// GenerateClusterSequence generates a cluster wide unique sequence number
 	GenerateClusterSequence(sequenceName string) (uint64, error)
 
@@ -108,6 +111,11 @@ type QueryExecutionInfo struct {
 	PsArgTypes        []common.ColumnType
 	PsArgs            []interface{}
 	PreparedStatement bool
+	// AsOf is the time, in unix micros, as of which the query reads its table, or zero if it reads the current rows
+	AsOf int64
+	// SampleSize is the number of rows the shard returns a random sample of, rather than all the rows of the query, or
+	// zero. Each sampled row has the number of rows it was sampled from appended.
+	SampleSize uint32
 }
 
 func (q *QueryExecutionInfo) Serialize(buff []byte) ([]byte, error) {
@@ -116,6 +124,8 @@ func (q *QueryExecutionInfo) Serialize(buff []byte) ([]byte, error) {
 	buff = common.AppendStringToBufferLE(buff, q.Query)
 	buff = common.AppendUint32ToBufferLE(buff, q.Limit)
 	buff = common.AppendUint64ToBufferLE(buff, q.ShardID)
+	buff = common.AppendUint64ToBufferLE(buff, uint64(q.AsOf))
+	buff = common.AppendUint32ToBufferLE(buff, q.SampleSize)
 	buff = appendBool(buff, q.SystemQuery)
 	buff = appendBool(buff, q.PreparedStatement)
 	if q.PreparedStatement {
@@ -165,6 +175,10 @@ func (q *QueryExecutionInfo) Deserialize(buff []byte) error {
 	q.Query, offset = common.ReadStringFromBufferLE(buff, offset)
 	q.Limit, offset = common.ReadUint32FromBufferLE(buff, offset)
 	q.ShardID, offset = common.ReadUint64FromBufferLE(buff, offset)
+	var asOf uint64
+	asOf, offset = common.ReadUint64FromBufferLE(buff, offset)
+	q.AsOf = int64(asOf)
+	q.SampleSize, offset = common.ReadUint32FromBufferLE(buff, offset)
 	q.SystemQuery = buff[offset] == 1
 	offset++
 	q.PreparedStatement = buff[offset] == 1
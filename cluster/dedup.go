@@ -56,6 +56,14 @@ originator_id uniquely defines where the row came from - in the case of ingestin
 source_id: 8 bytes
 partition_id: 8 bytes
 
+The low 4 bytes of partition_id are the Kafka partition. The 2 bytes above them hold the number of times the offsets of
+the source have been reset with ALTER SOURCE ... RESET OFFSETS. After a reset, messages can be consumed again at offsets which have already
+been seen, so the reset gives them a new originator_id which they aren't deduplicated against.
+
+The high 2 bytes of partition_id hold the index of the array element a row was parsed from, when a source explodes an
+array in each message into a row per element. The rows parsed from a message all have the same offset, so each element
+has its own originator_id.
+
 When receiving a row from another shard (e.g. in the case of forwarding a partial aggregation) it is made of:
 
 internal aggregation table id: 8 bytes
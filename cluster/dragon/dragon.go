@@ -183,6 +183,10 @@ func (d *Dragon) GetLocalShardIDs() []uint64 {
 	return d.localDataShards
 }
 
+func (d *Dragon) GetReplicaNodeIDs(shardID uint64) []int {
+	return d.shardAllocs[shardID]
+}
+
 func (d *Dragon) ExecuteRemotePullQuery(queryInfo *cluster.QueryExecutionInfo, rowsFactory *common.RowsFactory) (*common.Rows, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
@@ -143,6 +143,10 @@ func (f *FakeCluster) GetLocalShardIDs() []uint64 {
 	return f.allShardIds
 }
 
+func (f *FakeCluster) GetReplicaNodeIDs(shardID uint64) []int {
+	return []int{f.nodeID}
+}
+
 func (f *FakeCluster) GenerateClusterSequence(sequenceName string) (uint64, error) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -125,5 +125,6 @@ func createConfigWithAllFields() conf.Config {
 		DataCompressionDisabled: true,
 		OrderByMaxRows:          123456,
 		MaxRowCacheSize:         "987654",
+		StrictSchemas:           true,
 	}
 }
@@ -93,4 +93,5 @@ intra-cluster-tls-client-auth = "require-and-verify-client-cert"
 global-cache-size                   = "12345"
 data-compression-disabled           = true
 order-by-max-rows                   = 123456
-max-row-cache-size                  = "987654"
\ No newline at end of file
+max-row-cache-size                  = "987654"
+strict-schemas                      = true
\ No newline at end of file
@@ -0,0 +1,282 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/interruptor"
+	"github.com/squareup/pranadb/parplan"
+	"github.com/squareup/pranadb/push"
+)
+
+// AlterMVCommand changes the query of a materialized view. A new materialized view is built and filled alongside the
+// current one, which carries on being updated and queried as normal. Once the new one has caught up it replaces the
+// current one under the same name, and the data of the current one is deleted.
+type AlterMVCommand struct {
+	lock              sync.Mutex
+	e                 *Executor
+	schemaName        string
+	sql               string
+	mvName            string
+	query             string
+	tableSequences    []uint64
+	prevMV            *push.MaterializedView
+	mv                *push.MaterializedView
+	toDeleteBatch     *cluster.ToDeleteBatch
+	prevToDeleteBatch *cluster.ToDeleteBatch
+	interruptor       interruptor.Interruptor
+	leadersMap        map[uint64]uint64
+	replaced          bool
+}
+
+func (a *AlterMVCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeAlterMV
+}
+
+func (a *AlterMVCommand) SchemaName() string {
+	return a.schemaName
+}
+
+func (a *AlterMVCommand) SQL() string {
+	return a.sql
+}
+
+func (a *AlterMVCommand) TableSequences() []uint64 {
+	return a.tableSequences
+}
+
+func (a *AlterMVCommand) Cancel() {
+	a.interruptor.Interrupt()
+}
+
+func NewOriginatingAlterMVCommand(e *Executor, schemaName string, sql string, tableSequences []uint64,
+	ast *parser.AlterMaterializedView) (*AlterMVCommand, error) {
+	leadersMap, err := e.cluster.GetLeadersMap()
+	if err != nil {
+		return nil, err
+	}
+	return &AlterMVCommand{
+		e:              e,
+		schemaName:     schemaName,
+		sql:            sql,
+		mvName:         strings.ToLower(ast.Name),
+		query:          ast.Query.String(),
+		tableSequences: tableSequences,
+		leadersMap:     leadersMap,
+	}, nil
+}
+
+func NewAlterMVCommand(e *Executor, schemaName string, sql string, tableSequences []uint64, extraData []byte) *AlterMVCommand {
+	return &AlterMVCommand{
+		e:              e,
+		schemaName:     schemaName,
+		sql:            sql,
+		tableSequences: tableSequences,
+		leadersMap:     deserializeLeadersMap(extraData),
+	}
+}
+
+func (a *AlterMVCommand) OnPhase(phase int32) error {
+	switch phase {
+	case 0:
+		return a.onPhase0()
+	case 1:
+		return a.onPhase1()
+	case 2:
+		return a.onPhase2()
+	case 3:
+		return a.onPhase3()
+	default:
+		panic("invalid phase")
+	}
+}
+
+func (a *AlterMVCommand) NumPhases() int {
+	return 4
+}
+
+func (a *AlterMVCommand) Before() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// Mainly validation
+	return a.createMV()
+}
+
+func (a *AlterMVCommand) onPhase0() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// If phase0 on the originating node, mv will already be set
+	if a.mv == nil {
+		if err := a.createMV(); err != nil {
+			return err
+		}
+	}
+
+	// If the alter fails the data of the new MV will be deleted on restart
+	tableIDs := []uint64{a.mv.Info.ID}
+	if a.mv.Info.VersionRetentionDuration != 0 {
+		tableIDs = append(tableIDs, a.mv.Info.VersionTableID)
+	}
+	var err error
+	a.toDeleteBatch, err = storeToDeleteBatchForTables(a.mv.Info.ID, tableIDs, a.e.cluster)
+	if err != nil {
+		return err
+	}
+
+	// Aggregations must be connected as remote consumers on all nodes before the fill starts, as with create
+	return a.mv.Connect(false, true)
+}
+
+func (a *AlterMVCommand) onPhase1() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var localLeaderShards []uint64
+	for shardID, nodeID := range a.leadersMap {
+		if nodeID == uint64(a.e.cluster.GetNodeID()) {
+			localLeaderShards = append(localLeaderShards, shardID)
+		}
+	}
+
+	if err := a.e.cluster.RegisterStartFill(a.leadersMap, &a.interruptor); err != nil {
+		return err
+	}
+
+	// The current MV carries on being updated while the new one is filled
+	if err := a.mv.Fill(localLeaderShards, &a.interruptor); err != nil {
+		return err
+	}
+
+	a.e.cluster.RegisterEndFill()
+	return nil
+}
+
+func (a *AlterMVCommand) onPhase2() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// Make sure any rows forwarded during the fill have been processed
+	if err := a.e.pushEngine.WaitForSchedulers(); err != nil {
+		return err
+	}
+
+	// The new MV is up to date, so it can now replace the current one
+	if err := a.mv.Replace(a.prevMV); err != nil {
+		return errors.WithStack(err)
+	}
+	a.replaced = true
+	if err := a.e.metaController.AlterMaterializedView(a.prevMV.Info, a.prevMV.InternalTables, a.mv.Info,
+		a.mv.InternalTables); err != nil {
+		return err
+	}
+	return a.e.cluster.RemoveToDeleteBatch(a.toDeleteBatch)
+}
+
+func (a *AlterMVCommand) onPhase3() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// Nothing uses the data of the replaced MV any more
+	return a.prevMV.Drop()
+}
+
+func (a *AlterMVCommand) AfterPhase(phase int32) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	switch phase {
+	case 0:
+		// We record the data of the current MV to delete now - it will only be deleted on restart once the current MV
+		// is no longer in the tables table
+		tableIDs := []uint64{a.prevMV.Info.ID}
+		if a.prevMV.Info.VersionRetentionDuration != 0 {
+			tableIDs = append(tableIDs, a.prevMV.Info.VersionTableID)
+		}
+		for _, it := range a.prevMV.InternalTables {
+			tableIDs = append(tableIDs, it.ID)
+		}
+		var err error
+		a.prevToDeleteBatch, err = storeToDeleteBatchForTables(a.prevMV.Info.ID, tableIDs, a.e.cluster)
+		return err
+	case 1:
+		// The new MV replaces the current one in the tables table once it has been filled, so after a failure and
+		// restart it's one or the other
+		return a.e.metaController.PersistAlteredMaterializedView(a.prevMV.Info, a.prevMV.InternalTables, a.mv.Info,
+			a.mv.InternalTables)
+	case 3:
+		return a.e.cluster.RemoveToDeleteBatch(a.prevToDeleteBatch)
+	}
+	return nil
+}
+
+func (a *AlterMVCommand) Cleanup() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.mv == nil || a.replaced {
+		return
+	}
+	if err := a.mv.Disconnect(); err != nil {
+		// Ignore
+	}
+	a.e.cluster.RegisterEndFill()
+}
+
+func (a *AlterMVCommand) createMV() error {
+	if a.mvName == "" {
+		ast, err := parser.Parse(a.sql)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if ast.Alter == nil || ast.Alter.MaterializedView == nil {
+			return errors.Errorf("not an alter materialized view command %s", a.sql)
+		}
+		a.mvName = strings.ToLower(ast.Alter.MaterializedView.Name)
+		a.query = ast.Alter.MaterializedView.Query.String()
+	}
+	mvInfo, ok := a.e.metaController.GetMaterializedView(a.schemaName, a.mvName)
+	if !ok {
+		return errors.NewUnknownMaterializedViewError(a.schemaName, a.mvName)
+	}
+	prevMV, err := a.e.pushEngine.GetMaterializedView(mvInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Anything consuming the MV would have to be rebuilt too
+	consuming := prevMV.GetConsumingMVOrIndexNames()
+	if len(consuming) != 0 {
+		return errors.NewAlterMaterializedViewHasChildrenError(a.schemaName, a.mvName, consuming)
+	}
+	sequences := a.tableSequences
+	var versionTableID uint64
+	if mvInfo.VersionRetentionDuration != 0 {
+		if len(sequences) < 4 {
+			return errors.NewPranaErrorf(errors.DdlRetry, "materialized view %s.%s changed", a.schemaName, a.mvName)
+		}
+		// The version table takes the last sequence, as with create
+		versionTableID = sequences[len(sequences)-1]
+		sequences = sequences[:len(sequences)-1]
+	}
+	schema, ok := a.e.metaController.GetSchema(a.schemaName)
+	if !ok {
+		return errors.Errorf("no such schema %s", a.schemaName)
+	}
+	seqGenerator := common.NewPreallocSeqGen(sequences)
+	tableID := seqGenerator.GenerateSequence()
+	mv, err := push.CreateReplacementMaterializedView(a.e.pushEngine, parplan.NewPlanner(schema), schema, prevMV, a.query,
+		tableID, versionTableID, seqGenerator)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	a.prevMV = prevMV
+	a.mv = mv
+	return nil
+}
+
+func (a *AlterMVCommand) GetExtraData() []byte {
+	return serializeLeadersMap(a.leadersMap)
+}
@@ -0,0 +1,412 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/command/parser/selector"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/push/source"
+)
+
+// AlterSourceCommand adds a column to a source, drops a column from it, or widens the type of one of its columns. The
+// source is stopped on every node, then once the rows it has already ingested have been processed its stored rows are
+// migrated to the new columns and anything consuming it is rebuilt. The altered source is persisted once that has been
+// done on every node, then it is started again. If the alteration fails on any node before the altered source is
+// persisted, it is rolled back on every node.
+type AlterSourceCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	ast        *parser.AlterSource
+	prevInfo   *common.SourceInfo
+	sourceInfo *common.SourceInfo
+	source     *source.Source
+	wasRunning bool
+	migrated   bool
+	finished   bool
+}
+
+func (a *AlterSourceCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeAlterSource
+}
+
+func (a *AlterSourceCommand) SchemaName() string {
+	return a.schemaName
+}
+
+func (a *AlterSourceCommand) SQL() string {
+	return a.sql
+}
+
+func (a *AlterSourceCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (a *AlterSourceCommand) Cancel() {
+}
+
+func NewOriginatingAlterSourceCommand(e *Executor, schemaName string, sql string, ast *parser.AlterSource) *AlterSourceCommand {
+	return &AlterSourceCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		ast:        ast,
+	}
+}
+
+func NewAlterSourceCommand(e *Executor, schemaName string, sql string) *AlterSourceCommand {
+	return &AlterSourceCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (a *AlterSourceCommand) Before() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err := a.getSourceInfos(); err != nil {
+		return err
+	}
+	return a.e.pushEngine.ValidateAlteredSource(a.sourceInfo)
+}
+
+func (a *AlterSourceCommand) OnPhase(phase int32) error {
+	switch phase {
+	case 0:
+		return a.onPhase0()
+	case 1:
+		return a.onPhase1()
+	case 2:
+		return a.onPhase2()
+	case 3:
+		return a.onPhase3()
+	default:
+		panic("invalid phase")
+	}
+}
+
+func (a *AlterSourceCommand) NumPhases() int {
+	return 4
+}
+
+func (a *AlterSourceCommand) onPhase0() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.sourceInfo == nil {
+		if err := a.getSourceInfos(); err != nil {
+			return err
+		}
+	}
+	src, err := a.e.pushEngine.GetSource(a.sourceInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	a.source = src
+	// Stop ingesting, so no more rows are forwarded with the old columns
+	a.wasRunning = src.IsRunning()
+	return src.Stop()
+}
+
+func (a *AlterSourceCommand) onPhase1() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// The source is now stopped on every node, so once the rows it has forwarded have been processed no rows remain
+	// with the old columns, other than those stored in the source
+	return a.e.pushEngine.WaitForSourceRowsProcessed(a.sourceInfo)
+}
+
+func (a *AlterSourceCommand) onPhase2() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	log.Debugf("alter source command phase 2 %s.%s", a.sourceInfo.SchemaName, a.sourceInfo.Name)
+	// Rows may be migrated even if this fails, so from now on the alteration is rolled back if it fails
+	a.migrated = true
+	if err := a.e.pushEngine.AlterSource(a.prevInfo, a.sourceInfo); err != nil {
+		return err
+	}
+	if err := a.e.metaController.AlterSource(a.sourceInfo); err != nil {
+		return errors.WithStack(err)
+	}
+	return a.e.pushEngine.RebuildSourceDependents(a.sourceInfo)
+}
+
+func (a *AlterSourceCommand) onPhase3() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// The altered source has been persisted, so the alteration can no longer be rolled back
+	a.finished = true
+	if err := a.e.pushEngine.FinishSourceAlteration(a.sourceInfo); err != nil {
+		return err
+	}
+	return a.restartSource()
+}
+
+func (a *AlterSourceCommand) AfterPhase(phase int32) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if phase == 2 {
+		// We persist the altered source once every node has migrated its rows and is ready to ingest with the new
+		// columns
+		return a.e.metaController.PersistAlteredSource(a.sourceInfo)
+	}
+	return nil
+}
+
+func (a *AlterSourceCommand) Cleanup() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.migrated && !a.finished {
+		if err := a.e.pushEngine.RollbackSourceAlteration(a.prevInfo, a.sourceInfo); err != nil {
+			// The source isn't restarted, as its rows may not match its columns
+			log.Errorf("failed to roll back alteration of source %s.%s %+v", a.sourceInfo.SchemaName,
+				a.sourceInfo.Name, err)
+			return
+		}
+		a.migrated = false
+	}
+	if err := a.restartSource(); err != nil {
+		log.Errorf("failed to restart source %+v", err)
+	}
+}
+
+func (a *AlterSourceCommand) restartSource() error {
+	if a.source == nil || !a.wasRunning {
+		return nil
+	}
+	a.wasRunning = false
+	return a.source.Start()
+}
+
+func (a *AlterSourceCommand) GetExtraData() []byte {
+	return nil
+}
+
+func (a *AlterSourceCommand) getSourceInfos() error {
+	if a.ast == nil {
+		ast, err := parser.Parse(a.sql)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if ast.Alter == nil || ast.Alter.Source == nil {
+			return errors.Errorf("not an alter source command %s", a.sql)
+		}
+		a.ast = ast.Alter.Source
+	}
+	sourceName := strings.ToLower(a.ast.Name)
+	prevInfo, ok := a.e.metaController.GetSource(a.schemaName, sourceName)
+	if !ok {
+		return errors.NewUnknownSourceError(a.schemaName, sourceName)
+	}
+	sourceInfo, err := getAlteredSourceInfo(prevInfo, a.ast)
+	if err != nil {
+		return err
+	}
+	a.prevInfo = prevInfo
+	a.sourceInfo = sourceInfo
+	return nil
+}
+
+// getAlteredSourceInfo returns a copy of the source with the alteration applied. Dropping a column moves the columns
+// after it, so primary key and index columns are renumbered.
+// nolint: gocyclo
+func getAlteredSourceInfo(prevInfo *common.SourceInfo, ast *parser.AlterSource) (*common.SourceInfo, error) {
+	tableInfo := *prevInfo.TableInfo
+	originInfo := *prevInfo.OriginInfo
+	colIndex := func(name string) int {
+		for i, colName := range tableInfo.ColumnNames {
+			if colName == name {
+				return i
+			}
+		}
+		return -1
+	}
+	// colMapping maps the index of each column before the alteration to its index after
+	colMapping := func(col int) int {
+		return col
+	}
+	switch {
+	case ast.AddColumn != nil:
+		colName := strings.ToLower(ast.AddColumn.Column.Name)
+		if colIndex(colName) != -1 {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Column %s already exists in %s.%s", colName,
+				prevInfo.SchemaName, prevInfo.Name)
+		}
+		if ast.AddColumn.Column.Computed != nil {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot add computed column %s. Computed columns can only be defined when a source is created", colName)
+		}
+		colType, err := ast.AddColumn.Column.ToColumnType()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		colSelector := ast.AddColumn.Selector.ToSelector()
+		if err := validateColumnSelector(colSelector); err != nil {
+			return nil, err
+		}
+		tableInfo.ColumnNames = append(copyStrings(tableInfo.ColumnNames), colName)
+		tableInfo.ColumnTypes = append(append([]common.ColumnType{}, tableInfo.ColumnTypes...), colType)
+		if tableInfo.ColsVisible != nil {
+			tableInfo.ColsVisible = append(append([]bool{}, tableInfo.ColsVisible...), true)
+		}
+		originInfo.ColSelectors = append(append([]selector.ColumnSelector{}, originInfo.ColSelectors...), colSelector)
+		if originInfo.ComputedColumns != nil {
+			originInfo.ComputedColumns = append(copyStrings(originInfo.ComputedColumns), "")
+		}
+		if err := validateExplodedArray(originInfo.ColSelectors); err != nil {
+			return nil, err
+		}
+	case ast.DropColumn != "":
+		colName := strings.ToLower(ast.DropColumn)
+		dropped, err := checkAlteredColumn(prevInfo, colName, colIndex(colName), "drop")
+		if err != nil {
+			return nil, err
+		}
+		if len(tableInfo.ColumnNames) == 1 {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot drop the only column of %s.%s",
+				prevInfo.SchemaName, prevInfo.Name)
+		}
+		if colName == "row_time" && prevInfo.RetentionDuration != 0 {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot drop column row_time as the retention time of %s.%s is based on it", prevInfo.SchemaName, prevInfo.Name)
+		}
+		tableInfo.ColumnNames = append(copyStrings(tableInfo.ColumnNames[:dropped]), tableInfo.ColumnNames[dropped+1:]...)
+		tableInfo.ColumnTypes = append(append([]common.ColumnType{}, tableInfo.ColumnTypes[:dropped]...),
+			tableInfo.ColumnTypes[dropped+1:]...)
+		if tableInfo.ColsVisible != nil {
+			tableInfo.ColsVisible = append(append([]bool{}, tableInfo.ColsVisible[:dropped]...),
+				tableInfo.ColsVisible[dropped+1:]...)
+		}
+		originInfo.ColSelectors = append(append([]selector.ColumnSelector{}, originInfo.ColSelectors[:dropped]...),
+			originInfo.ColSelectors[dropped+1:]...)
+		if originInfo.ComputedColumns != nil {
+			originInfo.ComputedColumns = append(copyStrings(originInfo.ComputedColumns[:dropped]),
+				originInfo.ComputedColumns[dropped+1:]...)
+			if allComputed(originInfo.ComputedColumns) {
+				return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+					"Cannot drop the only column of %s.%s which is not computed", prevInfo.SchemaName, prevInfo.Name)
+			}
+		}
+		colMapping = func(col int) int {
+			if col > dropped {
+				return col - 1
+			}
+			return col
+		}
+	case ast.ModifyColumn != nil:
+		colName := strings.ToLower(ast.ModifyColumn.Name)
+		modified, err := checkAlteredColumn(prevInfo, colName, colIndex(colName), "modify")
+		if err != nil {
+			return nil, err
+		}
+		if ast.ModifyColumn.Computed != nil {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot modify column %s to be computed. Computed columns can only be defined when a source is created", colName)
+		}
+		colType, err := ast.ModifyColumn.ToColumnType()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		prevType := tableInfo.ColumnTypes[modified]
+		if !isWidening(prevType, colType) {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot modify column %s from %s to %s. Only widening an integer type, or the precision of a decimal type, is supported",
+				colName, prevType.String(), colType.String())
+		}
+		tableInfo.ColumnTypes = append([]common.ColumnType{}, tableInfo.ColumnTypes...)
+		tableInfo.ColumnTypes[modified] = colType
+	default:
+		return nil, errors.Errorf("invalid alter source %s", prevInfo.Name)
+	}
+
+	tableInfo.PrimaryKeyCols = mapColumns(tableInfo.PrimaryKeyCols, colMapping)
+	tableInfo.CalcPKColsSet()
+	if prevInfo.IndexInfos != nil {
+		tableInfo.IndexInfos = make(map[string]*common.IndexInfo, len(prevInfo.IndexInfos))
+		for indexName, prevIndexInfo := range prevInfo.IndexInfos {
+			indexInfo := *prevIndexInfo
+			indexInfo.IndexCols = mapColumns(indexInfo.IndexCols, colMapping)
+			indexInfo.IncludeCols = mapColumns(indexInfo.IncludeCols, colMapping)
+			indexInfo.CalcColsSet()
+			tableInfo.IndexInfos[indexName] = &indexInfo
+		}
+	}
+	return &common.SourceInfo{TableInfo: &tableInfo, OriginInfo: &originInfo}, nil
+}
+
+// checkAlteredColumn checks that a column which is to be dropped or modified exists and is not stored in the primary
+// key or in an index, and returns its index
+func checkAlteredColumn(sourceInfo *common.SourceInfo, colName string, colIndex int, op string) (int, error) {
+	if colIndex == -1 {
+		return 0, errors.NewPranaErrorf(errors.InvalidStatement, "Unknown column %s in %s.%s", colName,
+			sourceInfo.SchemaName, sourceInfo.Name)
+	}
+	if sourceInfo.IsPrimaryKeyCol(colIndex) {
+		return 0, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot %s column %s as it is part of the primary key",
+			op, colName)
+	}
+	for _, indexInfo := range sourceInfo.IndexInfos {
+		if indexInfo.ContainsColIndex(colIndex) {
+			return 0, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot %s column %s as it is used by index %s",
+				op, colName, indexInfo.Name)
+		}
+	}
+	return colIndex, nil
+}
+
+// isWidening returns true if every value of the type prevType can be stored in the type colType, and the columns are
+// not of the same type. Integer types are encoded the same way so their values need no conversion.
+func isWidening(prevType common.ColumnType, colType common.ColumnType) bool {
+	switch prevType.Type {
+	case common.TypeTinyInt:
+		return colType.Type == common.TypeInt || colType.Type == common.TypeBigInt
+	case common.TypeInt:
+		return colType.Type == common.TypeBigInt
+	case common.TypeDecimal:
+		return colType.Type == common.TypeDecimal && colType.DecScale == prevType.DecScale &&
+			colType.DecPrecision > prevType.DecPrecision
+	default:
+		return false
+	}
+}
+
+func mapColumns(cols []int, colMapping func(int) int) []int {
+	if cols == nil {
+		return nil
+	}
+	mapped := make([]int, len(cols))
+	for i, col := range cols {
+		if col == -1 {
+			// An expression in a functional index
+			mapped[i] = -1
+		} else {
+			mapped[i] = colMapping(col)
+		}
+	}
+	return mapped
+}
+
+func copyStrings(strs []string) []string {
+	return append(make([]string, 0, len(strs)+1), strs...)
+}
+
+// allComputed returns true if every column of a source is computed, so no value would be selected from the messages
+func allComputed(computed []string) bool {
+	for _, expr := range computed {
+		if expr == "" {
+			return false
+		}
+	}
+	return true
+}
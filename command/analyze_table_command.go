@@ -0,0 +1,152 @@
+package command
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/execctx"
+	"github.com/squareup/pranadb/parplan"
+)
+
+const analyzeBatchSize = 1000
+
+// AnalyzeTableCommand distributes the statistics collected for a table to every node in the cluster so the planner
+// can use them. The statistics are collected on the originating node before the command is run, and are passed to the
+// other nodes in the extra data.
+type AnalyzeTableCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	tableName  string
+	stats      *common.TableStats
+}
+
+func (a *AnalyzeTableCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeAnalyzeTable
+}
+
+func (a *AnalyzeTableCommand) SchemaName() string {
+	return a.schemaName
+}
+
+func (a *AnalyzeTableCommand) SQL() string {
+	return a.sql
+}
+
+func (a *AnalyzeTableCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (a *AnalyzeTableCommand) Cancel() {
+}
+
+func NewOriginatingAnalyzeTableCommand(e *Executor, schemaName string, sql string, tableName string, stats *common.TableStats) *AnalyzeTableCommand {
+	return &AnalyzeTableCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		tableName:  tableName,
+		stats:      stats,
+	}
+}
+
+func NewAnalyzeTableCommand(e *Executor, schemaName string, sql string, extraData []byte) *AnalyzeTableCommand {
+	stats := &common.TableStats{}
+	if err := json.Unmarshal(extraData, stats); err != nil {
+		panic(err)
+	}
+	return &AnalyzeTableCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		stats:      stats,
+	}
+}
+
+func (a *AnalyzeTableCommand) Before() error {
+	return nil
+}
+
+func (a *AnalyzeTableCommand) OnPhase(phase int32) error {
+	switch phase {
+	case 0:
+		return a.onPhase0()
+	default:
+		panic("invalid phase")
+	}
+}
+
+func (a *AnalyzeTableCommand) NumPhases() int {
+	return 1
+}
+
+func (a *AnalyzeTableCommand) onPhase0() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.tableName == "" {
+		ast, err := parser.Parse(a.sql)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if ast.Analyze == "" {
+			return errors.Errorf("not an analyze table command %s", a.sql)
+		}
+		a.tableName = strings.ToLower(ast.Analyze)
+	}
+	return a.e.metaController.RegisterTableStats(a.schemaName, a.tableName, a.stats)
+}
+
+func (a *AnalyzeTableCommand) AfterPhase(phase int32) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if phase == 0 {
+		return a.e.metaController.PersistTableStats(a.schemaName, a.tableName, a.stats)
+	}
+	return nil
+}
+
+func (a *AnalyzeTableCommand) Cleanup() {
+}
+
+func (a *AnalyzeTableCommand) GetExtraData() []byte {
+	bytes, err := json.Marshal(a.stats)
+	if err != nil {
+		panic(err)
+	}
+	return bytes
+}
+
+// collectTableStats builds statistics for the table from a random sample of the rows of each shard, taken on the
+// shards so the whole table isn't sent to this node
+func (e *Executor) collectTableStats(execCtx *execctx.ExecutionContext, tableName string) (*common.TableStats, error) {
+	var tableInfo *common.TableInfo
+	src, ok := e.metaController.GetSource(execCtx.Schema.Name, tableName)
+	if !ok {
+		mv, ok := e.metaController.GetMaterializedView(execCtx.Schema.Name, tableName)
+		if !ok {
+			return nil, errors.NewUnknownTableError(execCtx.Schema.Name, tableName)
+		}
+		tableInfo = mv.GetTableInfo()
+	} else {
+		tableInfo = src.GetTableInfo()
+	}
+	collector := parplan.NewStatsCollector(tableInfo)
+	scanCtx := e.CreateExecutionContext(execCtx.Ctx, execCtx.Schema)
+	shardSamples, err := e.pullEngine.SampleShards(scanCtx, collector.Query(), collector.SampleSize(), analyzeBatchSize)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, rows := range shardSamples {
+		if err := collector.AddShardSample(rows); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return collector.Build(), nil
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/squareup/pranadb/pull"
 	"github.com/squareup/pranadb/pull/exec"
 	"github.com/squareup/pranadb/push"
+	"github.com/squareup/pranadb/schemaregistry"
 )
 
 const ddlRetryTimeout = 1 * time.Minute
@@ -36,6 +37,7 @@ type Executor struct {
 	pushEngine        *push.Engine
 	pullEngine        *pull.Engine
 	protoRegistry     protolib.Resolver
+	schemaRegistry    schemaregistry.Registry
 	execCtxIDSequence int64
 	ddlRunner         *DDLCommandRunner
 	failureInjector   failinject.Injector
@@ -46,13 +48,14 @@ type Executor struct {
 
 func NewCommandExecutor(metaController *meta.Controller, pushEngine *push.Engine, pullEngine *pull.Engine,
 	cluster cluster.Cluster, ddlClient remoting.Broadcaster, ddlResetClient remoting.Broadcaster, protoRegistry protolib.Resolver,
-	failureInjector failinject.Injector, config *conf.Config) *Executor {
+	schemaRegistry schemaregistry.Registry, failureInjector failinject.Injector, config *conf.Config) *Executor {
 	ex := &Executor{
 		cluster:           cluster,
 		metaController:    metaController,
 		pushEngine:        pushEngine,
 		pullEngine:        pullEngine,
 		protoRegistry:     protoRegistry,
+		schemaRegistry:    schemaRegistry,
 		execCtxIDSequence: -1,
 		failureInjector:   failureInjector,
 		ddlClient:         ddlClient,
@@ -95,9 +98,38 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 	case ast.Select != "":
 		dag, err := e.pullEngine.BuildPullQuery(execCtx, sql, argTypes, args)
 		return dag, errors.WithStack(err)
+	case ast.Use != "":
+		// The schema in use is held by the client, so using one only has to check it exists
+		if e.config.StrictSchemas && !e.metaController.SchemaExists(strings.ToLower(ast.Use)) {
+			return nil, errors.NewUnknownSchemaError(strings.ToLower(ast.Use))
+		}
+		return exec.Empty, nil
+	case ast.Create != nil && ast.Create.Schema != "":
+		command := NewCreateSchemaCommand(e, strings.ToLower(ast.Create.Schema), sql)
+		if err := e.ddlRunner.RunCommand(execCtx.Ctx, command); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.Drop != nil && ast.Drop.Schema && ast.Drop.Cascade:
+		command := NewOriginatingDropCascadeCommand(e, strings.ToLower(ast.Drop.Name), sql, ast.Drop)
+		if err := e.ddlRunner.RunCommand(execCtx.Ctx, command); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.Drop != nil && ast.Drop.Schema:
+		command := NewDropSchemaCommand(e, strings.ToLower(ast.Drop.Name), sql)
+		if err := e.ddlRunner.RunCommand(execCtx.Ctx, command); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
 	case ast.Create != nil && ast.Create.Source != nil:
-		// We need two sequence numbers if the source has a retention period as we create an index for that
-		sequences, err := e.generateTableIDSequences(2)
+		// We need two sequence numbers if the source has a retention period as we create an index for that, and a
+		// third if it keeps prior versions of rows
+		numSequences := 2
+		if sourceKeepsVersions(ast.Create.Source) {
+			numSequences = 3
+		}
+		sequences, err := e.generateTableIDSequences(numSequences)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -113,7 +145,12 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 		return exec.Empty, nil
 	case ast.Create != nil && ast.Create.MaterializedView != nil:
 		if err := e.executeCommandWithRetry(execCtx.Ctx, func() (DDLCommand, error) {
-			sequences, err := e.generateTableIDSequences(3)
+			// The MV keeps prior versions of rows in a table of its own
+			numSequences := 3
+			if mvKeepsVersions(ast.Create.MaterializedView) {
+				numSequences = 4
+			}
+			sequences, err := e.generateTableIDSequences(numSequences)
 			if err != nil {
 				return nil, errors.WithStack(err)
 			}
@@ -141,6 +178,17 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 			return nil, errors.WithStack(err)
 		}
 		return exec.Empty, nil
+	case ast.Create != nil && ast.Create.View != nil:
+		if err := e.executeCommandWithRetry(execCtx.Ctx, func() (DDLCommand, error) {
+			sequences, err := e.generateTableIDSequences(1)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return NewOriginatingCreateViewCommand(e, execCtx.Planner(), execCtx.Schema, sql, sequences, ast.Create.View), nil
+		}); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
 	case ast.Create != nil && ast.Create.Index != nil:
 		if err := e.executeCommandWithRetry(execCtx.Ctx, func() (DDLCommand, error) {
 			sequences, err := e.generateTableIDSequences(1)
@@ -156,6 +204,56 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 			return nil, errors.WithStack(err)
 		}
 		return exec.Empty, nil
+	case ast.Alter != nil && isRename(ast.Alter):
+		command := NewOriginatingRenameCommand(e, execCtx.Schema.Name, sql, ast.Alter)
+		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.Alter != nil && ast.Alter.Source != nil && ast.Alter.Source.ResetOffsets != nil:
+		command := NewOriginatingResetOffsetsCommand(e, execCtx.Schema.Name, sql, ast.Alter.Source)
+		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.Alter != nil && ast.Alter.Source != nil:
+		command := NewOriginatingAlterSourceCommand(e, execCtx.Schema.Name, sql, ast.Alter.Source)
+		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.Alter != nil && ast.Alter.MaterializedView != nil:
+		if err := e.executeCommandWithRetry(execCtx.Ctx, func() (DDLCommand, error) {
+			// The new MV needs its own table ids, including a version table if the MV keeps prior versions of rows
+			numSequences := 3
+			mvInfo, ok := e.metaController.GetMaterializedView(execCtx.Schema.Name, strings.ToLower(ast.Alter.MaterializedView.Name))
+			if ok && mvInfo.VersionRetentionDuration != 0 {
+				numSequences = 4
+			}
+			sequences, err := e.generateTableIDSequences(numSequences)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			command, err := NewOriginatingAlterMVCommand(e, execCtx.Schema.Name, sql, sequences, ast.Alter.MaterializedView)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return command, nil
+		}); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.Drop != nil && ast.Drop.Cascade && (ast.Drop.Source || ast.Drop.MaterializedView):
+		// Nothing depends on a sink or an index, so dropping one with cascade is the same as without
+		command := NewOriginatingDropCascadeCommand(e, execCtx.Schema.Name, sql, ast.Drop)
+		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
 	case ast.Drop != nil && ast.Drop.Source:
 		command := NewOriginatingDropSourceCommand(e, execCtx.Schema.Name, sql, ast.Drop.Name)
 		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
@@ -177,6 +275,16 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 			return nil, errors.WithStack(err)
 		}
 		return exec.Empty, nil
+	case ast.Drop != nil && ast.Drop.View:
+		if ast.Drop.Cascade {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot drop view with cascade")
+		}
+		command := NewOriginatingDropViewCommand(e, execCtx.Schema.Name, sql, ast.Drop.Name)
+		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
 	case ast.Drop != nil && ast.Drop.Index:
 		command := NewOriginatingDropIndexCommand(e, execCtx.Schema.Name, sql, ast.Drop.TableName, ast.Drop.Name)
 		err = e.ddlRunner.RunCommand(execCtx.Ctx, command)
@@ -202,6 +310,36 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 			return nil, errors.WithStack(err)
 		}
 		return rows, nil
+	case ast.Show != nil && ast.Show.Sources:
+		rows, err := e.execShowSources(execCtx.Schema.Name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return rows, nil
+	case ast.Show != nil && ast.Show.MaterializedViews:
+		rows, err := e.execShowMaterializedViews(execCtx.Schema.Name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return rows, nil
+	case ast.Show != nil && ast.Show.Sinks:
+		rows, err := e.execShowSinks(execCtx.Schema.Name)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return rows, nil
+	case ast.Show != nil && ast.Show.Create != nil:
+		rows, err := e.execShowCreate(execCtx.Schema.Name, ast.Show.Create)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return rows, nil
+	case ast.Show != nil && ast.Show.Lineage:
+		rows, err := e.execShowLineage(execCtx.Schema.Name, ast.Show.LineageFor)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return rows, nil
 	case ast.Describe != "":
 		rows, err := e.execDescribe(execCtx, strings.ToLower(ast.Describe))
 		if err != nil {
@@ -213,6 +351,27 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 			return nil, errors.WithStack(err)
 		}
 		return exec.Empty, nil
+	case ast.Analyze != "":
+		tableName := strings.ToLower(ast.Analyze)
+		stats, err := e.collectTableStats(execCtx, tableName)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		command := NewOriginatingAnalyzeTableCommand(e, execCtx.Schema.Name, sql, tableName, stats)
+		if err := e.ddlRunner.RunCommand(execCtx.Ctx, command); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
+	case ast.PauseSource != "" || ast.ResumeSource != "":
+		sourceName := ast.PauseSource
+		if sourceName == "" {
+			sourceName = ast.ResumeSource
+		}
+		command := NewOriginatingPauseSourceCommand(e, execCtx.Schema.Name, sql, sourceName, ast.PauseSource != "")
+		if err := e.ddlRunner.RunCommand(execCtx.Ctx, command); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return exec.Empty, nil
 	case ast.SourceSetMaxRate != nil:
 		if err := e.execSetMaxSourceIngestRate(execCtx, ast.SourceSetMaxRate.SourceName, ast.SourceSetMaxRate.Rate); err != nil {
 			return nil, errors.WithStack(err)
@@ -222,6 +381,19 @@ func (e *Executor) ExecuteSQLStatement(execCtx *execctx.ExecutionContext, sql st
 	return nil, errors.Errorf("invalid statement %s", sql)
 }
 
+// UseSchema returns the schema to execute statements in. Unless schemas are strict, a schema which doesn't exist is
+// created, and should be deleted with DeleteSchemaIfEmpty once the statement has been executed.
+func (e *Executor) UseSchema(schemaName string) (*common.Schema, error) {
+	if !e.config.StrictSchemas {
+		return e.metaController.GetOrCreateSchema(schemaName), nil
+	}
+	schema, ok := e.metaController.GetSchema(schemaName)
+	if !ok || !e.metaController.SchemaExists(schemaName) {
+		return nil, errors.NewUnknownSchemaError(schemaName)
+	}
+	return schema, nil
+}
+
 func (e *Executor) CreateExecutionContext(ctx context.Context, schema *common.Schema) *execctx.ExecutionContext {
 	seq := atomic.AddInt64(&e.execCtxIDSequence, 1)
 	ctxID := fmt.Sprintf("%d-%d", e.cluster.GetNodeID(), seq)
@@ -330,18 +502,173 @@ func (e *Executor) execShowIndexes(schemaName string, tableName string) (exec.Pu
 		rows.AppendStringToColumn(0, indexName)
 		sb := strings.Builder{}
 		for i, index := range indexInfo.IndexCols {
-			colName := tableInfo.ColumnNames[index]
-			sb.WriteString(colName)
+			if index == -1 {
+				sb.WriteString(indexInfo.IndexExprs[i])
+			} else {
+				sb.WriteString(tableInfo.ColumnNames[index])
+			}
+			if common.IsDesc(indexInfo.IndexColsDesc, i) {
+				sb.WriteString(" desc")
+			}
 			if i != len(indexInfo.IndexCols)-1 {
 				sb.WriteString(", ")
 			}
 		}
+		if len(indexInfo.IncludeCols) > 0 {
+			sb.WriteString(" include (")
+			for i, index := range indexInfo.IncludeCols {
+				sb.WriteString(tableInfo.ColumnNames[index])
+				if i != len(indexInfo.IncludeCols)-1 {
+					sb.WriteString(", ")
+				}
+			}
+			sb.WriteString(")")
+		}
+		if indexInfo.Predicate != "" {
+			sb.WriteString(" where ")
+			sb.WriteString(indexInfo.Predicate)
+		}
 		rows.AppendStringToColumn(1, sb.String())
 	}
 	staticRows, err := exec.NewStaticRows([]string{fmt.Sprintf("indexes_on_%s", tableName), "columns"}, rows)
 	return staticRows, errors.WithStack(err)
 }
 
+// showTableNames returns the names of the tables of the given kind in the schema, in order
+func (e *Executor) showTableNames(schemaName string, kind string) ([]string, error) {
+	rows, err := e.pullEngine.ExecuteQuery("sys", fmt.Sprintf("select name from tables where schema_name='%s' and kind='%s' order by name", schemaName, kind))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	names := make([]string, rows.RowCount())
+	for i := 0; i < rows.RowCount(); i++ {
+		row := rows.GetRow(i)
+		names[i] = row.GetString(0)
+	}
+	return names, nil
+}
+
+func (e *Executor) execShowSources(schemaName string) (exec.PullExecutor, error) {
+	names, err := e.showTableNames(schemaName, meta.TableKindSource)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rowsFactory := common.NewRowsFactory(
+		[]common.ColumnType{common.VarcharColumnType, common.VarcharColumnType, common.VarcharColumnType},
+	)
+	rows := rowsFactory.NewRows(len(names))
+	for _, name := range names {
+		sourceInfo, ok := e.metaController.GetSource(schemaName, name)
+		if !ok {
+			// Dropped since the names were fetched
+			continue
+		}
+		src, err := e.pushEngine.GetSource(sourceInfo.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rows.AppendStringToColumn(0, name)
+		rows.AppendStringToColumn(1, sourceInfo.OriginInfo.TopicsString())
+		rows.AppendStringToColumn(2, push.SourceStatus(src))
+	}
+	staticRows, err := exec.NewStaticRows([]string{fmt.Sprintf("sources_in_%s", schemaName), "topic", "status"}, rows)
+	return staticRows, errors.WithStack(err)
+}
+
+func (e *Executor) execShowMaterializedViews(schemaName string) (exec.PullExecutor, error) {
+	names, err := e.showTableNames(schemaName, meta.TableKindMaterializedView)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rowsFactory := common.NewRowsFactory(
+		[]common.ColumnType{common.VarcharColumnType, common.VarcharColumnType, common.VarcharColumnType},
+	)
+	rows := rowsFactory.NewRows(len(names))
+	for _, name := range names {
+		mvInfo, ok := e.metaController.GetMaterializedView(schemaName, name)
+		if !ok {
+			continue
+		}
+		mv, err := e.pushEngine.GetMaterializedView(mvInfo.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		running, err := mv.IsRunning()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rows.AppendStringToColumn(0, name)
+		rows.AppendStringToColumn(1, strings.TrimSpace(mvInfo.Query))
+		rows.AppendStringToColumn(2, push.RunningStatus(running))
+	}
+	staticRows, err := exec.NewStaticRows([]string{fmt.Sprintf("materialized_views_in_%s", schemaName), "query", "status"}, rows)
+	return staticRows, errors.WithStack(err)
+}
+
+func (e *Executor) execShowSinks(schemaName string) (exec.PullExecutor, error) {
+	names, err := e.showTableNames(schemaName, meta.TableKindSink)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rowsFactory := common.NewRowsFactory(
+		[]common.ColumnType{common.VarcharColumnType, common.VarcharColumnType, common.VarcharColumnType},
+	)
+	rows := rowsFactory.NewRows(len(names))
+	for _, name := range names {
+		sinkInfo, ok := e.metaController.GetSink(schemaName, name)
+		if !ok {
+			continue
+		}
+		sink, err := e.pushEngine.GetSink(sinkInfo.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rows.AppendStringToColumn(0, name)
+		rows.AppendStringToColumn(1, sinkInfo.TargetInfo.TopicName)
+		rows.AppendStringToColumn(2, push.RunningStatus(sink.IsRunning()))
+	}
+	staticRows, err := exec.NewStaticRows([]string{fmt.Sprintf("sinks_in_%s", schemaName), "topic", "status"}, rows)
+	return staticRows, errors.WithStack(err)
+}
+
+func (e *Executor) execShowCreate(schemaName string, show *parser.ShowCreate) (exec.PullExecutor, error) {
+	name := strings.ToLower(show.Name)
+	var createSQL string
+	switch {
+	case show.Source:
+		sourceInfo, ok := e.metaController.GetSource(schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownSourceError(schemaName, name)
+		}
+		createSQL = showCreateSource(sourceInfo)
+	case show.MaterializedView:
+		mvInfo, ok := e.metaController.GetMaterializedView(schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownMaterializedViewError(schemaName, name)
+		}
+		createSQL = showCreateMaterializedView(mvInfo)
+	case show.View:
+		viewInfo, ok := e.metaController.GetView(schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownViewError(schemaName, name)
+		}
+		createSQL = showCreateView(viewInfo)
+	default:
+		sinkInfo, ok := e.metaController.GetSink(schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownSinkError(schemaName, name)
+		}
+		createSQL = showCreateSink(sinkInfo)
+	}
+	rowsFactory := common.NewRowsFactory(
+		[]common.ColumnType{common.VarcharColumnType},
+	)
+	rows := rowsFactory.NewRows(1)
+	rows.AppendStringToColumn(0, createSQL)
+	staticRows, err := exec.NewStaticRows([]string{"create_statement"}, rows)
+	return staticRows, errors.WithStack(err)
+}
+
 var describeRowsFactory = common.NewRowsFactory(
 	[]common.ColumnType{
 		{Type: common.TypeVarchar}, // field
@@ -388,6 +715,8 @@ func (e *Executor) execDescribe(execCtx *execctx.ExecutionContext, tableName str
 		tableInfo = meta.DecodeSourceInfoRow(&tableRow).TableInfo
 	case meta.TableKindMaterializedView:
 		tableInfo = meta.DecodeMaterializedViewInfoRow(&tableRow).TableInfo
+	case meta.TableKindView:
+		tableInfo = meta.DecodeViewInfoRow(&tableRow).TableInfo
 	case meta.TableKindInternal:
 		// NB: This case is for completness as sys.table doesn't know about internal tables.
 		tableInfo = meta.DecodeInternalTableInfoRow(&tableRow).TableInfo
@@ -415,15 +744,23 @@ func (e *Executor) FailureInjector() failinject.Injector {
 }
 
 func storeToDeleteBatch(tableID uint64, clust cluster.Cluster) (*cluster.ToDeleteBatch, error) {
+	return storeToDeleteBatchForTables(tableID, []uint64{tableID}, clust)
+}
+
+// storeToDeleteBatchForTables is like storeToDeleteBatch but deletes the data of other tables which belong to the table
+// too
+func storeToDeleteBatchForTables(conditionalTableID uint64, tableIDs []uint64, clust cluster.Cluster) (*cluster.ToDeleteBatch, error) {
 	// We record prefixes in the to_delete table - this makes sure data is deleted on restart if failure occurs
 	// after this
 	var prefixes [][]byte
-	for _, shardID := range clust.GetAllShardIDs() {
-		prefix := table.EncodeTableKeyPrefix(tableID, shardID, 16)
-		prefixes = append(prefixes, prefix)
+	for _, tableID := range tableIDs {
+		for _, shardID := range clust.GetAllShardIDs() {
+			prefix := table.EncodeTableKeyPrefix(tableID, shardID, 16)
+			prefixes = append(prefixes, prefix)
+		}
 	}
 	batch := &cluster.ToDeleteBatch{
-		ConditionalTableID: tableID,
+		ConditionalTableID: conditionalTableID,
 		Prefixes:           prefixes,
 	}
 	if err := clust.AddToDeleteBatch(batch); err != nil {
@@ -206,21 +206,72 @@ func (c *CreateIndexCommand) getIndexInfo(ast *parser.CreateIndex) (*common.Inde
 	for colIndex, colName := range tabInfo.ColumnNames {
 		colMap[colName] = colIndex
 	}
-	indexCols := make([]int, len(ast.ColumnNames))
-	indexColMap := make(map[int]struct{}, len(ast.ColumnNames))
-	for i, colName := range ast.ColumnNames {
+	indexCols := make([]int, len(ast.Columns))
+	indexColMap := make(map[int]struct{}, len(ast.Columns))
+	var indexExprs []string
+	var indexColsDesc []bool
+	for i, col := range ast.Columns {
+		if col.Desc {
+			if indexColsDesc == nil {
+				indexColsDesc = make([]bool, len(ast.Columns))
+			}
+			indexColsDesc[i] = true
+		}
+		if col.Expr != nil {
+			if indexExprs == nil {
+				indexExprs = make([]string, len(ast.Columns))
+			}
+			indexExprs[i] = col.Expr.String()
+			indexCols[i] = -1
+			continue
+		}
+		colIndex, ok := colMap[col.Name]
+		if !ok {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Unknown column %s in %s.%s",
+				col.Name, c.SchemaName(), ast.TableName)
+		}
+		if _, ok := indexColMap[colIndex]; ok {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Index cannot contain same column multiple times")
+		}
+		indexCols[i] = colIndex
+		indexColMap[colIndex] = struct{}{}
+	}
+	var includeCols []int
+	for _, colName := range ast.IncludeColumnNames {
 		colIndex, ok := colMap[colName.Name]
 		if !ok {
 			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Unknown column %s in %s.%s",
 				colName.Name, c.SchemaName(), ast.TableName)
 		}
-		indexCols[i] = colIndex
+		if _, ok := indexColMap[colIndex]; ok {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Index cannot contain same column multiple times")
+		}
+		if tabInfo.IsPrimaryKeyCol(colIndex) {
+			// The PK is always stored in the index
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Primary key column %s cannot be included in index",
+				colName.Name)
+		}
+		includeCols = append(includeCols, colIndex)
 		indexColMap[colIndex] = struct{}{}
 	}
-	if len(indexColMap) != len(ast.ColumnNames) {
-		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Index cannot contain same column multiple times")
+	indexInfo := common.NewIndexInfo(c.SchemaName(), c.tableSequences[0], ast.TableName, ast.Name, indexCols, includeCols)
+	indexInfo.IndexColsDesc = indexColsDesc
+	if ast.Where != nil {
+		indexInfo.Predicate = strings.TrimSpace(ast.Where.String())
+	}
+	if indexExprs == nil && indexInfo.Predicate == "" {
+		return indexInfo, nil
+	}
+	// We compile the expressions now so any errors in them are reported when the index is created
+	_, keyTypes, _, err := c.pl.BuildIndexExpressions(ast.TableName, indexExprs, indexInfo.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	if indexExprs != nil {
+		indexInfo.IndexExprs = indexExprs
+		indexInfo.IndexExprTypes = keyTypes
 	}
-	return common.NewIndexInfo(c.SchemaName(), c.tableSequences[0], ast.TableName, ast.Name, indexCols), nil
+	return indexInfo, nil
 }
 
 func (c *CreateIndexCommand) GetExtraData() []byte {
@@ -12,6 +12,7 @@ import (
 	"github.com/squareup/pranadb/push"
 	"strings"
 	"sync"
+	"time"
 )
 
 type CreateMVCommand struct {
@@ -146,7 +147,11 @@ func (c *CreateMVCommand) onPhase0() error {
 	// We store rows in the to_delete table - if MV creation fails (e.g. node crashes) then on restart the MV state will
 	// be cleaned up - we have to add a prefix for each shard as the shard id comes first in the key
 	var err error
-	c.toDeleteBatch, err = storeToDeleteBatch(c.mv.Info.ID, c.e.cluster)
+	tableIDs := []uint64{c.mv.Info.ID}
+	if c.mv.Info.VersionRetentionDuration != 0 {
+		tableIDs = append(tableIDs, c.mv.Info.VersionTableID)
+	}
+	c.toDeleteBatch, err = storeToDeleteBatchForTables(c.mv.Info.ID, tableIDs, c.e.cluster)
 	if err != nil {
 		return err
 	}
@@ -259,18 +264,42 @@ func (c *CreateMVCommand) Cleanup() {
 func (c *CreateMVCommand) createMVFromAST(ast *parser.CreateMaterializedView) (*push.MaterializedView, error) {
 	mvName := strings.ToLower(ast.Name)
 	querySQL := ast.Query.String()
-	seqGenerator := common.NewPreallocSeqGen(c.tableSequences)
+	var initTable, sVersionRetentionTime string
+	for _, info := range ast.OriginInformation {
+		if info.InitialState != "" && initTable == "" {
+			initTable = info.InitialState
+		}
+		if info.VersionRetentionTime != "" {
+			sVersionRetentionTime = info.VersionRetentionTime
+		}
+	}
+	sequences := c.tableSequences
+	var versionRetentionTime time.Duration
+	var versionTableID uint64
+	if sVersionRetentionTime != "" {
+		var err error
+		versionRetentionTime, err = parseVersionRetentionTime(sVersionRetentionTime)
+		if err != nil {
+			return nil, err
+		}
+		// The version table takes the last sequence, so the internal tables get the same ids when the MV is loaded
+		versionTableID = sequences[len(sequences)-1]
+		sequences = sequences[:len(sequences)-1]
+	}
+	seqGenerator := common.NewPreallocSeqGen(sequences)
 	tableID := seqGenerator.GenerateSequence()
-	var initTable string
-	if ast.OriginInformation != nil {
-		for _, info := range ast.OriginInformation {
-			if info.InitialState != "" {
-				initTable = info.InitialState
-				break
-			}
+	return push.CreateMaterializedView(c.e.pushEngine, c.pl, c.schema, mvName, querySQL, initTable, tableID,
+		versionRetentionTime, versionTableID, seqGenerator)
+}
+
+// mvKeepsVersions returns true if the materialized view keeps prior versions of its rows
+func mvKeepsVersions(ast *parser.CreateMaterializedView) bool {
+	for _, info := range ast.OriginInformation {
+		if info.VersionRetentionTime != "" {
+			return true
 		}
 	}
-	return push.CreateMaterializedView(c.e.pushEngine, c.pl, c.schema, mvName, querySQL, initTable, tableID, seqGenerator)
+	return false
 }
 
 func (c *CreateMVCommand) createMV() (*push.MaterializedView, error) {
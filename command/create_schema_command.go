@@ -0,0 +1,95 @@
+package command
+
+import (
+	"sync"
+
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+)
+
+// CreateSchemaCommand creates a schema. Unlike a schema which is used without being created, it's persisted and
+// remains when it has no tables, until it's dropped.
+type CreateSchemaCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	persisted  bool
+}
+
+func (c *CreateSchemaCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeCreateSchema
+}
+
+func (c *CreateSchemaCommand) SchemaName() string {
+	return c.schemaName
+}
+
+func (c *CreateSchemaCommand) SQL() string {
+	return c.sql
+}
+
+func (c *CreateSchemaCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (c *CreateSchemaCommand) Cancel() {
+}
+
+// NewCreateSchemaCommand creates the command, the name of the schema to create is the schema the command runs in
+func NewCreateSchemaCommand(e *Executor, schemaName string, sql string) *CreateSchemaCommand {
+	return &CreateSchemaCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (c *CreateSchemaCommand) Before() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.schemaName == meta.SystemSchemaName || c.e.metaController.SchemaExists(c.schemaName) {
+		return errors.NewSchemaAlreadyExistsError(c.schemaName)
+	}
+	if err := c.e.metaController.PersistSchema(c.schemaName); err != nil {
+		return err
+	}
+	c.persisted = true
+	return nil
+}
+
+func (c *CreateSchemaCommand) OnPhase(phase int32) error {
+	if phase == 0 {
+		c.e.metaController.RegisterSchema(c.schemaName)
+		return nil
+	}
+	panic("invalid phase")
+}
+
+func (c *CreateSchemaCommand) NumPhases() int {
+	return 1
+}
+
+func (c *CreateSchemaCommand) AfterPhase(phase int32) error {
+	return nil
+}
+
+func (c *CreateSchemaCommand) Cleanup() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if schema, ok := c.e.metaController.GetSchema(c.schemaName); ok && schema.LenTables() == 0 {
+		if err := c.e.metaController.UnregisterSchema(c.schemaName); err != nil {
+			// Ignore
+		}
+	}
+	if c.persisted {
+		if err := c.e.metaController.DeleteSchemaAndTables(c.schemaName, nil, nil); err != nil {
+			// Ignore
+		}
+	}
+}
+
+func (c *CreateSchemaCommand) GetExtraData() []byte {
+	return nil
+}
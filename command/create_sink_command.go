@@ -10,6 +10,8 @@ import (
 	"github.com/squareup/pranadb/meta"
 	"github.com/squareup/pranadb/parplan"
 	"github.com/squareup/pranadb/push"
+	"github.com/squareup/pranadb/schemaregistry"
+	"google.golang.org/protobuf/reflect/protoreflect"
 	"strings"
 	"sync"
 	"time"
@@ -196,6 +198,9 @@ func (c *CreateSinkCommand) createSinkFromAST(ast *parser.CreateSink) (*push.Sin
 			injectors = make([]selector.ColumnSelector, len(cs))
 			for i := 0; i < len(cs); i++ {
 				injectors[i] = cs[i].ToSelector()
+				if injectors[i].Explodes() {
+					return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Injectors cannot use [*]")
+				}
 			}
 		case opt.BrokerName != "":
 			brokerName = opt.BrokerName
@@ -218,6 +223,25 @@ func (c *CreateSinkCommand) createSinkFromAST(ast *parser.CreateSink) (*push.Sin
 	if valueEncoding == common.KafkaEncodingUnknown {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "valueEncoding is required")
 	}
+	for _, enc := range []common.KafkaEncoding{headerEncoding, keyEncoding, valueEncoding} {
+		if enc.Encoding.IsChangeEvent() {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Encoding %s can only be used by sources", enc)
+		}
+		if enc.Encoding == common.EncodingAvro {
+			if enc.SchemaName == "" {
+				return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Encoding avro must specify the subject to encode with, as avro:<subject>")
+			}
+			schema, err := c.e.schemaRegistry.GetLatestSchema(enc.SchemaName)
+			if err != nil || schema.Type != schemaregistry.SchemaTypeAvro {
+				return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Avro subject %q not found in the schema registry", enc.SchemaName)
+			}
+		}
+		if enc.Encoding == common.EncodingProtobufConfluent {
+			if err := c.validateProtobufConfluentEncoding(enc); err != nil {
+				return nil, err
+			}
+		}
+	}
 	if brokerName == "" {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "brokerName is required")
 	}
@@ -259,6 +283,29 @@ func (c *CreateSinkCommand) createSinkFromAST(ast *parser.CreateSink) (*push.Sin
 	return push.CreateSink(c.e.pushEngine, c.pl, c.schema, sinkName, querySQL, tableID, seqGenerator, originInfo)
 }
 
+// validateProtobufConfluentEncoding checks that the schema registry has a subject named after the message, whose schema
+// id messages are written with, and that the message is registered with Prana or defined by the subject's schema
+func (c *CreateSinkCommand) validateProtobufConfluentEncoding(enc common.KafkaEncoding) error {
+	if enc.SchemaName == "" {
+		return errors.NewPranaErrorf(errors.InvalidStatement, "Encoding protobuf-confluent must specify the message, as protobuf-confluent:<name>")
+	}
+	schema, err := c.e.schemaRegistry.GetLatestSchema(enc.SchemaName)
+	if err != nil || schema.Type != schemaregistry.SchemaTypeProtobuf {
+		return errors.NewPranaErrorf(errors.InvalidStatement, "Protobuf subject %q not found in the schema registry", enc.SchemaName)
+	}
+	if _, err := c.e.protoRegistry.FindDescriptorByName(protoreflect.FullName(enc.SchemaName)); err == nil {
+		return nil
+	}
+	fd, err := schema.ProtobufFile(c.e.protoRegistry)
+	if err == nil {
+		_, err = schemaregistry.FindMessage(fd, protoreflect.FullName(enc.SchemaName))
+	}
+	if err != nil {
+		return errors.NewPranaErrorf(errors.InvalidStatement, "Proto message %q not found in schema %d: %v", enc.SchemaName, schema.ID, err)
+	}
+	return nil
+}
+
 func parseEmitAfter(sEmitAfter string) (time.Duration, error) {
 	sr := strings.Trim(sEmitAfter, " \t")
 	var dur time.Duration
@@ -2,6 +2,7 @@ package command
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -17,6 +18,7 @@ import (
 	"github.com/squareup/pranadb/interruptor"
 	"github.com/squareup/pranadb/meta"
 	"github.com/squareup/pranadb/push/source"
+	"github.com/squareup/pranadb/schemaregistry"
 	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
@@ -120,27 +122,87 @@ func (c *CreateSourceCommand) validate() error {
 	}
 
 	for _, enc := range []common.KafkaEncoding{origInfo.HeaderEncoding, origInfo.KeyEncoding, origInfo.ValueEncoding} {
-		if enc.Encoding != common.EncodingProtobuf {
+		// The schema of a change event encoding is the schema of its change events
+		switch rowEncoding := enc.Encoding.RowEncoding(); {
+		case rowEncoding == common.EncodingProtobuf:
+			_, err := c.e.protoRegistry.FindDescriptorByName(protoreflect.FullName(enc.SchemaName))
+			if err != nil {
+				return errors.NewPranaErrorf(errors.InvalidStatement, "Proto message %q not registered", enc.SchemaName)
+			}
+		case rowEncoding == common.EncodingAvro && enc.SchemaName != "":
+			schema, err := c.e.schemaRegistry.GetLatestSchema(enc.SchemaName)
+			if err != nil || schema.Type != schemaregistry.SchemaTypeAvro {
+				return errors.NewPranaErrorf(errors.InvalidStatement, "Avro subject %q not found in the schema registry", enc.SchemaName)
+			}
+		case rowEncoding == common.EncodingProtobufConfluent && enc.SchemaName == "":
+			return errors.NewPranaErrorf(errors.InvalidStatement, "Encoding %s must specify the message, as %s:<name>", enc.Encoding, enc.Encoding)
+		}
+	}
+
+	for i, sel := range origInfo.ColSelectors {
+		if origInfo.IsComputed(i) || c.sourceInfo.IsAppendKey(i) {
 			continue
 		}
-		_, err := c.e.protoRegistry.FindDescriptorByName(protoreflect.FullName(enc.SchemaName))
-		if err != nil {
-			return errors.NewPranaErrorf(errors.InvalidStatement, "Proto message %q not registered", enc.SchemaName)
+		if err := validateColumnSelector(sel); err != nil {
+			return err
+		}
+	}
+	return validateExplodedArray(origInfo.ColSelectors)
+}
+
+func validateColumnSelector(sel selector.ColumnSelector) error {
+	if sel.MetaKey == nil && len(sel.Selector) == 0 {
+		return errors.NewPranaErrorf(errors.InvalidStatement, "Invalid column selector %q", sel)
+	}
+	if sel.NumExplodes() > 1 {
+		return errors.NewPranaErrorf(errors.InvalidStatement, "Invalid column selector %q. [*] can only be used once", sel)
+	}
+	if sel.MetaKey != nil {
+		f := *sel.MetaKey
+		if !(f == "header" || f == "key" || f == "timestamp" || f == "topic" || f == "partition" || f == "offset") {
+			return errors.NewPranaErrorf(errors.InvalidStatement, `Invalid metadata key in column selector %q. Valid values are "header", "key", "timestamp", "topic", "partition", "offset".`, sel)
 		}
 	}
+	return nil
+}
 
-	for _, sel := range origInfo.ColSelectors {
-		if sel.MetaKey == nil && len(sel.Selector) == 0 {
-			return errors.NewPranaErrorf(errors.InvalidStatement, "Invalid column selector %q", sel)
+// validateExplodedArray checks that the column selectors which explode an array with [*] all explode the same one, as
+// a message is parsed into a row for each of its elements
+func validateExplodedArray(selectors []selector.ColumnSelector) error {
+	var explodedArray string
+	for _, sel := range selectors {
+		if !sel.Explodes() {
+			continue
 		}
-		if sel.MetaKey != nil {
-			f := *sel.MetaKey
-			if !(f == "header" || f == "key" || f == "timestamp") {
-				return errors.NewPranaErrorf(errors.InvalidStatement, `Invalid metadata key in column selector %q. Valid values are "header", "key", "timestamp".`, sel)
-			}
+		arr := formatDDLSelector(sel.ExplodedArray())
+		if explodedArray == "" {
+			explodedArray = arr
+		} else if arr != explodedArray {
+			return errors.NewPranaErrorf(errors.InvalidStatement,
+				"Column selectors can only explode one array with [*], but both %q and %q are exploded", explodedArray, arr)
 		}
 	}
+	return nil
+}
 
+// validateTopicNames checks the topics a source consumes from. A topic name starting with ^ is a regular expression
+// matching the names of the topics
+func validateTopicNames(topicNames []string) error {
+	names := make(map[string]struct{}, len(topicNames))
+	for _, topicName := range topicNames {
+		if topicName == "" {
+			return errors.NewPranaErrorf(errors.InvalidStatement, "Topic name cannot be empty")
+		}
+		if _, ok := names[topicName]; ok {
+			return errors.NewPranaErrorf(errors.InvalidStatement, "Duplicate topic name %s", topicName)
+		}
+		names[topicName] = struct{}{}
+		if common.IsTopicPattern(topicName) {
+			if _, err := regexp.Compile(topicName); err != nil {
+				return errors.NewPranaErrorf(errors.InvalidStatement, "Invalid topic pattern %s: %v", topicName, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -252,10 +314,13 @@ func (c *CreateSourceCommand) GetExtraData() []byte {
 func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.SourceInfo, error) {
 	ast.Name = strings.ToLower(ast.Name)
 	var (
-		colNames []string
-		colTypes []common.ColumnType
-		colIndex = map[string]int{}
-		pkCols   []int
+		colNames    []string
+		colTypes    []common.ColumnType
+		colIndex    = map[string]int{}
+		pkCols      []int
+		pkDesc      []bool
+		computed    []string
+		numComputed int
 	)
 	for i, option := range ast.Options {
 		switch {
@@ -270,13 +335,24 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 				return nil, errors.WithStack(err)
 			}
 			colTypes = append(colTypes, colType)
+			var expr string
+			if col.Computed != nil {
+				expr = col.Computed.String()
+				numComputed++
+			}
+			computed = append(computed, expr)
 		case len(option.PrimaryKey) > 0:
 			for _, pk := range option.PrimaryKey {
-				index, ok := colIndex[strings.ToLower(pk)]
+				index, ok := colIndex[strings.ToLower(pk.Name)]
 				if !ok {
-					return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid primary key column %q", option.PrimaryKey)
+					pkNames := make([]string, len(option.PrimaryKey))
+					for i, pk := range option.PrimaryKey {
+						pkNames[i] = pk.Name
+					}
+					return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid primary key column %q", pkNames)
 				}
 				pkCols = append(pkCols, index)
+				pkDesc = append(pkDesc, pk.Desc)
 			}
 		default:
 			panic(repr.String(option))
@@ -288,11 +364,15 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 		ingestFilter                               string
 		propsMap                                   map[string]string
 		colSelectors                               []selector.ColumnSelector
-		brokerName, topicName                      string
+		brokerName                                 string
+		topicNames                                 []string
 		initialiseFrom                             string
 		transient                                  bool
 		startWithFirstMV                           bool
+		appendOnly                                 bool
+		errorPolicy, deadLetterTopic               string
 		sRetentionTime                             string
+		sVersionRetentionTime                      string
 	)
 	for _, opt := range ast.OriginInformation {
 		switch {
@@ -326,12 +406,18 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 			}
 		case opt.BrokerName != "":
 			brokerName = opt.BrokerName
-		case opt.TopicName != "":
-			topicName = opt.TopicName
+		case opt.TopicName != nil:
+			topicNames = opt.TopicName
 		case opt.InitialState != "":
 			initialiseFrom = opt.InitialState
+		case opt.ErrorPolicy != "":
+			errorPolicy = strings.ToLower(opt.ErrorPolicy)
+		case opt.DeadLetterTopic != "":
+			deadLetterTopic = opt.DeadLetterTopic
 		case opt.RetentionTime != "":
 			sRetentionTime = opt.RetentionTime
+		case opt.VersionRetentionTime != "":
+			sVersionRetentionTime = opt.VersionRetentionTime
 		}
 		if opt.Transient != nil && *opt.Transient {
 			transient = true
@@ -339,6 +425,9 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 		if opt.StartWithFirstMV != nil && *opt.StartWithFirstMV {
 			startWithFirstMV = true
 		}
+		if opt.AppendOnly != nil && *opt.AppendOnly {
+			appendOnly = true
+		}
 	}
 	if headerEncoding == common.KafkaEncodingUnknown {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "headerEncoding is required")
@@ -349,16 +438,79 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 	if valueEncoding == common.KafkaEncodingUnknown {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "valueEncoding is required")
 	}
+	if headerEncoding.Encoding.IsChangeEvent() || keyEncoding.Encoding.IsChangeEvent() {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Change event encodings can only be used for valueEncoding")
+	}
+	if valueEncoding.Encoding.IsChangeEvent() {
+		// Change events delete rows by their key, so the source must store its rows and have a key of its own
+		if transient {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot use valueEncoding %s for a Transient source", valueEncoding)
+		}
+		if appendOnly {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot use valueEncoding %s for an AppendOnly source", valueEncoding)
+		}
+	}
 	if brokerName == "" {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "brokerName is required")
 	}
-	if topicName == "" {
+	if len(topicNames) == 0 {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "topicName is required")
 	}
-	if len(colSelectors) != len(colTypes) {
+	if err := validateTopicNames(topicNames); err != nil {
+		return nil, err
+	}
+	if numComputed == len(colTypes) {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "At least one column must not be computed")
+	}
+	if len(colSelectors) != len(colTypes)-numComputed {
+		if numComputed > 0 {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Number of column selectors (%d) must match number of columns which are not computed (%d)",
+				len(colSelectors), len(colTypes)-numComputed)
+		}
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement,
 			"Number of column selectors (%d) must match number of columns (%d)", len(colSelectors), len(colTypes))
 	}
+	if numComputed > 0 {
+		// Computed columns have no selector, so the selectors are spread over the columns which aren't computed
+		selected := colSelectors
+		colSelectors = make([]selector.ColumnSelector, len(colTypes))
+		for i, expr := range computed {
+			if expr == "" {
+				colSelectors[i] = selected[0]
+				selected = selected[1:]
+			}
+		}
+	} else {
+		computed = nil
+	}
+	var colsVisible []bool
+	if appendOnly {
+		if len(pkCols) != 0 {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot specify a primary key for an AppendOnly source. Its primary key is generated")
+		}
+		if _, ok := colIndex[common.AppendKeyColumnName]; ok {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Column name %s is reserved for AppendOnly sources",
+				common.AppendKeyColumnName)
+		}
+		// The generated key is a hidden column after the others, with no selector
+		colsVisible = make([]bool, len(colNames), len(colNames)+1)
+		for i := range colsVisible {
+			colsVisible[i] = true
+		}
+		colsVisible = append(colsVisible, false)
+		pkCols = []int{len(colNames)}
+		colIndex[common.AppendKeyColumnName] = len(colNames)
+		colNames = append(colNames, common.AppendKeyColumnName)
+		colTypes = append(colTypes, common.BigIntColumnType)
+		colSelectors = append(colSelectors, selector.ColumnSelector{})
+		if computed != nil {
+			computed = append(computed, "")
+		}
+	}
 	if len(pkCols) == 0 {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Primary key is required")
 	}
@@ -368,10 +520,24 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 	if initialiseFrom != "" && transient {
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot specify InitialState for a Transient source")
 	}
+	if initialiseFrom != "" && appendOnly {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot specify InitialState for an AppendOnly source")
+	}
+	switch errorPolicy {
+	case "", common.ErrorPolicyFail, common.ErrorPolicySkip, common.ErrorPolicyDeadLetter:
+	default:
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+			"Unknown ErrorPolicy %s - must be one of %s, %s or %s", errorPolicy, common.ErrorPolicyFail,
+			common.ErrorPolicySkip, common.ErrorPolicyDeadLetter)
+	}
+	if deadLetterTopic != "" && errorPolicy != common.ErrorPolicyDeadLetter {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement,
+			"Cannot specify DeadLetterTopic unless ErrorPolicy is %s", common.ErrorPolicyDeadLetter)
+	}
 	var retentionTime time.Duration
 	if sRetentionTime != "" {
 		var err error
-		retentionTime, err = parseRetentionTime(sRetentionTime)
+		retentionTime, err = parseRetentionTime("RetentionTime", sRetentionTime)
 		if err != nil {
 			return nil, err
 		}
@@ -379,6 +545,17 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "RetentionTime must be > 0")
 		}
 	}
+	var versionRetentionTime time.Duration
+	if sVersionRetentionTime != "" {
+		if transient {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot specify VersionRetentionTime for a Transient source")
+		}
+		var err error
+		versionRetentionTime, err = parseVersionRetentionTime(sVersionRetentionTime)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	pkMap := make(map[int]struct{}, len(pkCols))
 	for _, pkCol := range pkCols {
@@ -388,21 +565,30 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Primary key cannot contain same column multiple times")
 	}
 
+	var topicName string
+	if len(topicNames) == 1 && !common.IsTopicPattern(topicNames[0]) {
+		topicName = topicNames[0]
+		topicNames = nil
+	}
 	originInfo := &common.SourceOriginInfo{
 		BrokerName:       brokerName,
 		TopicName:        topicName,
+		TopicNames:       topicNames,
 		HeaderEncoding:   headerEncoding,
 		KeyEncoding:      keyEncoding,
 		ValueEncoding:    valueEncoding,
 		IngestFilter:     ingestFilter,
 		ColSelectors:     colSelectors,
+		ComputedColumns:  computed,
 		Properties:       propsMap,
 		InitialState:     initialiseFrom,
 		ConsumerGroupID:  c.consumerGroupID,
 		Transient:        transient,
 		StartWithFirstMV: startWithFirstMV,
+		AppendOnly:       appendOnly,
+		ErrorPolicy:      errorPolicy,
+		DeadLetterTopic:  deadLetterTopic,
 	}
-	var colsVisible []bool
 	var lastUpdateIndexID uint64
 	if retentionTime == 0 {
 		lastUpdateIndexID = 0
@@ -412,13 +598,46 @@ func (c *CreateSourceCommand) getSourceInfo(ast *parser.CreateSource) (*common.S
 	tableInfo := common.NewTableInfo(c.tableSequences[0], c.schemaName, ast.Name, pkCols, colNames, colTypes,
 		retentionTime, lastUpdateIndexID)
 	tableInfo.ColsVisible = colsVisible
+	if versionRetentionTime != 0 {
+		tableInfo.VersionRetentionDuration = versionRetentionTime
+		tableInfo.VersionTableID = c.tableSequences[2]
+	}
+	for _, desc := range pkDesc {
+		if desc {
+			tableInfo.PrimaryKeyDesc = pkDesc
+			break
+		}
+	}
 	return &common.SourceInfo{
 		TableInfo:  tableInfo,
 		OriginInfo: originInfo,
 	}, nil
 }
 
-func parseRetentionTime(retentionTime string) (time.Duration, error) {
+// sourceKeepsVersions returns true if the source keeps prior versions of its rows
+func sourceKeepsVersions(ast *parser.CreateSource) bool {
+	for _, opt := range ast.OriginInformation {
+		if opt.VersionRetentionTime != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseVersionRetentionTime parses the VersionRetentionTime option of a source or materialized view, which is how long
+// prior versions of rows are kept for so the table can be queried as of a time in the past.
+func parseVersionRetentionTime(versionRetentionTime string) (time.Duration, error) {
+	dur, err := parseRetentionTime("VersionRetentionTime", versionRetentionTime)
+	if err != nil {
+		return 0, err
+	}
+	if dur < time.Second {
+		return 0, errors.NewPranaErrorf(errors.InvalidStatement, "VersionRetentionTime must be at least 1s")
+	}
+	return dur, nil
+}
+
+func parseRetentionTime(optionName string, retentionTime string) (time.Duration, error) {
 	sr := strings.Trim(retentionTime, " \t")
 	var dur time.Duration
 	l := len(sr)
@@ -434,8 +653,8 @@ func parseRetentionTime(retentionTime string) (time.Duration, error) {
 		}
 	}
 	if dur <= 0 {
-		return 0, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid RetentionTime %s. Must be an integer > 0 "+
-			"followed by a unit. Valid units are \"d\", \"s\", \"m\", \"h\"", retentionTime)
+		return 0, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid %s %s. Must be an integer > 0 "+
+			"followed by a unit. Valid units are \"d\", \"s\", \"m\", \"h\"", optionName, retentionTime)
 	}
 	return dur, nil
 }
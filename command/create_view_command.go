@@ -0,0 +1,191 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/parplan"
+)
+
+type CreateViewCommand struct {
+	lock           sync.Mutex
+	e              *Executor
+	pl             *parplan.Planner
+	schema         *common.Schema
+	createViewSQL  string
+	tableSequences []uint64
+	viewInfo       *common.ViewInfo
+	ast            *parser.CreateView
+	persisted      bool
+}
+
+func (c *CreateViewCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeCreateView
+}
+
+func (c *CreateViewCommand) SchemaName() string {
+	return c.schema.Name
+}
+
+func (c *CreateViewCommand) SQL() string {
+	return c.createViewSQL
+}
+
+func (c *CreateViewCommand) TableSequences() []uint64 {
+	return c.tableSequences
+}
+
+func (c *CreateViewCommand) Cancel() {
+}
+
+func NewOriginatingCreateViewCommand(e *Executor, pl *parplan.Planner, schema *common.Schema, sql string,
+	tableSequences []uint64, ast *parser.CreateView) *CreateViewCommand {
+	return &CreateViewCommand{
+		e:              e,
+		schema:         schema,
+		pl:             pl,
+		ast:            ast,
+		createViewSQL:  sql,
+		tableSequences: tableSequences,
+	}
+}
+
+func NewCreateViewCommand(e *Executor, schemaName string, createViewSQL string, tableSequences []uint64) *CreateViewCommand {
+	schema := e.metaController.GetOrCreateSchema(schemaName)
+	pl := parplan.NewPlanner(schema)
+	return &CreateViewCommand{
+		e:              e,
+		schema:         schema,
+		pl:             pl,
+		createViewSQL:  createViewSQL,
+		tableSequences: tableSequences,
+	}
+}
+
+func (c *CreateViewCommand) OnPhase(phase int32) error {
+	if phase == 0 {
+		return c.onPhase0()
+	}
+	panic("invalid phase")
+}
+
+func (c *CreateViewCommand) NumPhases() int {
+	return 1
+}
+
+func (c *CreateViewCommand) Before() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	viewInfo, err := c.createViewInfoFromAST(c.ast)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.viewInfo = viewInfo
+	if err := c.e.metaController.ExistsTable(c.schema, viewInfo.Name); err != nil {
+		return err
+	}
+	if err := c.e.metaController.PersistView(viewInfo); err != nil {
+		return err
+	}
+	c.persisted = true
+	return nil
+}
+
+func (c *CreateViewCommand) onPhase0() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// If phase0 on the originating node, the view will already be set
+	if c.viewInfo == nil {
+		viewInfo, err := c.createViewInfo()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.viewInfo = viewInfo
+	}
+	return c.e.metaController.RegisterView(c.viewInfo)
+}
+
+func (c *CreateViewCommand) AfterPhase(phase int32) error {
+	return nil
+}
+
+func (c *CreateViewCommand) Cleanup() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.viewInfo == nil {
+		return
+	}
+	if view, ok := c.e.metaController.GetView(c.viewInfo.SchemaName, c.viewInfo.Name); ok && view.ID == c.viewInfo.ID {
+		if err := c.e.metaController.UnregisterView(c.viewInfo.SchemaName, c.viewInfo.Name); err != nil {
+			// Ignore
+		}
+	}
+	if c.persisted {
+		if err := c.e.metaController.DeleteView(c.viewInfo.ID); err != nil {
+			// Ignore
+		}
+	}
+}
+
+// createViewInfoFromAST plans the query of the view, to validate it and to find the columns of the rows it returns
+func (c *CreateViewCommand) createViewInfoFromAST(ast *parser.CreateView) (*common.ViewInfo, error) {
+	viewName := strings.ToLower(ast.Name)
+	querySQL := strings.TrimSpace(ast.Query.String())
+	stmt, paramCount, err := c.pl.Parse(querySQL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if paramCount > 0 {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "View query cannot have parameters")
+	}
+	logical, err := c.pl.BuildLogicalPlan(stmt, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cols := logical.Schema().Columns
+	colNames := make([]string, 0, len(cols))
+	colTypes := make([]common.ColumnType, 0, len(cols))
+	names := make(map[string]struct{}, len(cols))
+	for i, outputName := range logical.OutputNames() {
+		colName := outputName.ColName.L
+		if _, ok := names[colName]; ok {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Duplicate column name %s in view %s", colName, viewName)
+		}
+		names[colName] = struct{}{}
+		colType, err := common.ConvertTiDBTypeToPranaType(cols[i].GetType())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		colNames = append(colNames, colName)
+		colTypes = append(colTypes, colType)
+	}
+	seqGenerator := common.NewPreallocSeqGen(c.tableSequences)
+	tableInfo := &common.TableInfo{
+		ID:          seqGenerator.GenerateSequence(),
+		SchemaName:  c.schema.Name,
+		Name:        viewName,
+		ColumnNames: colNames,
+		ColumnTypes: colTypes,
+	}
+	return &common.ViewInfo{TableInfo: tableInfo, Query: querySQL}, nil
+}
+
+func (c *CreateViewCommand) createViewInfo() (*common.ViewInfo, error) {
+	ast, err := parser.Parse(c.createViewSQL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if ast.Create == nil || ast.Create.View == nil {
+		return nil, errors.Errorf("not a create view %s", c.createViewSQL)
+	}
+	return c.createViewInfoFromAST(ast.Create.View)
+}
+
+func (c *CreateViewCommand) GetExtraData() []byte {
+	return nil
+}
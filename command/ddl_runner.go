@@ -75,6 +75,17 @@ const (
 	DDLCommandTypeDropMV
 	DDLCommandTypeCreateIndex
 	DDLCommandTypeDropIndex
+	DDLCommandTypeAnalyzeTable
+	DDLCommandTypeAlterSource
+	DDLCommandTypeAlterMV
+	DDLCommandTypeDropCascade
+	DDLCommandTypeCreateView
+	DDLCommandTypeDropView
+	DDLCommandTypeRename
+	DDLCommandTypeCreateSchema
+	DDLCommandTypeDropSchema
+	DDLCommandTypePauseSource
+	DDLCommandTypeResetOffsets
 )
 
 func NewDDLCommandRunner(ce *Executor) *DDLCommandRunner {
@@ -104,6 +115,28 @@ func NewDDLCommand(e *Executor, commandType DDLCommandType, schemaName string, s
 		return NewCreateIndexCommand(e, schemaName, sql, tableSequences, extraData)
 	case DDLCommandTypeDropIndex:
 		return NewDropIndexCommand(e, schemaName, sql)
+	case DDLCommandTypeAnalyzeTable:
+		return NewAnalyzeTableCommand(e, schemaName, sql, extraData)
+	case DDLCommandTypeAlterSource:
+		return NewAlterSourceCommand(e, schemaName, sql)
+	case DDLCommandTypeAlterMV:
+		return NewAlterMVCommand(e, schemaName, sql, tableSequences, extraData)
+	case DDLCommandTypeDropCascade:
+		return NewDropCascadeCommand(e, schemaName, sql)
+	case DDLCommandTypeCreateView:
+		return NewCreateViewCommand(e, schemaName, sql, tableSequences)
+	case DDLCommandTypeDropView:
+		return NewDropViewCommand(e, schemaName, sql)
+	case DDLCommandTypeRename:
+		return NewRenameCommand(e, schemaName, sql)
+	case DDLCommandTypeCreateSchema:
+		return NewCreateSchemaCommand(e, schemaName, sql)
+	case DDLCommandTypeDropSchema:
+		return NewDropSchemaCommand(e, schemaName, sql)
+	case DDLCommandTypePauseSource:
+		return NewPauseSourceCommand(e, schemaName, sql)
+	case DDLCommandTypeResetOffsets:
+		return NewResetOffsetsCommand(e, schemaName, sql)
 	default:
 		panic("invalid ddl command")
 	}
@@ -349,11 +382,16 @@ func (d *DDLCommandRunner) RunWithLock(commandKey string, command DDLCommand, dd
 		}
 		if err != nil {
 			log.Debugf("Error return from broadcasting phase %d for DDL command %d %s %v cancel will be broadcast", phase, command.CommandType(), ddlInfo.Sql, err)
-			d.commands.Delete(commandKey)
-			// Broadcast a cancel to clean up command state across the cluster
+			// Broadcast a cancel to clean up command state across the cluster, including this node, where the phase
+			// may have succeeded even though it failed elsewhere
 			if err2 := d.broadcastCancel(command.SchemaName()); err2 != nil {
 				// Ignore
 			}
+			if _, ok := d.commands.LoadAndDelete(commandKey); ok {
+				// The cancel didn't reach this node
+				command.Cancel()
+				command.Cleanup()
+			}
 			log.Debugf("Broadcast of cancel returned for DDL command %d %s", command.CommandType(), ddlInfo.Sql)
 			return errors.WithStack(err)
 		}
@@ -0,0 +1,513 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/push"
+	"github.com/squareup/pranadb/push/source"
+)
+
+// DropCascadeCommand drops a source or materialized view along with everything which depends on it - the sinks and
+// indexes on it, the materialized views which consume it and the views which use it, and everything which depends on
+// those in turn. It also drops a schema along with everything in it.
+//
+// Everything is dropped from storage in a single batch after phase 0, before anything is changed in memory, so if the
+// command fails before then nothing has been dropped. Once that batch has been written the drop can't be rolled back,
+// and anything not yet dropped if the command fails after it will be on restart. Dependents are dropped before what
+// they depend on.
+type DropCascadeCommand struct {
+	lock            sync.Mutex
+	e               *Executor
+	schemaName      string
+	sql             string
+	tableName       string
+	materialized    bool
+	schema          bool
+	drops           []*cascadeDrop
+	toDeleteBatches []*cluster.ToDeleteBatch
+	persisted       bool
+}
+
+// cascadeDrop is one of the things dropped by a DropCascadeCommand. Exactly one of its fields is set.
+type cascadeDrop struct {
+	sink       *push.Sink
+	indexInfo  *common.IndexInfo
+	mv         *push.MaterializedView
+	sourceInfo *common.SourceInfo
+	viewInfo   *common.ViewInfo
+}
+
+func (d *DropCascadeCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeDropCascade
+}
+
+func (d *DropCascadeCommand) SchemaName() string {
+	return d.schemaName
+}
+
+func (d *DropCascadeCommand) SQL() string {
+	return d.sql
+}
+
+func (d *DropCascadeCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (d *DropCascadeCommand) Cancel() {
+}
+
+func NewOriginatingDropCascadeCommand(e *Executor, schemaName string, sql string, ast *parser.Drop) *DropCascadeCommand {
+	return &DropCascadeCommand{
+		e:            e,
+		schemaName:   schemaName,
+		sql:          sql,
+		tableName:    strings.ToLower(ast.Name),
+		materialized: ast.MaterializedView,
+		schema:       ast.Schema,
+	}
+}
+
+func NewDropCascadeCommand(e *Executor, schemaName string, sql string) *DropCascadeCommand {
+	return &DropCascadeCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (d *DropCascadeCommand) Before() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.getDrops()
+}
+
+func (d *DropCascadeCommand) OnPhase(phase int32) error {
+	switch phase {
+	case 0:
+		return d.onPhase0()
+	case 1:
+		return d.onPhase1()
+	case 2:
+		return d.onPhase2()
+	default:
+		panic("invalid phase")
+	}
+}
+
+func (d *DropCascadeCommand) NumPhases() int {
+	return 3
+}
+
+func (d *DropCascadeCommand) onPhase0() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// Nothing is changed until everything to drop has been found on every node
+	if d.drops == nil {
+		return d.getDrops()
+	}
+	return nil
+}
+
+func (d *DropCascadeCommand) onPhase1() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// Everything is removed from in memory metadata and disconnected from what it consumes, dependents first so that
+	// what they consume is still registered
+	for _, drop := range d.drops {
+		var err error
+		switch {
+		case drop.sink != nil:
+			err = d.disconnectSink(drop.sink)
+		case drop.indexInfo != nil:
+			err = d.disconnectIndex(drop.indexInfo)
+		case drop.mv != nil:
+			err = d.disconnectMV(drop.mv)
+		case drop.viewInfo != nil:
+			err = d.e.metaController.UnregisterView(d.schemaName, drop.viewInfo.Name)
+		default:
+			err = d.disconnectSource(drop.sourceInfo)
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if d.schema {
+		// A schema which wasn't created with CREATE SCHEMA has already gone along with its last table
+		if _, ok := d.e.metaController.GetSchema(d.schemaName); ok {
+			return d.e.metaController.UnregisterSchema(d.schemaName)
+		}
+	}
+	return nil
+}
+
+func (d *DropCascadeCommand) onPhase2() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// Everything is now disconnected on all nodes, so we can remove it from the push engine and delete its data
+	for _, drop := range d.drops {
+		var err error
+		switch {
+		case drop.sink != nil:
+			if err = d.e.pushEngine.RemoveSink(drop.sink.Info.ID); err == nil {
+				err = drop.sink.Drop()
+			}
+		case drop.indexInfo != nil:
+			err = d.e.pushEngine.DeleteIndexData(drop.indexInfo)
+		case drop.mv != nil:
+			if err = d.e.pushEngine.RemoveMV(drop.mv.Info.ID); err == nil {
+				err = drop.mv.Drop()
+			}
+		case drop.viewInfo != nil:
+			// A view has no data
+		default:
+			var src *source.Source
+			if src, err = d.e.pushEngine.RemoveSource(drop.sourceInfo); err == nil {
+				err = src.Drop()
+			}
+		}
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+func (d *DropCascadeCommand) AfterPhase(phase int32) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	switch phase {
+	case 0:
+		// We record prefixes in the to_delete table first - this makes sure the data is deleted on restart if failure
+		// occurs after everything is deleted from storage
+		var tableIDs, indexIDs []uint64
+		for _, drop := range d.drops {
+			var id uint64
+			switch {
+			case drop.sink != nil:
+				id = drop.sink.Info.ID
+				tableIDs = append(tableIDs, id)
+			case drop.indexInfo != nil:
+				id = drop.indexInfo.ID
+				indexIDs = append(indexIDs, id)
+			case drop.mv != nil:
+				id = drop.mv.Info.ID
+				tableIDs = append(tableIDs, id)
+				for _, it := range drop.mv.InternalTables {
+					tableIDs = append(tableIDs, it.ID)
+				}
+			case drop.viewInfo != nil:
+				// A view has no data, so there's nothing to delete apart from its row in the tables table
+				tableIDs = append(tableIDs, drop.viewInfo.ID)
+				continue
+			default:
+				id = drop.sourceInfo.ID
+				tableIDs = append(tableIDs, id)
+			}
+			toDeleteBatch, err := storeToDeleteBatch(id, d.e.cluster)
+			if err != nil {
+				return err
+			}
+			d.toDeleteBatches = append(d.toDeleteBatches, toDeleteBatch)
+		}
+		// Everything is deleted from storage in the same batch, so after a failure and restart it's all or nothing
+		if d.schema {
+			if err := d.e.metaController.DeleteSchemaAndTables(d.schemaName, tableIDs, indexIDs); err != nil {
+				return err
+			}
+		} else if err := d.e.metaController.DeleteTablesAndIndexes(tableIDs, indexIDs); err != nil {
+			return err
+		}
+		d.persisted = true
+	case 2:
+		// Now delete rows from the to_delete table
+		for _, toDeleteBatch := range d.toDeleteBatches {
+			if err := d.e.cluster.RemoveToDeleteBatch(toDeleteBatch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *DropCascadeCommand) Cleanup() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// If nothing was dropped from storage then nothing was changed, apart from the to_delete rows we may have stored.
+	// Those are only stored on the originating node
+	if d.persisted {
+		return
+	}
+	for _, toDeleteBatch := range d.toDeleteBatches {
+		if err := d.e.cluster.RemoveToDeleteBatch(toDeleteBatch); err != nil {
+			// Ignore - they won't be acted on as the tables still exist
+		}
+	}
+}
+
+func (d *DropCascadeCommand) disconnectSink(sink *push.Sink) error {
+	if err := sink.Stop(); err != nil {
+		return err
+	}
+	if err := d.e.metaController.UnregisterSink(d.schemaName, sink.Info.Name); err != nil {
+		return errors.WithStack(err)
+	}
+	return sink.Disconnect()
+}
+
+func (d *DropCascadeCommand) disconnectIndex(indexInfo *common.IndexInfo) error {
+	// The table the index is on is still registered, so the index can be unattached from it
+	if err := d.e.pushEngine.UnattachIndex(indexInfo); err != nil {
+		return err
+	}
+	return d.e.metaController.UnregisterIndex(d.schemaName, indexInfo.TableName, indexInfo.Name)
+}
+
+func (d *DropCascadeCommand) disconnectMV(mv *push.MaterializedView) error {
+	var itNames []string
+	for _, it := range mv.InternalTables {
+		itNames = append(itNames, it.Name)
+	}
+	if err := mv.Disconnect(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.e.metaController.UnregisterMaterializedView(d.schemaName, mv.Info.Name, itNames)
+}
+
+func (d *DropCascadeCommand) disconnectSource(sourceInfo *common.SourceInfo) error {
+	if err := d.e.metaController.UnregisterSource(d.schemaName, sourceInfo.Name); err != nil {
+		return errors.WithStack(err)
+	}
+	src, err := d.e.pushEngine.GetSource(sourceInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// src.Stop() stops the sources consumers, it does not remove it
+	return src.Stop()
+}
+
+// getDrops finds everything to drop, in the order it must be dropped
+func (d *DropCascadeCommand) getDrops() error {
+	if d.tableName == "" {
+		ast, err := parser.Parse(d.sql)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if ast.Drop == nil || !ast.Drop.Cascade {
+			return errors.Errorf("not a drop cascade command %s", d.sql)
+		}
+		d.tableName = strings.ToLower(ast.Drop.Name)
+		d.materialized = ast.Drop.MaterializedView
+		d.schema = ast.Drop.Schema
+	}
+	var drops []*cascadeDrop
+	if d.schema {
+		var err error
+		if drops, err = d.getSchemaDrops(); err != nil {
+			return err
+		}
+	} else if d.materialized {
+		mvInfo, ok := d.e.metaController.GetMaterializedView(d.schemaName, d.tableName)
+		if !ok {
+			return errors.NewUnknownMaterializedViewError(d.schemaName, d.tableName)
+		}
+		mv, err := d.e.pushEngine.GetMaterializedView(mvInfo.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if drops, err = d.getMVDrops(mv, drops); err != nil {
+			return err
+		}
+	} else {
+		sourceInfo, ok := d.e.metaController.GetSource(d.schemaName, d.tableName)
+		if !ok {
+			return errors.NewUnknownSourceError(d.schemaName, d.tableName)
+		}
+		src, err := d.e.pushEngine.GetSource(sourceInfo.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		drops, err = d.getDependentDrops(sourceInfo.TableInfo, src.GetConsumingNodeNames(), drops)
+		if err != nil {
+			return err
+		}
+		drops = append(drops, &cascadeDrop{sourceInfo: sourceInfo})
+	}
+	d.drops = drops
+	return nil
+}
+
+func (d *DropCascadeCommand) getMVDrops(mv *push.MaterializedView, drops []*cascadeDrop) ([]*cascadeDrop, error) {
+	if containsMVDrop(drops, mv) {
+		// When dropping a schema, an MV may already have been dropped along with something else
+		return drops, nil
+	}
+	drops, err := d.getDependentDrops(mv.Info.TableInfo, mv.GetConsumingMVOrIndexNames(), drops)
+	if err != nil {
+		return nil, err
+	}
+	return append(drops, &cascadeDrop{mv: mv}), nil
+}
+
+// getDependentDrops appends everything which depends on the table to drops, given the names of the consumers of the
+// table
+func (d *DropCascadeCommand) getDependentDrops(tableInfo *common.TableInfo, consumerNames []string,
+	drops []*cascadeDrop) ([]*cascadeDrop, error) {
+	indexConsumerNames := make(map[string]struct{}, len(tableInfo.IndexInfos))
+	var indexInfos []*common.IndexInfo
+	for _, indexInfo := range tableInfo.IndexInfos {
+		indexConsumerNames[fmt.Sprintf("%s.%s", tableInfo.Name, indexInfo.Name)] = struct{}{}
+		indexInfos = append(indexInfos, indexInfo)
+	}
+	// Sort to make the order deterministic
+	sort.Strings(consumerNames)
+	sort.Slice(indexInfos, func(i, j int) bool {
+		return indexInfos[i].Name < indexInfos[j].Name
+	})
+	for _, consumerName := range consumerNames {
+		if _, ok := indexConsumerNames[consumerName]; ok {
+			continue
+		}
+		if mvInfo, ok := d.e.metaController.GetMaterializedView(d.schemaName, consumerName); ok {
+			mv, err := d.e.pushEngine.GetMaterializedView(mvInfo.ID)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if drops, err = d.getMVDrops(mv, drops); err != nil {
+				return nil, err
+			}
+		} else if sinkInfo, ok := d.e.metaController.GetSink(d.schemaName, consumerName); ok {
+			sink, err := d.e.pushEngine.GetSink(sinkInfo.ID)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if !containsSinkDrop(drops, sink) {
+				drops = append(drops, &cascadeDrop{sink: sink})
+			}
+		} else {
+			return nil, errors.Errorf("cannot drop %s.%s: unknown consumer %s", d.schemaName, tableInfo.Name,
+				consumerName)
+		}
+	}
+	for _, indexInfo := range indexInfos {
+		drops = append(drops, &cascadeDrop{indexInfo: indexInfo})
+	}
+	return d.getViewDrops(tableInfo.Name, drops)
+}
+
+// getViewDrops appends the views which use the named table or view to drops, along with the views which use those in
+// turn. Anything else defined on a view consumes the tables the view uses, so it's dropped along with them.
+func (d *DropCascadeCommand) getViewDrops(name string, drops []*cascadeDrop) ([]*cascadeDrop, error) {
+	viewNames, err := d.e.getViewChildren(d.schemaName, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, viewName := range viewNames {
+		viewInfo, ok := d.e.metaController.GetView(d.schemaName, viewName)
+		if !ok || containsViewDrop(drops, viewInfo) {
+			// A view which uses more than one of the dropped tables is only dropped once
+			continue
+		}
+		if drops, err = d.getViewDrops(viewName, drops); err != nil {
+			return nil, err
+		}
+		drops = append(drops, &cascadeDrop{viewInfo: viewInfo})
+	}
+	return drops, nil
+}
+
+// getSchemaDrops finds everything in the schema to drop, in the order it must be dropped
+func (d *DropCascadeCommand) getSchemaDrops() ([]*cascadeDrop, error) {
+	if d.schemaName == meta.SystemSchemaName {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Cannot drop schema %s", d.schemaName)
+	}
+	schema, ok := d.e.metaController.GetSchema(d.schemaName)
+	if !ok || !d.e.metaController.SchemaExists(d.schemaName) {
+		return nil, errors.NewUnknownSchemaError(d.schemaName)
+	}
+	var drops []*cascadeDrop
+	// Each table is dropped after everything which depends on it, and only once
+	for _, tbl := range schema.GetTables() {
+		var err error
+		switch info := tbl.(type) {
+		case *common.SourceInfo:
+			var src *source.Source
+			if src, err = d.e.pushEngine.GetSource(info.ID); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if drops, err = d.getDependentDrops(info.TableInfo, src.GetConsumingNodeNames(), drops); err != nil {
+				return nil, err
+			}
+			drops = append(drops, &cascadeDrop{sourceInfo: info})
+		case *common.MaterializedViewInfo:
+			var mv *push.MaterializedView
+			if mv, err = d.e.pushEngine.GetMaterializedView(info.ID); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if drops, err = d.getMVDrops(mv, drops); err != nil {
+				return nil, err
+			}
+		case *common.SinkInfo:
+			var sink *push.Sink
+			if sink, err = d.e.pushEngine.GetSink(info.ID); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if !containsSinkDrop(drops, sink) {
+				drops = append(drops, &cascadeDrop{sink: sink})
+			}
+		case *common.ViewInfo:
+			if containsViewDrop(drops, info) {
+				continue
+			}
+			if drops, err = d.getViewDrops(info.Name, drops); err != nil {
+				return nil, err
+			}
+			drops = append(drops, &cascadeDrop{viewInfo: info})
+		}
+	}
+	return drops, nil
+}
+
+func containsMVDrop(drops []*cascadeDrop, mv *push.MaterializedView) bool {
+	for _, drop := range drops {
+		if drop.mv != nil && drop.mv.Info.ID == mv.Info.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSinkDrop(drops []*cascadeDrop, sink *push.Sink) bool {
+	for _, drop := range drops {
+		if drop.sink != nil && drop.sink.Info.ID == sink.Info.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func containsViewDrop(drops []*cascadeDrop, viewInfo *common.ViewInfo) bool {
+	for _, drop := range drops {
+		if drop.viewInfo != nil && drop.viewInfo.ID == viewInfo.ID {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DropCascadeCommand) GetExtraData() []byte {
+	return nil
+}
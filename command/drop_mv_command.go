@@ -72,6 +72,13 @@ func (d *DropMVCommand) Before() error {
 	if len(consuming) != 0 {
 		return errors.NewMaterializedViewHasChildrenError(mv.Info.SchemaName, mv.Info.Name, consuming)
 	}
+	views, err := d.e.getViewChildren(d.schemaName, mv.Info.Name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(views) != 0 {
+		return errors.NewMaterializedViewHasChildrenError(mv.Info.SchemaName, mv.Info.Name, views)
+	}
 	return nil
 }
 
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"sync"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+)
+
+// DropSchemaCommand drops an empty schema. A schema with tables in it can only be dropped with DROP SCHEMA ... CASCADE,
+// which is a DropCascadeCommand.
+type DropSchemaCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+}
+
+func (d *DropSchemaCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeDropSchema
+}
+
+func (d *DropSchemaCommand) SchemaName() string {
+	return d.schemaName
+}
+
+func (d *DropSchemaCommand) SQL() string {
+	return d.sql
+}
+
+func (d *DropSchemaCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (d *DropSchemaCommand) Cancel() {
+}
+
+// NewDropSchemaCommand creates the command, the name of the schema to drop is the schema the command runs in
+func NewDropSchemaCommand(e *Executor, schemaName string, sql string) *DropSchemaCommand {
+	return &DropSchemaCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (d *DropSchemaCommand) Before() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.schemaName == meta.SystemSchemaName {
+		return errors.NewPranaErrorf(errors.InvalidStatement, "Cannot drop schema %s", d.schemaName)
+	}
+	schema, ok := d.e.metaController.GetSchema(d.schemaName)
+	if !ok || !d.e.metaController.SchemaExists(d.schemaName) {
+		return errors.NewUnknownSchemaError(d.schemaName)
+	}
+	var children []string
+	for _, tbl := range schema.GetTables() {
+		if _, ok := tbl.(*common.InternalTableInfo); !ok {
+			children = append(children, tbl.GetTableInfo().Name)
+		}
+	}
+	if len(children) != 0 {
+		return errors.NewSchemaHasChildrenError(d.schemaName, children)
+	}
+	return nil
+}
+
+func (d *DropSchemaCommand) OnPhase(phase int32) error {
+	if phase == 0 {
+		return d.onPhase0()
+	}
+	panic("invalid phase")
+}
+
+func (d *DropSchemaCommand) NumPhases() int {
+	return 1
+}
+
+func (d *DropSchemaCommand) onPhase0() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.e.metaController.GetSchema(d.schemaName); !ok {
+		return nil
+	}
+	return d.e.metaController.UnregisterSchema(d.schemaName)
+}
+
+func (d *DropSchemaCommand) AfterPhase(phase int32) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if phase == 0 {
+		return d.e.metaController.DeleteSchemaAndTables(d.schemaName, nil, nil)
+	}
+	return nil
+}
+
+func (d *DropSchemaCommand) Cleanup() {
+}
+
+func (d *DropSchemaCommand) GetExtraData() []byte {
+	return nil
+}
@@ -75,6 +75,13 @@ func (d *DropSourceCommand) Before() error {
 	if len(consuming) != 0 {
 		return errors.NewSourceHasChildrenError(d.sourceInfo.SchemaName, d.sourceInfo.Name, consuming)
 	}
+	views, err := d.e.getViewChildren(d.schemaName, sourceInfo.Name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(views) != 0 {
+		return errors.NewSourceHasChildrenError(d.sourceInfo.SchemaName, d.sourceInfo.Name, views)
+	}
 	return nil
 }
 
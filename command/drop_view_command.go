@@ -0,0 +1,214 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/parplan"
+)
+
+type DropViewCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	viewName   string
+	viewInfo   *common.ViewInfo
+}
+
+func (d *DropViewCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeDropView
+}
+
+func (d *DropViewCommand) SchemaName() string {
+	return d.schemaName
+}
+
+func (d *DropViewCommand) SQL() string {
+	return d.sql
+}
+
+func (d *DropViewCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (d *DropViewCommand) Cancel() {
+}
+
+func NewOriginatingDropViewCommand(e *Executor, schemaName string, sql string, viewName string) *DropViewCommand {
+	return &DropViewCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		viewName:   strings.ToLower(viewName),
+	}
+}
+
+func NewDropViewCommand(e *Executor, schemaName string, sql string) *DropViewCommand {
+	return &DropViewCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (d *DropViewCommand) Before() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	viewInfo, err := d.getViewInfo()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	d.viewInfo = viewInfo
+
+	children, err := d.e.getQueryChildren(d.schemaName, viewInfo.Name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(children) != 0 {
+		return errors.NewViewHasChildrenError(d.schemaName, viewInfo.Name, children)
+	}
+	return nil
+}
+
+func (d *DropViewCommand) OnPhase(phase int32) error {
+	if phase == 0 {
+		return d.onPhase0()
+	}
+	panic("invalid phase")
+}
+
+func (d *DropViewCommand) NumPhases() int {
+	return 1
+}
+
+func (d *DropViewCommand) onPhase0() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.viewInfo == nil {
+		viewInfo, err := d.getViewInfo()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		d.viewInfo = viewInfo
+	}
+	return d.e.metaController.UnregisterView(d.schemaName, d.viewInfo.Name)
+}
+
+func (d *DropViewCommand) AfterPhase(phase int32) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if phase == 0 {
+		// A view has no data, so it's enough to delete it from the tables table
+		return d.e.metaController.DeleteView(d.viewInfo.ID)
+	}
+	return nil
+}
+
+func (d *DropViewCommand) Cleanup() {
+}
+
+func (d *DropViewCommand) getViewInfo() (*common.ViewInfo, error) {
+	if d.viewName == "" {
+		ast, err := parser.Parse(d.sql)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if ast.Drop == nil || !ast.Drop.View {
+			return nil, errors.Errorf("not a drop view command %s", d.sql)
+		}
+		d.viewName = strings.ToLower(ast.Drop.Name)
+	}
+	viewInfo, ok := d.e.metaController.GetView(d.schemaName, d.viewName)
+	if !ok {
+		return nil, errors.NewUnknownViewError(d.schemaName, d.viewName)
+	}
+	return viewInfo, nil
+}
+
+func (d *DropViewCommand) GetExtraData() []byte {
+	return nil
+}
+
+// getViewChildren returns the names of the views whose queries use the named table or view, ordered by name
+func (e *Executor) getViewChildren(schemaName string, name string) ([]string, error) {
+	schema, ok := e.metaController.GetSchema(schemaName)
+	if !ok {
+		return nil, nil
+	}
+	var children []string
+	for _, view := range schema.GetViews() {
+		uses, err := queryUsesTable(schemaName, view.Query, name)
+		if err != nil {
+			return nil, err
+		}
+		if uses {
+			children = append(children, view.Name)
+		}
+	}
+	return children, nil
+}
+
+// getQueryChildren returns the names of the views, materialized views and sinks whose queries use the named view.
+// Materialized views and sinks defined on a view consume the tables the view uses rather than the view itself, so
+// they're found from their queries.
+func (e *Executor) getQueryChildren(schemaName string, name string) ([]string, error) {
+	children, err := e.getViewChildren(schemaName, name)
+	if err != nil {
+		return nil, err
+	}
+	mvNames, err := e.showTableNames(schemaName, meta.TableKindMaterializedView)
+	if err != nil {
+		return nil, err
+	}
+	for _, mvName := range mvNames {
+		mvInfo, ok := e.metaController.GetMaterializedView(schemaName, mvName)
+		if !ok {
+			continue
+		}
+		uses, err := queryUsesTable(schemaName, mvInfo.Query, name)
+		if err != nil {
+			return nil, err
+		}
+		if uses {
+			children = append(children, mvName)
+		}
+	}
+	sinkNames, err := e.showTableNames(schemaName, meta.TableKindSink)
+	if err != nil {
+		return nil, err
+	}
+	for _, sinkName := range sinkNames {
+		sinkInfo, ok := e.metaController.GetSink(schemaName, sinkName)
+		if !ok {
+			continue
+		}
+		uses, err := queryUsesTable(schemaName, sinkInfo.Query, name)
+		if err != nil {
+			return nil, err
+		}
+		if uses {
+			children = append(children, sinkName)
+		}
+	}
+	return children, nil
+}
+
+func queryUsesTable(schemaName string, query string, name string) (bool, error) {
+	names, err := parplan.ReferencedTableNames(schemaName, query)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	for _, n := range names {
+		if n == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
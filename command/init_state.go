@@ -28,6 +28,13 @@ func validateInitState(initTable string, thisState *common.TableInfo, metaContro
 	if !matches {
 		return errors.NewPranaErrorf(errors.InvalidStatement, structureNotMatchMsg(tabInfo, thisState))
 	}
+	// The rows are copied with their keys, so the primary keys must also be ordered the same way
+	for i := range thisState.PrimaryKeyCols {
+		if i < len(tabInfo.PrimaryKeyCols) && common.IsDesc(thisState.PrimaryKeyDesc, i) != common.IsDesc(tabInfo.PrimaryKeyDesc, i) {
+			return errors.NewPranaErrorf(errors.InvalidStatement,
+				"Primary key ordering of %s does not match the primary key ordering of the initial state table", thisState.Name)
+		}
+	}
 	return nil
 }
 
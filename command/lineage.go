@@ -0,0 +1,246 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/pull/exec"
+)
+
+// LineageKindIndex is the kind of an index in the lineage graph. The other kinds are the kinds of table stored in
+// sys.tables.
+const LineageKindIndex = "index"
+
+// Lineage is the graph of how data flows between the sources, materialized views, sinks and indexes of a schema. An
+// edge goes from a table to each of the things which consume from it. The internal aggregate tables of a materialized
+// view have an edge to the materialized view, as it's from them that the rows of the materialized view are taken.
+type Lineage struct {
+	SchemaName string         `json:"schema"`
+	Nodes      []*LineageNode `json:"nodes"`
+	Edges      []*LineageEdge `json:"edges"`
+}
+
+type LineageNode struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+type LineageEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+var lineageNodeShapes = map[string]string{
+	meta.TableKindSource:           "cylinder",
+	meta.TableKindMaterializedView: "box",
+	meta.TableKindSink:             "cds",
+	meta.TableKindInternal:         "note",
+	LineageKindIndex:               "component",
+}
+
+// DOT returns the graph in the Graphviz DOT language
+func (l *Lineage) DOT() string {
+	sb := &strings.Builder{}
+	sb.WriteString(fmt.Sprintf("digraph %q {\n", l.SchemaName))
+	for _, node := range l.Nodes {
+		sb.WriteString(fmt.Sprintf("  %q [shape=%s, label=%q];\n", node.Name, lineageNodeShapes[node.Kind],
+			fmt.Sprintf("%s\n(%s)", node.Name, node.Kind)))
+	}
+	for _, edge := range l.Edges {
+		sb.WriteString(fmt.Sprintf("  %q -> %q;\n", edge.From, edge.To))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// GetLineage builds the lineage graph of a schema from the consumers registered with the sources and materialized
+// views in the push engine. If name is not empty the graph only contains what feeds, or is fed by, the source,
+// materialized view or sink with that name.
+func (e *Executor) GetLineage(schemaName string, name string) (*Lineage, error) {
+	b := &lineageBuilder{
+		e:          e,
+		schemaName: schemaName,
+		kinds:      map[string]string{},
+		edges:      map[string][]string{},
+	}
+	if err := b.build(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return b.lineage(nil), nil
+	}
+	name = strings.ToLower(name)
+	kind, ok := b.kinds[name]
+	if !ok || kind == meta.TableKindInternal || kind == LineageKindIndex {
+		return nil, errors.NewPranaErrorf(errors.UnknownTable, "Unknown table %s.%s", schemaName, name)
+	}
+	return b.lineage(b.connected(name)), nil
+}
+
+type lineageBuilder struct {
+	e          *Executor
+	schemaName string
+	kinds      map[string]string
+	// edges holds the names of the consumers of each node
+	edges map[string][]string
+}
+
+func (b *lineageBuilder) build() error {
+	sourceNames, err := b.e.showTableNames(b.schemaName, meta.TableKindSource)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, sourceName := range sourceNames {
+		sourceInfo, ok := b.e.metaController.GetSource(b.schemaName, sourceName)
+		if !ok {
+			// Dropped since the names were fetched
+			continue
+		}
+		src, err := b.e.pushEngine.GetSource(sourceInfo.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		b.addTable(sourceInfo.TableInfo, meta.TableKindSource, src.GetConsumingNodeNames())
+	}
+	mvNames, err := b.e.showTableNames(b.schemaName, meta.TableKindMaterializedView)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, mvName := range mvNames {
+		mvInfo, ok := b.e.metaController.GetMaterializedView(b.schemaName, mvName)
+		if !ok {
+			continue
+		}
+		mv, err := b.e.pushEngine.GetMaterializedView(mvInfo.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		b.addTable(mvInfo.TableInfo, meta.TableKindMaterializedView, mv.GetConsumingMVOrIndexNames())
+		for _, it := range mv.InternalTables {
+			b.kinds[it.Name] = meta.TableKindInternal
+			b.edges[it.Name] = append(b.edges[it.Name], mvName)
+		}
+	}
+	sinkNames, err := b.e.showTableNames(b.schemaName, meta.TableKindSink)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, sinkName := range sinkNames {
+		b.kinds[sinkName] = meta.TableKindSink
+	}
+	// Consumers which aren't in the graph are internal to the push engine, such as the index on the row time of a
+	// source with a retention time, or a materialized view which is being built to replace another
+	for from, consumerNames := range b.edges {
+		var known []string
+		for _, consumerName := range consumerNames {
+			if _, ok := b.kinds[consumerName]; ok {
+				known = append(known, consumerName)
+			}
+		}
+		sort.Strings(known)
+		b.edges[from] = known
+	}
+	return nil
+}
+
+func (b *lineageBuilder) addTable(tableInfo *common.TableInfo, kind string, consumerNames []string) {
+	b.kinds[tableInfo.Name] = kind
+	b.edges[tableInfo.Name] = append(b.edges[tableInfo.Name], consumerNames...)
+	for _, indexInfo := range tableInfo.IndexInfos {
+		// Indexes consume from their table under the name <table>.<index>
+		b.kinds[fmt.Sprintf("%s.%s", tableInfo.Name, indexInfo.Name)] = LineageKindIndex
+	}
+}
+
+// connected returns the names of the nodes which feed, or are fed by, the named node, including the node itself
+func (b *lineageBuilder) connected(name string) map[string]struct{} {
+	feeders := map[string][]string{}
+	for from, consumerNames := range b.edges {
+		for _, consumerName := range consumerNames {
+			feeders[consumerName] = append(feeders[consumerName], from)
+		}
+	}
+	names := map[string]struct{}{}
+	walkLineage(name, b.edges, names)
+	walkLineage(name, feeders, names)
+	return names
+}
+
+func walkLineage(name string, edges map[string][]string, visited map[string]struct{}) {
+	visited[name] = struct{}{}
+	for _, next := range edges[name] {
+		if _, ok := visited[next]; !ok {
+			walkLineage(next, edges, visited)
+		}
+	}
+}
+
+// lineage returns the graph, restricted to the given names if they're not nil, with nodes and edges sorted by name
+func (b *lineageBuilder) lineage(names map[string]struct{}) *Lineage {
+	include := func(name string) bool {
+		if names == nil {
+			return true
+		}
+		_, ok := names[name]
+		return ok
+	}
+	lineage := &Lineage{SchemaName: b.schemaName, Nodes: []*LineageNode{}, Edges: []*LineageEdge{}}
+	for name, kind := range b.kinds {
+		if include(name) {
+			lineage.Nodes = append(lineage.Nodes, &LineageNode{Name: name, Kind: kind})
+		}
+	}
+	sort.Slice(lineage.Nodes, func(i, j int) bool {
+		return lineage.Nodes[i].Name < lineage.Nodes[j].Name
+	})
+	for _, node := range lineage.Nodes {
+		for _, consumerName := range b.edges[node.Name] {
+			if include(consumerName) {
+				lineage.Edges = append(lineage.Edges, &LineageEdge{From: node.Name, To: consumerName})
+			}
+		}
+	}
+	return lineage
+}
+
+func (e *Executor) execShowLineage(schemaName string, name string) (exec.PullExecutor, error) {
+	lineage, err := e.GetLineage(schemaName, name)
+	if err != nil {
+		return nil, err
+	}
+	rowsFactory := common.NewRowsFactory(
+		[]common.ColumnType{common.VarcharColumnType, common.VarcharColumnType, common.VarcharColumnType,
+			common.VarcharColumnType},
+	)
+	kinds := make(map[string]string, len(lineage.Nodes))
+	for _, node := range lineage.Nodes {
+		kinds[node.Name] = node.Kind
+	}
+	hasEdges := make(map[string]struct{}, len(lineage.Nodes))
+	for _, edge := range lineage.Edges {
+		hasEdges[edge.From] = struct{}{}
+		hasEdges[edge.To] = struct{}{}
+	}
+	rows := rowsFactory.NewRows(len(lineage.Edges))
+	for _, edge := range lineage.Edges {
+		rows.AppendStringToColumn(0, edge.From)
+		rows.AppendStringToColumn(1, kinds[edge.From])
+		rows.AppendStringToColumn(2, edge.To)
+		rows.AppendStringToColumn(3, kinds[edge.To])
+	}
+	// Nodes which nothing consumes from and which consume from nothing have a row of their own
+	for _, node := range lineage.Nodes {
+		if _, ok := hasEdges[node.Name]; !ok {
+			rows.AppendStringToColumn(0, node.Name)
+			rows.AppendStringToColumn(1, node.Kind)
+			rows.AppendNullToColumn(2)
+			rows.AppendNullToColumn(3)
+		}
+	}
+	staticRows, err := exec.NewStaticRows([]string{"from", "from_kind", "to", "to_kind"}, rows)
+	return staticRows, errors.WithStack(err)
+}
@@ -26,8 +26,12 @@ type RawQuery struct {
 }
 
 func (r *RawQuery) String() string {
+	return tokensToString(r.Tokens)
+}
+
+func tokensToString(tokens []lexer.Token) string {
 	out := strings.Builder{}
-	for _, token := range r.Tokens {
+	for _, token := range tokens {
 		v := token.Value
 		if token.Type == parser.Lexer().Symbols()["String"] {
 			// THIS IS A HACK! Need to fix participle bug that's stripping the quotes from the raw tokens
@@ -45,8 +49,15 @@ type CreateMaterializedView struct {
 	Query             *RawQuery                            `"AS" @@`
 }
 
+// CreateView creates a view, a named query which is planned as part of the queries which use it
+type CreateView struct {
+	Name  string    `@Ident`
+	Query *RawQuery `"AS" @@`
+}
+
 type MaterializedViewOriginInformation struct {
-	InitialState string `"InitialState" "=" @String`
+	InitialState         string `"InitialState" "=" @String`
+	VersionRetentionTime string `|"VersionRetentionTime" "=" @String`
 }
 
 type ColumnDef struct {
@@ -56,6 +67,22 @@ type ColumnDef struct {
 
 	Type       common.Type `@(("VARCHAR"|"TINYINT"|"INT"|"BIGINT"|"TIMESTAMP"|"DOUBLE"|"DECIMAL"))` // Conversion done by common.Type.Capture()
 	Parameters []int       `("(" @Number ("," @Number)* ")")?`                                      // Optional parameters to the type(x [, x, ...])
+
+	// Computed is the expression a computed column of a source is evaluated from, if the column is computed
+	Computed *ComputedExpr `("AS" @@)?`
+}
+
+// ComputedExpr is the expression of a computed column, over the other columns of the source. It must be enclosed in
+// parentheses.
+type ComputedExpr struct {
+	Tokens []lexer.Token
+	Expr   *ParenTokens `@@`
+}
+
+// String returns the expression without its enclosing parentheses
+func (c *ComputedExpr) String() string {
+	expr := strings.TrimSpace(tokensToString(c.Tokens))
+	return strings.TrimSpace(expr[1 : len(expr)-1])
 }
 
 func (c *ColumnDef) ToColumnType() (common.ColumnType, error) {
@@ -98,8 +125,14 @@ func (c *ColumnDef) ToColumnType() (common.ColumnType, error) {
 }
 
 type TableOption struct {
-	PrimaryKey []string   `  "PRIMARY" "KEY" "(" @Ident ( "," @Ident )* ")"`
-	Column     *ColumnDef `| @@`
+	PrimaryKey []*KeyColumn `  "PRIMARY" "KEY" "(" @@ ( "," @@ )* ")"`
+	Column     *ColumnDef   `| @@`
+}
+
+// KeyColumn is a column of a primary key, which can be ordered ascending (the default) or descending.
+type KeyColumn struct {
+	Name string `@Ident`
+	Desc bool   `( @"DESC" | "ASC" )?`
 }
 
 type CreateSource struct {
@@ -115,18 +148,22 @@ type CreateSink struct {
 }
 
 type SourceOriginInformation struct {
-	BrokerName       string                        `"BrokerName" "=" @String`
-	TopicName        string                        `|"TopicName" "=" @String`
-	HeaderEncoding   string                        `|"HeaderEncoding" "=" @String`
-	KeyEncoding      string                        `|"KeyEncoding" "=" @String`
-	ValueEncoding    string                        `|"ValueEncoding" "=" @String`
-	IngestFilter     string                        `|"IngestFilter" "=" @String`
-	InitialState     string                        `|"InitialState" "=" @String`
-	Transient        *Boolean                      `|"Transient" "=" @Ident`
-	StartWithFirstMV *Boolean                      `|"StartWithFirstMV" "=" @Ident`
-	RetentionTime    string                        `|"RetentionTime" "=" @String`
-	ColSelectors     []*selector.ColumnSelectorAST `|"ColumnSelectors" "=" "(" (@@ ("," @@)*)? ")"`
-	Properties       []*TopicInfoProperty          `|"Properties" "=" "(" (@@ ("," @@)*)? ")"`
+	BrokerName           string                        `"BrokerName" "=" @String`
+	TopicName            []string                      `|"TopicName" "=" (@String | "(" @String ("," @String)* ")")`
+	HeaderEncoding       string                        `|"HeaderEncoding" "=" @String`
+	KeyEncoding          string                        `|"KeyEncoding" "=" @String`
+	ValueEncoding        string                        `|"ValueEncoding" "=" @String`
+	IngestFilter         string                        `|"IngestFilter" "=" @String`
+	InitialState         string                        `|"InitialState" "=" @String`
+	Transient            *Boolean                      `|"Transient" "=" @Ident`
+	StartWithFirstMV     *Boolean                      `|"StartWithFirstMV" "=" @Ident`
+	AppendOnly           *Boolean                      `|"AppendOnly" "=" @Ident`
+	ErrorPolicy          string                        `|"ErrorPolicy" "=" @String`
+	DeadLetterTopic      string                        `|"DeadLetterTopic" "=" @String`
+	RetentionTime        string                        `|"RetentionTime" "=" @String`
+	VersionRetentionTime string                        `|"VersionRetentionTime" "=" @String`
+	ColSelectors         []*selector.ColumnSelectorAST `|"ColumnSelectors" "=" "(" (@@ ("," @@)*)? ")"`
+	Properties           []*TopicInfoProperty          `|"Properties" "=" "(" (@@ ("," @@)*)? ")"`
 }
 
 type SinkTargetInformation struct {
@@ -167,21 +204,54 @@ type TopicInfoProperty struct {
 }
 
 type CreateIndex struct {
-	Name        string        `@Ident "ON"`
-	TableName   string        `@Ident`
-	ColumnNames []*ColumnName `"(" @@ ("," @@)* ")"`
+	Name               string         `@Ident "ON"`
+	TableName          string         `@Ident`
+	Columns            []*IndexColumn `"(" @@ ("," @@)* ")"`
+	IncludeColumnNames []*ColumnName  `("INCLUDE" "(" @@ ("," @@)* ")")?`
+	Where              *RawQuery      `("WHERE" @@)?`
 }
 
 type ColumnName struct {
 	Name string `@Ident`
 }
 
+// IndexColumn is a column of an index key. It's either a column of the table or an expression over the columns of
+// the table, which must be a function call or be enclosed in parentheses. It can be ordered ascending (the default) or
+// descending.
+type IndexColumn struct {
+	Expr *IndexExpr `( @@`
+	Name string     `| @Ident )`
+	Desc bool       `( @"DESC" | "ASC" )?`
+}
+
+type IndexExpr struct {
+	Tokens   []lexer.Token
+	FuncName string       `@Ident?`
+	Args     *ParenTokens `@@`
+}
+
+func (i *IndexExpr) String() string {
+	return strings.TrimSpace(tokensToString(i.Tokens))
+}
+
+// ParenTokens matches any tokens enclosed in balanced parentheses.
+type ParenTokens struct {
+	Parts []*ParenTokensPart `"(":Punct @@* ")":Punct`
+}
+
+type ParenTokensPart struct {
+	Nested *ParenTokens `  @@`
+	Tokens []string     `| !("(":Punct | ")":Punct)`
+}
+
 // Create statement.
 type Create struct {
 	MaterializedView *CreateMaterializedView `  "MATERIALIZED" "VIEW" @@`
 	Source           *CreateSource           `| "SOURCE" @@`
 	Sink             *CreateSink             `| "SINK" @@`
 	Index            *CreateIndex            `| "INDEX" @@`
+	View             *CreateView             `| "VIEW" @@`
+	Schema           string                  `| "SCHEMA" @Ident`
 }
 
 // Drop statement
@@ -189,17 +259,103 @@ type Drop struct {
 	MaterializedView bool   `(   @"MATERIALIZED" "VIEW"`
 	Source           bool   `  | @"SOURCE"`
 	Sink             bool   `  | @"SINK"`
-	Index            bool   `  | @"INDEX" )`
+	Index            bool   `  | @"INDEX"`
+	View             bool   `  | @"VIEW"`
+	Schema           bool   `  | @"SCHEMA" )`
 	Name             string `@Ident `
 	TableName        string `("ON" @Ident)?`
+	Cascade          bool   `@"CASCADE"?`
+}
+
+// Alter statement
+type Alter struct {
+	Source           *AlterSource           `(  "SOURCE" @@`
+	MaterializedView *AlterMaterializedView ` | "MATERIALIZED" "VIEW" @@`
+	Sink             *AlterSink             ` | "SINK" @@ )`
+}
+
+// AlterSource statement. A column can be added to the end of a source, dropped from it, or modified to have a wider
+// type, the source can be renamed, or the offsets it consumes from can be reset.
+type AlterSource struct {
+	Name         string        `@Ident`
+	AddColumn    *AddColumn    `(  "ADD" "COLUMN" @@`
+	DropColumn   string        ` | "DROP" "COLUMN" @Ident`
+	ModifyColumn *ColumnDef    ` | "MODIFY" "COLUMN" @@`
+	RenameTo     string        ` | "RENAME" "TO" @Ident`
+	ResetOffsets *ResetOffsets ` | "RESET" "OFFSETS" "TO" @@ )`
+}
+
+// ResetOffsets is where a source consumes from after its offsets are reset - the earliest or latest message in each
+// partition, the first message in each partition at or after a timestamp, or the given offsets of some partitions
+type ResetOffsets struct {
+	Earliest         bool               `(  @"EARLIEST"`
+	Latest           bool               ` | @"LATEST"`
+	Timestamp        string             ` | "TIMESTAMP" @String`
+	PartitionOffsets []*PartitionOffset ` | "(" @@ ("," @@)* ")" )`
+}
+
+type PartitionOffset struct {
+	Partition int32 `@Number "=" ">"`
+	Offset    int64 `@Number`
+}
+
+// AddColumn is a column added to a source, along with the selector for its value in the messages the source ingests
+type AddColumn struct {
+	Column   *ColumnDef                  `@@`
+	Selector *selector.ColumnSelectorAST `"SELECTOR" @@`
+}
+
+// AlterMaterializedView statement. The materialized view is rebuilt with the new query, then replaces the current one,
+// or the materialized view is renamed.
+type AlterMaterializedView struct {
+	Name     string    `@Ident`
+	Query    *RawQuery `(  "AS" @@`
+	RenameTo string    ` | "RENAME" "TO" @Ident )`
+}
+
+// AlterSink statement. A sink can only be renamed.
+type AlterSink struct {
+	Name     string `@Ident`
+	RenameTo string `"RENAME" "TO" @Ident`
+}
+
+// Rename returns the name of the source, materialized view or sink which is renamed and its new name, or false if the
+// statement is not a rename
+func (a *Alter) Rename() (string, string, bool) {
+	switch {
+	case a.Source != nil && a.Source.RenameTo != "":
+		return a.Source.Name, a.Source.RenameTo, true
+	case a.MaterializedView != nil && a.MaterializedView.RenameTo != "":
+		return a.MaterializedView.Name, a.MaterializedView.RenameTo, true
+	case a.Sink != nil:
+		return a.Sink.Name, a.Sink.RenameTo, true
+	default:
+		return "", "", false
+	}
 }
 
 // Show statement
 type Show struct {
-	Tables    bool   `(  @"TABLES"`
-	Schemas   bool   `| @"SCHEMAS"`
-	Indexes   bool   `| @"INDEXES" )`
-	TableName string `("ON" @Ident)?`
+	Tables            bool        `(  @"TABLES"`
+	Schemas           bool        `| @"SCHEMAS"`
+	Indexes           bool        `| @"INDEXES"`
+	Sources           bool        `| @"SOURCES"`
+	MaterializedViews bool        `| @("MATERIALIZED" "VIEWS")`
+	Sinks             bool        `| @"SINKS"`
+	Create            *ShowCreate `| "CREATE" @@`
+	Lineage           bool        `| @"LINEAGE" )`
+	TableName         string      `("ON" @Ident)?`
+	// LineageFor restricts the lineage shown to what feeds, and is fed by, the named source, materialized view or sink
+	LineageFor string `("FOR" @Ident)?`
+}
+
+// ShowCreate shows the statement which would create a source, materialized view, sink or view as it is now
+type ShowCreate struct {
+	Source           bool   `(  @"SOURCE"`
+	MaterializedView bool   ` | @("MATERIALIZED" "VIEW")`
+	Sink             bool   ` | @"SINK"`
+	View             bool   ` | @"VIEW" )`
+	Name             string `@Ident`
 }
 
 type SourceSetMaxRate struct {
@@ -213,8 +369,28 @@ type AST struct {
 	Use              string            `(  "USE" @Ident`
 	Drop             *Drop             ` | "DROP" @@ `
 	Create           *Create           ` | "CREATE" @@ `
+	Alter            *Alter            ` | "ALTER" @@ `
 	Show             *Show             ` | "SHOW" @@ `
 	Describe         string            ` | "DESCRIBE" @Ident `
 	SourceSetMaxRate *SourceSetMaxRate ` | "SOURCE" "SET" "MAX" "RATE" @@ `
-	ResetDdl         string            ` | "RESET" "DDL" @Ident ) ';'?`
+	ResetDdl         string            ` | "RESET" "DDL" @Ident `
+	PauseSource      string            ` | "PAUSE" "SOURCE" @Ident `
+	ResumeSource     string            ` | "RESUME" "SOURCE" @Ident `
+	Analyze          string            ` | "ANALYZE" "TABLE" @Ident ) ';'?`
+}
+
+// NeedsSchema returns false for the statements which can be executed without a schema in use
+func (a *AST) NeedsSchema() bool {
+	switch {
+	case a.Use != "":
+		return false
+	case a.Show != nil && a.Show.Schemas:
+		return false
+	case a.Create != nil && a.Create.Schema != "":
+		return false
+	case a.Drop != nil && a.Drop.Schema:
+		return false
+	default:
+		return true
+	}
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/participle/v2/lexer"
@@ -38,6 +39,25 @@ func TestParse(t *testing.T) {
 				},
 			},
 		}, ""},
+		{"CreateView", `CREATE VIEW myview AS SELECT * FROM table`, &AST{
+			Create: &Create{
+				View: &CreateView{
+					Name: "myview",
+					Query: &RawQuery{
+						Tokens: []lexer.Token{
+							{Type: -6, Value: " ", Pos: lexer.Position{Offset: 21, Line: 1, Column: 22}},
+							{Type: -2, Value: "SELECT", Pos: lexer.Position{Offset: 22, Line: 1, Column: 23}},
+							{Type: -6, Value: " ", Pos: lexer.Position{Offset: 28, Line: 1, Column: 29}},
+							{Type: -5, Value: "*", Pos: lexer.Position{Offset: 29, Line: 1, Column: 30}},
+							{Type: -6, Value: " ", Pos: lexer.Position{Offset: 30, Line: 1, Column: 31}},
+							{Type: -2, Value: "FROM", Pos: lexer.Position{Offset: 31, Line: 1, Column: 32}},
+							{Type: -6, Value: " ", Pos: lexer.Position{Offset: 35, Line: 1, Column: 36}},
+							{Type: -2, Value: "table", Pos: lexer.Position{Offset: 36, Line: 1, Column: 37}},
+						},
+					},
+				},
+			},
+		}, ""},
 		{"CreateSource", `
 			create source sensor_readings(
 			sensor_id bigint,
@@ -68,11 +88,11 @@ func TestParse(t *testing.T) {
 					{Column: &ColumnDef{Pos: lexer.Position{Offset: 38, Line: 3, Column: 4}, Name: "sensor_id", Type: common.Type(3)}},
 					{Column: &ColumnDef{Pos: lexer.Position{Offset: 59, Line: 4, Column: 4}, Name: "location", Type: common.Type(6)}},
 					{Column: &ColumnDef{Pos: lexer.Position{Offset: 80, Line: 5, Column: 4}, Name: "temperature", Type: common.Type(4)}},
-					{PrimaryKey: []string{"sensor_id", "location"}},
+					{PrimaryKey: []*KeyColumn{{Name: "sensor_id"}, {Name: "location"}}},
 				},
 				OriginInformation: []*SourceOriginInformation{
 					{BrokerName: "testbroker"},
-					{TopicName: "testtopic"},
+					{TopicName: []string{"testtopic"}},
 					{HeaderEncoding: "json"},
 					{KeyEncoding: "json"},
 					{ValueEncoding: "json"},
@@ -101,6 +121,10 @@ func TestParse(t *testing.T) {
 			"DropMaterializedView", "DROP MATERIALIZED VIEW test_mv_1",
 			&AST{Drop: &Drop{MaterializedView: true, Name: "test_mv_1"}}, "",
 		},
+		{
+			"DropView", "DROP VIEW test_view_1",
+			&AST{Drop: &Drop{View: true, Name: "test_view_1"}}, "",
+		},
 		{
 			"Describe", `DESCRIBE foo`,
 			&AST{Describe: "foo"}, "",
@@ -117,6 +141,63 @@ func TestParse(t *testing.T) {
 			"ShowIndexes", `SHOW INDEXES on test_mv1`,
 			&AST{Show: &Show{Indexes: true, TableName: "test_mv1"}}, "",
 		},
+		{
+			"ShowSources", `SHOW SOURCES`,
+			&AST{Show: &Show{Sources: true}}, "",
+		},
+		{
+			"ShowMaterializedViews", `SHOW MATERIALIZED VIEWS`,
+			&AST{Show: &Show{MaterializedViews: true}}, "",
+		},
+		{
+			"ShowSinks", `SHOW SINKS`,
+			&AST{Show: &Show{Sinks: true}}, "",
+		},
+		{
+			"ShowCreateSource", `SHOW CREATE SOURCE payments`,
+			&AST{Show: &Show{Create: &ShowCreate{Source: true, Name: "payments"}}}, "",
+		},
+		{
+			"ShowCreateMaterializedView", `show create materialized view totals`,
+			&AST{Show: &Show{Create: &ShowCreate{MaterializedView: true, Name: "totals"}}}, "",
+		},
+		{
+			"ShowCreateSink", `SHOW CREATE SINK payments_out`,
+			&AST{Show: &Show{Create: &ShowCreate{Sink: true, Name: "payments_out"}}}, "",
+		},
+		{
+			"ShowCreateView", `show create view big_payments`,
+			&AST{Show: &Show{Create: &ShowCreate{View: true, Name: "big_payments"}}}, "",
+		},
+		{
+			"ShowLineage", `SHOW LINEAGE`,
+			&AST{Show: &Show{Lineage: true}}, "",
+		},
+		{
+			"ShowLineageFor", `show lineage for totals`,
+			&AST{Show: &Show{Lineage: true, LineageFor: "totals"}}, "",
+		},
+		{
+			"CreateIndexInclude", `CREATE INDEX idx ON payments(customer_id) INCLUDE (amount, status)`,
+			&AST{Create: &Create{Index: &CreateIndex{
+				Name:               "idx",
+				TableName:          "payments",
+				Columns:            []*IndexColumn{{Name: "customer_id"}},
+				IncludeColumnNames: []*ColumnName{{Name: "amount"}, {Name: "status"}},
+			}}}, "",
+		},
+		{
+			"AnalyzeTable", `ANALYZE TABLE test_mv1`,
+			&AST{Analyze: "test_mv1"}, "",
+		},
+		{
+			"PauseSource", `PAUSE SOURCE payments`,
+			&AST{PauseSource: "payments"}, "",
+		},
+		{
+			"ResumeSource", `resume source payments;`,
+			&AST{ResumeSource: "payments"}, "",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -141,3 +222,239 @@ func intRef(v int) *int {
 func stringRef(v string) *string {
 	return &v
 }
+
+func TestParseCreateFunctionalAndPartialIndex(t *testing.T) {
+	actual, err := Parse(`CREATE INDEX idx ON payments(lower(email), concat(a, '(', b), customer_id) INCLUDE (amount) WHERE status = 'PENDING'`)
+	require.NoError(t, err)
+	index := actual.Create.Index
+	require.Equal(t, "idx", index.Name)
+	require.Equal(t, "payments", index.TableName)
+	require.Equal(t, 3, len(index.Columns))
+	require.Equal(t, "lower(email)", index.Columns[0].Expr.String())
+	require.Equal(t, `concat(a, "(", b)`, index.Columns[1].Expr.String())
+	require.Nil(t, index.Columns[2].Expr)
+	require.Equal(t, "customer_id", index.Columns[2].Name)
+	require.Equal(t, []*ColumnName{{Name: "amount"}}, index.IncludeColumnNames)
+	require.Equal(t, `status = "PENDING"`, strings.TrimSpace(index.Where.String()))
+}
+
+func TestParseDescendingKeyColumns(t *testing.T) {
+	actual, err := Parse(`CREATE INDEX idx ON orders(customer_id, created_at DESC, lower(email) desc, amount ASC)`)
+	require.NoError(t, err)
+	index := actual.Create.Index
+	require.Equal(t, 4, len(index.Columns))
+	require.Equal(t, "customer_id", index.Columns[0].Name)
+	require.False(t, index.Columns[0].Desc)
+	require.Equal(t, "created_at", index.Columns[1].Name)
+	require.True(t, index.Columns[1].Desc)
+	require.Equal(t, "lower(email)", index.Columns[2].Expr.String())
+	require.True(t, index.Columns[2].Desc)
+	require.Equal(t, "amount", index.Columns[3].Name)
+	require.False(t, index.Columns[3].Desc)
+
+	actual, err = Parse(`CREATE SOURCE events(customer_id BIGINT, event_time TIMESTAMP, PRIMARY KEY (customer_id, event_time DESC)) WITH (brokername = "testbroker", topicname = "testtopic")`)
+	require.NoError(t, err)
+	require.Equal(t, []*KeyColumn{{Name: "customer_id"}, {Name: "event_time", Desc: true}}, actual.Create.Source.Options[2].PrimaryKey)
+}
+
+func TestParseVersionRetentionTime(t *testing.T) {
+	actual, err := Parse(`CREATE MATERIALIZED VIEW mv1 WITH (VersionRetentionTime = "1h") AS SELECT * FROM src1`)
+	require.NoError(t, err)
+	require.Equal(t, []*MaterializedViewOriginInformation{{VersionRetentionTime: "1h"}},
+		actual.Create.MaterializedView.OriginInformation)
+
+	actual, err = Parse(`CREATE SOURCE src1(id BIGINT, PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = "testtopic", versionretentiontime = "30m")`)
+	require.NoError(t, err)
+	require.Equal(t, "30m", actual.Create.Source.OriginInformation[2].VersionRetentionTime)
+}
+
+func TestParseErrorPolicy(t *testing.T) {
+	actual, err := Parse(`CREATE SOURCE src1(id BIGINT, PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = "testtopic", errorpolicy = "deadletter", deadlettertopic = "dlq")`)
+	require.NoError(t, err)
+	require.Equal(t, "deadletter", actual.Create.Source.OriginInformation[2].ErrorPolicy)
+	require.Equal(t, "dlq", actual.Create.Source.OriginInformation[3].DeadLetterTopic)
+}
+
+func TestParseComputedColumns(t *testing.T) {
+	actual, err := Parse(`CREATE SOURCE orders(id BIGINT, cents BIGINT, amount DECIMAL(10, 2) AS (cents / 100), name VARCHAR AS (concat(first, ' ', last)), PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = "testtopic")`)
+	require.NoError(t, err)
+	options := actual.Create.Source.Options
+	require.Equal(t, 5, len(options))
+	require.Nil(t, options[0].Column.Computed)
+	require.Nil(t, options[1].Column.Computed)
+	require.Equal(t, "amount", options[2].Column.Name)
+	require.Equal(t, []int{10, 2}, options[2].Column.Parameters)
+	require.Equal(t, "cents / 100", options[2].Column.Computed.String())
+	require.Equal(t, `concat(first, " ", last)`, options[3].Column.Computed.String())
+
+	_, err = Parse(`CREATE SOURCE orders(id BIGINT, amount BIGINT AS cents, PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = "testtopic")`)
+	require.Error(t, err)
+}
+
+func TestParseMultipleTopics(t *testing.T) {
+	actual, err := Parse(`CREATE SOURCE src1(id BIGINT, PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = ("orders_us", "orders_eu"))`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders_us", "orders_eu"}, actual.Create.Source.OriginInformation[1].TopicName)
+
+	actual, err = Parse(`CREATE SOURCE src1(id BIGINT, PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = "^orders_.*")`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"^orders_.*"}, actual.Create.Source.OriginInformation[1].TopicName)
+
+	_, err = Parse(`CREATE SOURCE src1(id BIGINT, PRIMARY KEY (id)) WITH (brokername = "testbroker", topicname = ())`)
+	require.Error(t, err)
+}
+
+func TestParseAppendOnly(t *testing.T) {
+	actual, err := Parse(`CREATE SOURCE events(kind VARCHAR, payload VARCHAR) WITH (brokername = "testbroker", topicname = "testtopic", appendonly = true, retentiontime = "1h")`)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(actual.Create.Source.Options))
+	appendOnly := actual.Create.Source.OriginInformation[2].AppendOnly
+	require.NotNil(t, appendOnly)
+	require.True(t, bool(*appendOnly))
+	require.Equal(t, "1h", actual.Create.Source.OriginInformation[3].RetentionTime)
+}
+
+func TestParseAlterSource(t *testing.T) {
+	actual, err := Parse(`ALTER SOURCE payments ADD COLUMN fee DECIMAL(10, 2) SELECTOR v.fee`)
+	require.NoError(t, err)
+	alter := actual.Alter.Source
+	require.Equal(t, "payments", alter.Name)
+	require.Equal(t, "fee", alter.AddColumn.Column.Name)
+	colType, err := alter.AddColumn.Column.ToColumnType()
+	require.NoError(t, err)
+	require.Equal(t, common.NewDecimalColumnType(10, 2), colType)
+	require.Equal(t, ".v.fee", alter.AddColumn.Selector.ToSelector().String())
+
+	actual, err = Parse(`alter source payments add column customer varchar selector meta("key").k0`)
+	require.NoError(t, err)
+	require.Equal(t, `meta("key").k0`, actual.Alter.Source.AddColumn.Selector.ToSelector().String())
+
+	actual, err = Parse(`ALTER SOURCE payments DROP COLUMN fee`)
+	require.NoError(t, err)
+	require.Equal(t, "fee", actual.Alter.Source.DropColumn)
+	require.Nil(t, actual.Alter.Source.AddColumn)
+
+	actual, err = Parse(`ALTER SOURCE payments MODIFY COLUMN amount BIGINT`)
+	require.NoError(t, err)
+	require.Equal(t, "amount", actual.Alter.Source.ModifyColumn.Name)
+	require.Equal(t, common.TypeBigInt, actual.Alter.Source.ModifyColumn.Type)
+
+	_, err = Parse(`ALTER SOURCE payments ADD COLUMN fee BIGINT`)
+	require.Error(t, err)
+}
+
+func TestParseAlterMaterializedView(t *testing.T) {
+	actual, err := Parse(`ALTER MATERIALIZED VIEW totals AS SELECT customer, SUM(amount) FROM payments GROUP BY customer`)
+	require.NoError(t, err)
+	require.Nil(t, actual.Alter.Source)
+	alter := actual.Alter.MaterializedView
+	require.Equal(t, "totals", alter.Name)
+	require.Equal(t, "SELECT customer, SUM(amount) FROM payments GROUP BY customer", strings.TrimSpace(alter.Query.String()))
+
+	_, err = Parse(`ALTER MATERIALIZED VIEW totals SELECT * FROM payments`)
+	require.Error(t, err)
+}
+
+func TestParseRename(t *testing.T) {
+	actual, err := Parse(`ALTER SOURCE payments RENAME TO payments2`)
+	require.NoError(t, err)
+	require.Equal(t, "payments2", actual.Alter.Source.RenameTo)
+	name, newName, ok := actual.Alter.Rename()
+	require.True(t, ok)
+	require.Equal(t, "payments", name)
+	require.Equal(t, "payments2", newName)
+
+	actual, err = Parse(`alter materialized view totals rename to customer_totals`)
+	require.NoError(t, err)
+	require.Nil(t, actual.Alter.MaterializedView.Query)
+	name, newName, ok = actual.Alter.Rename()
+	require.True(t, ok)
+	require.Equal(t, "totals", name)
+	require.Equal(t, "customer_totals", newName)
+
+	actual, err = Parse(`ALTER SINK payments_out RENAME TO payments_topic`)
+	require.NoError(t, err)
+	name, newName, ok = actual.Alter.Rename()
+	require.True(t, ok)
+	require.Equal(t, "payments_out", name)
+	require.Equal(t, "payments_topic", newName)
+
+	actual, err = Parse(`ALTER SOURCE payments DROP COLUMN fee`)
+	require.NoError(t, err)
+	_, _, ok = actual.Alter.Rename()
+	require.False(t, ok)
+
+	_, err = Parse(`ALTER SINK payments_out DROP COLUMN fee`)
+	require.Error(t, err)
+}
+
+func TestParseResetOffsets(t *testing.T) {
+	actual, err := Parse(`ALTER SOURCE payments RESET OFFSETS TO EARLIEST`)
+	require.NoError(t, err)
+	require.Equal(t, "payments", actual.Alter.Source.Name)
+	require.True(t, actual.Alter.Source.ResetOffsets.Earliest)
+	_, _, ok := actual.Alter.Rename()
+	require.False(t, ok)
+
+	actual, err = Parse(`alter source payments reset offsets to latest`)
+	require.NoError(t, err)
+	require.True(t, actual.Alter.Source.ResetOffsets.Latest)
+
+	actual, err = Parse(`ALTER SOURCE payments RESET OFFSETS TO TIMESTAMP '2021-04-12 09:00:00'`)
+	require.NoError(t, err)
+	require.Equal(t, "2021-04-12 09:00:00", actual.Alter.Source.ResetOffsets.Timestamp)
+
+	actual, err = Parse(`ALTER SOURCE payments RESET OFFSETS TO (0 => 100, 3 => 0)`)
+	require.NoError(t, err)
+	require.Equal(t, []*PartitionOffset{{Partition: 0, Offset: 100}, {Partition: 3, Offset: 0}},
+		actual.Alter.Source.ResetOffsets.PartitionOffsets)
+
+	_, err = Parse(`ALTER SOURCE payments RESET OFFSETS TO ()`)
+	require.Error(t, err)
+	_, err = Parse(`ALTER SOURCE payments RESET OFFSETS`)
+	require.Error(t, err)
+}
+
+func TestParseDropCascade(t *testing.T) {
+	actual, err := Parse(`DROP SOURCE payments CASCADE`)
+	require.NoError(t, err)
+	require.True(t, actual.Drop.Source)
+	require.Equal(t, "payments", actual.Drop.Name)
+	require.True(t, actual.Drop.Cascade)
+
+	actual, err = Parse(`drop materialized view totals cascade`)
+	require.NoError(t, err)
+	require.True(t, actual.Drop.MaterializedView)
+	require.True(t, actual.Drop.Cascade)
+
+	actual, err = Parse(`DROP MATERIALIZED VIEW totals`)
+	require.NoError(t, err)
+	require.False(t, actual.Drop.Cascade)
+}
+
+func TestParseSchema(t *testing.T) {
+	actual, err := Parse(`CREATE SCHEMA payments`)
+	require.NoError(t, err)
+	require.Equal(t, "payments", actual.Create.Schema)
+	require.False(t, actual.NeedsSchema())
+
+	actual, err = Parse(`drop schema payments`)
+	require.NoError(t, err)
+	require.True(t, actual.Drop.Schema)
+	require.Equal(t, "payments", actual.Drop.Name)
+	require.False(t, actual.Drop.Cascade)
+	require.False(t, actual.NeedsSchema())
+
+	actual, err = Parse(`DROP SCHEMA payments CASCADE`)
+	require.NoError(t, err)
+	require.True(t, actual.Drop.Schema)
+	require.True(t, actual.Drop.Cascade)
+
+	actual, err = Parse(`use payments`)
+	require.NoError(t, err)
+	require.False(t, actual.NeedsSchema())
+
+	actual, err = Parse(`drop source payments`)
+	require.NoError(t, err)
+	require.True(t, actual.NeedsSchema())
+}
@@ -73,6 +73,45 @@ func (s ColumnSelector) String() string {
 	return v
 }
 
+// Explodes returns true if the selector explodes an array with [*]
+func (s *ColumnSelector) Explodes() bool {
+	return s.explodeIndex() != -1
+}
+
+// ExplodedArray returns the selector for the array which the selector explodes
+func (s *ColumnSelector) ExplodedArray() ColumnSelector {
+	return ColumnSelector{MetaKey: s.MetaKey, Selector: s.Selector[:s.explodeIndex()]}
+}
+
+// SelectElement evaluates a selector which explodes an array against the element of the array at index
+func (s *ColumnSelector) SelectElement(meta map[string]interface{}, body interface{}, index int) (interface{}, error) {
+	i := s.explodeIndex()
+	sel := append(SelectorInjector{}, s.Selector...)
+	sel[i] = Path{NumberIndex: &index}
+	element := ColumnSelector{MetaKey: s.MetaKey, Selector: sel}
+	return element.Select(meta, body)
+}
+
+func (s *ColumnSelector) explodeIndex() int {
+	for i, p := range s.Selector {
+		if p.Explode {
+			return i
+		}
+	}
+	return -1
+}
+
+// NumExplodes returns the number of arrays the selector explodes with [*]
+func (s *ColumnSelector) NumExplodes() int {
+	n := 0
+	for _, p := range s.Selector {
+		if p.Explode {
+			n++
+		}
+	}
+	return n
+}
+
 type SelectorAST struct {
 	Field string       `@Ident`
 	Index []*Index     `( "[" @@ "]" )*`
@@ -81,7 +120,8 @@ type SelectorAST struct {
 
 type Index struct {
 	Number *int    `@Number |`
-	String *string `@String`
+	String *string `@String |`
+	Star   bool    `@"*"`
 }
 
 func (a *SelectorAST) ToSelector() SelectorInjector {
@@ -92,6 +132,8 @@ func (a *SelectorAST) ToSelector() SelectorInjector {
 			for _, idx := range a.Index {
 				if idx.Number != nil {
 					sel = append(sel, Path{NumberIndex: idx.Number})
+				} else if idx.Star {
+					sel = append(sel, Path{Explode: true})
 				} else {
 					sel = append(sel, Path{Field: idx.String})
 				}
@@ -107,6 +149,9 @@ type SelectorInjector []Path
 type Path struct {
 	Field       *string
 	NumberIndex *int
+	// Explode is true for [*], which selects every element of an array. A column selector which explodes an array
+	// fans a message out into a row for each element.
+	Explode bool
 }
 
 // ErrNotFound is returned when the selectorInjector references an invalid field
@@ -157,6 +202,9 @@ func ParseSelector(str string) (SelectorInjector, error) {
 func (s SelectorInjector) Select(obj interface{}) (interface{}, error) {
 	var ok bool
 	for i, token := range s {
+		if token.Explode {
+			return nil, errors.Errorf("cannot select every element of an array at %q without exploding it", s[0:i+1])
+		}
 		switch vv := obj.(type) {
 		case map[string]interface{}:
 			if token.NumberIndex != nil {
@@ -184,6 +232,9 @@ func (s SelectorInjector) Select(obj interface{}) (interface{}, error) {
 func (s SelectorInjector) Inject(obj interface{}, value interface{}) error {
 	for i := 0; i < len(s); i++ {
 		token := s[i]
+		if token.Explode {
+			return errors.NewPranaErrorf(errors.InvalidStatement, "Injectors cannot use [*]")
+		}
 		last := i == len(s)-1
 		switch vv := obj.(type) {
 		case map[string]interface{}:
@@ -363,6 +414,8 @@ func (s SelectorInjector) String() string {
 			sb.WriteRune('[')
 			sb.WriteString(strconv.Itoa(*p.NumberIndex))
 			sb.WriteRune(']')
+		} else if p.Explode {
+			sb.WriteString("[*]")
 		}
 	}
 	return sb.String()
@@ -50,6 +50,11 @@ func TestParseColumnSelector(t *testing.T) {
 			selector: `meta("key").hello.world`,
 			want:     ColumnSelector{MetaKey: stringRef("key"), Selector: newSelector("hello", "world")},
 		},
+		{
+			name:     "explode",
+			selector: `items[*].sku`,
+			want:     ColumnSelector{Selector: SelectorInjector{{Field: stringRef("items")}, {Explode: true}, {Field: stringRef("sku")}}},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -229,6 +234,38 @@ func TestSelect(t *testing.T) {
 	}
 }
 
+func TestSelectElement(t *testing.T) {
+	sel, err := ParseColumnSelector(`items[*].sku`)
+	require.NoError(t, err)
+	require.True(t, sel.Explodes())
+	require.Equal(t, 1, sel.NumExplodes())
+	require.Equal(t, "items[*].sku", sel.Selector.String())
+
+	var body interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"items":[{"sku":"a"},{"sku":"b"}]}`), &body))
+	explodedArray := sel.ExplodedArray()
+	arr, err := explodedArray.Select(nil, body)
+	require.NoError(t, err)
+	require.Equal(t, "items", explodedArray.Selector.String())
+	require.Equal(t, 2, len(arr.([]interface{})))
+	for i, sku := range []string{"a", "b"} {
+		v, err := sel.SelectElement(nil, body, i)
+		require.NoError(t, err)
+		require.Equal(t, sku, v)
+	}
+	// A selector which explodes an array can only select from one element at a time
+	_, err = sel.Select(nil, body)
+	require.Error(t, err)
+
+	sel, err = ParseColumnSelector(`items[0].sku`)
+	require.NoError(t, err)
+	require.False(t, sel.Explodes())
+
+	sel, err = ParseColumnSelector(`items[*].parts[*]`)
+	require.NoError(t, err)
+	require.Equal(t, 2, sel.NumExplodes())
+}
+
 func TestSelectProto(t *testing.T) {
 	data := &testproto.TestTypes{
 		DoubleField: 1.2,
@@ -0,0 +1,133 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+)
+
+// PauseSourceCommand pauses or resumes a source. A paused source stops consuming from Kafka on every node, and stays
+// paused across restarts until it is resumed.
+type PauseSourceCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	sourceName string
+	paused     bool
+	sourceInfo *common.SourceInfo
+}
+
+func (p *PauseSourceCommand) CommandType() DDLCommandType {
+	return DDLCommandTypePauseSource
+}
+
+func (p *PauseSourceCommand) SchemaName() string {
+	return p.schemaName
+}
+
+func (p *PauseSourceCommand) SQL() string {
+	return p.sql
+}
+
+func (p *PauseSourceCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (p *PauseSourceCommand) Cancel() {
+}
+
+func NewOriginatingPauseSourceCommand(e *Executor, schemaName string, sql string, sourceName string,
+	paused bool) *PauseSourceCommand {
+	return &PauseSourceCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		sourceName: strings.ToLower(sourceName),
+		paused:     paused,
+	}
+}
+
+func NewPauseSourceCommand(e *Executor, schemaName string, sql string) *PauseSourceCommand {
+	return &PauseSourceCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (p *PauseSourceCommand) Before() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, ok := p.e.metaController.GetSource(p.schemaName, p.sourceName); !ok {
+		return errors.NewUnknownSourceError(p.schemaName, p.sourceName)
+	}
+	return nil
+}
+
+func (p *PauseSourceCommand) OnPhase(phase int32) error {
+	if phase == 0 {
+		return p.onPhase0()
+	}
+	panic("invalid phase")
+}
+
+func (p *PauseSourceCommand) NumPhases() int {
+	return 1
+}
+
+func (p *PauseSourceCommand) onPhase0() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.sourceName == "" {
+		ast, err := parser.Parse(p.sql)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch {
+		case ast.PauseSource != "":
+			p.sourceName = strings.ToLower(ast.PauseSource)
+			p.paused = true
+		case ast.ResumeSource != "":
+			p.sourceName = strings.ToLower(ast.ResumeSource)
+		default:
+			return errors.Errorf("not a pause or resume source command %s", p.sql)
+		}
+	}
+	sourceInfo, err := p.e.metaController.SetSourcePaused(p.schemaName, p.sourceName, p.paused)
+	if err != nil {
+		return err
+	}
+	p.sourceInfo = sourceInfo
+	src, err := p.e.pushEngine.GetSource(sourceInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if p.paused {
+		return src.Pause()
+	}
+	return src.Resume()
+}
+
+func (p *PauseSourceCommand) AfterPhase(phase int32) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if phase == 0 {
+		// Only persisted once the source has been paused or resumed on every node
+		return p.e.metaController.PersistSourceOriginInfo(p.sourceInfo)
+	}
+	return nil
+}
+
+func (p *PauseSourceCommand) Cleanup() {
+}
+
+func (p *PauseSourceCommand) GetExtraData() []byte {
+	return nil
+}
@@ -0,0 +1,247 @@
+package command
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/parplan"
+)
+
+// RenameCommand renames a source, materialized view or sink. The materialized views, sinks and views whose queries use
+// the renamed table have their queries changed to use the new name. Everything is renamed in memory on every node,
+// then persisted in a single batch.
+type RenameCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	ast        *parser.Alter
+	rename     *meta.TableRename
+}
+
+func (r *RenameCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeRename
+}
+
+func (r *RenameCommand) SchemaName() string {
+	return r.schemaName
+}
+
+func (r *RenameCommand) SQL() string {
+	return r.sql
+}
+
+func (r *RenameCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (r *RenameCommand) Cancel() {
+}
+
+func NewOriginatingRenameCommand(e *Executor, schemaName string, sql string, ast *parser.Alter) *RenameCommand {
+	return &RenameCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		ast:        ast,
+	}
+}
+
+func NewRenameCommand(e *Executor, schemaName string, sql string) *RenameCommand {
+	return &RenameCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (r *RenameCommand) Before() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rename, err := r.getRename()
+	if err != nil {
+		return err
+	}
+	r.rename = rename
+	return nil
+}
+
+func (r *RenameCommand) OnPhase(phase int32) error {
+	if phase == 0 {
+		return r.onPhase0()
+	}
+	panic("invalid phase")
+}
+
+func (r *RenameCommand) NumPhases() int {
+	return 1
+}
+
+func (r *RenameCommand) onPhase0() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.rename == nil {
+		rename, err := r.getRename()
+		if err != nil {
+			return err
+		}
+		r.rename = rename
+	}
+	if err := r.e.metaController.RenameTable(r.schemaName, r.rename); err != nil {
+		return err
+	}
+	return r.e.pushEngine.RenameTable(r.rename)
+}
+
+func (r *RenameCommand) AfterPhase(phase int32) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if phase == 0 {
+		return r.e.metaController.PersistRenamedTable(r.rename)
+	}
+	return nil
+}
+
+func (r *RenameCommand) Cleanup() {
+}
+
+func (r *RenameCommand) GetExtraData() []byte {
+	return nil
+}
+
+// getRename finds the table to rename and everything which changes with it
+func (r *RenameCommand) getRename() (*meta.TableRename, error) {
+	if r.ast == nil {
+		ast, err := parser.Parse(r.sql)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if ast.Alter == nil {
+			return nil, errors.Errorf("not a rename command %s", r.sql)
+		}
+		r.ast = ast.Alter
+	}
+	name, newName, ok := r.ast.Rename()
+	if !ok {
+		return nil, errors.Errorf("not a rename command %s", r.sql)
+	}
+	name = strings.ToLower(name)
+	newName = strings.ToLower(newName)
+	schema, ok := r.e.metaController.GetSchema(r.schemaName)
+	if !ok {
+		schema = r.e.metaController.GetOrCreateSchema(r.schemaName)
+	}
+	rename := &meta.TableRename{PrevName: name}
+	switch {
+	case r.ast.Source != nil:
+		sourceInfo, ok := r.e.metaController.GetSource(r.schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownSourceError(r.schemaName, name)
+		}
+		rename.Table = &common.SourceInfo{TableInfo: renamedTableInfo(sourceInfo.TableInfo, newName),
+			OriginInfo: sourceInfo.OriginInfo}
+	case r.ast.MaterializedView != nil:
+		mvInfo, ok := r.e.metaController.GetMaterializedView(r.schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownMaterializedViewError(r.schemaName, name)
+		}
+		mv, err := r.e.pushEngine.GetMaterializedView(mvInfo.ID)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rename.Table = &common.MaterializedViewInfo{TableInfo: renamedTableInfo(mvInfo.TableInfo, newName),
+			OriginInfo: mvInfo.OriginInfo, Query: mvInfo.Query}
+		// The internal tables are named after the materialized view, so they're renamed with it
+		for _, it := range mv.InternalTables {
+			itName := newName + strings.TrimPrefix(it.Name, name)
+			rename.PrevInternalTableNames = append(rename.PrevInternalTableNames, it.Name)
+			rename.InternalTables = append(rename.InternalTables, &common.InternalTableInfo{
+				TableInfo:            renamedTableInfo(it.TableInfo, itName),
+				MaterializedViewName: newName,
+			})
+		}
+	default:
+		sinkInfo, ok := r.e.metaController.GetSink(r.schemaName, name)
+		if !ok {
+			return nil, errors.NewUnknownSinkError(r.schemaName, name)
+		}
+		rename.Table = &common.SinkInfo{TableInfo: renamedTableInfo(sinkInfo.TableInfo, newName), Name: newName,
+			Query: sinkInfo.Query, TargetInfo: sinkInfo.TargetInfo}
+	}
+	if err := r.e.metaController.ExistsTable(schema, newName); err != nil {
+		return nil, err
+	}
+	dependents, err := getRenamedDependents(schema, name, newName)
+	if err != nil {
+		return nil, err
+	}
+	rename.Dependents = dependents
+	return rename, nil
+}
+
+// renamedTableInfo returns a copy of the table info with a new name, including the infos of the indexes on it
+func renamedTableInfo(prevInfo *common.TableInfo, newName string) *common.TableInfo {
+	tableInfo := *prevInfo
+	tableInfo.Name = newName
+	if prevInfo.IndexInfos != nil {
+		tableInfo.IndexInfos = make(map[string]*common.IndexInfo, len(prevInfo.IndexInfos))
+		for indexName, prevIndexInfo := range prevInfo.IndexInfos {
+			indexInfo := *prevIndexInfo
+			indexInfo.TableName = newName
+			tableInfo.IndexInfos[indexName] = &indexInfo
+		}
+	}
+	return &tableInfo
+}
+
+// getRenamedDependents returns copies of the materialized views, sinks and views whose queries use the named table,
+// with their queries changed to use its new name
+func getRenamedDependents(schema *common.Schema, name string, newName string) ([]common.Table, error) {
+	var dependents []common.Table
+	for _, tbl := range schema.GetTables() {
+		var query string
+		switch info := tbl.(type) {
+		case *common.MaterializedViewInfo:
+			query = info.Query
+		case *common.SinkInfo:
+			query = info.Query
+		case *common.ViewInfo:
+			query = info.Query
+		default:
+			continue
+		}
+		uses, err := queryUsesTable(schema.Name, query, name)
+		if err != nil {
+			return nil, err
+		}
+		if !uses {
+			continue
+		}
+		renamedQuery, err := parplan.RenameTableInQuery(schema.Name, query, name, newName)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		switch info := tbl.(type) {
+		case *common.MaterializedViewInfo:
+			dependents = append(dependents, &common.MaterializedViewInfo{TableInfo: info.TableInfo,
+				OriginInfo: info.OriginInfo, Query: renamedQuery})
+		case *common.SinkInfo:
+			dependents = append(dependents, &common.SinkInfo{TableInfo: info.TableInfo, Name: info.Name,
+				Query: renamedQuery, TargetInfo: info.TargetInfo})
+		case *common.ViewInfo:
+			dependents = append(dependents, &common.ViewInfo{TableInfo: info.TableInfo, Query: renamedQuery})
+		}
+	}
+	return dependents, nil
+}
+
+func isRename(alter *parser.Alter) bool {
+	_, _, ok := alter.Rename()
+	return ok
+}
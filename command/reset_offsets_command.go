@@ -0,0 +1,234 @@
+package command
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/command/parser"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/kafka"
+	"github.com/squareup/pranadb/push/source"
+)
+
+// ResetOffsetsCommand resets the offsets a source consumes from in Kafka, so messages are replayed or skipped. The
+// source is stopped on every node, then the offsets of its consumer group are reset before it is started again.
+// Resetting the offsets gives the rows the source ingests a new dedup key, so replayed messages aren't discarded as
+// duplicates of the messages which were ingested before.
+type ResetOffsetsCommand struct {
+	lock       sync.Mutex
+	e          *Executor
+	schemaName string
+	sql        string
+	ast        *parser.AlterSource
+	reset      *kafka.OffsetsReset
+	source     *source.Source
+	wasRunning bool
+}
+
+func (r *ResetOffsetsCommand) CommandType() DDLCommandType {
+	return DDLCommandTypeResetOffsets
+}
+
+func (r *ResetOffsetsCommand) SchemaName() string {
+	return r.schemaName
+}
+
+func (r *ResetOffsetsCommand) SQL() string {
+	return r.sql
+}
+
+func (r *ResetOffsetsCommand) TableSequences() []uint64 {
+	return nil
+}
+
+func (r *ResetOffsetsCommand) Cancel() {
+}
+
+func NewOriginatingResetOffsetsCommand(e *Executor, schemaName string, sql string, ast *parser.AlterSource) *ResetOffsetsCommand {
+	return &ResetOffsetsCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+		ast:        ast,
+	}
+}
+
+func NewResetOffsetsCommand(e *Executor, schemaName string, sql string) *ResetOffsetsCommand {
+	return &ResetOffsetsCommand{
+		e:          e,
+		schemaName: schemaName,
+		sql:        sql,
+	}
+}
+
+func (r *ResetOffsetsCommand) Before() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sourceInfo, err := r.getSourceInfo()
+	if err != nil {
+		return err
+	}
+	// The offsets are only reset in Kafka by the originating node, so only it needs the reset
+	reset, err := getOffsetsReset(r.ast.ResetOffsets)
+	if err != nil {
+		return err
+	}
+	if reset.Kind == kafka.OffsetsResetPartitions && sourceInfo.OriginInfo.TopicName == "" {
+		// The partitions of each topic have the same ids, so it would be ambiguous which topic they're in
+		return errors.NewPranaErrorf(errors.InvalidStatement,
+			"Cannot reset the offsets of partitions of source %s.%s as it consumes from more than one topic",
+			sourceInfo.SchemaName, sourceInfo.Name)
+	}
+	if sourceInfo.OriginInfo.OffsetsResets >= source.MaxOffsetsResets {
+		// The rows consumed again could be discarded as duplicates of rows ingested before an earlier reset
+		return errors.NewPranaErrorf(errors.InvalidStatement,
+			"Cannot reset the offsets of source %s.%s as they have already been reset %d times",
+			sourceInfo.SchemaName, sourceInfo.Name, source.MaxOffsetsResets)
+	}
+	r.reset = reset
+	return nil
+}
+
+func (r *ResetOffsetsCommand) OnPhase(phase int32) error {
+	switch phase {
+	case 0:
+		return r.onPhase0()
+	case 1:
+		return r.onPhase1()
+	default:
+		panic("invalid phase")
+	}
+}
+
+func (r *ResetOffsetsCommand) NumPhases() int {
+	return 2
+}
+
+func (r *ResetOffsetsCommand) onPhase0() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sourceInfo, err := r.getSourceInfo()
+	if err != nil {
+		return err
+	}
+	src, err := r.e.pushEngine.GetSource(sourceInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	r.source = src
+	// The offsets can only be reset once nothing in the consumer group is consuming
+	r.wasRunning = src.IsRunning()
+	return src.Stop()
+}
+
+func (r *ResetOffsetsCommand) onPhase1() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	sourceInfo, err := r.e.metaController.IncrementSourceOffsetsResets(r.schemaName, strings.ToLower(r.ast.Name))
+	if err != nil {
+		return err
+	}
+	r.source.OffsetsReset(sourceInfo)
+	return r.restartSource()
+}
+
+func (r *ResetOffsetsCommand) AfterPhase(phase int32) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if phase != 0 {
+		return nil
+	}
+	// The source is now stopped on every node
+	if err := r.source.ResetOffsets(r.reset); err != nil {
+		return err
+	}
+	// We persist the source with its new number of resets before any node starts ingesting with it, so rows ingested
+	// after the reset can't be deduplicated against rows ingested before it, even after a restart
+	sourceInfo := r.source.Info()
+	originInfo := *sourceInfo.OriginInfo
+	originInfo.OffsetsResets++
+	return r.e.metaController.PersistSourceOriginInfo(&common.SourceInfo{TableInfo: sourceInfo.TableInfo,
+		OriginInfo: &originInfo})
+}
+
+func (r *ResetOffsetsCommand) Cleanup() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.restartSource(); err != nil {
+		log.Errorf("failed to restart source %+v", err)
+	}
+}
+
+func (r *ResetOffsetsCommand) restartSource() error {
+	if r.source == nil || !r.wasRunning {
+		return nil
+	}
+	r.wasRunning = false
+	return r.source.Start()
+}
+
+func (r *ResetOffsetsCommand) GetExtraData() []byte {
+	return nil
+}
+
+func (r *ResetOffsetsCommand) getSourceInfo() (*common.SourceInfo, error) {
+	if r.ast == nil {
+		ast, err := parser.Parse(r.sql)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if ast.Alter == nil || ast.Alter.Source == nil || ast.Alter.Source.ResetOffsets == nil {
+			return nil, errors.Errorf("not a reset offsets command %s", r.sql)
+		}
+		r.ast = ast.Alter.Source
+	}
+	sourceName := strings.ToLower(r.ast.Name)
+	sourceInfo, ok := r.e.metaController.GetSource(r.schemaName, sourceName)
+	if !ok {
+		return nil, errors.NewUnknownSourceError(r.schemaName, sourceName)
+	}
+	return sourceInfo, nil
+}
+
+func getOffsetsReset(ast *parser.ResetOffsets) (*kafka.OffsetsReset, error) {
+	switch {
+	case ast.Earliest:
+		return &kafka.OffsetsReset{Kind: kafka.OffsetsResetEarliest}, nil
+	case ast.Latest:
+		return &kafka.OffsetsReset{Kind: kafka.OffsetsResetLatest}, nil
+	case ast.Timestamp != "":
+		ts, err := common.NewTimestampFromString(ast.Timestamp)
+		if err != nil {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid timestamp '%s'", ast.Timestamp)
+		}
+		gt, err := ts.GoTime(time.UTC)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &kafka.OffsetsReset{Kind: kafka.OffsetsResetTimestamp, Timestamp: gt}, nil
+	default:
+		partitionOffsets := make(map[int32]int64, len(ast.PartitionOffsets))
+		for _, po := range ast.PartitionOffsets {
+			if po.Partition < 0 {
+				return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid partition %d", po.Partition)
+			}
+			if po.Offset < 0 {
+				return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid offset %d for partition %d",
+					po.Offset, po.Partition)
+			}
+			if _, ok := partitionOffsets[po.Partition]; ok {
+				return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Partition %d is reset more than once",
+					po.Partition)
+			}
+			partitionOffsets[po.Partition] = po.Offset
+		}
+		return &kafka.OffsetsReset{Kind: kafka.OffsetsResetPartitions, PartitionOffsets: partitionOffsets}, nil
+	}
+}
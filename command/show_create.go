@@ -0,0 +1,250 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/squareup/pranadb/command/parser/selector"
+	"github.com/squareup/pranadb/common"
+)
+
+// The DDL generated here is what would create the source, materialized view, sink or view as it is now, including any
+// changes made by ALTER. It's generated from the stored metadata rather than the original SQL, so options that were
+// defaulted are included and it parses to the same metadata. It's generated on a single line so it can be copied from
+// the output of the client.
+
+func showCreateSource(info *common.SourceInfo) string {
+	sb := &strings.Builder{}
+	sb.WriteString("create source ")
+	sb.WriteString(info.Name)
+	sb.WriteString("(")
+	origin := info.OriginInfo
+	first := true
+	for i, colName := range info.ColumnNames {
+		// The generated key of an append only source isn't declared
+		if info.IsAppendKey(i) {
+			continue
+		}
+		if !first {
+			sb.WriteString(", ")
+		}
+		first = false
+		sb.WriteString(fmt.Sprintf("%s %s", colName, info.ColumnTypes[i].String()))
+		if origin.IsComputed(i) {
+			sb.WriteString(fmt.Sprintf(" as (%s)", origin.ComputedColumns[i]))
+		}
+	}
+	if !origin.AppendOnly {
+		sb.WriteString(", primary key (")
+		for i, pkCol := range info.PrimaryKeyCols {
+			if i != 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(info.ColumnNames[pkCol])
+			if common.IsDesc(info.PrimaryKeyDesc, i) {
+				sb.WriteString(" desc")
+			}
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(") with (")
+	opts := &ddlOptions{}
+	opts.addString("brokername", origin.BrokerName)
+	opts.addStrings("topicname", origin.Topics())
+	opts.addString("headerencoding", origin.HeaderEncoding.String())
+	opts.addString("keyencoding", origin.KeyEncoding.String())
+	opts.addString("valueencoding", origin.ValueEncoding.String())
+	if origin.IngestFilter != "" {
+		opts.addString("ingestfilter", origin.IngestFilter)
+	}
+	if origin.InitialState != "" {
+		opts.addString("initialstate", origin.InitialState)
+	}
+	if origin.Transient {
+		opts.add("transient", "true")
+	}
+	if origin.StartWithFirstMV {
+		opts.add("startwithfirstmv", "true")
+	}
+	if origin.AppendOnly {
+		opts.add("appendonly", "true")
+	}
+	if origin.ErrorPolicy != "" {
+		opts.addString("errorpolicy", origin.ErrorPolicy)
+	}
+	if origin.DeadLetterTopic != "" {
+		opts.addString("deadlettertopic", origin.DeadLetterTopic)
+	}
+	if info.RetentionDuration != 0 {
+		opts.addString("retentiontime", formatRetentionTime(info.RetentionDuration))
+	}
+	if info.VersionRetentionDuration != 0 {
+		opts.addString("versionretentiontime", formatRetentionTime(info.VersionRetentionDuration))
+	}
+	opts.addProperties(origin.Properties)
+	// Computed columns and the generated key have no selector
+	selectors := make([]selector.ColumnSelector, 0, len(origin.ColSelectors))
+	for i, sel := range origin.ColSelectors {
+		if !origin.IsComputed(i) && !info.IsAppendKey(i) {
+			selectors = append(selectors, sel)
+		}
+	}
+	opts.addSelectors("columnselectors", selectors)
+	opts.writeTo(sb)
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func showCreateMaterializedView(info *common.MaterializedViewInfo) string {
+	sb := &strings.Builder{}
+	sb.WriteString("create materialized view ")
+	sb.WriteString(info.Name)
+	opts := &ddlOptions{}
+	if info.OriginInfo != nil && info.OriginInfo.InitialState != "" {
+		opts.addString("initialstate", info.OriginInfo.InitialState)
+	}
+	if info.VersionRetentionDuration != 0 {
+		opts.addString("versionretentiontime", formatRetentionTime(info.VersionRetentionDuration))
+	}
+	if len(opts.opts) > 0 {
+		sb.WriteString(" with (")
+		opts.writeTo(sb)
+		sb.WriteString(")")
+	}
+	sb.WriteString(" as ")
+	sb.WriteString(strings.TrimSpace(info.Query))
+	return sb.String()
+}
+
+func showCreateSink(info *common.SinkInfo) string {
+	sb := &strings.Builder{}
+	sb.WriteString("create sink ")
+	sb.WriteString(info.Name)
+	sb.WriteString(" with (")
+	target := info.TargetInfo
+	opts := &ddlOptions{}
+	opts.addString("brokername", target.BrokerName)
+	opts.addString("topicname", target.TopicName)
+	opts.add("numpartitions", strconv.Itoa(target.NumPartitions))
+	opts.add("maxbufferedmessages", strconv.Itoa(target.MaxBufferedMessages))
+	if target.EmitAfter != 0 {
+		opts.addString("emitafter", formatEmitAfter(target.EmitAfter))
+	}
+	opts.addString("headerencoding", target.HeaderEncoding.String())
+	opts.addString("keyencoding", target.KeyEncoding.String())
+	opts.addString("valueencoding", target.ValueEncoding.String())
+	opts.addProperties(target.Properties)
+	opts.addSelectors("injectors", target.Injectors)
+	opts.writeTo(sb)
+	sb.WriteString(") as ")
+	sb.WriteString(strings.TrimSpace(info.Query))
+	return sb.String()
+}
+
+func showCreateView(info *common.ViewInfo) string {
+	return fmt.Sprintf("create view %s as %s", info.Name, strings.TrimSpace(info.Query))
+}
+
+type ddlOptions struct {
+	opts []string
+}
+
+func (d *ddlOptions) add(name string, value string) {
+	d.opts = append(d.opts, fmt.Sprintf("%s = %s", name, value))
+}
+
+func (d *ddlOptions) addString(name string, value string) {
+	d.add(name, quoteDDLString(value))
+}
+
+// addStrings adds an option which is one string, or a list of them
+func (d *ddlOptions) addStrings(name string, values []string) {
+	if len(values) == 1 {
+		d.addString(name, values[0])
+		return
+	}
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quoteDDLString(value)
+	}
+	d.add(name, "("+strings.Join(quoted, ", ")+")")
+}
+
+func (d *ddlOptions) addProperties(props map[string]string) {
+	if len(props) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	sProps := make([]string, len(keys))
+	for i, key := range keys {
+		sProps[i] = fmt.Sprintf("%s = %s", quoteDDLString(key), quoteDDLString(props[key]))
+	}
+	d.add("properties", "("+strings.Join(sProps, ", ")+")")
+}
+
+func (d *ddlOptions) addSelectors(name string, selectors []selector.ColumnSelector) {
+	sSelectors := make([]string, len(selectors))
+	for i, sel := range selectors {
+		sSelectors[i] = formatDDLSelector(sel)
+	}
+	d.add(name, "("+strings.Join(sSelectors, ", ")+")")
+}
+
+func (d *ddlOptions) writeTo(sb *strings.Builder) {
+	sb.WriteString(strings.Join(d.opts, ", "))
+}
+
+// formatDDLSelector formats a column selector as it's written in a column selector or injector list. The String method
+// of the selector can't be used as it prefixes a selector on the message body with a dot.
+func formatDDLSelector(sel selector.ColumnSelector) string {
+	if sel.MetaKey != nil {
+		return sel.String()
+	}
+	return sel.Selector.String()
+}
+
+// quoteDDLString quotes a string option. Strings can't contain escapes, so it's quoted with single quotes if it
+// contains a double quote.
+func quoteDDLString(s string) string {
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
+
+// formatRetentionTime formats a retention time, which unlike emit after can be given in days
+func formatRetentionTime(d time.Duration) string {
+	day := 24 * time.Hour
+	if d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return formatDDLDuration(d)
+}
+
+// formatEmitAfter formats an emit after, which unlike a retention time can be given in milliseconds
+func formatEmitAfter(d time.Duration) string {
+	if d%time.Second != 0 && d%time.Millisecond == 0 {
+		return fmt.Sprintf("%dms", d/time.Millisecond)
+	}
+	return formatDDLDuration(d)
+}
+
+// formatDDLDuration formats a duration in the largest whole unit that can be parsed back as a retention time or
+// emit after, falling back to fractional seconds.
+func formatDDLDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+	}
+}
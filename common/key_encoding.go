@@ -80,6 +80,19 @@ func EncodeKey(key Key, colTypes []ColumnType, keyColIndexes []int, buffer []byt
 	return buffer, nil
 }
 
+// EncodeOrderedKeyElement encodes a key element which may be ordered descending.
+func EncodeOrderedKeyElement(value interface{}, colType ColumnType, desc bool, buffer []byte) ([]byte, error) {
+	start := len(buffer)
+	buffer, err := EncodeKeyElement(value, colType, buffer)
+	if err != nil {
+		return nil, err
+	}
+	if desc {
+		InvertBytes(buffer[start:])
+	}
+	return buffer, nil
+}
+
 func EncodeKeyElement(value interface{}, colType ColumnType, buffer []byte) ([]byte, error) {
 	switch colType.Type {
 	case TypeTinyInt, TypeInt, TypeBigInt:
@@ -127,20 +140,33 @@ func EncodeKeyElement(value interface{}, colType ColumnType, buffer []byte) ([]b
 }
 
 func EncodeKeyCols(row *Row, colIndexes []int, colTypes []ColumnType, buffer []byte) ([]byte, error) {
-	for _, colIndex := range colIndexes {
+	return EncodeOrderedKeyCols(row, colIndexes, nil, colTypes, buffer)
+}
+
+// EncodeOrderedKeyCols encodes key columns where some may be ordered descending. desc holds true for each of the
+// colIndexes which is descending, or is nil if they are all ascending.
+func EncodeOrderedKeyCols(row *Row, colIndexes []int, desc []bool, colTypes []ColumnType, buffer []byte) ([]byte, error) {
+	for i, colIndex := range colIndexes {
 		colType := colTypes[colIndex]
+		start := len(buffer)
 		var err error
 		buffer, err = EncodeKeyCol(row, colIndex, colType, buffer)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		if IsDesc(desc, i) {
+			InvertBytes(buffer[start:])
+		}
 	}
 	return buffer, nil
 }
 
-func EncodeIndexKeyCols(row *Row, colIndexes []int, colTypes []ColumnType, buffer []byte) ([]byte, error) {
-	for _, colIndex := range colIndexes {
+// EncodeIndexKeyCols encodes nullable key columns, where some may be ordered descending. desc holds true for each of
+// the colIndexes which is descending, or is nil if they are all ascending.
+func EncodeIndexKeyCols(row *Row, colIndexes []int, desc []bool, colTypes []ColumnType, buffer []byte) ([]byte, error) {
+	for i, colIndex := range colIndexes {
 		colType := colTypes[colIndex]
+		start := len(buffer)
 		var err error
 		if row.IsNull(colIndex) {
 			buffer = append(buffer, 0)
@@ -151,10 +177,26 @@ func EncodeIndexKeyCols(row *Row, colIndexes []int, colTypes []ColumnType, buffe
 				return nil, errors.WithStack(err)
 			}
 		}
+		if IsDesc(desc, i) {
+			InvertBytes(buffer[start:])
+		}
 	}
 	return buffer, nil
 }
 
+// IsDesc returns true if the key column at the position is ordered descending.
+func IsDesc(desc []bool, pos int) bool {
+	return desc != nil && desc[pos]
+}
+
+// InvertBytes inverts the bits of an encoded key element in place. As key elements are either fixed length or
+// self-delimiting this reverses their order so the element can be ordered descending.
+func InvertBytes(buffer []byte) {
+	for i, b := range buffer {
+		buffer[i] = ^b
+	}
+}
+
 func EncodeKeyCol(row *Row, colIndex int, colType ColumnType, buffer []byte) ([]byte, error) {
 	// Key columns must be stored in big-endian so whole key can be compared byte-wise
 	switch colType.Type {
@@ -188,11 +230,11 @@ func EncodeKeyCol(row *Row, colIndex int, colType ColumnType, buffer []byte) ([]
 	return buffer, nil
 }
 
-func DecodeIndexOrPKCols(buffer []byte, offset int, pk bool, indexOrPKColTypes []ColumnType, indexOrPKOutputCols []int, rows *Rows) (int, error) {
+func DecodeIndexOrPKCols(buffer []byte, offset int, pk bool, indexOrPKColTypes []ColumnType, desc []bool, indexOrPKOutputCols []int, rows *Rows) (int, error) {
 	for i, outputCol := range indexOrPKOutputCols {
 		colType := indexOrPKColTypes[i]
 		var err error
-		offset, err = DecodeIndexOrPKCol(buffer, offset, colType, outputCol, pk, rows)
+		offset, err = DecodeIndexOrPKCol(buffer, offset, colType, IsDesc(desc, i), outputCol, pk, rows)
 		if err != nil {
 			return 0, err
 		}
@@ -200,7 +242,17 @@ func DecodeIndexOrPKCols(buffer []byte, offset int, pk bool, indexOrPKColTypes [
 	return offset, nil
 }
 
-func DecodeIndexOrPKCol(buffer []byte, offset int, colType ColumnType, outputColIndex int, pkCol bool, rows *Rows) (int, error) {
+func DecodeIndexOrPKCol(buffer []byte, offset int, colType ColumnType, desc bool, outputColIndex int, pkCol bool, rows *Rows) (int, error) {
+	if desc {
+		// We decode a copy of the rest of the buffer with the bits inverted back
+		inverted := CopyByteSlice(buffer[offset:])
+		InvertBytes(inverted)
+		decoded, err := DecodeIndexOrPKCol(inverted, 0, colType, false, outputColIndex, pkCol, rows)
+		if err != nil {
+			return 0, err
+		}
+		return offset + decoded, nil
+	}
 	isNull := false
 	if !pkCol {
 		isNull = buffer[offset] == 0
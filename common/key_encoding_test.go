@@ -163,3 +163,43 @@ func checkLessThan(t *testing.T, b1, b2 []byte) {
 	diff := bytes.Compare(b1, b2)
 	require.Equal(t, -1, diff, "expected %x < %x", b1, b2)
 }
+
+func TestKeyEncodeDescending(t *testing.T) {
+	colTypes := []ColumnType{BigIntColumnType, VarcharColumnType}
+	rf := NewRowsFactory(colTypes)
+	rows := rf.NewRows(4)
+	for _, val := range []string{"a", "ab", "b"} {
+		rows.AppendInt64ToColumn(0, 1)
+		rows.AppendStringToColumn(1, val)
+	}
+	rows.AppendInt64ToColumn(0, 1)
+	rows.AppendNullToColumn(1)
+	desc := []bool{false, true}
+	var keys [][]byte
+	for i := 0; i < rows.RowCount(); i++ {
+		row := rows.GetRow(i)
+		key, err := EncodeIndexKeyCols(&row, []int{0, 1}, desc, colTypes, nil)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	// The descending column orders the keys in reverse, with nulls last
+	checkLessThan(t, keys[2], keys[1])
+	checkLessThan(t, keys[1], keys[0])
+	checkLessThan(t, keys[0], keys[3])
+
+	decoded := rf.NewRows(rows.RowCount())
+	for _, key := range keys {
+		offset, err := DecodeIndexOrPKCols(key, 0, false, colTypes, desc, []int{0, 1}, decoded)
+		require.NoError(t, err)
+		require.Equal(t, len(key), offset)
+	}
+	for i := 0; i < rows.RowCount(); i++ {
+		expected := rows.GetRow(i)
+		actual := decoded.GetRow(i)
+		require.Equal(t, expected.GetInt64(0), actual.GetInt64(0))
+		require.Equal(t, expected.IsNull(1), actual.IsNull(1))
+		if !expected.IsNull(1) {
+			require.Equal(t, expected.GetString(1), actual.GetString(1))
+		}
+	}
+}
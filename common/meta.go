@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -208,10 +209,13 @@ func parseTimestampType(sargtype string) (ColumnType, error) {
 }
 
 type TableInfo struct {
-	ID                uint64
-	SchemaName        string
-	Name              string
-	PrimaryKeyCols    []int
+	ID             uint64
+	SchemaName     string
+	Name           string
+	PrimaryKeyCols []int
+	// PrimaryKeyDesc holds true for each of the PrimaryKeyCols which is ordered descending, or is nil if they are all
+	// ascending
+	PrimaryKeyDesc    []bool
 	ColumnNames       []string
 	ColumnTypes       []ColumnType
 	IndexInfos        map[string]*IndexInfo
@@ -219,7 +223,12 @@ type TableInfo struct {
 	Internal          bool
 	RetentionDuration time.Duration
 	RowTimeIndexID    uint64
-	pKColsSet         map[int]struct{}
+	// VersionRetentionDuration is how long prior versions of rows are kept so the table can be queried as of a time in
+	// the past, or zero if prior versions are not kept
+	VersionRetentionDuration time.Duration
+	// VersionTableID is the id of the table which holds the prior versions of rows
+	VersionTableID uint64
+	pKColsSet      map[int]struct{}
 }
 
 func NewTableInfo(id uint64, schemaName string, name string, pkCols []int, colNames []string, colTypes []ColumnType,
@@ -257,44 +266,88 @@ func (t *TableInfo) IsPrimaryKeyCol(colIndex int) bool {
 }
 
 type IndexInfo struct {
-	SchemaName   string
-	ID           uint64
-	TableName    string
-	Name         string
-	IndexCols    []int
+	SchemaName string
+	ID         uint64
+	TableName  string
+	Name       string
+	IndexCols  []int
+	// IncludeCols are columns which are not part of the index key but whose values are stored in the index entry, so
+	// queries which need them can be satisfied from the index without looking up the row in the table
+	IncludeCols []int
+	// IndexColsDesc holds true for each of the IndexCols which is ordered descending, or is nil if they are all
+	// ascending
+	IndexColsDesc []bool
+	// IndexExprs holds, for a functional index, the SQL of the expression whose value is indexed for each column of the
+	// index key, or the empty string where the key column is a plain column of the table. The IndexCols entry for an
+	// expression is -1
+	IndexExprs []string
+	// IndexExprTypes holds the types of the values of the expressions in IndexExprs, at the same positions
+	IndexExprTypes []ColumnType
+	// Predicate is the SQL of the WHERE clause of a partial index - only rows which match it are indexed
+	Predicate    string
 	indexColsSet map[int]struct{}
 }
 
-func NewIndexInfo(schemaName string, id uint64, tableName string, name string, indexCols []int) *IndexInfo {
+func NewIndexInfo(schemaName string, id uint64, tableName string, name string, indexCols []int, includeCols []int) *IndexInfo {
 	ii := &IndexInfo{
-		SchemaName: schemaName,
-		ID:         id,
-		TableName:  tableName,
-		Name:       name,
-		IndexCols:  indexCols,
+		SchemaName:  schemaName,
+		ID:          id,
+		TableName:   tableName,
+		Name:        name,
+		IndexCols:   indexCols,
+		IncludeCols: includeCols,
 	}
 	ii.CalcColsSet()
 	return ii
 }
 
 func (i *IndexInfo) CalcColsSet() {
-	i.indexColsSet = make(map[int]struct{}, len(i.IndexCols))
+	i.indexColsSet = make(map[int]struct{}, len(i.IndexCols)+len(i.IncludeCols))
 	for _, col := range i.IndexCols {
+		if col != -1 {
+			i.indexColsSet[col] = struct{}{}
+		}
+	}
+	for _, col := range i.IncludeCols {
 		i.indexColsSet[col] = struct{}{}
 	}
 }
 
+// ContainsColIndex returns true if the value of the column is stored in the index, either as part of the key or as an
+// included column
 func (i *IndexInfo) ContainsColIndex(colIndex int) bool {
 	_, ok := i.indexColsSet[colIndex]
 	return ok
 }
 
+// HasIndexExprs returns true if any of the columns of the index key are expressions
+func (i *IndexInfo) HasIndexExprs() bool {
+	return len(i.IndexExprs) > 0
+}
+
+// KeyColTypes returns the types of the columns of the index key, given the types of the columns of the table
+func (i *IndexInfo) KeyColTypes(tableColTypes []ColumnType) []ColumnType {
+	colTypes := make([]ColumnType, len(i.IndexCols))
+	for j, indexCol := range i.IndexCols {
+		if indexCol == -1 {
+			colTypes[j] = i.IndexExprTypes[j]
+		} else {
+			colTypes[j] = tableColTypes[indexCol]
+		}
+	}
+	return colTypes
+}
+
 type Schema struct {
 	// Schema can be mutated from different goroutines so we need to lock to protect access to it's maps
-	lock   sync.RWMutex
-	Name   string
-	tables map[string]Table
-	sinks  map[string]*SinkInfo
+	lock sync.RWMutex
+	Name string
+	// Created is true if the schema was created with CREATE SCHEMA. A created schema is persisted and is only removed
+	// by DROP SCHEMA, otherwise a schema is removed once it has no tables.
+	Created bool
+	tables  map[string]Table
+	sinks   map[string]*SinkInfo
+	stats   map[string]*TableStats
 }
 
 func NewSchema(name string) *Schema {
@@ -302,6 +355,7 @@ func NewSchema(name string) *Schema {
 		Name:   name,
 		tables: make(map[string]Table),
 		sinks:  make(map[string]*SinkInfo),
+		stats:  make(map[string]*TableStats),
 	}
 }
 
@@ -357,6 +411,62 @@ func (s *Schema) DeleteTable(name string) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	delete(s.tables, name)
+	delete(s.stats, name)
+}
+
+// ReplaceTable replaces the table with the given name. Any statistics for the table are removed as they were collected
+// for the table it replaces.
+func (s *Schema) ReplaceTable(name string, table Table) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tables[name] = table
+	delete(s.stats, name)
+}
+
+// RenameTable replaces the table with the given name with the same table under a new name. Any statistics for the
+// table are kept.
+func (s *Schema) RenameTable(name string, newName string, table Table) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.tables, name)
+	s.tables[newName] = table
+	if stats, ok := s.stats[name]; ok {
+		delete(s.stats, name)
+		s.stats[newName] = stats
+	}
+}
+
+// GetTables returns the tables in the schema, including views, ordered by name
+func (s *Schema) GetTables() []Table {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	names := make([]string, 0, len(s.tables))
+	for name := range s.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tables := make([]Table, len(names))
+	for i, name := range names {
+		tables[i] = s.tables[name]
+	}
+	return tables
+}
+
+func (s *Schema) PutTableStats(tableName string, stats *TableStats) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.tables[tableName]; !ok {
+		return errors.Errorf("table with Name %s does not exist in Schema %s", tableName, s.Name)
+	}
+	s.stats[tableName] = stats
+	return nil
+}
+
+func (s *Schema) GetTableStats(tableName string) (*TableStats, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	stats, ok := s.stats[tableName]
+	return stats, ok
 }
 
 func (s *Schema) LenTables() int {
@@ -365,17 +475,59 @@ func (s *Schema) LenTables() int {
 	return len(s.tables)
 }
 
+// GetAllTableInfos returns the infos of the tables in the schema which can be scanned. Views aren't included, as they
+// have no rows of their own.
 func (s *Schema) GetAllTableInfos() map[string]*TableInfo {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 	lt := len(s.tables)
 	infos := make(map[string]*TableInfo, lt)
 	for name, tab := range s.tables {
+		if _, ok := tab.(*ViewInfo); ok {
+			continue
+		}
 		infos[name] = tab.GetTableInfo()
 	}
 	return infos
 }
 
+// GetViews returns the views in the schema, ordered by name
+func (s *Schema) GetViews() []*ViewInfo {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var views []*ViewInfo
+	for _, tab := range s.tables {
+		if view, ok := tab.(*ViewInfo); ok {
+			views = append(views, view)
+		}
+	}
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].Name < views[j].Name
+	})
+	return views
+}
+
+// CopyWithTable returns a copy of the schema in which the table with the given name is replaced. It's used to plan
+// queries against a table as it will be once it has been altered.
+func (s *Schema) CopyWithTable(name string, table Table) *Schema {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	cp := NewSchema(s.Name)
+	for tableName, tab := range s.tables {
+		cp.tables[tableName] = tab
+	}
+	for sinkName, sink := range s.sinks {
+		cp.sinks[sinkName] = sink
+	}
+	for tableName, stats := range s.stats {
+		if tableName != name {
+			cp.stats[tableName] = stats
+		}
+	}
+	cp.tables[name] = table
+	return cp
+}
+
 func (s *Schema) Equal(other *Schema) bool {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
@@ -393,6 +545,11 @@ func (i *SourceInfo) String() string {
 	return "source_" + i.TableInfo.String()
 }
 
+// IsAppendKey returns true if the column is the key generated for each row ingested by an append only source
+func (i *SourceInfo) IsAppendKey(colIndex int) bool {
+	return i.OriginInfo.AppendOnly && colIndex == i.PrimaryKeyCols[0]
+}
+
 type Table interface {
 	GetTableInfo() *TableInfo
 }
@@ -406,21 +563,91 @@ func (i *MetaTableInfo) String() string {
 	return "meta_" + i.TableInfo.String()
 }
 
+// VirtualTableInfo describes a system table whose rows aren't stored, they're generated from the state of the node
+// leading each shard when the table is queried.
+type VirtualTableInfo struct {
+	*TableInfo
+}
+
+func (i *VirtualTableInfo) String() string {
+	return "virtual_" + i.TableInfo.String()
+}
+
 type SourceOriginInfo struct {
-	BrokerName       string
-	TopicName        string
-	KeyEncoding      KafkaEncoding
-	ValueEncoding    KafkaEncoding
-	HeaderEncoding   KafkaEncoding
-	ColSelectors     []selector.ColumnSelector
+	BrokerName string
+	// TopicName is the topic the source consumes from. It's empty if the source consumes from more than one topic, in
+	// which case they're in TopicNames.
+	TopicName string
+	// TopicNames has the topics a source which consumes from more than one topic consumes from. A topic name starting
+	// with ^ is a regular expression, and the source consumes from every topic whose name matches it, including topics
+	// created after the source.
+	TopicNames     []string
+	KeyEncoding    KafkaEncoding
+	ValueEncoding  KafkaEncoding
+	HeaderEncoding KafkaEncoding
+	ColSelectors   []selector.ColumnSelector
+	// ComputedColumns has the expression of each column which is computed from the other columns when a row is
+	// ingested, or "" for a column selected from the message. It's nil if the source has no computed columns. The
+	// column selector of a computed column is empty.
+	ComputedColumns  []string
 	Properties       map[string]string
 	IngestFilter     string
 	InitialState     string
 	ConsumerGroupID  string
 	Transient        bool
 	StartWithFirstMV bool
+	// AppendOnly is true if every row ingested by the source is a new row, rather than an upsert. The primary key of
+	// an append only source is a hidden column, AppendKeyColumnName, whose value is generated when a row is ingested.
+	AppendOnly bool
+	// Paused is true if the source has been paused with PAUSE SOURCE, in which case it does not consume from Kafka
+	// until it is resumed, even after a restart
+	Paused bool
+	// ErrorPolicy decides what happens to a message which can't be ingested, or is empty for ErrorPolicyFail
+	ErrorPolicy string
+	// DeadLetterTopic is the topic rejected messages are also sent to with ErrorPolicyDeadLetter, if any
+	DeadLetterTopic string
+	// OffsetsResets is the number of times the offsets of the source have been reset. It is part of the dedup key of
+	// ingested rows, so messages which are consumed again after a reset aren't discarded as duplicates
+	OffsetsResets uint32
+}
+
+// AppendKeyColumnName is the name of the hidden primary key column of an append only source
+const AppendKeyColumnName = "__append_id"
+
+// IsComputed returns true if the column is computed from the other columns when a row is ingested
+func (s *SourceOriginInfo) IsComputed(colIndex int) bool {
+	return s.ComputedColumns != nil && s.ComputedColumns[colIndex] != ""
+}
+
+// Topics returns the topics, or topic patterns, the source consumes from
+func (s *SourceOriginInfo) Topics() []string {
+	if s.TopicName != "" {
+		return []string{s.TopicName}
+	}
+	return s.TopicNames
 }
 
+// TopicsString returns the topics the source consumes from, for display
+func (s *SourceOriginInfo) TopicsString() string {
+	return strings.Join(s.Topics(), ",")
+}
+
+// IsTopicPattern returns true if the topic name is a regular expression matching the names of topics to consume from
+func IsTopicPattern(topicName string) bool {
+	return strings.HasPrefix(topicName, "^")
+}
+
+// The error policies of a source
+const (
+	// ErrorPolicyFail stops the source when a message can't be ingested
+	ErrorPolicyFail = "fail"
+	// ErrorPolicySkip drops messages which can't be ingested
+	ErrorPolicySkip = "skip"
+	// ErrorPolicyDeadLetter records messages which can't be ingested in the dead letters table, and the dead letter
+	// topic if there is one
+	ErrorPolicyDeadLetter = "deadletter"
+)
+
 type SinkTargetInfo struct {
 	BrokerName          string
 	TopicName           string
@@ -440,16 +667,18 @@ type KafkaEncoding struct {
 }
 
 var (
-	KafkaEncodingUnknown     = KafkaEncoding{Encoding: EncodingUnknown}
-	KafkaEncodingRaw         = KafkaEncoding{Encoding: EncodingRaw}
-	KafkaEncodingCSV         = KafkaEncoding{Encoding: EncodingCSV}
-	KafkaEncodingJSON        = KafkaEncoding{Encoding: EncodingJSON}
-	KafkaEncodingFloat32BE   = KafkaEncoding{Encoding: EncodingFloat32BE}
-	KafkaEncodingFloat64BE   = KafkaEncoding{Encoding: EncodingFloat64BE}
-	KafkaEncodingInt32BE     = KafkaEncoding{Encoding: EncodingInt32BE}
-	KafkaEncodingInt64BE     = KafkaEncoding{Encoding: EncodingInt64BE}
-	KafkaEncodingInt16BE     = KafkaEncoding{Encoding: EncodingInt16BE}
-	KafkaEncodingStringBytes = KafkaEncoding{Encoding: EncodingStringBytes}
+	KafkaEncodingUnknown      = KafkaEncoding{Encoding: EncodingUnknown}
+	KafkaEncodingRaw          = KafkaEncoding{Encoding: EncodingRaw}
+	KafkaEncodingCSV          = KafkaEncoding{Encoding: EncodingCSV}
+	KafkaEncodingJSON         = KafkaEncoding{Encoding: EncodingJSON}
+	KafkaEncodingFloat32BE    = KafkaEncoding{Encoding: EncodingFloat32BE}
+	KafkaEncodingFloat64BE    = KafkaEncoding{Encoding: EncodingFloat64BE}
+	KafkaEncodingInt32BE      = KafkaEncoding{Encoding: EncodingInt32BE}
+	KafkaEncodingInt64BE      = KafkaEncoding{Encoding: EncodingInt64BE}
+	KafkaEncodingInt16BE      = KafkaEncoding{Encoding: EncodingInt16BE}
+	KafkaEncodingStringBytes  = KafkaEncoding{Encoding: EncodingStringBytes}
+	KafkaEncodingDebeziumJSON = KafkaEncoding{Encoding: EncodingDebeziumJSON}
+	KafkaEncodingAvro         = KafkaEncoding{Encoding: EncodingAvro}
 )
 
 type Encoding int
@@ -466,6 +695,11 @@ const (
 	EncodingInt64BE
 	EncodingInt16BE
 	EncodingStringBytes
+	EncodingDebeziumJSON              // Debezium change events, in JSON
+	EncodingAvro                      // Avro, in the schema registry wire format
+	EncodingProtobufConfluent         // Protobuf, in the schema registry wire format
+	EncodingDebeziumAvro              // Debezium change events, in Avro in the schema registry wire format
+	EncodingDebeziumProtobufConfluent // Debezium change events, in protobuf in the schema registry wire format
 )
 
 // KafkaEncodingFromString decodes an encoding and an optional schema name from the string,
@@ -503,11 +737,93 @@ func EncodingFormatFromString(str string) Encoding {
 		return EncodingInt16BE
 	case "stringbytes":
 		return EncodingStringBytes
+	case "debezium-json":
+		return EncodingDebeziumJSON
+	case "avro":
+		return EncodingAvro
+	case "protobuf-confluent":
+		return EncodingProtobufConfluent
+	case "debezium-avro":
+		return EncodingDebeziumAvro
+	case "debezium-protobuf-confluent":
+		return EncodingDebeziumProtobufConfluent
 	default:
 		return EncodingUnknown
 	}
 }
 
+// String returns the name of the encoding, as understood by EncodingFormatFromString
+func (e Encoding) String() string {
+	switch e {
+	case EncodingJSON:
+		return "json"
+	case EncodingProtobuf:
+		return "protobuf"
+	case EncodingRaw:
+		return "raw"
+	case EncodingCSV:
+		return "csv"
+	case EncodingFloat32BE:
+		return "float32be"
+	case EncodingFloat64BE:
+		return "float64be"
+	case EncodingInt32BE:
+		return "int32be"
+	case EncodingInt64BE:
+		return "int64be"
+	case EncodingInt16BE:
+		return "int16be"
+	case EncodingStringBytes:
+		return "stringbytes"
+	case EncodingDebeziumJSON:
+		return "debezium-json"
+	case EncodingAvro:
+		return "avro"
+	case EncodingProtobufConfluent:
+		return "protobuf-confluent"
+	case EncodingDebeziumAvro:
+		return "debezium-avro"
+	case EncodingDebeziumProtobufConfluent:
+		return "debezium-protobuf-confluent"
+	default:
+		return "unknown"
+	}
+}
+
+// IsChangeEvent returns true if a message in the encoding is a change event, which upserts or deletes a row, rather than
+// the row itself
+func (e Encoding) IsChangeEvent() bool {
+	switch e {
+	case EncodingDebeziumJSON, EncodingDebeziumAvro, EncodingDebeziumProtobufConfluent:
+		return true
+	default:
+		return false
+	}
+}
+
+// RowEncoding returns the encoding of the rows held in the change events of a change event encoding, or the encoding
+// itself if it isn't a change event encoding
+func (e Encoding) RowEncoding() Encoding {
+	switch e {
+	case EncodingDebeziumJSON:
+		return EncodingJSON
+	case EncodingDebeziumAvro:
+		return EncodingAvro
+	case EncodingDebeziumProtobufConfluent:
+		return EncodingProtobufConfluent
+	default:
+		return e
+	}
+}
+
+// String returns the encoding in the format understood by KafkaEncodingFromString
+func (k KafkaEncoding) String() string {
+	if k.SchemaName == "" {
+		return k.Encoding.String()
+	}
+	return fmt.Sprintf("%s:%s", k.Encoding, k.SchemaName)
+}
+
 type MaterializedViewInfo struct {
 	*TableInfo
 	OriginInfo *MaterializedViewOriginInfo
@@ -528,6 +844,17 @@ func (i *MaterializedViewInfo) String() string {
 	return "mv_" + i.TableInfo.String()
 }
 
+// ViewInfo describes a view. A view has no rows of its own, its query is planned as part of each query which uses it.
+// Its TableInfo holds the columns of the rows returned by its query.
+type ViewInfo struct {
+	*TableInfo
+	Query string
+}
+
+func (i *ViewInfo) String() string {
+	return "view_" + i.TableInfo.String()
+}
+
 type SinkInfo struct {
 	*TableInfo
 	Name       string
@@ -0,0 +1,30 @@
+package common
+
+// TableStats holds the statistics collected for a table by ANALYZE TABLE. They are used by the planner to estimate
+// the number of rows a scan will return, so it can choose between a table scan and a secondary index by cost.
+type TableStats struct {
+	TableID  uint64
+	RowCount int64
+	// Columns holds the stats for each visible column, keyed by column name
+	Columns map[string]*HistogramStats
+	// Indexes holds the stats for each secondary index, keyed by index id
+	Indexes map[uint64]*HistogramStats
+}
+
+// HistogramStats is an equi-depth histogram over the values of a column, or over the encoded keys of an index.
+// Bucket bounds are stored in memcomparable key encoding so they can be decoded back into values of any column type.
+type HistogramStats struct {
+	NDV        int64
+	NullCount  int64
+	TotColSize int64
+	Buckets    []StatsBucket
+}
+
+type StatsBucket struct {
+	Lower []byte
+	Upper []byte
+	// Count is the cumulative count of all values in this bucket and all previous buckets
+	Count int64
+	// Repeat is the number of times the upper bound occurs
+	Repeat int64
+}
@@ -15,5 +15,14 @@ const (
 	ForwardDedupTableID         = 11
 	ShardLeaderTableID          = 12
 	DummyTableID                = 13
+	TableStatsTableID           = 14
+	SourcesTableID              = 15 // SourcesTableID, SinksTableID and ShardsTableID are virtual, so nothing is stored
+	SinksTableID                = 16
+	ShardsTableID               = 17
+	SchemasTableID              = 18
+	DeadLettersTableID          = 19
+	SourceTopicsTableID         = 20 // SourceTopicsTableID stores the indexes of the topics of sources
+	MigrationBackupTableID      = 21 // MigrationBackupTableID stores the rows of a source being altered before migration
+	SourceStatusTableID         = 22 // SourceStatusTableID stores the status each node reports for its consumers of sources
 	UserTableIDBase             = 1000
 )
@@ -52,6 +52,7 @@ type Config struct {
 	HTTPAPIServerTLSConfig       TLSConfig `embed:"" prefix:"http-api-server-tls-"`
 	SourceStatsEnabled           bool
 	ProtobufDescriptorDir        string `help:"Directory containing protobuf file descriptor sets that Prana should load to use for decoding Kafka messages. Filenames must end with .bin" type:"existingdir"`
+	SchemaRegistryURL            string `help:"URL of the schema registry that Prana should use to resolve Avro and protobuf schemas for decoding and encoding Kafka messages. A directory containing a sub-directory of <id>.avsc or <id>.bin schema files for each subject can be used instead"`
 	LifecycleEndpointEnabled     bool   `name:"lifecycle-endpoint-enabled"`
 	LifeCycleListenAddress       string
 	StartupEndpointPath          string
@@ -79,6 +80,7 @@ type Config struct {
 	DataCompressionDisabled      bool
 	OrderByMaxRows               int
 	MaxRowCacheSize              string
+	StrictSchemas                bool `help:"Set to true to make using a schema which hasn't been created with CREATE SCHEMA an error"`
 }
 
 type TLSConfig struct {
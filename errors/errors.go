@@ -29,6 +29,12 @@ const (
 	TooManyRows
 	UnknownSink
 	SinkAlreadyExists
+	UnknownView
+	ViewAlreadyExists
+	ViewHasChildren
+	UnknownSchema
+	SchemaAlreadyExists
+	SchemaHasChildren
 )
 
 // Ingest errors
@@ -72,6 +78,18 @@ func NewUnknownSinkError(schemaName string, mvName string) PranaError {
 	return NewPranaErrorf(UnknownMaterializedView, "Unknown sink: %s.%s", schemaName, mvName)
 }
 
+func NewUnknownViewError(schemaName string, viewName string) PranaError {
+	return NewPranaErrorf(UnknownView, "Unknown view: %s.%s", schemaName, viewName)
+}
+
+func NewUnknownSchemaError(schemaName string) PranaError {
+	return NewPranaErrorf(UnknownSchema, "Unknown schema: %s", schemaName)
+}
+
+func NewSchemaAlreadyExistsError(schemaName string) PranaError {
+	return NewPranaErrorf(SchemaAlreadyExists, "Schema %s already exists", schemaName)
+}
+
 func NewUnknownTableError(schemaName string, tableName string) PranaError {
 	return NewPranaErrorf(UnknownTable, "Unknown source or materialized view: %s.%s", schemaName, tableName)
 }
@@ -88,6 +106,18 @@ func NewMaterializedViewHasChildrenError(schemaName string, materializedViewName
 	return NewPranaErrorf(MaterializedViewHasChildren, "Cannot drop materialized view %s.%s it has the following children %s", schemaName, materializedViewName, getChildString(schemaName, childMVs))
 }
 
+func NewViewHasChildrenError(schemaName string, viewName string, children []string) PranaError {
+	return NewPranaErrorf(ViewHasChildren, "Cannot drop view %s.%s it has the following children %s", schemaName, viewName, getChildString(schemaName, children))
+}
+
+func NewSchemaHasChildrenError(schemaName string, children []string) PranaError {
+	return NewPranaErrorf(SchemaHasChildren, "Cannot drop schema %s it has the following children %s", schemaName, getChildString(schemaName, children))
+}
+
+func NewAlterMaterializedViewHasChildrenError(schemaName string, materializedViewName string, childMVs []string) PranaError {
+	return NewPranaErrorf(MaterializedViewHasChildren, "Cannot alter materialized view %s.%s it has the following children %s", schemaName, materializedViewName, getChildString(schemaName, childMVs))
+}
+
 func NewUnknownLoadRunnerfCommandError(commandName string) PranaError {
 	return NewPranaErrorf(UnknownPerfCommand, "Unknown perf runner command %s", commandName)
 }
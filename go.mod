@@ -25,6 +25,7 @@ require (
 	github.com/google/btree v1.0.0
 	github.com/google/uuid v1.3.0
 	github.com/gotestyourself/gotestyourself v2.2.0+incompatible // indirect
+	github.com/hamba/avro/v2 v2.20.0
 	github.com/hashicorp/golang-lru v0.5.4
 	github.com/lib/pq v1.10.4 // indirect
 	github.com/lni/dragonboat/v3 v3.3.5
@@ -38,7 +39,7 @@ require (
 	github.com/prometheus/client_golang v1.5.1
 	github.com/segmentio/kafka-go v0.4.29
 	github.com/sirupsen/logrus v1.8.1
-	github.com/stretchr/testify v1.7.0
+	github.com/stretchr/testify v1.7.1
 	github.com/twmb/murmur3 v1.1.6
 	go.uber.org/atomic v1.7.0
 	go.uber.org/ratelimit v0.2.0
@@ -14,6 +14,8 @@ import (
 
 // Kafka Message Provider implementation that uses the standard Confluent golang client
 
+const resetOffsetsTimeoutMs = 10000
+
 func NewMessageProviderFactory(topicName string, props map[string]string, groupID string) MessageClient {
 	return &ConfluentMessageProviderFactory{
 		topicName: topicName,
@@ -22,6 +24,30 @@ func NewMessageProviderFactory(topicName string, props map[string]string, groupI
 	}
 }
 
+// ListTopics returns the names of the topics in the Kafka cluster the properties connect to
+func ListTopics(props map[string]string) ([]string, error) {
+	cm := &kafka.ConfigMap{}
+	for k, v := range props {
+		if err := cm.SetKey(k, v); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	admin, err := kafka.NewAdminClient(cm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer admin.Close()
+	md, err := admin.GetMetadata(nil, true, resetOffsetsTimeoutMs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	topicNames := make([]string, 0, len(md.Topics))
+	for topicName := range md.Topics {
+		topicNames = append(topicNames, topicName)
+	}
+	return topicNames, nil
+}
+
 type ConfluentMessageProviderFactory struct {
 	topicName string
 	props     map[string]string
@@ -152,8 +178,13 @@ func (cmp *ConfluentMessageProvider) GetMessage(pollTimeout time.Duration) (*Mes
 				Value: hdr.Value,
 			}
 		}
+		var topicName string
+		if msg.TopicPartition.Topic != nil {
+			topicName = *msg.TopicPartition.Topic
+		}
 		m := &Message{
 			PartInfo: PartInfo{
+				Topic:       topicName,
 				PartitionID: msg.TopicPartition.Partition,
 				Offset:      int64(msg.TopicPartition.Offset),
 			},
@@ -206,6 +237,18 @@ func (cmp *ConfluentMessageProvider) Start() error {
 	cmp.lock.Lock()
 	defer cmp.lock.Unlock()
 
+	consumer, err := cmp.newConsumer()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := consumer.Subscribe(cmp.krpf.topicName, cmp.RebalanceOccurred); err != nil {
+		return errors.WithStack(err)
+	}
+	cmp.consumer = consumer
+	return nil
+}
+
+func (cmp *ConfluentMessageProvider) newConsumer() (*kafka.Consumer, error) {
 	cm := &kafka.ConfigMap{
 		"group.id":             cmp.krpf.groupID,
 		"auto.offset.reset":    "earliest",
@@ -215,16 +258,95 @@ func (cmp *ConfluentMessageProvider) Start() error {
 	}
 	for k, v := range cmp.krpf.props {
 		if err := cm.SetKey(k, v); err != nil {
-			return errors.WithStack(err)
+			return nil, errors.WithStack(err)
 		}
 	}
-	consumer, err := kafka.NewConsumer(cm)
+	return kafka.NewConsumer(cm)
+}
+
+// ResetOffsets commits the offsets for the reset using a consumer in the group which isn't subscribed to the topic.
+// Kafka only accepts the commit if no other member of the group is consuming.
+func (cmp *ConfluentMessageProvider) ResetOffsets(reset *OffsetsReset) error {
+	cmp.lock.Lock()
+	defer cmp.lock.Unlock()
+
+	consumer, err := cmp.newConsumer()
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	if err := consumer.Subscribe(cmp.krpf.topicName, cmp.RebalanceOccurred); err != nil {
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Warnf("failed to close consumer after resetting offsets %v", err)
+		}
+	}()
+	md, err := consumer.GetMetadata(&cmp.topicName, false, resetOffsetsTimeoutMs)
+	if err != nil {
 		return errors.WithStack(err)
 	}
-	cmp.consumer = consumer
-	return nil
+	topicMeta, ok := md.Topics[cmp.topicName]
+	if !ok || topicMeta.Error.Code() != kafka.ErrNoError {
+		return errors.Errorf("failed to get metadata for topic %s", cmp.topicName)
+	}
+	partitions := make(map[int32]struct{}, len(topicMeta.Partitions))
+	for _, part := range topicMeta.Partitions {
+		partitions[part.ID] = struct{}{}
+	}
+	var offsets []kafka.TopicPartition
+	switch reset.Kind {
+	case OffsetsResetEarliest, OffsetsResetLatest:
+		for partID := range partitions {
+			low, high, err := consumer.QueryWatermarkOffsets(cmp.topicName, partID, resetOffsetsTimeoutMs)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			offset := low
+			if reset.Kind == OffsetsResetLatest {
+				offset = high
+			}
+			offsets = append(offsets, cmp.topicPartition(partID, offset))
+		}
+	case OffsetsResetTimestamp:
+		times := make([]kafka.TopicPartition, 0, len(partitions))
+		for partID := range partitions {
+			times = append(times, cmp.topicPartition(partID, reset.Timestamp.UnixMilli()))
+		}
+		found, err := consumer.OffsetsForTimes(times, resetOffsetsTimeoutMs)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, tp := range found {
+			if tp.Error != nil {
+				return errors.WithStack(tp.Error)
+			}
+			offset := int64(tp.Offset)
+			if offset < 0 {
+				// No message at or after the timestamp, so consume from the end of the partition
+				_, high, err := consumer.QueryWatermarkOffsets(cmp.topicName, tp.Partition, resetOffsetsTimeoutMs)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				offset = high
+			}
+			offsets = append(offsets, cmp.topicPartition(tp.Partition, offset))
+		}
+	case OffsetsResetPartitions:
+		for partID, offset := range reset.PartitionOffsets {
+			if _, ok := partitions[partID]; !ok {
+				return errors.NewPranaErrorf(errors.InvalidStatement, "Topic %s has no partition %d", cmp.topicName, partID)
+			}
+			offsets = append(offsets, cmp.topicPartition(partID, offset))
+		}
+	default:
+		return errors.Errorf("unexpected offsets reset kind %d", reset.Kind)
+	}
+	_, err = consumer.CommitOffsets(offsets)
+	return errors.WithStack(err)
+}
+
+func (cmp *ConfluentMessageProvider) topicPartition(partID int32, offset int64) kafka.TopicPartition {
+	return kafka.TopicPartition{
+		Topic:     &cmp.topicName,
+		Partition: partID,
+		Offset:    kafka.Offset(offset),
+	}
 }
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hamba/avro/v2"
 	"github.com/squareup/pranadb/common"
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 	pref "google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/dynamicpb"
 )
@@ -288,6 +291,114 @@ func (s *NestedJSONKeyNestedJSONValueEncoder) EncodeMessage(row *common.Row, col
 	return message, nil
 }
 
+// JSONKeyJSONArrayValueEncoder encodes as top level JSON key, and a JSON value holding an array, no headers. The first
+// column is encoded as the key k0, and as v0 in the value. Each other column must be a varchar holding a ';' separated
+// list, and element j of the array "items" is an object with the jth item of each list, as e0 to eN. Items which are
+// valid JSON are encoded as JSON, others as strings. An empty list is encoded as an empty array, and if the second
+// column is null there is no array.
+type JSONKeyJSONArrayValueEncoder struct {
+}
+
+func (s *JSONKeyJSONArrayValueEncoder) Name() string {
+	return "JSONKeyJSONArrayValueEncoder"
+}
+
+func (s *JSONKeyJSONArrayValueEncoder) EncodeMessage(row *common.Row, colTypes []common.ColumnType, keyCols []int, timestamp time.Time) (*Message, error) {
+	keyVal := checkType(getColVal(0, colTypes[0], row))
+	keyMap := map[string]interface{}{"k0": keyVal}
+	valMap := map[string]interface{}{"v0": keyVal}
+	var items []map[string]interface{}
+	for i := 1; i < len(colTypes); i++ {
+		elemCol := i - 1
+		if colTypes[i].Type != common.TypeVarchar {
+			return nil, errors.Errorf("column %d must be a varchar", i)
+		}
+		if row.IsNull(i) {
+			if elemCol == 0 {
+				break
+			}
+			continue
+		}
+		if elemCol == 0 {
+			items = []map[string]interface{}{}
+		}
+		list := row.GetString(i)
+		if list != "" {
+			for j, item := range strings.Split(list, ";") {
+				if j == len(items) {
+					items = append(items, map[string]interface{}{})
+				}
+				var itemVal interface{}
+				if err := json.Unmarshal([]byte(item), &itemVal); err != nil {
+					itemVal = item
+				}
+				items[j][fmt.Sprintf("e%d", elemCol)] = itemVal
+			}
+		}
+	}
+	if items != nil {
+		valMap["items"] = items
+	}
+	keyBytes, err := json.Marshal(keyMap)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	valBytes, err := json.Marshal(valMap)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	message := &Message{
+		TimeStamp: timestamp,
+		Key:       keyBytes,
+		Value:     valBytes,
+	}
+	return message, nil
+}
+
+// DebeziumJSONValueEncoder encodes Debezium change events in JSON, with a JSON key. The first column is the op of the
+// event, and the other columns are the row it changed, encoded as v0 to vN, with the second column also encoded as the
+// key k0. The row is the after of the event, or the before of a delete ("d"). A null op is a tombstone, with no value.
+type DebeziumJSONValueEncoder struct {
+}
+
+func (s *DebeziumJSONValueEncoder) Name() string {
+	return "DebeziumJSONValueEncoder"
+}
+
+func (s *DebeziumJSONValueEncoder) EncodeMessage(row *common.Row, colTypes []common.ColumnType, keyCols []int, timestamp time.Time) (*Message, error) {
+	if len(colTypes) < 2 || colTypes[0].Type != common.TypeVarchar {
+		return nil, errors.Error("first column must be a varchar holding the op, followed by the row")
+	}
+	keyMap := map[string]interface{}{"k0": checkType(getColVal(1, colTypes[1], row))}
+	keyBytes, err := json.Marshal(keyMap)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	message := &Message{
+		TimeStamp: timestamp,
+		Key:       keyBytes,
+	}
+	if row.IsNull(0) {
+		return message, nil
+	}
+	rowMap := map[string]interface{}{}
+	for i := 1; i < len(colTypes); i++ {
+		rowMap[fmt.Sprintf("v%d", i-1)] = checkType(getColVal(i, colTypes[i], row))
+	}
+	op := row.GetString(0)
+	event := map[string]interface{}{"op": op, "before": nil, "after": nil}
+	if op == "d" {
+		event["before"] = rowMap
+	} else {
+		event["after"] = rowMap
+	}
+	message.Value, err = json.Marshal(event)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return message, nil
+}
+
 // JSONHeadersEncoder puts the message key encoded as JSON in one header and the message value encoded as JSON
 // in another, the actual message key and value are empty JSON objects
 type JSONHeadersEncoder struct {
@@ -443,3 +554,219 @@ func checkType(val interface{}) interface{} {
 	}
 	return val
 }
+
+func NewStringKeyAvroValueEncoderFactory(registry schemaregistry.Registry) func(options string) (MessageEncoder, error) {
+	return func(options string) (MessageEncoder, error) {
+		return NewStringKeyAvroValueEncoder(registry, options)
+	}
+}
+
+func NewStringKeyAvroValueEncoder(registry schemaregistry.Registry, options string) (MessageEncoder, error) {
+	id, err := strconv.ParseInt(options, 10, 32)
+	if err != nil {
+		return nil, errors.Errorf("expected the id of an avro schema, but was %q", options)
+	}
+	registered, err := registry.GetSchema(int32(id))
+	if err != nil {
+		return nil, err
+	}
+	schema, err := registered.AvroSchema()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := schema.(*avro.RecordSchema)
+	if !ok {
+		return nil, errors.Errorf("expected avro schema %d to be a record, but was %s", id, schema.Type())
+	}
+	return &StringKeyAvroValueEncoder{id: int32(id), schema: record}, nil
+}
+
+// StringKeyAvroValueEncoder is an encoder that translates each row to an Avro record, written with the schema with the
+// id in the options, in the schema registry wire format. Columns 0 to N correspond to the fields of the record, in order.
+// This means that the key also ends up as a field in the value record. Record, array and map fields are given as JSON, in
+// varchar columns.
+type StringKeyAvroValueEncoder struct {
+	id     int32
+	schema *avro.RecordSchema
+}
+
+func (e *StringKeyAvroValueEncoder) Name() string {
+	return "StringKeyAvroValueEncoder"
+}
+
+func (e *StringKeyAvroValueEncoder) EncodeMessage(row *common.Row, colTypes []common.ColumnType, keyCols []int, timestamp time.Time) (*Message, error) {
+	if len(keyCols) != 1 {
+		return nil, errors.Error("must be only one pk col for binary key encoding")
+	}
+	fields := e.schema.Fields()
+	if len(colTypes) != len(fields) {
+		return nil, errors.Errorf("expected a column for each of the %d fields of the avro record", len(fields))
+	}
+	keyColIndex := keyCols[0]
+	if colTypes[keyColIndex] != common.VarcharColumnType {
+		return nil, errors.Error("Key is not a varchar column")
+	}
+	record := map[string]interface{}{}
+	for i, colType := range colTypes {
+		field := fields[i]
+		val := getColVal(i, colType, row)
+		switch field.Type().Type() {
+		case avro.Record, avro.Array, avro.Map:
+			if str, ok := val.(string); ok {
+				if err := json.Unmarshal([]byte(str), &val); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+		}
+		record[field.Name()] = val
+	}
+	valBytes, err := schemaregistry.EncodeAvro(schemaregistry.AppendWireFormat(nil, e.id), e.schema, record)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{
+		TimeStamp: timestamp,
+		Key:       []byte(row.GetString(keyColIndex)),
+		Value:     valBytes,
+	}, nil
+}
+
+func NewDebeziumAvroValueEncoderFactory(registry schemaregistry.Registry) func(options string) (MessageEncoder, error) {
+	return func(options string) (MessageEncoder, error) {
+		return NewDebeziumAvroValueEncoder(registry, options)
+	}
+}
+
+func NewDebeziumAvroValueEncoder(registry schemaregistry.Registry, options string) (MessageEncoder, error) {
+	encoder, err := NewStringKeyAvroValueEncoder(registry, options)
+	if err != nil {
+		return nil, err
+	}
+	avroEncoder := encoder.(*StringKeyAvroValueEncoder) //nolint:forcetypeassert
+	var rowSchema *avro.RecordSchema
+	for _, field := range avroEncoder.schema.Fields() {
+		if field.Name() != "after" {
+			continue
+		}
+		if union, ok := field.Type().(*avro.UnionSchema); ok {
+			for _, branch := range union.Types() {
+				if ref, ok := branch.(*avro.RefSchema); ok {
+					branch = ref.Schema()
+				}
+				if record, ok := branch.(*avro.RecordSchema); ok {
+					rowSchema = record
+				}
+			}
+		}
+	}
+	if rowSchema == nil {
+		return nil, errors.Errorf("expected avro schema %s to be a Debezium envelope, with a nullable after record", options)
+	}
+	return &DebeziumAvroValueEncoder{StringKeyAvroValueEncoder: *avroEncoder, rowSchema: rowSchema}, nil
+}
+
+// DebeziumAvroValueEncoder encodes Debezium change events in Avro, written with the envelope schema with the id in the
+// options, in the schema registry wire format, with a JSON key. The first column is the op of the event, and the other
+// columns are the fields of the row it changed, in order, with the second column also encoded as the key k0. The row
+// is the after of the event, or the before of a delete ("d"). A null op is a tombstone, with no value.
+type DebeziumAvroValueEncoder struct {
+	StringKeyAvroValueEncoder
+	rowSchema *avro.RecordSchema
+}
+
+func (e *DebeziumAvroValueEncoder) Name() string {
+	return "DebeziumAvroValueEncoder"
+}
+
+func (e *DebeziumAvroValueEncoder) EncodeMessage(row *common.Row, colTypes []common.ColumnType, keyCols []int, timestamp time.Time) (*Message, error) {
+	fields := e.rowSchema.Fields()
+	if len(colTypes) != len(fields)+1 || colTypes[0].Type != common.TypeVarchar {
+		return nil, errors.Errorf("expected a varchar column holding the op, followed by a column for each of the %d fields of the avro record", len(fields))
+	}
+	keyBytes, err := json.Marshal(map[string]interface{}{"k0": checkType(getColVal(1, colTypes[1], row))})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	message := &Message{
+		TimeStamp: timestamp,
+		Key:       keyBytes,
+	}
+	if row.IsNull(0) {
+		return message, nil
+	}
+	record := map[string]interface{}{}
+	for i, field := range fields {
+		record[field.Name()] = getColVal(i+1, colTypes[i+1], row)
+	}
+	op := row.GetString(0)
+	event := map[string]interface{}{"op": op, "before": nil, "after": nil}
+	if op == "d" {
+		event["before"] = record
+	} else {
+		event["after"] = record
+	}
+	message.Value, err = schemaregistry.EncodeAvro(schemaregistry.AppendWireFormat(nil, e.id), e.schema, event)
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func NewStringKeyProtobufConfluentValueEncoderFactory(protoRegistry protolib.Resolver, registry schemaregistry.Registry) func(options string) (MessageEncoder, error) {
+	return func(options string) (MessageEncoder, error) {
+		return NewStringKeyProtobufConfluentValueEncoder(protoRegistry, registry, options)
+	}
+}
+
+func NewStringKeyProtobufConfluentValueEncoder(protoRegistry protolib.Resolver, registry schemaregistry.Registry, options string) (MessageEncoder, error) {
+	name := pref.FullName(options)
+	schema, err := registry.GetLatestSchema(options)
+	if err != nil {
+		return nil, err
+	}
+	var mdesc pref.MessageDescriptor
+	if desc, err := protoRegistry.FindDescriptorByName(name); err == nil {
+		d, ok := desc.(pref.MessageDescriptor)
+		if !ok {
+			return nil, errors.Errorf("expected %q to be a MessageDescriptor, but was %v", name, reflect.TypeOf(desc))
+		}
+		mdesc = d
+	} else {
+		fd, err := schema.ProtobufFile(protoRegistry)
+		if err != nil {
+			return nil, err
+		}
+		if mdesc, err = schemaregistry.FindMessage(fd, name); err != nil {
+			return nil, err
+		}
+	}
+	return &StringKeyProtobufConfluentValueEncoder{
+		StringKeyProtobufValueEncoder: StringKeyProtobufValueEncoder{d: mdesc},
+		id:                            schema.ID,
+		indexes:                       schemaregistry.MessageIndexes(mdesc),
+	}, nil
+}
+
+// StringKeyProtobufConfluentValueEncoder is a StringKeyProtobufValueEncoder that writes messages in the schema registry
+// wire format, with the schema id of the latest version of the subject named after the message in the options. The
+// message is registered with Prana or defined by the subject's schema.
+type StringKeyProtobufConfluentValueEncoder struct {
+	StringKeyProtobufValueEncoder
+	id      int32
+	indexes []int
+}
+
+func (e *StringKeyProtobufConfluentValueEncoder) Name() string {
+	return "StringKeyProtobufConfluentValueEncoder"
+}
+
+func (e *StringKeyProtobufConfluentValueEncoder) EncodeMessage(row *common.Row, colTypes []common.ColumnType, keyCols []int, timestamp time.Time) (*Message, error) {
+	message, err := e.StringKeyProtobufValueEncoder.EncodeMessage(row, colTypes, keyCols, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	buff := schemaregistry.AppendWireFormat(nil, e.id)
+	buff = schemaregistry.AppendMessageIndexes(buff, e.indexes)
+	message.Value = append(buff, message.Value...)
+	return message, nil
+}
@@ -63,7 +63,8 @@ func (f *FakeKafka) CreateTopic(name string, partitions int) (*Topic, error) {
 	parts := make([]*Partition, partitions)
 	for i := 0; i < partitions; i++ {
 		parts[i] = &Partition{
-			id: int32(i),
+			topicName: name,
+			id:        int32(i),
 		}
 	}
 	topic := &Topic{
@@ -121,17 +122,37 @@ type Topic struct {
 }
 
 type Partition struct {
-	lock     sync.Mutex
-	id       int32
-	messages []*Message
+	lock      sync.Mutex
+	topicName string
+	id        int32
+	messages  []*Message
 }
 
 type MessageQueue chan *Message
 
+// offsetFor returns the offset of the next message to consume after the partition is reset to the earliest, latest or
+// timestamp
+func (p *Partition) offsetFor(reset *OffsetsReset) int64 {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	switch reset.Kind {
+	case OffsetsResetEarliest:
+		return 0
+	case OffsetsResetTimestamp:
+		for _, msg := range p.messages {
+			if !msg.TimeStamp.Before(reset.Timestamp) {
+				return msg.PartInfo.Offset
+			}
+		}
+	}
+	return int64(len(p.messages))
+}
+
 func (p *Partition) push(message *Message) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	message.PartInfo = PartInfo{
+		Topic:       p.topicName,
 		PartitionID: p.id,
 		Offset:      int64(len(p.messages)),
 	}
@@ -174,6 +195,11 @@ func (t *Topic) calcPartition(message *Message) (int, error) {
 }
 
 func (t *Topic) CreateSubscriber(groupID string, rebalanceCB RebalanceCallback) (*Subscriber, error) {
+	group := t.getOrCreateGroup(groupID)
+	return group.createSubscriber(t, group, rebalanceCB)
+}
+
+func (t *Topic) getOrCreateGroup(groupID string) *Group {
 	group, ok := t.getGroup(groupID)
 	if !ok {
 		t.lock.Lock()
@@ -184,7 +210,28 @@ func (t *Topic) CreateSubscriber(groupID string, rebalanceCB RebalanceCallback)
 		}
 		t.lock.Unlock()
 	}
-	return group.createSubscriber(t, group, rebalanceCB)
+	return group
+}
+
+// resetOffsets sets the committed offsets of the group, which, unlike commitOffsets, can move them backwards
+func (t *Topic) resetOffsets(groupID string, reset *OffsetsReset) error {
+	offsets := make(map[int32]int64, len(t.partitions))
+	switch reset.Kind {
+	case OffsetsResetEarliest, OffsetsResetLatest, OffsetsResetTimestamp:
+		for _, part := range t.partitions {
+			offsets[part.id] = part.offsetFor(reset)
+		}
+	case OffsetsResetPartitions:
+		for partID, offset := range reset.PartitionOffsets {
+			if partID < 0 || int(partID) >= len(t.partitions) {
+				return errors.NewPranaErrorf(errors.InvalidStatement, "Topic %s has no partition %d", t.Name, partID)
+			}
+			offsets[partID] = offset
+		}
+	default:
+		return errors.Errorf("unexpected offsets reset kind %d", reset.Kind)
+	}
+	return t.getOrCreateGroup(groupID).resetOffsets(offsets)
 }
 
 func (t *Topic) close() {
@@ -280,6 +327,19 @@ func (g *Group) commitOffsets(offsets map[int32]int64) error {
 	return nil
 }
 
+func (g *Group) resetOffsets(offsets map[int32]int64) error {
+	g.subscribersLock.Lock()
+	defer g.subscribersLock.Unlock()
+	if len(g.subscribers) != 0 {
+		return errors.Errorf("cannot reset offsets on group %s as it has active subscribers", g.id)
+	}
+	for partID, offset := range offsets {
+		// As with commitOffsets we keep track of the last offset that was consumed
+		g.offsets.Store(partID, offset-1)
+	}
+	return nil
+}
+
 func (g *Group) getQuiesceChannel() chan *quiesceResponse {
 	g.qcl.Lock()
 	defer g.qcl.Unlock()
@@ -503,7 +563,29 @@ func (c *Subscriber) Unsubscribe() error {
 	return c.group.unsubscribe(c)
 }
 
+// ListFakeTopics returns the names of the topics of the fake Kafka in the broker configuration
+func ListFakeTopics(props map[string]string) ([]string, error) {
+	fk, err := getFakeKafkaFromProps(props)
+	if err != nil {
+		return nil, err
+	}
+	return fk.GetTopicNames(), nil
+}
+
 func NewFakeMessageProviderFactory(topicName string, props map[string]string, groupName string) (MessageClient, error) {
+	fk, err := getFakeKafkaFromProps(props)
+	if err != nil {
+		return nil, err
+	}
+	return &FakeMessageProviderFactory{
+		fk:        fk,
+		topicName: topicName,
+		props:     props,
+		groupID:   groupName,
+	}, nil
+}
+
+func getFakeKafkaFromProps(props map[string]string) (*FakeKafka, error) {
 	sFakeKafkaID, ok := props[FakeKafkaIDPropName]
 	if !ok {
 		return nil, errors.Error("no fakeKafkaID property in broker configuration")
@@ -516,12 +598,7 @@ func NewFakeMessageProviderFactory(topicName string, props map[string]string, gr
 	if !ok {
 		return nil, errors.Errorf("cannot find fake kafka with id %d", fakeKafkaID)
 	}
-	return &FakeMessageProviderFactory{
-		fk:        fk,
-		topicName: topicName,
-		props:     props,
-		groupID:   groupName,
-	}, nil
+	return fk, nil
 }
 
 type FakeMessageProviderFactory struct {
@@ -586,6 +663,10 @@ func (f *FakeMessageProvider) Start() error {
 	return nil
 }
 
+func (f *FakeMessageProvider) ResetOffsets(reset *OffsetsReset) error {
+	return f.topic.resetOffsets(f.groupID, reset)
+}
+
 func (f *FakeMessageProvider) Stop() error {
 	f.subscriber.stopped.Set(true)
 	return nil
@@ -92,6 +92,7 @@ func TestIngestConsumeOneSubscriber(t *testing.T) {
 		msg, err := sub.GetMessage(5 * time.Second)
 		require.NoError(t, err)
 		require.NotNil(t, msg)
+		require.Equal(t, "topic1", msg.PartInfo.Topic)
 		receivedMsgs[string(msg.Key)] = msg
 	}
 
@@ -139,6 +140,50 @@ func TestIngestConsumeTwoSubscribersOneGroup(t *testing.T) {
 	}
 }
 
+func TestResetOffsets(t *testing.T) {
+	fk := NewFakeKafka()
+	topic, err := fk.CreateTopic("topic1", 1)
+	require.NoError(t, err)
+	start := time.Date(2021, time.Month(4), 12, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		err := topic.push(&Message{Key: []byte(fmt.Sprintf("key%d", i)), TimeStamp: start.Add(time.Duration(i) * time.Second)})
+		require.NoError(t, err)
+	}
+
+	groupID := "group1"
+	sub, err := topic.CreateSubscriber(groupID, nil)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		msg, err := sub.GetMessage(5 * time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, msg)
+	}
+	require.NoError(t, sub.commitOffsets(map[int32]int64{0: 5}))
+
+	// The offsets can't be reset while the group is consuming
+	err = topic.resetOffsets(groupID, &OffsetsReset{Kind: OffsetsResetEarliest})
+	require.Error(t, err)
+	require.NoError(t, sub.Unsubscribe())
+
+	nextOffset := func(reset *OffsetsReset) int64 {
+		require.NoError(t, topic.resetOffsets(groupID, reset))
+		sub, err := topic.CreateSubscriber(groupID, nil)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, sub.Unsubscribe())
+		}()
+		return sub.nextOffsets[0]
+	}
+	require.Equal(t, int64(0), nextOffset(&OffsetsReset{Kind: OffsetsResetEarliest}))
+	require.Equal(t, int64(5), nextOffset(&OffsetsReset{Kind: OffsetsResetLatest}))
+	require.Equal(t, int64(3), nextOffset(&OffsetsReset{Kind: OffsetsResetPartitions, PartitionOffsets: map[int32]int64{0: 3}}))
+	require.Equal(t, int64(2), nextOffset(&OffsetsReset{Kind: OffsetsResetTimestamp, Timestamp: start.Add(1500 * time.Millisecond)}))
+	require.Equal(t, int64(5), nextOffset(&OffsetsReset{Kind: OffsetsResetTimestamp, Timestamp: start.Add(time.Hour)}))
+
+	err = topic.resetOffsets(groupID, &OffsetsReset{Kind: OffsetsResetPartitions, PartitionOffsets: map[int32]int64{1: 0}})
+	require.Error(t, err)
+}
+
 func newConsumer(groupID string, topic *Topic, msgCounter *int64, maxMessages int64) *consumer {
 	return &consumer{
 		groupID:     groupID,
@@ -12,9 +12,8 @@ import (
 // For ingested rows, timestamp starts at this value and increments by one second for each row
 var timestampBase = time.Date(2021, time.Month(4), 12, 9, 0, 0, 0, time.UTC)
 
-// IngestRows ingests rows given schema and source name - convenience method for use in tests
-func IngestRows(f *FakeKafka, sourceInfo *common.SourceInfo, colTypes []common.ColumnType, rows *common.Rows, encoder MessageEncoder) error {
-	topicName := sourceInfo.OriginInfo.TopicName
+// IngestRows ingests rows into a topic the source consumes from - convenience method for use in tests
+func IngestRows(f *FakeKafka, topicName string, sourceInfo *common.SourceInfo, colTypes []common.ColumnType, rows *common.Rows, encoder MessageEncoder) error {
 	topic, ok := f.GetTopic(topicName)
 	if !ok {
 		return errors.Errorf("cannot find topic %s", topicName)
@@ -16,6 +16,9 @@ type MessageProvider interface {
 	Start() error
 	Close() error
 	SetRebalanceCallback(callback RebalanceCallback)
+	// ResetOffsets sets the committed offsets of the consumer group, so consuming starts from where the reset says. It
+	// is called instead of Start, while nothing in the group is consuming.
+	ResetOffsets(reset *OffsetsReset) error
 }
 
 type MessageProducer interface {
@@ -40,6 +43,26 @@ type MessageHeader struct {
 }
 
 type PartInfo struct {
+	// Topic is the topic the message was consumed from, if the client knows it
+	Topic       string
 	PartitionID int32
 	Offset      int64
 }
+
+type OffsetsResetKind int
+
+const (
+	OffsetsResetEarliest OffsetsResetKind = iota + 1
+	OffsetsResetLatest
+	OffsetsResetTimestamp
+	OffsetsResetPartitions
+)
+
+// OffsetsReset says where a consumer group consumes from after its offsets are reset - the earliest or latest message
+// in each partition, the first message in each partition at or after Timestamp, or the offsets in PartitionOffsets.
+// With OffsetsResetPartitions the offsets of the partitions which aren't in PartitionOffsets are unchanged.
+type OffsetsReset struct {
+	Kind             OffsetsResetKind
+	Timestamp        time.Time
+	PartitionOffsets map[int32]int64
+}
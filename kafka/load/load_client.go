@@ -201,6 +201,10 @@ func (l *LoadClientMessageProvider) Close() error {
 func (l *LoadClientMessageProvider) SetRebalanceCallback(callback kafka.RebalanceCallback) {
 }
 
+func (l *LoadClientMessageProvider) ResetOffsets(reset *kafka.OffsetsReset) error {
+	return errors.NewPranaErrorf(errors.InvalidStatement, "Resetting offsets is not supported by the load client")
+}
+
 func (l *LoadClientMessageProvider) genLoop() {
 	var msgCount int64
 	var msg *kafka.Message
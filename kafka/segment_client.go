@@ -33,6 +33,10 @@ func NewMessageProviderFactory(topicName string, props map[string]string, groupI
 	}
 }
 
+func ListTopics(props map[string]string) ([]string, error) {
+	return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Topic patterns are not supported by the segmentio client")
+}
+
 type SegmentMessageProviderFactory struct {
 	topicName string
 	props     map[string]string
@@ -59,6 +63,10 @@ var _ MessageProvider = &SegmentKafkaMessageProvider{}
 func (smp *SegmentKafkaMessageProvider) SetRebalanceCallback(callback RebalanceCallback) {
 }
 
+func (smp *SegmentKafkaMessageProvider) ResetOffsets(reset *OffsetsReset) error {
+	return errors.NewPranaErrorf(errors.InvalidStatement, "Resetting offsets is not supported by the segmentio client")
+}
+
 func (smp *SegmentKafkaMessageProvider) GetMessage(pollTimeout time.Duration) (*Message, error) {
 	smp.lock.Lock()
 	defer smp.lock.Unlock()
@@ -86,6 +94,7 @@ func (smp *SegmentKafkaMessageProvider) GetMessage(pollTimeout time.Duration) (*
 	}
 	m := &Message{
 		PartInfo: PartInfo{
+			Topic:       msg.Topic,
 			PartitionID: int32(msg.Partition),
 			Offset:      msg.Offset,
 		},
@@ -8,12 +8,15 @@ import (
 
 var tableInfoRowsFactory = common.NewRowsFactory(TableDefTableInfo.ColumnTypes)
 var indexInfoRowsFactory = common.NewRowsFactory(IndexDefTableInfo.ColumnTypes)
+var tableStatsRowsFactory = common.NewRowsFactory(TableStatsTableInfo.ColumnTypes)
+var schemaRowsFactory = common.NewRowsFactory(SchemasTableInfo.ColumnTypes)
 
 const (
 	TableKindSource           = "source"
 	TableKindSink             = "sink"
 	TableKindMaterializedView = "materialized_view"
 	TableKindInternal         = "internal"
+	TableKindView             = "view"
 )
 
 // EncodeIndexInfoToRow encodes a common.IndexInfo into a database row.
@@ -36,6 +39,33 @@ func DecodeIndexInfoRow(row *common.Row) *common.IndexInfo {
 	return &info
 }
 
+// EncodeSchemaToRow encodes the name of a schema created with CREATE SCHEMA into a database row.
+func EncodeSchemaToRow(schemaName string) *common.Row {
+	rows := schemaRowsFactory.NewRows(1)
+	rows.AppendStringToColumn(0, schemaName)
+	row := rows.GetRow(0)
+	return &row
+}
+
+// EncodeTableStatsToRow encodes a common.TableStats into a database row.
+func EncodeTableStatsToRow(schemaName string, tableName string, stats *common.TableStats) *common.Row {
+	rows := tableStatsRowsFactory.NewRows(1)
+	rows.AppendInt64ToColumn(0, int64(stats.TableID))
+	rows.AppendStringToColumn(1, schemaName)
+	rows.AppendStringToColumn(2, tableName)
+	rows.AppendInt64ToColumn(3, stats.RowCount)
+	rows.AppendStringToColumn(4, jsonEncode(stats))
+	row := rows.GetRow(0)
+	return &row
+}
+
+// DecodeTableStatsRow decodes a database row into a common.TableStats.
+func DecodeTableStatsRow(row *common.Row) *common.TableStats {
+	stats := common.TableStats{}
+	jsonDecode(row.GetString(4), &stats)
+	return &stats
+}
+
 // EncodeSourceInfoToRow encodes a common.SourceInfo into a database row.
 func EncodeSourceInfoToRow(info *common.SourceInfo) *common.Row {
 	rows := tableInfoRowsFactory.NewRows(1)
@@ -111,6 +141,31 @@ func DecodeMaterializedViewInfoRow(row *common.Row) *common.MaterializedViewInfo
 	return &info
 }
 
+// EncodeViewInfoToRow encodes a common.ViewInfo into a database row.
+func EncodeViewInfoToRow(info *common.ViewInfo) *common.Row {
+	rows := tableInfoRowsFactory.NewRows(1)
+	rows.AppendInt64ToColumn(0, int64(info.TableInfo.ID))
+	rows.AppendStringToColumn(1, TableKindView)
+	rows.AppendStringToColumn(2, info.SchemaName)
+	rows.AppendStringToColumn(3, info.Name)
+	rows.AppendStringToColumn(4, jsonEncode(info.TableInfo))
+	rows.AppendNullToColumn(5)
+	rows.AppendStringToColumn(6, info.Query)
+	rows.AppendNullToColumn(7)
+	row := rows.GetRow(0)
+	return &row
+}
+
+// DecodeViewInfoRow decodes a database row into a common.ViewInfo.
+func DecodeViewInfoRow(row *common.Row) *common.ViewInfo {
+	info := common.ViewInfo{
+		Query: row.GetString(6),
+	}
+	jsonDecode(row.GetString(4), &info.TableInfo)
+	info.TableInfo.CalcPKColsSet()
+	return &info
+}
+
 // EncodeInternalTableInfoToRow encodes a common.InternalTableInfo into a database row.
 func EncodeInternalTableInfoToRow(info *common.InternalTableInfo) *common.Row {
 	rows := tableInfoRowsFactory.NewRows(1)
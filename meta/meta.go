@@ -20,6 +20,12 @@ const (
 	IndexDefTableName = "indexes"
 	ProtobufTableName = "protos"
 	DummyTableName    = "dummy"
+	TableStatsName    = "table_stats"
+	SourcesTableName  = "sources"
+	SinksTableName    = "sinks"
+	ShardsTableName   = "shards"
+	SchemasTableName  = "schemata"
+	DeadLettersName   = "dead_letters"
 )
 
 // TableDefTableInfo is a static definition of the table schema for the table schema table.
@@ -77,6 +83,109 @@ var DummyTableInfo = &common.MetaTableInfo{TableInfo: common.NewTableInfo(
 		common.BigIntColumnType,
 	}, 0, 0)}
 
+// TableStatsTableInfo holds the statistics collected by ANALYZE TABLE, one row per table.
+var TableStatsTableInfo = &common.MetaTableInfo{TableInfo: common.NewTableInfo(
+	common.TableStatsTableID,
+	SystemSchemaName,
+	TableStatsName,
+	[]int{0},
+	[]string{"id", "schema_name", "table_name", "row_count", "stats"},
+	[]common.ColumnType{
+		common.BigIntColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.BigIntColumnType,
+		common.VarcharColumnType,
+	}, 0, 0)}
+
+// SourcesTableInfo is a virtual table with a row for each source on each node, as each node consumes from the topic
+// of every source. The rows are those each node last reported for its own consumers.
+var SourcesTableInfo = &common.VirtualTableInfo{TableInfo: common.NewTableInfo(
+	common.SourcesTableID,
+	SystemSchemaName,
+	SourcesTableName,
+	[]int{0, 1, 2},
+	[]string{"schema_name", "name", "node_id", "broker_name", "topic_name", "status", "rows_ingested", "ingest_rate",
+		"committed_offsets"},
+	[]common.ColumnType{
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.BigIntColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.BigIntColumnType,
+		common.DoubleColumnType,
+		common.VarcharColumnType,
+	}, 0, 0)}
+
+// SinksTableInfo is a virtual table with a row for each sink on each shard, as a sink buffers and sends the rows of
+// each shard separately.
+var SinksTableInfo = &common.VirtualTableInfo{TableInfo: common.NewTableInfo(
+	common.SinksTableID,
+	SystemSchemaName,
+	SinksTableName,
+	[]int{0, 1, 2},
+	[]string{"schema_name", "name", "shard_id", "topic_name", "status", "buffered_messages", "messages_sent",
+		"last_send"},
+	[]common.ColumnType{
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.BigIntColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.BigIntColumnType,
+		common.BigIntColumnType,
+		common.NewTimestampColumnType(6),
+	}, 0, 0)}
+
+// ShardsTableInfo is a virtual table with a row for each data shard.
+var ShardsTableInfo = &common.VirtualTableInfo{TableInfo: common.NewTableInfo(
+	common.ShardsTableID,
+	SystemSchemaName,
+	ShardsTableName,
+	[]int{0},
+	[]string{"shard_id", "leader_node", "replicas", "lag_ms"},
+	[]common.ColumnType{
+		common.BigIntColumnType,
+		common.BigIntColumnType,
+		common.VarcharColumnType,
+		common.BigIntColumnType,
+	}, 0, 0)}
+
+// SchemasTableInfo holds the schemas created with CREATE SCHEMA. Other schemas only exist while they have tables.
+var SchemasTableInfo = &common.MetaTableInfo{TableInfo: common.NewTableInfo(
+	common.SchemasTableID,
+	SystemSchemaName,
+	SchemasTableName,
+	[]int{0},
+	[]string{"name"},
+	[]common.ColumnType{
+		common.VarcharColumnType,
+	}, 0, 0)}
+
+// DeadLettersTableInfo holds the messages rejected by sources with the deadletter error policy, one row for each
+// message, along with why it was rejected. The key and value of the message are stored as they were received.
+var DeadLettersTableInfo = &common.MetaTableInfo{TableInfo: common.NewTableInfo(
+	common.DeadLettersTableID,
+	SystemSchemaName,
+	DeadLettersName,
+	[]int{0, 5, 1, 2},
+	[]string{"source_id", "partition_id", "message_offset", "schema_name", "source_name", "topic_name", "message_key",
+		"message_value", "error_message", "rejected_at"},
+	[]common.ColumnType{
+		common.BigIntColumnType,
+		common.BigIntColumnType,
+		common.BigIntColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.VarcharColumnType,
+		common.NewTimestampColumnType(6),
+	}, 0, 0)}
+
 type Controller struct {
 	lock     sync.RWMutex
 	schemas  map[string]*common.Schema
@@ -84,6 +193,8 @@ type Controller struct {
 	cluster  cluster.Cluster
 	tableIDs map[uint64]struct{}
 	indexIDs map[uint64]struct{}
+	// statsIDs holds the ids of the tables which have persisted statistics
+	statsIDs map[uint64]struct{}
 }
 
 func NewController(store cluster.Cluster) *Controller {
@@ -93,6 +204,7 @@ func NewController(store cluster.Cluster) *Controller {
 		cluster:  store,
 		tableIDs: make(map[uint64]struct{}),
 		indexIDs: make(map[uint64]struct{}),
+		statsIDs: make(map[uint64]struct{}),
 	}
 }
 
@@ -165,6 +277,21 @@ func (c *Controller) GetSink(schemaName string, name string) (*common.SinkInfo,
 	return sink, ok
 }
 
+func (c *Controller) GetView(schemaName string, name string) (*common.ViewInfo, bool) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	schema, ok := c.schemas[schemaName]
+	if !ok {
+		return nil, false
+	}
+	tb, ok := schema.GetTable(name)
+	if !ok {
+		return nil, false
+	}
+	view, ok := tb.(*common.ViewInfo)
+	return view, ok
+}
+
 func (c *Controller) GetIndex(schemaName string, tableName string, indexName string) (*common.IndexInfo, bool) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -221,6 +348,60 @@ func (c *Controller) getOrCreateSchema(schemaName string) *common.Schema {
 	return schema
 }
 
+// SchemaExists returns true if the schema was created with CREATE SCHEMA, or has tables
+func (c *Controller) SchemaExists(schemaName string) bool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	schema, ok := c.schemas[schemaName]
+	return ok && (schema.Created || schema.LenTables() != 0)
+}
+
+// RegisterSchema makes a schema created with CREATE SCHEMA available. It does not persist it
+func (c *Controller) RegisterSchema(schemaName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema := c.getOrCreateSchema(schemaName)
+	schema.Created = true
+}
+
+// UnregisterSchema removes a schema created with CREATE SCHEMA. Its tables must have been unregistered first
+func (c *Controller) UnregisterSchema(schemaName string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[schemaName]
+	if !ok {
+		return errors.Errorf("no such schema %s", schemaName)
+	}
+	if schema.LenTables() != 0 {
+		return errors.Errorf("schema %s has tables", schemaName)
+	}
+	delete(c.schemas, schemaName)
+	return nil
+}
+
+func (c *Controller) PersistSchema(schemaName string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	if err := table.Upsert(SchemasTableInfo.TableInfo, EncodeSchemaToRow(schemaName), wb); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
+// DeleteSchemaAndTables deletes a schema created with CREATE SCHEMA from storage, along with tables and indexes in it,
+// in a single batch
+func (c *Controller) DeleteSchemaAndTables(schemaName string, tableIDs []uint64, indexIDs []uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	c.addTablesAndIndexesDeletes(tableIDs, indexIDs, wb)
+	key := table.EncodeTableKeyPrefix(common.SchemasTableID, cluster.SystemSchemaShardID, 24)
+	key = common.KeyEncodeString(key, schemaName)
+	wb.AddDelete(key)
+	return c.cluster.WriteBatch(wb, false)
+}
+
 func (c *Controller) ExistsTable(schema *common.Schema, name string) error {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
@@ -236,6 +417,8 @@ func (c *Controller) existsTable(schema *common.Schema, name string) error {
 			return errors.NewPranaErrorf(errors.MaterializedViewAlreadyExists, "Materialized view %s.%s already exists", schema.Name, name)
 		case *common.SinkInfo:
 			return errors.NewPranaErrorf(errors.SinkAlreadyExists, "Sink %s.%s already exists", schema.Name, name)
+		case *common.ViewInfo:
+			return errors.NewPranaErrorf(errors.ViewAlreadyExists, "View %s.%s already exists", schema.Name, name)
 		default:
 			return errors.Errorf("Table %s.%s already exists", schema.Name, name)
 		}
@@ -316,6 +499,301 @@ func (c *Controller) PersistSource(sourceInfo *common.SourceInfo) error {
 	return c.cluster.WriteBatch(wb, false)
 }
 
+// PersistAlteredSource persists a source which has been altered, along with its indexes, whose columns may have moved.
+// Any statistics collected for the source are deleted as they no longer match its columns.
+func (c *Controller) PersistAlteredSource(sourceInfo *common.SourceInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	// Indexes are persisted separately from the table they're on
+	tableInfo := *sourceInfo.TableInfo
+	tableInfo.IndexInfos = nil
+	toPersist := &common.SourceInfo{TableInfo: &tableInfo, OriginInfo: sourceInfo.OriginInfo}
+	if err := table.Upsert(TableDefTableInfo.TableInfo, EncodeSourceInfoToRow(toPersist), wb); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, indexInfo := range sourceInfo.IndexInfos {
+		if err := table.Upsert(IndexDefTableInfo.TableInfo, EncodeIndexInfoToRow(indexInfo), wb); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if _, ok := c.statsIDs[sourceInfo.ID]; ok {
+		statsKey := table.EncodeTableKeyPrefix(common.TableStatsTableID, cluster.SystemSchemaShardID, 24)
+		statsKey = common.KeyEncodeInt64(statsKey, int64(sourceInfo.ID))
+		wb.AddDelete(statsKey)
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
+// AlterSource replaces a registered source with the source after it has been altered. Any statistics registered for
+// the source are removed. It does not persist it
+func (c *Controller) AlterSource(sourceInfo *common.SourceInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[sourceInfo.SchemaName]
+	if !ok {
+		return errors.Errorf("no such schema %s", sourceInfo.SchemaName)
+	}
+	tbl, ok := schema.GetTable(sourceInfo.Name)
+	if !ok {
+		return errors.Errorf("no such source %s", sourceInfo.Name)
+	}
+	if _, ok := tbl.(*common.SourceInfo); !ok {
+		return errors.Errorf("%s is not a source", tbl)
+	}
+	schema.DeleteTable(sourceInfo.Name)
+	schema.PutTable(sourceInfo.Name, sourceInfo)
+	delete(c.statsIDs, sourceInfo.ID)
+	return nil
+}
+
+// GetPersistedSource returns the source with the given id as it is persisted, which while the source is being altered
+// may not be as it is registered. It returns nil if there is no such source.
+func (c *Controller) GetPersistedSource(sourceID uint64) (*common.SourceInfo, error) {
+	key := table.EncodeTableKeyPrefix(common.SchemaTableID, cluster.SystemSchemaShardID, 24)
+	key = common.KeyEncodeInt64(key, int64(sourceID))
+	value, err := c.cluster.LinearizableGet(cluster.SystemSchemaShardID, key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if value == nil {
+		return nil, nil
+	}
+	rows := tableInfoRowsFactory.NewRows(1)
+	if err := common.DecodeRow(value, TableDefTableInfo.ColumnTypes, rows); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	row := rows.GetRow(0)
+	if row.GetString(1) != TableKindSource {
+		return nil, nil
+	}
+	return DecodeSourceInfoRow(&row), nil
+}
+
+// SetSourcePaused replaces a registered source with a copy which is paused or resumed, and returns the copy. It does
+// not persist it
+func (c *Controller) SetSourcePaused(schemaName string, sourceName string, paused bool) (*common.SourceInfo, error) {
+	return c.updateSourceOriginInfo(schemaName, sourceName, func(originInfo *common.SourceOriginInfo) {
+		originInfo.Paused = paused
+	})
+}
+
+// IncrementSourceOffsetsResets replaces a registered source with a copy which records that its offsets have been
+// reset once more, and returns the copy. It does not persist it
+func (c *Controller) IncrementSourceOffsetsResets(schemaName string, sourceName string) (*common.SourceInfo, error) {
+	return c.updateSourceOriginInfo(schemaName, sourceName, func(originInfo *common.SourceOriginInfo) {
+		originInfo.OffsetsResets++
+	})
+}
+
+func (c *Controller) updateSourceOriginInfo(schemaName string, sourceName string,
+	update func(originInfo *common.SourceOriginInfo)) (*common.SourceInfo, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[schemaName]
+	if !ok {
+		return nil, errors.NewUnknownSourceError(schemaName, sourceName)
+	}
+	tbl, ok := schema.GetTable(sourceName)
+	if !ok {
+		return nil, errors.NewUnknownSourceError(schemaName, sourceName)
+	}
+	prevInfo, ok := tbl.(*common.SourceInfo)
+	if !ok {
+		return nil, errors.NewUnknownSourceError(schemaName, sourceName)
+	}
+	originInfo := *prevInfo.OriginInfo
+	update(&originInfo)
+	sourceInfo := &common.SourceInfo{TableInfo: prevInfo.TableInfo, OriginInfo: &originInfo}
+	schema.PutTable(sourceName, sourceInfo)
+	return sourceInfo, nil
+}
+
+// PersistSourceOriginInfo persists a source whose origin info has changed, as when it is paused or resumed or its
+// offsets are reset
+func (c *Controller) PersistSourceOriginInfo(sourceInfo *common.SourceInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	// Indexes are persisted separately from the table they're on
+	tableInfo := *sourceInfo.TableInfo
+	tableInfo.IndexInfos = nil
+	toPersist := &common.SourceInfo{TableInfo: &tableInfo, OriginInfo: sourceInfo.OriginInfo}
+	if err := table.Upsert(TableDefTableInfo.TableInfo, EncodeSourceInfoToRow(toPersist), wb); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
+// PersistAlteredMaterializedView persists a materialized view which replaces prev, along with its internal tables, and
+// deletes prev and its internal tables, all in the same batch.
+func (c *Controller) PersistAlteredMaterializedView(prev *common.MaterializedViewInfo,
+	prevInternalTables []*common.InternalTableInfo, mvInfo *common.MaterializedViewInfo,
+	internalTables []*common.InternalTableInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	prevIDs := []uint64{prev.ID}
+	for _, info := range prevInternalTables {
+		prevIDs = append(prevIDs, info.ID)
+	}
+	for _, id := range prevIDs {
+		key := table.EncodeTableKeyPrefix(common.SchemaTableID, cluster.SystemSchemaShardID, 24)
+		key = common.KeyEncodeInt64(key, int64(id))
+		wb.AddDelete(key)
+	}
+	if _, ok := c.statsIDs[prev.ID]; ok {
+		statsKey := table.EncodeTableKeyPrefix(common.TableStatsTableID, cluster.SystemSchemaShardID, 24)
+		statsKey = common.KeyEncodeInt64(statsKey, int64(prev.ID))
+		wb.AddDelete(statsKey)
+	}
+	if err := table.Upsert(TableDefTableInfo.TableInfo, EncodeMaterializedViewInfoToRow(mvInfo), wb); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, info := range internalTables {
+		if err := table.Upsert(TableDefTableInfo.TableInfo, EncodeInternalTableInfoToRow(info), wb); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
+// AlterMaterializedView replaces a registered materialized view and its internal tables with the materialized view
+// which has been built to replace it. Clients see one or the other, never neither. It does not persist it
+func (c *Controller) AlterMaterializedView(prev *common.MaterializedViewInfo,
+	prevInternalTables []*common.InternalTableInfo, mvInfo *common.MaterializedViewInfo,
+	internalTables []*common.InternalTableInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[mvInfo.SchemaName]
+	if !ok {
+		return errors.Errorf("no such schema %s", mvInfo.SchemaName)
+	}
+	tbl, ok := schema.GetTable(mvInfo.Name)
+	if !ok {
+		return errors.Errorf("no such mv %s", mvInfo.Name)
+	}
+	if tbl.GetTableInfo().ID != prev.ID {
+		return errors.Errorf("mv %s has id %d not %d", mvInfo.Name, tbl.GetTableInfo().ID, prev.ID)
+	}
+	if err := c.checkTableID(mvInfo.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, it := range internalTables {
+		if err := c.checkTableID(it.ID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, it := range prevInternalTables {
+		delete(c.tableIDs, it.ID)
+		schema.DeleteTable(it.Name)
+	}
+	delete(c.tableIDs, prev.ID)
+	delete(c.statsIDs, prev.ID)
+	schema.ReplaceTable(mvInfo.Name, mvInfo)
+	c.tableIDs[mvInfo.ID] = struct{}{}
+	for _, it := range internalTables {
+		schema.PutTable(it.Name, it)
+		c.tableIDs[it.ID] = struct{}{}
+	}
+	return nil
+}
+
+// TableRename describes a source, materialized view or sink which is renamed, along with the tables whose queries
+// are changed to use the new name
+type TableRename struct {
+	PrevName string
+	// Table is the renamed source, materialized view or sink
+	Table common.Table
+	// PrevInternalTableNames are the names of the internal tables of a renamed materialized view, and InternalTables
+	// the same tables renamed to match it
+	PrevInternalTableNames []string
+	InternalTables         []*common.InternalTableInfo
+	// Dependents are the materialized views, sinks and views whose queries use the renamed table, with their queries
+	// changed
+	Dependents []common.Table
+}
+
+// RenameTable replaces a registered source, materialized view or sink, and the tables which depend on it, with the
+// renamed versions. It does not persist them
+func (c *Controller) RenameTable(schemaName string, rename *TableRename) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[schemaName]
+	if !ok {
+		return errors.Errorf("no such schema %s", schemaName)
+	}
+	tableInfo := rename.Table.GetTableInfo()
+	tbl, ok := schema.GetTable(rename.PrevName)
+	if !ok {
+		return errors.Errorf("no such table %s", rename.PrevName)
+	}
+	if tbl.GetTableInfo().ID != tableInfo.ID {
+		return errors.Errorf("table %s has id %d not %d", rename.PrevName, tbl.GetTableInfo().ID, tableInfo.ID)
+	}
+	if err := c.existsTable(schema, tableInfo.Name); err != nil {
+		return err
+	}
+	schema.RenameTable(rename.PrevName, tableInfo.Name, rename.Table)
+	for i, it := range rename.InternalTables {
+		schema.RenameTable(rename.PrevInternalTableNames[i], it.Name, it)
+	}
+	for _, dependent := range rename.Dependents {
+		schema.PutTable(dependent.GetTableInfo().Name, dependent)
+	}
+	return nil
+}
+
+// PersistRenamedTable persists a renamed source, materialized view or sink, along with its indexes and internal
+// tables and the tables which depend on it, all in the same batch. Rows are keyed by id, so the rows they replace are
+// overwritten.
+func (c *Controller) PersistRenamedTable(rename *TableRename) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	tables := append([]common.Table{rename.Table}, rename.Dependents...)
+	for _, it := range rename.InternalTables {
+		tables = append(tables, it)
+	}
+	for _, tbl := range tables {
+		row, err := encodeTableToRow(tbl)
+		if err != nil {
+			return err
+		}
+		if err := table.Upsert(TableDefTableInfo.TableInfo, row, wb); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, indexInfo := range rename.Table.GetTableInfo().IndexInfos {
+		if err := table.Upsert(IndexDefTableInfo.TableInfo, EncodeIndexInfoToRow(indexInfo), wb); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
+// encodeTableToRow encodes a table into a row of the tables table. Indexes are persisted separately from the table
+// they're on, so they're not included.
+func encodeTableToRow(tbl common.Table) (*common.Row, error) {
+	tableInfo := *tbl.GetTableInfo()
+	tableInfo.IndexInfos = nil
+	switch tbl := tbl.(type) {
+	case *common.SourceInfo:
+		return EncodeSourceInfoToRow(&common.SourceInfo{TableInfo: &tableInfo, OriginInfo: tbl.OriginInfo}), nil
+	case *common.MaterializedViewInfo:
+		return EncodeMaterializedViewInfoToRow(&common.MaterializedViewInfo{TableInfo: &tableInfo,
+			OriginInfo: tbl.OriginInfo, Query: tbl.Query}), nil
+	case *common.SinkInfo:
+		return EncodeSinkInfoToRow(tbl), nil
+	case *common.ViewInfo:
+		return EncodeViewInfoToRow(tbl), nil
+	case *common.InternalTableInfo:
+		return EncodeInternalTableInfoToRow(tbl), nil
+	default:
+		return nil, errors.Errorf("cannot persist %s", tbl)
+	}
+}
+
 // RegisterSink adds a Sink to the metadata controller, making it active. It does not persist it
 func (c *Controller) RegisterSink(sinkInfo *common.SinkInfo) error {
 	c.lock.Lock()
@@ -345,6 +823,34 @@ func (c *Controller) PersistSink(sinkInfo *common.SinkInfo) error {
 	return c.cluster.WriteBatch(wb, false)
 }
 
+// RegisterView adds a view to the metadata controller, making it active. It does not persist it
+func (c *Controller) RegisterView(viewInfo *common.ViewInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	log.Debugf("Registering view %s with id %d", viewInfo.Name, viewInfo.ID)
+	if err := c.checkTableID(viewInfo.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	schema := c.getOrCreateSchema(viewInfo.SchemaName)
+	err := c.existsTable(schema, viewInfo.Name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	schema.PutTable(viewInfo.Name, viewInfo)
+	c.tableIDs[viewInfo.ID] = struct{}{}
+	return nil
+}
+
+func (c *Controller) PersistView(viewInfo *common.ViewInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	if err := table.Upsert(TableDefTableInfo.TableInfo, EncodeViewInfoToRow(viewInfo), wb); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
 func (c *Controller) PersistMaterializedView(mvInfo *common.MaterializedViewInfo, internalTables []*common.InternalTableInfo) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -433,14 +939,26 @@ func (c *Controller) UnregisterSource(schemaName string, sourceName string) erro
 }
 
 func (c *Controller) DeleteSource(sourceID uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.deleteTableWithID(sourceID)
 }
 
 func (c *Controller) DeleteSink(sinkID uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.deleteTableWithID(sinkID)
 }
 
+func (c *Controller) DeleteView(viewID uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.deleteTableWithID(viewID)
+}
+
 func (c *Controller) DeleteMaterializedView(mvInfo *common.MaterializedViewInfo, internalTableIDs []*common.InternalTableInfo) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	if err := c.deleteTableWithID(mvInfo.ID); err != nil {
 		return errors.WithStack(err)
 	}
@@ -503,19 +1021,96 @@ func (c *Controller) UnregisterSink(schemaName string, sinkName string) error {
 	return nil
 }
 
+func (c *Controller) UnregisterView(schemaName string, viewName string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[schemaName]
+	if !ok {
+		return errors.Errorf("no such schema %s", schemaName)
+	}
+	tbl, ok := schema.GetTable(viewName)
+	if !ok {
+		return errors.Errorf("no such view %s", viewName)
+	}
+	if _, ok := tbl.(*common.ViewInfo); !ok {
+		return errors.Errorf("%s is not a view", tbl)
+	}
+	delete(c.tableIDs, tbl.GetTableInfo().ID)
+	schema.DeleteTable(viewName)
+	c.deleteSchemaIfEmpty(schema)
+	return nil
+}
+
+// RegisterTableStats makes the statistics for a table available to the planner. It does not persist them
+func (c *Controller) RegisterTableStats(schemaName string, tableName string, stats *common.TableStats) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	schema, ok := c.schemas[schemaName]
+	if !ok {
+		return errors.Errorf("no such schema %s", schemaName)
+	}
+	if err := schema.PutTableStats(tableName, stats); err != nil {
+		return err
+	}
+	c.statsIDs[stats.TableID] = struct{}{}
+	return nil
+}
+
+func (c *Controller) PersistTableStats(schemaName string, tableName string, stats *common.TableStats) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	if err := table.Upsert(TableStatsTableInfo.TableInfo, EncodeTableStatsToRow(schemaName, tableName, stats), wb); err != nil {
+		return errors.WithStack(err)
+	}
+	return c.cluster.WriteBatch(wb, false)
+}
+
 func (c *Controller) DeleteEntityWithID(tableID uint64) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	return c.deleteTableWithID(tableID)
 }
 
+// DeleteTablesAndIndexes deletes tables and indexes from storage in a single batch, so either all of them are deleted
+// or none are
+func (c *Controller) DeleteTablesAndIndexes(tableIDs []uint64, indexIDs []uint64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	c.addTablesAndIndexesDeletes(tableIDs, indexIDs, wb)
+	return c.cluster.WriteBatch(wb, false)
+}
+
+func (c *Controller) addTablesAndIndexesDeletes(tableIDs []uint64, indexIDs []uint64, wb *cluster.WriteBatch) {
+	for _, tableID := range tableIDs {
+		c.addTableDeletes(tableID, wb)
+	}
+	for _, indexID := range indexIDs {
+		key := table.EncodeTableKeyPrefix(common.IndexTableID, cluster.SystemSchemaShardID, 24)
+		key = common.KeyEncodeInt64(key, int64(indexID))
+		wb.AddDelete(key)
+	}
+}
+
 func (c *Controller) deleteTableWithID(tableID uint64) error {
 	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	c.addTableDeletes(tableID, wb)
+	return c.cluster.WriteBatch(wb, false)
+}
+
+func (c *Controller) addTableDeletes(tableID uint64, wb *cluster.WriteBatch) {
 	var key []byte
 	key = table.EncodeTableKeyPrefix(common.SchemaTableID, cluster.SystemSchemaShardID, 24)
 	key = common.KeyEncodeInt64(key, int64(tableID))
 	wb.AddDelete(key)
-	return c.cluster.WriteBatch(wb, false)
+	// Any statistics collected for the table are deleted along with it
+	if _, ok := c.statsIDs[tableID]; ok {
+		statsKey := table.EncodeTableKeyPrefix(common.TableStatsTableID, cluster.SystemSchemaShardID, 24)
+		statsKey = common.KeyEncodeInt64(statsKey, int64(tableID))
+		wb.AddDelete(statsKey)
+		delete(c.statsIDs, tableID)
+	}
 }
 
 func (c *Controller) deleteIndexWithID(indexID uint64) error {
@@ -529,13 +1124,20 @@ func (c *Controller) deleteIndexWithID(indexID uint64) error {
 
 func (c *Controller) registerSystemSchema() {
 	schema := c.getOrCreateSchema("sys")
+	schema.Created = true
 	schema.PutTable(TableDefTableInfo.Name, TableDefTableInfo)
 	schema.PutTable(IndexDefTableInfo.Name, IndexDefTableInfo)
 	schema.PutTable(ProtobufTableInfo.Name, ProtobufTableInfo)
 	schema.PutTable(DummyTableInfo.Name, DummyTableInfo)
+	schema.PutTable(TableStatsTableInfo.Name, TableStatsTableInfo)
+	schema.PutTable(SourcesTableInfo.Name, SourcesTableInfo)
+	schema.PutTable(SinksTableInfo.Name, SinksTableInfo)
+	schema.PutTable(ShardsTableInfo.Name, ShardsTableInfo)
+	schema.PutTable(SchemasTableInfo.Name, SchemasTableInfo)
+	schema.PutTable(DeadLettersTableInfo.Name, DeadLettersTableInfo)
 }
 
-// DeleteSchemaIfEmpty - Schema are removed once they have no more tables
+// DeleteSchemaIfEmpty - Schema are removed once they have no more tables, unless they were created with CREATE SCHEMA
 func (c *Controller) DeleteSchemaIfEmpty(schema *common.Schema) {
 	c.doDeleteSchemaIfEmpty(schema, true)
 }
@@ -549,7 +1151,9 @@ func (c *Controller) doDeleteSchemaIfEmpty(schema *common.Schema, lock bool) {
 		if lock {
 			c.lock.Lock()
 		}
-		delete(c.schemas, schema.Name)
+		if !schema.Created {
+			delete(c.schemas, schema.Name)
+		}
 		if lock {
 			c.lock.Unlock()
 		}
@@ -33,6 +33,15 @@ type MVTables struct {
 }
 
 func (l *Loader) Start() error { //nolint:gocyclo
+	schemaRows, err := l.queryExec.ExecuteQuery("sys", "select name from schemata order by name")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for i := 0; i < schemaRows.RowCount(); i++ {
+		schemaRow := schemaRows.GetRow(i)
+		l.meta.RegisterSchema(schemaRow.GetString(0))
+	}
+
 	tableRows, err := l.queryExec.ExecuteQuery("sys",
 		"select id, kind, schema_name, name, table_info, topic_info, query, mv_name from tables order by id")
 	if err != nil {
@@ -46,6 +55,8 @@ func (l *Loader) Start() error { //nolint:gocyclo
 
 	// MVs must be started in the load order so we maintain a slice
 	var mvsToLoad []tableKey
+	// Sinks can consume from MVs so they're started after all the MVs have been
+	var sinksToLoad []*common.SinkInfo
 
 	for i := 0; i < tableRows.RowCount(); i++ {
 		tableRow := tableRows.GetRow(i)
@@ -56,6 +67,10 @@ func (l *Loader) Start() error { //nolint:gocyclo
 			if err := l.meta.RegisterSource(info); err != nil {
 				return errors.WithStack(err)
 			}
+			// The node may have stopped while the source was being altered
+			if err := l.pushEngine.RecoverSourceAlteration(info); err != nil {
+				return errors.WithStack(err)
+			}
 			_, err := l.pushEngine.CreateSource(info)
 			if err != nil {
 				return errors.WithStack(err)
@@ -77,23 +92,11 @@ func (l *Loader) Start() error { //nolint:gocyclo
 			if ok {
 				return errors.Errorf("sink %s %s already loaded", info.SchemaName, info.Name)
 			}
-			if err := l.meta.RegisterSink(info); err != nil {
-				return errors.WithStack(err)
-			}
-			schema := l.meta.GetOrCreateSchema(info.SchemaName)
-			pl := parplan.NewPlanner(schema)
-			seqGen := common.NewPreallocSeqGen([]uint64{info.ID})
-			sink, err := push.CreateSink(l.pushEngine, pl, schema, info.Name, info.Query, info.ID, seqGen, info.TargetInfo)
-			if err != nil {
-				return errors.WithStack(err)
-			}
-			if err := l.pushEngine.RegisterSink(sink); err != nil {
-				return errors.WithStack(err)
-			}
-			if err := sink.Start(); err != nil {
-				return errors.WithStack(err)
-			}
-			if err := sink.Connect(); err != nil {
+			sinksToLoad = append(sinksToLoad, info)
+		case meta.TableKindView:
+			// Views are loaded in the order they were created, before anything created after them which uses them
+			info := meta.DecodeViewInfoRow(&tableRow)
+			if err := l.meta.RegisterView(info); err != nil {
 				return errors.WithStack(err)
 			}
 		case meta.TableKindInternal:
@@ -128,7 +131,7 @@ func (l *Loader) Start() error { //nolint:gocyclo
 			l.pushEngine,
 			parplan.NewPlanner(schema),
 			schema, mvt.mvInfo.Name, mvt.mvInfo.Query, "", mvID,
-			seqGen)
+			mvt.mvInfo.VersionRetentionDuration, mvt.mvInfo.VersionTableID, seqGen)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -143,6 +146,28 @@ func (l *Loader) Start() error { //nolint:gocyclo
 		}
 	}
 
+	for _, info := range sinksToLoad {
+		if err := l.meta.RegisterSink(info); err != nil {
+			return errors.WithStack(err)
+		}
+		schema := l.meta.GetOrCreateSchema(info.SchemaName)
+		pl := parplan.NewPlanner(schema)
+		seqGen := common.NewPreallocSeqGen([]uint64{info.ID})
+		sink, err := push.CreateSink(l.pushEngine, pl, schema, info.Name, info.Query, info.ID, seqGen, info.TargetInfo)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := l.pushEngine.RegisterSink(sink); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := sink.Start(); err != nil {
+			return errors.WithStack(err)
+		}
+		if err := sink.Connect(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
 	for i := 0; i < indexRows.RowCount(); i++ {
 		indexRow := indexRows.GetRow(i)
 		info := meta.DecodeIndexInfoRow(&indexRow)
@@ -154,6 +179,20 @@ func (l *Loader) Start() error { //nolint:gocyclo
 		}
 	}
 
+	statsRows, err := l.queryExec.ExecuteQuery("sys",
+		"select id, schema_name, table_name, row_count, stats from table_stats order by id")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for i := 0; i < statsRows.RowCount(); i++ {
+		statsRow := statsRows.GetRow(i)
+		schemaName := statsRow.GetString(1)
+		tableName := statsRow.GetString(2)
+		if err := l.meta.RegisterTableStats(schemaName, tableName, meta.DecodeTableStatsRow(&statsRow)); err != nil {
+			log.Warnf("found stats for table %s.%s but table not loaded", schemaName, tableName)
+		}
+	}
+
 	return nil
 }
 
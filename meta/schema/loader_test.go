@@ -10,6 +10,7 @@ import (
 	"github.com/squareup/pranadb/conf"
 	"github.com/squareup/pranadb/kafka"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 
 	"github.com/squareup/pranadb/cluster"
 	"github.com/squareup/pranadb/command"
@@ -173,10 +174,11 @@ func runServer(t *testing.T, clus cluster.Cluster, notif *remoting.FakeServer) (
 	shardr := sharder.NewSharder(clus)
 	config := conf.NewTestConfig(fakeKafka.ID)
 	pullEngine := pull.NewPullEngine(clus, metaController, shardr, config)
-	pushEngine := push.NewPushEngine(clus, shardr, metaController, config, pullEngine, protolib.EmptyRegistry, failinject.NewDummyInjector())
+	pushEngine := push.NewPushEngine(clus, shardr, metaController, config, pullEngine, protolib.EmptyRegistry, schemaregistry.EmptyRegistry,
+		failinject.NewDummyInjector())
 	ddlClient := remoting.NewFakeClient(notif)
 	ddlResetClient := remoting.NewFakeClient(notif)
-	ce := command.NewCommandExecutor(metaController, pushEngine, pullEngine, clus, ddlClient, ddlResetClient, protolib.EmptyRegistry, failinject.NewDummyInjector(), config)
+	ce := command.NewCommandExecutor(metaController, pushEngine, pullEngine, clus, ddlClient, ddlResetClient, protolib.EmptyRegistry, schemaregistry.EmptyRegistry, failinject.NewDummyInjector(), config)
 	notif.RegisterMessageHandler(remoting.ClusterMessageDDLStatement, ce.DDlCommandRunner().DdlHandler())
 	clus.SetRemoteQueryExecutionCallback(pullEngine)
 	clus.RegisterShardListenerFactory(pushEngine)
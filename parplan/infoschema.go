@@ -2,7 +2,13 @@ package parplan
 
 import (
 	"fmt"
+	"strings"
+
+	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/mysql"
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/tidb/statistics"
 
 	"github.com/squareup/pranadb/tidb"
 
@@ -16,6 +22,16 @@ import (
 // We only implement the parts we actually need
 type pranaInfoSchema struct {
 	schemaMap map[string]*schemaTables
+	stats     map[int64]*common.TableStats
+	// includes holds the included columns of indexes, keyed by index id
+	includes map[int64][]*model.IndexColumn
+	// indexExprs holds the key expressions and predicates of functional and partial indexes, keyed by index id
+	indexExprs map[int64]*common.IndexInfo
+	// indexColsDesc holds which columns of indexes are descending, keyed by index id
+	indexColsDesc map[int64][]bool
+	// primaryKeyDesc holds which columns of primary keys are descending, keyed by table id
+	primaryKeyDesc map[int64][]bool
+	parser         *Parser
 }
 
 type schemaTables struct {
@@ -38,6 +54,12 @@ func schemaToInfoSchema(schema *common.Schema) infoschema.InfoSchema {
 
 	result := &pranaInfoSchema{}
 	result.schemaMap = make(map[string]*schemaTables)
+	result.stats = make(map[int64]*common.TableStats)
+	result.includes = make(map[int64][]*model.IndexColumn)
+	result.indexExprs = make(map[int64]*common.IndexInfo)
+	result.indexColsDesc = make(map[int64][]bool)
+	result.primaryKeyDesc = make(map[int64][]bool)
+	result.parser = NewParser()
 
 	var tabInfos []*model.TableInfo
 	tablesMap := make(map[string]*model.TableInfo)
@@ -98,18 +120,34 @@ func schemaToInfoSchema(schema *common.Schema) infoschema.InfoSchema {
 				Global:    false,
 			}
 			indexes = append(indexes, pkIndex)
+			if tableInfo.PrimaryKeyDesc != nil {
+				result.indexColsDesc[pkIndex.ID] = tableInfo.PrimaryKeyDesc
+			}
+		}
+		if tableInfo.PrimaryKeyDesc != nil {
+			result.primaryKeyDesc[int64(tableInfo.ID)] = tableInfo.PrimaryKeyDesc
 		}
 
 		if tableInfo.IndexInfos != nil {
 			for _, indexInfo := range tableInfo.IndexInfos {
 				var indexCols []*model.IndexColumn
-				for _, columnIndex := range indexInfo.IndexCols {
-					col := &model.IndexColumn{
-						Name:   model.NewCIStr(tableInfo.ColumnNames[columnIndex]),
-						Offset: columnIndex,
-						Length: -1,
+				for i, columnIndex := range indexInfo.IndexCols {
+					var col *model.IndexColumn
+					if columnIndex == -1 {
+						// The planner matches an expression in the key of a functional index using the expression
+						// itself, so the name must not match any column of the table
+						col = &model.IndexColumn{
+							Name:   model.NewCIStr(fmt.Sprintf("$expr_%d", i)),
+							Offset: -1,
+							Length: -1,
+						}
+					} else {
+						col = &model.IndexColumn{
+							Name:   model.NewCIStr(tableInfo.ColumnNames[columnIndex]),
+							Offset: columnIndex,
+							Length: -1,
+						}
 					}
-
 					indexCols = append(indexCols, col)
 				}
 				index := &model.IndexInfo{
@@ -126,6 +164,23 @@ func schemaToInfoSchema(schema *common.Schema) infoschema.InfoSchema {
 					Global:    false,
 				}
 				indexes = append(indexes, index)
+
+				if indexInfo.IndexColsDesc != nil {
+					result.indexColsDesc[index.ID] = indexInfo.IndexColsDesc
+				}
+
+				if indexInfo.HasIndexExprs() || indexInfo.Predicate != "" {
+					result.indexExprs[index.ID] = indexInfo
+				}
+
+				for _, columnIndex := range indexInfo.IncludeCols {
+					col := &model.IndexColumn{
+						Name:   model.NewCIStr(tableInfo.ColumnNames[columnIndex]),
+						Offset: columnIndex,
+						Length: -1,
+					}
+					result.includes[index.ID] = append(result.includes[index.ID], col)
+				}
 			}
 		}
 
@@ -140,6 +195,10 @@ func schemaToInfoSchema(schema *common.Schema) infoschema.InfoSchema {
 
 		tablesMap[tableInfo.Name] = tab
 
+		if stats, ok := schema.GetTableStats(tableInfo.Name); ok {
+			result.stats[tab.ID] = stats
+		}
+
 		tabInfos = append(tabInfos, tab)
 	}
 
@@ -175,6 +234,76 @@ func (pis *pranaInfoSchema) TableByID(id int64) (*model.TableInfo, bool) {
 	panic("should not be called")
 }
 
+// TableStats implements planner.StatsProvider so the planner can use the statistics collected by ANALYZE TABLE
+func (pis *pranaInfoSchema) TableStats(tblInfo *model.TableInfo) *statistics.Table {
+	stats, ok := pis.stats[tblInfo.ID]
+	if !ok {
+		return nil
+	}
+	statsTbl, err := toStatisticsTable(tblInfo, stats)
+	if err != nil {
+		log.Warnf("failed to convert stats for table %s - pseudo stats will be used %v", tblInfo.Name.L, err)
+		return nil
+	}
+	return statsTbl
+}
+
+// IndexIncludes implements planner.IndexIncludesProvider so the planner knows when an index with included columns covers
+// a query
+func (pis *pranaInfoSchema) IndexIncludes(idxInfo *model.IndexInfo) []*model.IndexColumn {
+	return pis.includes[idxInfo.ID]
+}
+
+// IndexColsDesc implements planner.KeyOrderProvider so the planner knows which order the rows of an index are stored in
+func (pis *pranaInfoSchema) IndexColsDesc(idxInfo *model.IndexInfo) []bool {
+	return pis.indexColsDesc[idxInfo.ID]
+}
+
+// PrimaryKeyDesc implements planner.KeyOrderProvider so the planner knows which order the rows of a table are stored in
+func (pis *pranaInfoSchema) PrimaryKeyDesc(tblInfo *model.TableInfo) []bool {
+	return pis.primaryKeyDesc[tblInfo.ID]
+}
+
+// IndexExprs implements planner.IndexExprsProvider so the planner can match the key expressions of functional indexes,
+// and the predicates of partial indexes, against the conditions of a query
+func (pis *pranaInfoSchema) IndexExprs(idxInfo *model.IndexInfo) ([]ast.ExprNode, ast.ExprNode, error) {
+	indexInfo, ok := pis.indexExprs[idxInfo.ID]
+	if !ok {
+		return nil, nil, nil
+	}
+	// We parse the expressions in a query as the parser doesn't parse expressions on their own
+	fields := []string{"1"}
+	for _, expr := range indexInfo.IndexExprs {
+		if expr != "" {
+			fields = append(fields, expr)
+		}
+	}
+	query := fmt.Sprintf("select %s from %s", strings.Join(fields, ", "), indexInfo.TableName)
+	if indexInfo.Predicate != "" {
+		query = fmt.Sprintf("%s where %s", query, indexInfo.Predicate)
+	}
+	stmt, _, err := pis.parser.Parse(query)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	sel, ok := stmt.stmt.(*ast.SelectStmt)
+	if !ok {
+		return nil, nil, errors.Errorf("invalid index expressions %s", query)
+	}
+	var keyExprs []ast.ExprNode
+	if indexInfo.HasIndexExprs() {
+		keyExprs = make([]ast.ExprNode, len(indexInfo.IndexExprs))
+		field := 1
+		for i, expr := range indexInfo.IndexExprs {
+			if expr != "" {
+				keyExprs[i] = sel.Fields.Fields[field].Expr
+				field++
+			}
+		}
+	}
+	return keyExprs, sel.Where, nil
+}
+
 func (pis *pranaInfoSchema) SchemaMetaVersion() int64 {
 	return 0
 }
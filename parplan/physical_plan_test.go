@@ -327,3 +327,176 @@ func TestSecondaryIndexMultiColumnRangeUsingIndexScanForPullQuery(t *testing.T)
 	require.Equal(t, "bar", is.Ranges[0].LowVal[2].GetString())
 	require.Equal(t, "", is.Ranges[0].HighVal[2].GetString())
 }
+
+func TestSecondaryIndexWithIncludedColumnsCoversQuery(t *testing.T) {
+	schema := createTestSchema()
+	index1 := &common.IndexInfo{
+		ID:          0,
+		SchemaName:  "test",
+		Name:        "index1",
+		TableName:   "table1",
+		IndexCols:   []int{2},
+		IncludeCols: []int{1},
+	}
+	require.NoError(t, schema.PutIndex(index1))
+	planner := NewPlanner(schema)
+	physi, _, _, err := planner.QueryToPlan("select * from table1 where col2=1", false, true)
+	require.NoError(t, err)
+	is, ok := physi.(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+	// The index covers all the columns, so rows don't need to be looked up in the table
+	require.False(t, is.DoubleRead)
+	require.Equal(t, 1, len(is.Ranges))
+
+	// The included column isn't part of the key so can't be used in the range
+	physi, _, _, err = planner.QueryToPlan("select * from table1 where col2=1 and col1='foo'", false, true)
+	require.NoError(t, err)
+	sel, ok := physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	is, ok = sel.Children()[0].(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+	require.False(t, is.DoubleRead)
+	require.Equal(t, 1, len(is.Ranges))
+	require.Equal(t, 1, len(is.Ranges[0].LowVal))
+}
+
+func TestFunctionalIndexLookupUsingIndexScanForPullQuery(t *testing.T) {
+	schema := createTestSchema()
+	index1 := &common.IndexInfo{
+		ID:             0,
+		SchemaName:     "test",
+		Name:           "index1",
+		TableName:      "table1",
+		IndexCols:      []int{-1},
+		IndexExprs:     []string{"lower(col1)"},
+		IndexExprTypes: []common.ColumnType{common.VarcharColumnType},
+	}
+	require.NoError(t, schema.PutIndex(index1))
+	planner := NewPlanner(schema)
+	physi, _, _, err := planner.QueryToPlan("select * from table1 where lower(col1)='foo'", false, true)
+	require.NoError(t, err)
+	// The condition is kept as the key of the index holds the value of the expression, not of the column
+	sel, ok := physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	is, ok := sel.Children()[0].(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+	require.True(t, is.DoubleRead)
+	require.Equal(t, 1, len(is.Ranges))
+	require.True(t, is.Ranges[0].IsPoint(planner.StatementContext()))
+	require.Equal(t, "foo", is.Ranges[0].LowVal[0].GetString())
+
+	// A condition on the column itself can't be used for the range of the index
+	physi, _, _, err = planner.QueryToPlan("select * from table1 where col1='foo'", false, true)
+	require.NoError(t, err)
+	sel, ok = physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	if is, ok := sel.Children()[0].(*planner2.PhysicalIndexScan); ok {
+		require.Equal(t, 1, len(is.Ranges))
+		require.True(t, is.Ranges[0].IsFullRange())
+	}
+}
+
+func TestPartialIndexOnlyUsedWhenQueryImpliesPredicate(t *testing.T) {
+	schema := createTestSchema()
+	index1 := &common.IndexInfo{
+		ID:         0,
+		SchemaName: "test",
+		Name:       "index1",
+		TableName:  "table1",
+		IndexCols:  []int{2},
+		Predicate:  "col1 = 'PENDING'",
+	}
+	require.NoError(t, schema.PutIndex(index1))
+	planner := NewPlanner(schema)
+	physi, _, _, err := planner.QueryToPlan("select * from table1 where col2=1 and col1='PENDING'", false, true)
+	require.NoError(t, err)
+	sel, ok := physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	is, ok := sel.Children()[0].(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+	require.Equal(t, 1, len(is.Ranges))
+	require.True(t, is.Ranges[0].IsPoint(planner.StatementContext()))
+
+	// Without the predicate the index doesn't hold all the rows the query needs
+	physi, _, _, err = planner.QueryToPlan("select * from table1 where col2=1", false, true)
+	require.NoError(t, err)
+	sel, ok = physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	_, ok = sel.Children()[0].(*planner2.PhysicalTableScan)
+	require.True(t, ok)
+
+	physi, _, _, err = planner.QueryToPlan("select * from table1", false, true)
+	require.NoError(t, err)
+	_, ok = physi.(*planner2.PhysicalTableScan)
+	require.True(t, ok)
+}
+
+func TestDescendingIndexSatisfiesOrderByDescWithoutSort(t *testing.T) {
+	schema := createTestSchema()
+	index1 := &common.IndexInfo{
+		ID:            100,
+		SchemaName:    "test",
+		Name:          "index1",
+		TableName:     "table1",
+		IndexCols:     []int{2},
+		IndexColsDesc: []bool{true},
+	}
+	require.NoError(t, schema.PutIndex(index1))
+	planner := NewPlanner(schema)
+	physi, _, _, err := planner.QueryToPlan("select * from table1 order by col2 desc limit 3", false, true)
+	require.NoError(t, err)
+	limit, ok := physi.(*planner2.PhysicalLimit)
+	require.True(t, ok)
+	is, ok := limit.Children()[0].(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+	require.True(t, is.KeepOrder)
+	require.Equal(t, 1, len(is.ByItems))
+	require.True(t, is.ByItems[0].Desc)
+
+	// The index can't be scanned in reverse, so ascending order needs a sort
+	physi, _, _, err = planner.QueryToPlan("select * from table1 order by col2 limit 3", false, true)
+	require.NoError(t, err)
+	_, ok = physi.(*planner2.PhysicalLimit)
+	require.False(t, ok)
+}
+
+func TestDescendingPrimaryKeySatisfiesOrderByDescWithoutSort(t *testing.T) {
+	schema := createTestSchema()
+	table1, ok := schema.GetTable("table1")
+	require.True(t, ok)
+	table1.GetTableInfo().PrimaryKeyDesc = []bool{true}
+	planner := NewPlanner(schema)
+	physi, _, _, err := planner.QueryToPlan("select * from table1 order by col0 desc limit 3", false, true)
+	require.NoError(t, err)
+	limit, ok := physi.(*planner2.PhysicalLimit)
+	require.True(t, ok)
+	ts, ok := limit.Children()[0].(*planner2.PhysicalTableScan)
+	require.True(t, ok)
+	require.True(t, ts.KeepOrder)
+
+	physi, _, _, err = planner.QueryToPlan("select * from table1 order by col0 limit 3", false, true)
+	require.NoError(t, err)
+	_, ok = physi.(*planner2.PhysicalLimit)
+	require.False(t, ok)
+}
+
+func TestAsOfQueryUsesTableScanWithTime(t *testing.T) {
+	schema := createTestSchema()
+	schema, err := attachIndexToSchema(schema)
+	require.NoError(t, err)
+	planner := NewPlanner(schema)
+	// Prior versions of rows are only kept for the table so it must be scanned even though there's an index on col2
+	physi, _, _, err := planner.QueryToPlan("select col2 from table1 as of timestamp '2021-10-01 12:34:56.123456' where col2=1", false, true)
+	require.NoError(t, err)
+	sel, ok := physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	ts, ok := sel.Children()[0].(*planner2.PhysicalTableScan)
+	require.True(t, ok)
+	require.NotNil(t, ts.AsOf)
+	require.Equal(t, "2021-10-01 12:34:56.123456", ts.AsOf.Format("2006-01-02 15:04:05.000000"))
+
+	physi, _, _, err = planner.QueryToPlan("select col2 from table1 where col2=1", false, true)
+	require.NoError(t, err)
+	_, ok = physi.(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+}
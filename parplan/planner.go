@@ -1,10 +1,16 @@
 package parplan
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/parser/mysql"
 	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/tidb/expression"
 	"github.com/squareup/pranadb/tidb/planner"
 	"github.com/squareup/pranadb/tidb/sessionctx"
 	"github.com/squareup/pranadb/tidb/sessionctx/stmtctx"
+	"github.com/squareup/pranadb/tidb/types"
 
 	"github.com/squareup/pranadb/sessctx"
 
@@ -77,6 +83,9 @@ func (p *Planner) doQueryToPlan(query string, prepare bool, pullQuery bool) (pla
 }
 
 func (p *Planner) BuildLogicalPlan(stmt AstHandle, prepare bool) (planner.LogicalPlan, error) {
+	if err := p.expandViews(stmt.stmt, 0); err != nil {
+		return nil, err
+	}
 	if err := p.preprocess(stmt.stmt, prepare); err != nil {
 		return nil, err
 	}
@@ -119,3 +128,103 @@ func (p *Planner) preprocess(stmt ast.Node, prepare bool) error {
 	}
 	return nil
 }
+
+// BuildIndexExpressions compiles the key expressions of a functional index, and the predicate of a partial index, on a
+// table so they can be evaluated against its rows. keyExprs holds the SQL of the expression for each column of the
+// index key, or the empty string where the key column is a plain column of the table, in which case the returned
+// expression is nil. The types of the values of the key expressions are returned too. The predicate is returned as a
+// list of expressions which must all be true for a row to be indexed.
+func (p *Planner) BuildIndexExpressions(tableName string, keyExprs []string, predicate string) ([]*common.Expression, []common.ColumnType, []*common.Expression, error) {
+	fields := []string{"1"}
+	for _, keyExpr := range keyExprs {
+		if keyExpr != "" {
+			fields = append(fields, keyExpr)
+		}
+	}
+	query := fmt.Sprintf("select %s from %s", strings.Join(fields, ", "), tableName)
+	if predicate != "" {
+		query = fmt.Sprintf("%s where %s", query, predicate)
+	}
+	stmt, _, err := p.Parse(query)
+	if err != nil {
+		return nil, nil, nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid index expression: %s", query)
+	}
+	logical, err := p.BuildLogicalPlan(stmt, false)
+	if err != nil {
+		return nil, nil, nil, errors.MaybeConvertToPranaErrorf(err, errors.InvalidStatement, err.Error())
+	}
+	// The expressions must be evaluated against single rows of the table, so the plan must be a projection and an
+	// optional selection directly over the table
+	proj, ok := logical.(*planner.LogicalProjection)
+	if !ok {
+		return nil, nil, nil, errors.NewPranaErrorf(errors.InvalidStatement, "Index expressions must be evaluated over a single row")
+	}
+	child := proj.Children()[0]
+	var conds []expression.Expression
+	if sel, ok := child.(*planner.LogicalSelection); ok {
+		conds = sel.Conditions
+		child = sel.Children()[0]
+	}
+	if _, ok := child.(*planner.LogicalDataSource); !ok {
+		return nil, nil, nil, errors.NewPranaErrorf(errors.InvalidStatement, "Index expressions must be evaluated over a single row")
+	}
+	keyExpressions := make([]*common.Expression, len(keyExprs))
+	keyTypes := make([]common.ColumnType, len(keyExprs))
+	pos := 1
+	for i, keyExpr := range keyExprs {
+		if keyExpr == "" {
+			continue
+		}
+		expr, err := p.toTableExpression(proj.Exprs[pos])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		keyTypes[i], err = indexExprType(proj.Exprs[pos].GetType())
+		if err != nil {
+			return nil, nil, nil, errors.NewPranaErrorf(errors.InvalidStatement, "Unsupported type for index expression %s", keyExpr)
+		}
+		keyExpressions[i] = expr
+		pos++
+	}
+	predicateExpressions := make([]*common.Expression, len(conds))
+	for i, cond := range conds {
+		expr, err := p.toTableExpression(cond)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		predicateExpressions[i] = expr
+	}
+	return keyExpressions, keyTypes, predicateExpressions, nil
+}
+
+// indexExprType gets the type of the values of an index expression. Dates are indexed as timestamps, so that we can
+// index the day of a timestamp.
+func indexExprType(tp *types.FieldType) (common.ColumnType, error) {
+	if tp.Tp == mysql.TypeDate || tp.Tp == mysql.TypeDatetime {
+		return common.NewTimestampColumnType(int8(tp.Decimal)), nil
+	}
+	return common.ConvertTiDBTypeToPranaType(tp)
+}
+
+func (p *Planner) toTableExpression(expr expression.Expression) (*common.Expression, error) {
+	if expression.IsMutableEffectsExpr(expr) {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Index expressions must be deterministic")
+	}
+	expr = expr.Clone()
+	resolveTableColIndexes(expr)
+	return common.NewExpression(expr, p.sessionCtx), nil
+}
+
+// resolveTableColIndexes makes the columns in the expression refer to the positions of the columns in the table, so the
+// expression can be evaluated directly against the table's rows
+func resolveTableColIndexes(expr expression.Expression) {
+	switch e := expr.(type) {
+	case *expression.Column:
+		// The ids of columns in the info schema are their position in the table plus one
+		e.Index = int(e.ID - 1)
+	case *expression.ScalarFunction:
+		for _, arg := range e.GetArgs() {
+			resolveTableColIndexes(arg)
+		}
+	}
+}
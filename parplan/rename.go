@@ -0,0 +1,52 @@
+package parplan
+
+import (
+	"strings"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/format"
+	"github.com/pingcap/parser/model"
+	"github.com/squareup/pranadb/errors"
+)
+
+// RenameTableInQuery returns the query with each reference to the named table or view in the given schema changed to
+// its new name, including where the name qualifies a column
+func RenameTableInQuery(schemaName string, query string, name string, newName string) (string, error) {
+	handle, _, err := NewParser().Parse(query)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	vis := &tableRenamer{schemaName: schemaName, name: name, newName: newName}
+	handle.stmt.Accept(vis)
+	sb := &strings.Builder{}
+	flags := format.RestoreStringSingleQuotes | format.RestoreStringWithoutCharset | format.RestoreKeyWordLowercase |
+		format.RestoreNameBackQuotes
+	if err := handle.stmt.Restore(format.NewRestoreCtx(flags, sb)); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return sb.String(), nil
+}
+
+type tableRenamer struct {
+	schemaName string
+	name       string
+	newName    string
+}
+
+func (t *tableRenamer) Enter(in ast.Node) (ast.Node, bool) {
+	switch node := in.(type) {
+	case *ast.TableName:
+		if node.Name.L == t.name && (node.Schema.L == "" || node.Schema.L == t.schemaName) {
+			node.Name = model.NewCIStr(t.newName)
+		}
+	case *ast.ColumnName:
+		if node.Table.L == t.name && (node.Schema.L == "" || node.Schema.L == t.schemaName) {
+			node.Table = model.NewCIStr(t.newName)
+		}
+	}
+	return in, false
+}
+
+func (t *tableRenamer) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
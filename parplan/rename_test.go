@@ -0,0 +1,40 @@
+package parplan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameTableInQuery(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			"select id, amount from orders where customer = 'c1'",
+			"select `id`,`amount` from `orders2` where `customer`='c1'",
+		},
+		{
+			"select orders.customer, sum(orders.amount) from test.orders group by orders.customer",
+			"select `orders2`.`customer`,sum(`orders2`.`amount`) from `test`.`orders2` group by `orders2`.`customer`",
+		},
+		{
+			"select o.id from orders o where o.amount > 100",
+			"select `o`.`id` from `orders2` as `o` where `o`.`amount`>100",
+		},
+		// A column with the same name as the table, and a table with the same name in another schema, are not renamed
+		{
+			"select orders from other.orders",
+			"select `orders` from `other`.`orders`",
+		},
+	}
+	for _, tc := range testCases {
+		renamed, err := RenameTableInQuery("test", tc.query, "orders", "orders2")
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, renamed)
+		// The renamed query must still parse
+		_, _, err = NewParser().Parse(renamed)
+		require.NoError(t, err)
+	}
+}
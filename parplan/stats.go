@@ -0,0 +1,338 @@
+package parplan
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/tidb/sessionctx/stmtctx"
+	"github.com/squareup/pranadb/tidb/statistics"
+	"github.com/squareup/pranadb/tidb/types"
+	"github.com/squareup/pranadb/tidb/util/codec"
+)
+
+const (
+	statsSampleSize = 10000
+	statsNumBuckets = 64
+)
+
+// StatsCollector builds the statistics for a table from a random sample of the rows of each shard. The samples are
+// combined in proportion to the number of rows in each shard, and histograms are built from the combined sample,
+// scaled up to the total number of rows in the table.
+type StatsCollector struct {
+	tableInfo  *common.TableInfo
+	colIndexes []int
+	sc         *stmtctx.StatementContext
+	rowCount   int64
+	// Each sampled row holds the key encoded value of each collected column
+	samples      [][][]byte
+	shardSamples []shardSample
+	rnd          *rand.Rand
+}
+
+type shardSample struct {
+	rowCount int64
+	samples  [][][]byte
+}
+
+func NewStatsCollector(tableInfo *common.TableInfo) *StatsCollector {
+	var colIndexes []int
+	for colIndex := range tableInfo.ColumnNames {
+		if tableInfo.ColsVisible != nil && !tableInfo.ColsVisible[colIndex] {
+			continue
+		}
+		colIndexes = append(colIndexes, colIndex)
+	}
+	return &StatsCollector{
+		tableInfo:  tableInfo,
+		colIndexes: colIndexes,
+		sc:         &stmtctx.StatementContext{TimeZone: time.UTC},
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+}
+
+// Query returns the pull query which selects the rows to be sampled from each shard.
+func (s *StatsCollector) Query() string {
+	colNames := make([]string, len(s.colIndexes))
+	for i, colIndex := range s.colIndexes {
+		colNames[i] = s.tableInfo.ColumnNames[colIndex]
+	}
+	return fmt.Sprintf("select %s from %s", strings.Join(colNames, ", "), s.tableInfo.Name)
+}
+
+// SampleSize returns the number of rows to sample from each shard
+func (s *StatsCollector) SampleSize() int {
+	return statsSampleSize
+}
+
+// AddShardSample adds a random sample of the rows of a shard. Each row has the number of rows in the shard appended
+// after the columns selected by Query.
+func (s *StatsCollector) AddShardSample(rows *common.Rows) error {
+	if rows.RowCount() == 0 {
+		return nil
+	}
+	sample := shardSample{samples: make([][][]byte, rows.RowCount())}
+	for i := 0; i < rows.RowCount(); i++ {
+		row := rows.GetRow(i)
+		encoded, err := s.encodeRow(&row)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		sample.samples[i] = encoded
+		sample.rowCount = row.GetInt64(len(s.colIndexes))
+	}
+	s.shardSamples = append(s.shardSamples, sample)
+	return nil
+}
+
+// mergeShardSamples combines the samples from the shards into a sample of the whole table, taking rows from each
+// shard's sample in proportion to the number of rows in the shard, so every row is equally likely to be sampled
+func (s *StatsCollector) mergeShardSamples() {
+	var total int64
+	for _, sample := range s.shardSamples {
+		total += sample.rowCount
+	}
+	for _, sample := range s.shardSamples {
+		num := int(math.Round(float64(statsSampleSize) * float64(sample.rowCount) / float64(total)))
+		if num > len(sample.samples) {
+			num = len(sample.samples)
+		}
+		s.rnd.Shuffle(len(sample.samples), func(i, j int) {
+			sample.samples[i], sample.samples[j] = sample.samples[j], sample.samples[i]
+		})
+		s.samples = append(s.samples, sample.samples[:num]...)
+	}
+	s.rowCount += total
+	s.shardSamples = nil
+}
+
+func (s *StatsCollector) encodeRow(row *common.Row) ([][]byte, error) {
+	sample := make([][]byte, len(s.colIndexes))
+	for i, colIndex := range s.colIndexes {
+		colType := s.tableInfo.ColumnTypes[colIndex]
+		var d types.Datum
+		if !row.IsNull(i) {
+			switch colType.Type {
+			case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
+				d = types.NewIntDatum(row.GetInt64(i))
+			case common.TypeDouble:
+				d.SetFloat64(row.GetFloat64(i))
+			case common.TypeVarchar:
+				d = types.NewStringDatum(row.GetString(i))
+			case common.TypeDecimal:
+				dec := row.GetDecimal(i)
+				myDec := new(types.MyDecimal)
+				if err := myDec.FromString([]byte(dec.String())); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				d = types.NewDecimalDatum(myDec)
+				d.SetLength(colType.DecPrecision)
+				d.SetFrac(colType.DecScale)
+			case common.TypeTimestamp:
+				d = types.NewTimeDatum(row.GetTimestamp(i))
+			default:
+				return nil, errors.Errorf("unexpected column type %d", colType.Type)
+			}
+		}
+		encoded, err := codec.EncodeKey(s.sc, nil, d)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sample[i] = encoded
+	}
+	return sample, nil
+}
+
+// Build creates the statistics from the samples added so far.
+func (s *StatsCollector) Build() *common.TableStats {
+	s.mergeShardSamples()
+	stats := &common.TableStats{
+		TableID:  s.tableInfo.ID,
+		RowCount: s.rowCount,
+		Columns:  make(map[string]*common.HistogramStats, len(s.colIndexes)),
+		Indexes:  make(map[uint64]*common.HistogramStats, len(s.tableInfo.IndexInfos)),
+	}
+	positions := make(map[int]int, len(s.colIndexes))
+	for i, colIndex := range s.colIndexes {
+		positions[colIndex] = i
+		values := make([][]byte, len(s.samples))
+		for j, sample := range s.samples {
+			values[j] = sample[i]
+		}
+		stats.Columns[s.tableInfo.ColumnNames[colIndex]] = s.buildHistogram(values, true)
+	}
+	for _, indexInfo := range s.tableInfo.IndexInfos {
+		if indexInfo.HasIndexExprs() || indexInfo.Predicate != "" {
+			// The sampled rows don't tell us the keys of a functional index, or which rows a partial index holds
+			continue
+		}
+		values := make([][]byte, len(s.samples))
+		for j, sample := range s.samples {
+			var key []byte
+			for _, colIndex := range indexInfo.IndexCols {
+				pos, ok := positions[colIndex]
+				if !ok {
+					// The index is on a column we haven't collected
+					key = nil
+					break
+				}
+				key = append(key, sample[pos]...)
+			}
+			values[j] = key
+		}
+		if len(values) > 0 && values[0] == nil {
+			continue
+		}
+		stats.Indexes[indexInfo.ID] = s.buildHistogram(values, false)
+	}
+	return stats
+}
+
+// buildHistogram builds an equi-depth histogram from the sampled values. For a column, nulls are counted separately
+// and are not included in the histogram, as the planner expects.
+func (s *StatsCollector) buildHistogram(values [][]byte, excludeNulls bool) *common.HistogramStats {
+	scale := 1.0
+	if len(s.samples) > 0 {
+		scale = float64(s.rowCount) / float64(len(s.samples))
+	}
+	hist := &common.HistogramStats{}
+	var totSize int64
+	nonNull := values[:0:0]
+	for _, value := range values {
+		totSize += int64(len(value))
+		if excludeNulls && value[0] == codec.NilFlag {
+			hist.NullCount++
+			continue
+		}
+		nonNull = append(nonNull, value)
+	}
+	hist.NullCount = int64(math.Round(float64(hist.NullCount) * scale))
+	hist.TotColSize = int64(math.Round(float64(totSize) * scale))
+	sort.Slice(nonNull, func(i, j int) bool {
+		return bytes.Compare(nonNull[i], nonNull[j]) < 0
+	})
+	depth := (len(nonNull) + statsNumBuckets - 1) / statsNumBuckets
+	var distinct, singletons, bucketStart int
+	for i := 0; i < len(nonNull); {
+		j := i + 1
+		for j < len(nonNull) && bytes.Equal(nonNull[i], nonNull[j]) {
+			j++
+		}
+		repeat := j - i
+		distinct++
+		if repeat == 1 {
+			singletons++
+		}
+		if len(hist.Buckets) == 0 || i-bucketStart >= depth {
+			hist.Buckets = append(hist.Buckets, common.StatsBucket{Lower: nonNull[i]})
+			bucketStart = i
+		}
+		bucket := &hist.Buckets[len(hist.Buckets)-1]
+		bucket.Upper = nonNull[i]
+		bucket.Count = int64(math.Round(float64(j) * scale))
+		bucket.Repeat = int64(math.Round(float64(repeat) * scale))
+		i = j
+	}
+	hist.NDV = estimateNDV(int64(len(nonNull)), int64(float64(len(nonNull))*scale), int64(distinct), int64(singletons))
+	return hist
+}
+
+// estimateNDV estimates the number of distinct values in the whole table from the number of distinct values in the
+// sample, using the Duj1 estimator from Haas et al.
+func estimateNDV(sampleSize int64, totalSize int64, distinct int64, singletons int64) int64 {
+	if sampleSize == 0 || sampleSize >= totalSize {
+		return distinct
+	}
+	n, N, d, f1 := float64(sampleSize), float64(totalSize), float64(distinct), float64(singletons)
+	ndv := n * d / (n - f1 + f1*n/N)
+	if ndv > N {
+		ndv = N
+	}
+	return int64(math.Round(ndv))
+}
+
+// toStatisticsTable converts the collected statistics for a table into the form used by the TiDB planner
+func toStatisticsTable(tblInfo *model.TableInfo, stats *common.TableStats) (*statistics.Table, error) {
+	t := statistics.PseudoTable(tblInfo)
+	t.Pseudo = false
+	t.Count = stats.RowCount
+	for _, col := range tblInfo.Columns {
+		colStats, ok := stats.Columns[col.Name.L]
+		if !ok {
+			continue
+		}
+		hist, err := toHistogram(col.ID, colStats, &col.FieldType, false)
+		if err != nil {
+			return nil, err
+		}
+		t.Columns[col.ID] = &statistics.Column{
+			PhysicalID: tblInfo.ID,
+			Info:       col,
+			IsHandle:   tblInfo.PKIsHandle && mysql.HasPriKeyFlag(col.Flag),
+			Histogram:  *hist,
+			Count:      int64(hist.TotalRowCount()),
+		}
+	}
+	for _, idx := range tblInfo.Indices {
+		idxStats, ok := stats.Indexes[uint64(idx.ID)]
+		if !ok {
+			continue
+		}
+		hist, err := toHistogram(idx.ID, idxStats, types.NewFieldType(mysql.TypeBlob), true)
+		if err != nil {
+			return nil, err
+		}
+		t.Indices[idx.ID] = &statistics.Index{
+			Info:      idx,
+			Histogram: *hist,
+		}
+	}
+	return t, nil
+}
+
+func toHistogram(id int64, histStats *common.HistogramStats, tp *types.FieldType, index bool) (*statistics.Histogram, error) {
+	hist := statistics.NewHistogram(id, histStats.NDV, histStats.NullCount, 0, tp, len(histStats.Buckets), histStats.TotColSize)
+	for _, bucket := range histStats.Buckets {
+		lower, err := decodeBound(bucket.Lower, tp, index)
+		if err != nil {
+			return nil, err
+		}
+		upper, err := decodeBound(bucket.Upper, tp, index)
+		if err != nil {
+			return nil, err
+		}
+		hist.AppendBucket(&lower, &upper, bucket.Count, bucket.Repeat)
+	}
+	hist.PreCalculateScalar()
+	return hist, nil
+}
+
+func decodeBound(encoded []byte, tp *types.FieldType, index bool) (types.Datum, error) {
+	if index {
+		// Index histograms are over the encoded keys
+		return types.NewBytesDatum(encoded), nil
+	}
+	_, d, err := codec.DecodeOne(encoded)
+	if err != nil {
+		return d, errors.WithStack(err)
+	}
+	if tp.Tp == mysql.TypeTimestamp {
+		// Times are key encoded in packed form
+		var t types.Time
+		if err := t.FromPackedUint(d.GetUint64()); err != nil {
+			return d, errors.WithStack(err)
+		}
+		t.SetType(mysql.TypeTimestamp)
+		t.SetFsp(int8(tp.Decimal))
+		d = types.NewTimeDatum(t)
+	}
+	return d, nil
+}
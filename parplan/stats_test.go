@@ -0,0 +1,133 @@
+package parplan
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/squareup/pranadb/common"
+	planner2 "github.com/squareup/pranadb/tidb/planner"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsCollector(t *testing.T) {
+	schema, err := attachIndexToSchema(createTestSchema())
+	require.NoError(t, err)
+	tableInfo := getTableInfo(t, schema, "table1")
+	stats := collectStats(t, tableInfo, 20000, func(i int) int64 { return int64(i % 10) })
+
+	require.Equal(t, int64(20000), stats.RowCount)
+	col0Stats := stats.Columns["col0"]
+	require.NotNil(t, col0Stats)
+	// Column values are unique so the estimate from the sample should be close to the row count
+	require.InDelta(t, 20000, col0Stats.NDV, 2000)
+	require.LessOrEqual(t, len(col0Stats.Buckets), statsNumBuckets)
+	require.Equal(t, int64(20000), col0Stats.Buckets[len(col0Stats.Buckets)-1].Count)
+
+	col1Stats := stats.Columns["col1"]
+	require.NotNil(t, col1Stats)
+	require.Equal(t, int64(20000), col1Stats.NullCount)
+	require.Equal(t, 0, len(col1Stats.Buckets))
+
+	col2Stats := stats.Columns["col2"]
+	require.NotNil(t, col2Stats)
+	require.Equal(t, int64(10), col2Stats.NDV)
+	require.Equal(t, 10, len(col2Stats.Buckets))
+
+	indexStats := stats.Indexes[tableInfo.IndexInfos["index1"].ID]
+	require.NotNil(t, indexStats)
+	require.Equal(t, int64(10), indexStats.NDV)
+}
+
+func TestStatsCollectorShardSamples(t *testing.T) {
+	schema, err := attachIndexToSchema(createTestSchema())
+	require.NoError(t, err)
+	tableInfo := getTableInfo(t, schema, "table1")
+	collector := NewStatsCollector(tableInfo)
+	colTypes := append(append([]common.ColumnType(nil), tableInfo.ColumnTypes...), common.BigIntColumnType)
+	// A sample from each of two shards - the first has three times as many rows as the second
+	addShardSample := func(numShardRows int64, col2Val int64) {
+		rows := common.NewRowsFactory(colTypes).NewRows(collector.SampleSize())
+		for i := 0; i < collector.SampleSize(); i++ {
+			rows.AppendInt64ToColumn(0, int64(i))
+			rows.AppendNullToColumn(1)
+			rows.AppendInt64ToColumn(2, col2Val)
+			rows.AppendInt64ToColumn(3, numShardRows)
+		}
+		require.NoError(t, collector.AddShardSample(rows))
+	}
+	addShardSample(30000, 0)
+	addShardSample(10000, 1)
+	require.NoError(t, collector.AddShardSample(common.NewRowsFactory(colTypes).NewRows(0)))
+	stats := collector.Build()
+
+	require.Equal(t, int64(40000), stats.RowCount)
+	col2Stats := stats.Columns["col2"]
+	require.NotNil(t, col2Stats)
+	require.Equal(t, 2, len(col2Stats.Buckets))
+	// The rows taken from each shard's sample are in proportion to the rows in the shard
+	require.Equal(t, int64(30000), col2Stats.Buckets[0].Repeat)
+	require.Equal(t, int64(10000), col2Stats.Buckets[1].Repeat)
+	require.Equal(t, int64(40000), col2Stats.Buckets[1].Count)
+}
+
+func TestTableScanChosenOverIndexWithLowSelectivity(t *testing.T) {
+	schema, err := attachIndexToSchema(createTestSchema())
+	require.NoError(t, err)
+	tableInfo := getTableInfo(t, schema, "table1")
+	// Every row has the same value for col2 so using the index would mean looking up every row in the table
+	stats := collectStats(t, tableInfo, 10000, func(i int) int64 { return 1 })
+	require.NoError(t, schema.PutTableStats("table1", stats))
+
+	physi, _, _, err := NewPlanner(schema).QueryToPlan("select * from table1 where col2=1", false, true)
+	require.NoError(t, err)
+	sel, ok := physi.(*planner2.PhysicalSelection)
+	require.True(t, ok)
+	_, ok = sel.Children()[0].(*planner2.PhysicalTableScan)
+	require.True(t, ok)
+}
+
+func TestIndexScanChosenWithHighSelectivity(t *testing.T) {
+	schema, err := attachIndexToSchema(createTestSchema())
+	require.NoError(t, err)
+	tableInfo := getTableInfo(t, schema, "table1")
+	stats := collectStats(t, tableInfo, 10000, func(i int) int64 { return int64(i) })
+	require.NoError(t, schema.PutTableStats("table1", stats))
+
+	physi, _, _, err := NewPlanner(schema).QueryToPlan("select * from table1 where col2=1", false, true)
+	require.NoError(t, err)
+	is, ok := physi.(*planner2.PhysicalIndexScan)
+	require.True(t, ok)
+	require.True(t, is.DoubleRead)
+	require.Equal(t, 1, len(is.Ranges))
+}
+
+func getTableInfo(t *testing.T, schema *common.Schema, tableName string) *common.TableInfo {
+	t.Helper()
+	tbl, ok := schema.GetTable(tableName)
+	require.True(t, ok)
+	return tbl.GetTableInfo()
+}
+
+// collectStats collects stats for table1, with col1 always null and col2 given by col2Val. The rows are split into
+// shards no bigger than the sample size, so the sample of each shard holds all its rows.
+func collectStats(t *testing.T, tableInfo *common.TableInfo, numRows int, col2Val func(i int) int64) *common.TableStats {
+	t.Helper()
+	collector := NewStatsCollector(tableInfo)
+	require.Equal(t, fmt.Sprintf("select col0, col1, col2 from %s", tableInfo.Name), collector.Query())
+	colTypes := append(append([]common.ColumnType(nil), tableInfo.ColumnTypes...), common.BigIntColumnType)
+	for start := 0; start < numRows; start += collector.SampleSize() {
+		end := start + collector.SampleSize()
+		if end > numRows {
+			end = numRows
+		}
+		rows := common.NewRowsFactory(colTypes).NewRows(end - start)
+		for i := start; i < end; i++ {
+			rows.AppendInt64ToColumn(0, int64(i))
+			rows.AppendNullToColumn(1)
+			rows.AppendInt64ToColumn(2, col2Val(i))
+			rows.AppendInt64ToColumn(3, int64(end-start))
+		}
+		require.NoError(t, collector.AddShardSample(rows))
+	}
+	return collector.Build()
+}
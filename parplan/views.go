@@ -0,0 +1,113 @@
+package parplan
+
+import (
+	"sort"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+)
+
+// maxViewDepth is how deeply views can be nested in other views
+const maxViewDepth = 32
+
+// expandViews replaces each reference to a view in the statement with the query of the view, as a derived table with
+// the name of the view, so the query of the view is planned as part of the statement
+func (p *Planner) expandViews(stmt ast.Node, depth int) error {
+	vis := &viewExpander{p: p, depth: depth}
+	stmt.Accept(vis)
+	return vis.err
+}
+
+type viewExpander struct {
+	p     *Planner
+	depth int
+	err   error
+}
+
+func (v *viewExpander) Enter(in ast.Node) (ast.Node, bool) {
+	if v.err != nil {
+		return in, true
+	}
+	ts, ok := in.(*ast.TableSource)
+	if !ok {
+		return in, false
+	}
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok || (tn.Schema.L != "" && tn.Schema.L != v.p.schema.Name) {
+		return in, false
+	}
+	tbl, ok := v.p.schema.GetTable(tn.Name.L)
+	if !ok {
+		return in, false
+	}
+	view, ok := tbl.(*common.ViewInfo)
+	if !ok {
+		return in, false
+	}
+	if tn.AsOf != nil {
+		v.err = errors.NewPranaErrorf(errors.InvalidStatement, "Cannot use AS OF with view %s", view.Name)
+		return in, true
+	}
+	if v.depth >= maxViewDepth {
+		v.err = errors.NewPranaErrorf(errors.InvalidStatement, "Views are nested more than %d deep", maxViewDepth)
+		return in, true
+	}
+	handle, _, err := v.p.Parse(view.Query)
+	if err != nil {
+		v.err = errors.WithStack(err)
+		return in, true
+	}
+	if err := v.p.expandViews(handle.stmt, v.depth+1); err != nil {
+		v.err = err
+		return in, true
+	}
+	rs, ok := handle.stmt.(ast.ResultSetNode)
+	if !ok {
+		v.err = errors.Errorf("query of view %s is not a select", view.Name)
+		return in, true
+	}
+	ts.Source = rs
+	if ts.AsName.L == "" {
+		ts.AsName = tn.Name
+	}
+	// The query of the view has already been expanded, so there's no need to visit it
+	return in, true
+}
+
+func (v *viewExpander) Leave(in ast.Node) (ast.Node, bool) {
+	return in, v.err == nil
+}
+
+// ReferencedTableNames returns the names of the tables and views in the given schema which are used by a query,
+// ordered by name
+func ReferencedTableNames(schemaName string, query string) ([]string, error) {
+	handle, _, err := NewParser().Parse(query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	vis := &tableNameCollector{schemaName: schemaName, names: map[string]struct{}{}}
+	handle.stmt.Accept(vis)
+	names := make([]string, 0, len(vis.names))
+	for name := range vis.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+type tableNameCollector struct {
+	schemaName string
+	names      map[string]struct{}
+}
+
+func (t *tableNameCollector) Enter(in ast.Node) (ast.Node, bool) {
+	if tn, ok := in.(*ast.TableName); ok && (tn.Schema.L == "" || tn.Schema.L == t.schemaName) {
+		t.names[tn.Name.L] = struct{}{}
+	}
+	return in, false
+}
+
+func (t *tableNameCollector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
@@ -28,6 +28,7 @@ type Engine struct {
 	shrder            *sharder.Sharder
 	available         common.AtomicBool
 	cfg               *conf.Config
+	virtualTables     sync.Map
 }
 
 func NewPullEngine(cluster cluster.Cluster, metaController *meta.Controller, shrder *sharder.Sharder, cfg *conf.Config) *Engine {
@@ -64,6 +65,11 @@ func (p *Engine) Stop() error {
 	return nil
 }
 
+// RegisterVirtualTable registers what generates the rows of the virtual table with the given id
+func (p *Engine) RegisterVirtualTable(tableID uint64, virtualTable exec.VirtualTable) {
+	p.virtualTables.Store(tableID, virtualTable)
+}
+
 func (p *Engine) SetAvailable() error {
 	// We execute a query which will fan out to all shards and wait until they are available
 	_, err := p.ExecuteQuery("sys", "select * from dummy")
@@ -80,6 +86,7 @@ func (p *Engine) BuildPullQuery(execCtx *execctx.ExecutionContext, query string,
 	qi.ExecutionID = execCtx.ID
 	qi.SchemaName = execCtx.Schema.Name
 	qi.Query = query
+	qi.AsOf = 0
 	isPs := len(argTypes) != 0
 	if isPs {
 		// It's a prepared statement
@@ -108,6 +115,79 @@ func (p *Engine) BuildPullQuery(execCtx *execctx.ExecutionContext, query string,
 	return p.buildPullDAGWithOutputNames(execCtx, logicalPlan, physicalPlan, false, p.cfg.OrderByMaxRows)
 }
 
+// SampleShards gets a random sample of at most sampleSize of the rows scanned by a query from each shard. The rows
+// are sampled on the shards, so only the samples are sent to this node. Each sampled row has the number of rows of
+// its shard appended as an extra BIGINT column.
+func (p *Engine) SampleShards(execCtx *execctx.ExecutionContext, query string, sampleSize int,
+	batchSize int) (map[uint64]*common.Rows, error) {
+	dag, err := p.BuildPullQuery(execCtx, query, nil, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	remote := findRemoteExecutor(dag)
+	if remote == nil {
+		return nil, errors.Errorf("cannot sample query %s", query)
+	}
+	colTypes := append(append([]common.ColumnType(nil), remote.ColTypes()...), common.BigIntColumnType)
+	rowsFactory := common.NewRowsFactory(colTypes)
+	shardIDs := p.cluster.GetAllShardIDs()
+	samples := make([]*common.Rows, len(shardIDs))
+	errs := make([]error, len(shardIDs))
+	var wg sync.WaitGroup
+	wg.Add(len(shardIDs))
+	for i, shardID := range shardIDs {
+		i, shardID := i, shardID
+		go func() {
+			defer wg.Done()
+			samples[i], errs[i] = p.sampleShard(execCtx, query, shardID, sampleSize, batchSize, rowsFactory)
+		}()
+	}
+	wg.Wait()
+	shardSamples := make(map[uint64]*common.Rows, len(shardIDs))
+	for i, shardID := range shardIDs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		shardSamples[shardID] = samples[i]
+	}
+	return shardSamples, nil
+}
+
+func (p *Engine) sampleShard(execCtx *execctx.ExecutionContext, query string, shardID uint64, sampleSize int,
+	batchSize int, rowsFactory *common.RowsFactory) (*common.Rows, error) {
+	queryInfo := &cluster.QueryExecutionInfo{
+		ExecutionID: fmt.Sprintf("%s-sample-%d", execCtx.ID, shardID),
+		SchemaName:  execCtx.Schema.Name,
+		Query:       query,
+		Limit:       uint32(batchSize),
+		ShardID:     shardID,
+		SampleSize:  uint32(sampleSize),
+	}
+	sample := rowsFactory.NewRows(batchSize)
+	for {
+		rows, err := p.cluster.ExecuteRemotePullQuery(queryInfo, rowsFactory)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sample.AppendAll(rows)
+		if rows.RowCount() < batchSize {
+			return sample, nil
+		}
+	}
+}
+
+func findRemoteExecutor(executor exec.PullExecutor) *exec.RemoteExecutor {
+	if re, ok := executor.(*exec.RemoteExecutor); ok {
+		return re
+	}
+	for _, child := range executor.GetChildren() {
+		if re := findRemoteExecutor(child); re != nil {
+			return re
+		}
+	}
+	return nil
+}
+
 // ExecuteRemotePullQuery - executes a pull query received from another node
 //nolint:gocyclo
 func (p *Engine) ExecuteRemotePullQuery(queryInfo *cluster.QueryExecutionInfo) (*common.Rows, error) {
@@ -154,6 +234,10 @@ func (p *Engine) ExecuteRemotePullQuery(queryInfo *cluster.QueryExecutionInfo) (
 		if scan == nil {
 			return nil, errors.Error("cannot find scan")
 		}
+		if queryInfo.SampleSize > 0 {
+			// Only the sample of the rows of the shard is returned
+			scan = exec.NewPullSample(scan, int(queryInfo.SampleSize))
+		}
 		execCtx.CurrentQuery = scan
 	} else if execCtx.QueryInfo.Query != queryInfo.Query {
 		// Sanity check
@@ -0,0 +1,141 @@
+package exec
+
+import (
+	"bytes"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/table"
+)
+
+type localScanner interface {
+	LocalScan(startKeyPrefix []byte, endKeyPrefix []byte, limit int) ([]cluster.KVPair, error)
+}
+
+type localIterator interface {
+	LocalIterator(startKeyPrefix []byte, endKeyPrefix []byte) cluster.KVIterator
+}
+
+// asOfScanner scans the rows of a table in a shard as they were at a time in the past. It does this by merging the
+// prior versions of rows which the table keeps in its version table with the current rows of the table. The version
+// entries are ordered by the key of their row, so only the versions of the rows in the range being scanned are read,
+// and the scan stops as soon as it has enough rows.
+type asOfScanner struct {
+	storage   localIterator
+	tableInfo *common.TableInfo
+	shardID   uint64
+	asOf      int64
+}
+
+func (a *asOfScanner) LocalScan(startKeyPrefix []byte, endKeyPrefix []byte, limit int) ([]cluster.KVPair, error) {
+	tablePrefix := table.EncodeTableKeyPrefix(a.tableInfo.ID, a.shardID, 16)
+	if endKeyPrefix == nil {
+		endKeyPrefix = table.EncodeTableKeyPrefix(a.tableInfo.ID+1, a.shardID, 16)
+	}
+	// We must open the current rows before the versions. A batch which commits in between adds a version holding the
+	// row we read, so we still get the right row. The other way round we could miss the version.
+	current := a.storage.LocalIterator(startKeyPrefix, endKeyPrefix)
+	defer closeIterator(current)
+	versionStart := table.EncodeVersionKeyPrefix(a.tableInfo.VersionTableID, a.shardID, startKeyPrefix[len(tablePrefix):])
+	versionEnd := table.EncodeVersionEntriesEnd(a.tableInfo.VersionTableID, a.shardID)
+	if bytes.HasPrefix(endKeyPrefix, tablePrefix) {
+		versionEnd = table.EncodeVersionKeyPrefix(a.tableInfo.VersionTableID, a.shardID, endKeyPrefix[len(tablePrefix):])
+	}
+	versions := &asOfVersions{
+		iter:        a.storage.LocalIterator(versionStart, versionEnd),
+		asOf:        a.asOf,
+		tablePrefix: tablePrefix,
+		start:       startKeyPrefix,
+		end:         endKeyPrefix,
+	}
+	defer closeIterator(versions.iter)
+
+	var pairs []cluster.KVPair
+	var cur cluster.KVPair
+	var ver rowVersion
+	var hasCur, hasVer bool
+	// The next row and version are only read when they're needed, so nothing is read after the last row
+	nextCur, nextVer := true, true
+	for limit == -1 || len(pairs) < limit {
+		if nextCur {
+			cur, hasCur = nextPair(current)
+			nextCur = false
+		}
+		if nextVer {
+			ver, hasVer = versions.next()
+			nextVer = false
+		}
+		if !hasCur && !hasVer {
+			break
+		}
+		if hasCur && (!hasVer || bytes.Compare(cur.Key, ver.key) < 0) {
+			// The row hasn't changed since the time
+			pairs = append(pairs, cur)
+			nextCur = true
+			continue
+		}
+		if hasCur && bytes.Equal(cur.Key, ver.key) {
+			nextCur = true
+		}
+		// The row was changed after the time, or has since been deleted, so its version is the row at the time
+		if ver.exists {
+			pairs = append(pairs, cluster.KVPair{Key: ver.key, Value: ver.value})
+		}
+		nextVer = true
+	}
+	return pairs, nil
+}
+
+type rowVersion struct {
+	key    []byte
+	value  []byte
+	exists bool
+}
+
+// asOfVersions iterates over the versions of the rows which were current at a time, in the order of their keys
+type asOfVersions struct {
+	iter        cluster.KVIterator
+	asOf        int64
+	tablePrefix []byte
+	start       []byte
+	end         []byte
+	lastPK      []byte
+}
+
+func (v *asOfVersions) next() (rowVersion, bool) {
+	for v.iter.HasNext() {
+		pair := v.iter.Next()
+		endTime, pk := table.DecodeVersionKey(pair.Key)
+		if v.lastPK != nil && bytes.Equal(pk, v.lastPK) {
+			// We already have the version of this row
+			continue
+		}
+		if endTime <= v.asOf {
+			// The version was replaced before the time
+			continue
+		}
+		key := append(common.CopyByteSlice(v.tablePrefix), pk...)
+		if bytes.Compare(key, v.start) < 0 || bytes.Compare(key, v.end) >= 0 {
+			continue
+		}
+		// The versions of a row are in order of time, so the first one replaced after the time is the row at the time
+		v.lastPK = pk
+		value, exists := table.DecodeVersionValue(pair.Value)
+		return rowVersion{key: key, value: value, exists: exists}, true
+	}
+	return rowVersion{}, false
+}
+
+func nextPair(iter cluster.KVIterator) (cluster.KVPair, bool) {
+	if !iter.HasNext() {
+		return cluster.KVPair{}, false
+	}
+	return iter.Next(), true
+}
+
+func closeIterator(iter cluster.KVIterator) {
+	if err := iter.Close(); err != nil {
+		log.Errorf("failed to close local iterator %v", err)
+	}
+}
@@ -17,8 +17,11 @@ type PullIndexReader struct {
 	covers            bool
 	indexOutputCols   []int
 	pkOutputCols      []int
+	includeOutputCols []int
 	indexColTypes     []common.ColumnType
 	pkColTypes        []common.ColumnType
+	includeColTypes   []common.ColumnType
+	pkSkipCols        []int
 	includedTableCols []bool
 	rows              *common.Rows
 	rangeIndex        int
@@ -53,9 +56,14 @@ func NewPullIndexReader(tableInfo *common.TableInfo, //nolint:gocyclo
 	for _, pkCol := range tableInfo.PrimaryKeyCols {
 		pkSet[pkCol] = struct{}{}
 	}
-	var indexOutputCols, pkOutputCols []int
-	var indexColTypes, pkColTypes []common.ColumnType
+	var indexOutputCols, pkOutputCols, includeOutputCols []int
+	var indexColTypes, includeColTypes []common.ColumnType
+	var pkSkipCols []int
 	var includedCols []bool
+	pkColTypes := make([]common.ColumnType, len(tableInfo.PrimaryKeyCols))
+	for i, pkCol := range tableInfo.PrimaryKeyCols {
+		pkColTypes[i] = tableInfo.ColumnTypes[pkCol]
+	}
 	if covers {
 		// For each index column we calculate the position in the output or -1 if it doesn't appear in the output
 		indexOutputCols = make([]int, len(indexInfo.IndexCols))
@@ -74,10 +82,7 @@ func NewPullIndexReader(tableInfo *common.TableInfo, //nolint:gocyclo
 			indexOutputCols[i] = -1
 		}
 
-		indexColTypes = make([]common.ColumnType, len(indexInfo.IndexCols))
-		for i, indexCol := range indexInfo.IndexCols {
-			indexColTypes[i] = tableInfo.ColumnTypes[indexCol]
-		}
+		indexColTypes = indexInfo.KeyColTypes(tableInfo.ColumnTypes)
 
 		// We do the same for PK cols
 		pkOutputCols = make([]int, len(tableInfo.PrimaryKeyCols))
@@ -91,9 +96,17 @@ func NewPullIndexReader(tableInfo *common.TableInfo, //nolint:gocyclo
 			}
 		}
 
-		pkColTypes = make([]common.ColumnType, len(tableInfo.PrimaryKeyCols))
-		for i, pkCol := range tableInfo.PrimaryKeyCols {
-			pkColTypes[i] = tableInfo.ColumnTypes[pkCol]
+		// And for the included cols, which are stored in the value after the PK
+		includeOutputCols = make([]int, len(indexInfo.IncludeCols))
+		includeColTypes = make([]common.ColumnType, len(indexInfo.IncludeCols))
+		for i, includeCol := range indexInfo.IncludeCols {
+			position, ok := colIndexesMap[includeCol]
+			if ok {
+				includeOutputCols[i] = position
+			} else {
+				includeOutputCols[i] = -1
+			}
+			includeColTypes[i] = tableInfo.ColumnTypes[includeCol]
 		}
 	} else {
 		// We create an array which tells us, for each column in the table, whether it's included in the output
@@ -102,6 +115,11 @@ func NewPullIndexReader(tableInfo *common.TableInfo, //nolint:gocyclo
 			_, ok := colIndexesMap[i]
 			includedCols[i] = ok
 		}
+		// If the index has included cols the value has them after the PK, so we need to be able to skip over the PK
+		pkSkipCols = make([]int, len(tableInfo.PrimaryKeyCols))
+		for i := range pkSkipCols {
+			pkSkipCols[i] = -1
+		}
 	}
 
 	// Calculate the types of the results
@@ -114,7 +132,8 @@ func NewPullIndexReader(tableInfo *common.TableInfo, //nolint:gocyclo
 		resultColNames = append(resultColNames, tableInfo.ColumnNames[colIndex])
 	}
 
-	rangeHolders, err := calcScanRangeKeys(scanRanges, indexInfo.ID, indexInfo.IndexCols, tableInfo, shardID, true)
+	rangeHolders, err := calcScanRangeKeys(scanRanges, indexInfo.ID, indexInfo.KeyColTypes(tableInfo.ColumnTypes),
+		indexInfo.IndexColsDesc, shardID, true)
 	if err != nil {
 		return nil, err
 	}
@@ -135,8 +154,11 @@ func NewPullIndexReader(tableInfo *common.TableInfo, //nolint:gocyclo
 		covers:            covers,
 		indexOutputCols:   indexOutputCols,
 		pkOutputCols:      pkOutputCols,
+		includeOutputCols: includeOutputCols,
 		indexColTypes:     indexColTypes,
 		pkColTypes:        pkColTypes,
+		includeColTypes:   includeColTypes,
+		pkSkipCols:        pkSkipCols,
 		includedTableCols: includedCols,
 		rangeHolders:      rangeHolders,
 	}, nil
@@ -180,17 +202,31 @@ func (p *PullIndexReader) getRowsFromRange(limit int, currRange *rangeHolder) er
 		}
 		if p.covers {
 			// Decode cols from the index
-			if _, err = common.DecodeIndexOrPKCols(kvPair.Key, 16, false, p.indexColTypes, p.indexOutputCols, p.rows); err != nil {
+			if _, err = common.DecodeIndexOrPKCols(kvPair.Key, 16, false, p.indexColTypes, p.indexInfo.IndexColsDesc, p.indexOutputCols, p.rows); err != nil {
 				return err
 			}
 			// And any from the PK
-			if _, err = common.DecodeIndexOrPKCols(kvPair.Value, 0, true, p.pkColTypes, p.pkOutputCols, p.rows); err != nil {
+			offset, err := common.DecodeIndexOrPKCols(kvPair.Value, 0, true, p.pkColTypes, p.tableInfo.PrimaryKeyDesc, p.pkOutputCols, p.rows)
+			if err != nil {
+				return err
+			}
+			// And any included cols
+			if _, err = common.DecodeIndexOrPKCols(kvPair.Value, offset, false, p.includeColTypes, nil, p.includeOutputCols, p.rows); err != nil {
 				return err
 			}
 		} else {
 			// Index doesn't cover, and we get cols from originating table
-			keyBuff := table.EncodeTableKeyPrefix(p.tableInfo.ID, p.shardID, 16+len(kvPair.Value))
-			keyBuff = append(keyBuff, kvPair.Value...)
+			pk := kvPair.Value
+			if len(p.indexInfo.IncludeCols) > 0 {
+				// The value has the included cols after the PK
+				pkLen, err := common.DecodeIndexOrPKCols(pk, 0, true, p.pkColTypes, p.tableInfo.PrimaryKeyDesc, p.pkSkipCols, nil)
+				if err != nil {
+					return err
+				}
+				pk = pk[:pkLen]
+			}
+			keyBuff := table.EncodeTableKeyPrefix(p.tableInfo.ID, p.shardID, 16+len(pk))
+			keyBuff = append(keyBuff, pk...)
 			value, err := p.storage.LocalGet(keyBuff)
 			if err != nil {
 				return errors.WithStack(err)
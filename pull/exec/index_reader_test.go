@@ -182,6 +182,42 @@ func TestReadIndexNotCovers(t *testing.T) {
 	testReadIndex(t, indexCols, pkCols, ranges, tableColIndexes, inpRows, tableColNames, tableColTypes, expectedRows, expectedColTypes)
 }
 
+func TestReadIndexIncludeCols(t *testing.T) {
+	inpRows := [][]interface{}{
+		{3, 2, "ccc", 3.3},
+		{5, nil, nil, 5.5},
+		{2, 2, "bbb", 2.2},
+		{1, 1, "aaa", 1.1},
+		{4, nil, "ddd", 4.4},
+	}
+	tableColNames := []string{"pk", "col1", "col2", "col3"}
+	tableColTypes := []common.ColumnType{common.BigIntColumnType, common.BigIntColumnType, common.VarcharColumnType, common.DoubleColumnType}
+	pkCols := []int{0}
+	indexCols := []int{1}
+	includeCols := []int{2}
+
+	// The included col is read from the index
+	tableColIndexes := []int{0, 1, 2}
+	expectedColTypes := []common.ColumnType{common.BigIntColumnType, common.BigIntColumnType, common.VarcharColumnType}
+	expectedRows := [][]interface{}{{2, 2, "bbb"}, {3, 2, "ccc"}}
+	ranges := createSimpleRanges(int64(2), int64(2), false, false)
+	testIndexReader(t, indexCols, includeCols, pkCols, ranges, tableColIndexes, inpRows, tableColNames, tableColTypes, expectedRows, expectedColTypes, true)
+
+	// Just the included col, including a null
+	tableColIndexes = []int{2}
+	expectedColTypes = []common.ColumnType{common.VarcharColumnType}
+	expectedRows = [][]interface{}{{"ddd"}, {nil}}
+	ranges = createSimpleRanges(nil, nil, false, false)
+	testIndexReader(t, indexCols, includeCols, pkCols, ranges, tableColIndexes, inpRows, tableColNames, tableColTypes, expectedRows, expectedColTypes, true)
+
+	// A col which isn't included means the row must be looked up in the table
+	tableColIndexes = []int{0, 2, 3}
+	expectedColTypes = []common.ColumnType{common.BigIntColumnType, common.VarcharColumnType, common.DoubleColumnType}
+	expectedRows = [][]interface{}{{2, "bbb", 2.2}, {3, "ccc", 3.3}}
+	ranges = createSimpleRanges(int64(2), int64(2), false, false)
+	testIndexReader(t, indexCols, includeCols, pkCols, ranges, tableColIndexes, inpRows, tableColNames, tableColTypes, expectedRows, expectedColTypes, false)
+}
+
 func TestCompositePK(t *testing.T) {
 	inpRows := [][]interface{}{
 		{2, 1, 2, "ccc"},
@@ -296,22 +332,32 @@ func createSimpleRanges(lowVal interface{}, highVal interface{}, lowExcl bool, h
 func testReadIndex(t *testing.T, indexCols []int, pkCols []int, ranges []*ScanRange, tableColIndexes []int, inpRows [][]interface{},
 	tableColNames []string, tableColTypes []common.ColumnType, expectedRows [][]interface{}, expectedColTypes []common.ColumnType) {
 	t.Helper()
-	testIndexReader(t, indexCols, pkCols, ranges, tableColIndexes, inpRows, tableColNames, tableColTypes, expectedRows, expectedColTypes)
+	covers := true
+	for _, colIndex := range tableColIndexes {
+		if !contains(indexCols, colIndex) && !contains(pkCols, colIndex) {
+			covers = false
+		}
+	}
+	testIndexReader(t, indexCols, nil, pkCols, ranges, tableColIndexes, inpRows, tableColNames, tableColTypes, expectedRows, expectedColTypes, covers)
 }
 
-func testIndexReader(t *testing.T, indexCols []int, pkCols []int, scanRanges []*ScanRange,
+func contains(cols []int, col int) bool {
+	for _, c := range cols {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+func testIndexReader(t *testing.T, indexCols []int, includeCols []int, pkCols []int, scanRanges []*ScanRange,
 	tableColIndexes []int, inpRows [][]interface{}, tableColNames []string,
-	tableColTypes []common.ColumnType, expectedRows [][]interface{}, expectedColTypes []common.ColumnType) {
+	tableColTypes []common.ColumnType, expectedRows [][]interface{}, expectedColTypes []common.ColumnType, expectCovers bool) {
 	t.Helper()
-	indexInfo := &common.IndexInfo{
-		SchemaName: "test",
-		ID:         1000000,
-		TableName:  "test_table",
-		Name:       "test_index",
-		IndexCols:  indexCols,
-	}
+	indexInfo := common.NewIndexInfo("test", 1000000, "test_table", "test_index", indexCols, includeCols)
 	ir, clust := setupIndexReader(t, inpRows, scanRanges, tableColIndexes, tableColNames, tableColTypes, pkCols, indexInfo)
 	defer stopCluster(t, clust)
+	require.Equal(t, expectCovers, ir.(*PullIndexReader).covers)
 	exp := toRows(t, expectedRows, expectedColTypes)
 	provided, err := ir.GetRows(100)
 	require.NoError(t, err)
@@ -347,6 +393,7 @@ func setupIndexReader(t *testing.T, inputRows [][]interface{}, scanRanges []*Sca
 			indexInfo.Name: indexInfo,
 		},
 	}
+	tableInfo.CalcPKColsSet()
 
 	inpRows := toRows(t, inputRows, colTypes)
 
@@ -20,6 +20,11 @@ type RemoteExecutor struct {
 	RemoteDag               PullExecutor
 	ShardIDs                []uint64
 	singlePointGetQueryInfo *cluster.QueryExecutionInfo
+	// When the rows from each shard are ordered, we merge them so they're returned in the same order
+	sortByExpressions []*common.Expression
+	descending        []bool
+	shardRows         []*common.Rows
+	shardRowIndexes   []int
 }
 
 func NewRemoteExecutor(remoteDAG PullExecutor, queryInfo *cluster.QueryExecutionInfo, colNames []string,
@@ -67,6 +72,19 @@ func NewRemoteExecutor(remoteDAG PullExecutor, queryInfo *cluster.QueryExecution
 	return &re
 }
 
+// NewOrderedRemoteExecutor creates a RemoteExecutor for a remote DAG which returns its rows from each shard in the order
+// of the sort expressions, the rows from all the shards are merged so they are returned in that order too.
+func NewOrderedRemoteExecutor(remoteDAG PullExecutor, queryInfo *cluster.QueryExecutionInfo, colNames []string,
+	colTypes []common.ColumnType, schemaName string, clust cluster.Cluster, pointGetShardIDs []uint64, desc []bool,
+	sortByExpressions []*common.Expression) *RemoteExecutor {
+	re := NewRemoteExecutor(remoteDAG, queryInfo, colNames, colTypes, schemaName, clust, pointGetShardIDs)
+	re.sortByExpressions = sortByExpressions
+	re.descending = desc
+	re.shardRows = make([]*common.Rows, len(re.clusterGetters))
+	re.shardRowIndexes = make([]int, len(re.clusterGetters))
+	return re
+}
+
 type clusterGetter struct {
 	shardID       uint64
 	re            *RemoteExecutor
@@ -111,6 +129,9 @@ func (re *RemoteExecutor) GetRows(limit int) (rows *common.Rows, err error) {
 		re.singlePointGetQueryInfo.Limit = uint32(limit)
 		return re.cluster.ExecuteRemotePullQuery(re.singlePointGetQueryInfo, re.rowsFactory)
 	}
+	if re.sortByExpressions != nil {
+		return re.getOrderedRows(limit)
+	}
 
 	numGetters := len(re.clusterGetters)
 	channels := make([]chan cluster.RemoteQueryResult, numGetters)
@@ -168,6 +189,72 @@ func (re *RemoteExecutor) GetRows(limit int) (rows *common.Rows, err error) {
 	return rows, nil
 }
 
+// getOrderedRows merges the ordered rows from each shard
+func (re *RemoteExecutor) getOrderedRows(limit int) (*common.Rows, error) {
+	rows := re.rowsFactory.NewRows(100)
+	for rows.RowCount() < limit {
+		// We can't know which row comes next unless we have rows from every shard which has more
+		if err := re.fillShardRows(limit); err != nil {
+			return nil, err
+		}
+		next := -1
+		var nextRow common.Row
+		for i, shardRows := range re.shardRows {
+			if shardRows == nil || re.shardRowIndexes[i] == shardRows.RowCount() {
+				continue
+			}
+			row := shardRows.GetRow(re.shardRowIndexes[i])
+			if next != -1 {
+				diff, err := compareRows(&row, &nextRow, re.sortByExpressions, re.descending, re.colTypes)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				if diff >= 0 {
+					continue
+				}
+			}
+			next = i
+			nextRow = row
+		}
+		if next == -1 {
+			// No more rows in any shard
+			break
+		}
+		rows.AppendRow(nextRow)
+		re.shardRowIndexes[next]++
+	}
+	return rows, nil
+}
+
+// fillShardRows gets the next rows from each shard which has no rows left to merge but isn't complete
+func (re *RemoteExecutor) fillShardRows(limit int) error {
+	channels := make([]chan cluster.RemoteQueryResult, len(re.clusterGetters))
+	var gettersCalled []int
+	for i, getter := range re.clusterGetters {
+		shardRows := re.shardRows[i]
+		if (shardRows == nil || re.shardRowIndexes[i] == shardRows.RowCount()) && !getter.isComplete() {
+			// We execute these in parallel
+			channels[i] = getter.GetRows(limit)
+			gettersCalled = append(gettersCalled, i)
+		}
+	}
+	for _, i := range gettersCalled {
+		res, ok := <-channels[i]
+		if !ok {
+			return errors.Error("channel was closed")
+		}
+		if res.Err != nil {
+			return res.Err
+		}
+		re.shardRows[i] = res.Rows
+		re.shardRowIndexes[i] = 0
+		if re.clusterGetters[i].isComplete() {
+			re.completeCount++
+		}
+	}
+	return nil
+}
+
 // Close - We override the Close method for PullExecutor and we call our remote shards with limit zero.
 // This signals them to release any resources.
 // Close is only called when the query is closed before all rows are returned - e.g. if a limit has been provided on
@@ -93,6 +93,32 @@ func TestRemoteExecutorGetInBatches(t *testing.T) {
 	}
 }
 
+func TestOrderedRemoteExecutorMergesRowsFromShards(t *testing.T) {
+	numRows := 100
+	rf := common.NewRowsFactory(colTypes)
+	// The rows from each shard are in order of the first column
+	_, allRows, tc := setupRowExecutor(t, numRows, rf)
+	re := NewOrderedRemoteExecutor(nil, &cluster.QueryExecutionInfo{}, colNames, colTypes, "test-schema", tc, nil,
+		[]bool{false}, []*common.Expression{common.NewColumnExpression(0, common.BigIntColumnType)})
+
+	allReceived := rf.NewRows(numRows)
+	for {
+		provided, err := re.GetRows(7)
+		require.NoError(t, err)
+		allReceived.AppendAll(provided)
+		if provided.RowCount() < 7 {
+			break
+		}
+	}
+	require.Equal(t, numRows, allReceived.RowCount())
+
+	arrRows := commontest.RowsToSlice(allReceived)
+	arrExpectedRows := commontest.RowsToSlice(allRows)
+	for i := 0; i < len(arrRows); i++ {
+		commontest.RowsEqual(t, *arrExpectedRows[i], *arrRows[i], colTypes)
+	}
+}
+
 func TestRemoteExecutorSystemTablesTableDoesNotFanout(t *testing.T) {
 	allShardsIds := make([]uint64, 10)
 	for i := 0; i < 10; i++ {
@@ -292,6 +318,10 @@ func (t *testCluster) GetLocalShardIDs() []uint64 {
 	panic("should not be called")
 }
 
+func (t *testCluster) GetReplicaNodeIDs(shardID uint64) []int {
+	panic("should not be called")
+}
+
 func (t *testCluster) GenerateClusterSequence(sequenceName string) (uint64, error) {
 	panic("should not be called")
 }
@@ -0,0 +1,123 @@
+package exec
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+)
+
+// PullSample returns a random sample of at most sampleSize of the rows of its child, each with the number of rows it was
+// sampled from appended as an extra BIGINT column. It runs where the rows are stored, so a shard of a table can be
+// sampled without sending all its rows to the node collecting the sample.
+type PullSample struct {
+	pullExecutorBase
+	sampleSize int
+	rnd        *rand.Rand
+	sample     *common.Rows
+	cursor     int
+}
+
+func NewPullSample(child PullExecutor, sampleSize int) *PullSample {
+	colNames := append(append([]string(nil), child.ColNames()...), "sampled_from")
+	colTypes := append(append([]common.ColumnType(nil), child.ColTypes()...), common.BigIntColumnType)
+	s := &PullSample{
+		pullExecutorBase: pullExecutorBase{
+			colNames:    colNames,
+			colTypes:    colTypes,
+			rowsFactory: common.NewRowsFactory(colTypes),
+		},
+		sampleSize: sampleSize,
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
+	}
+	ConnectPullExecutors([]PullExecutor{child}, s)
+	return s
+}
+
+func (s *PullSample) GetRows(limit int) (*common.Rows, error) {
+	if limit < 1 {
+		return nil, errors.Errorf("invalid limit %d", limit)
+	}
+	if s.sample == nil {
+		if err := s.takeSample(); err != nil {
+			return nil, err
+		}
+	}
+	end := s.cursor + limit
+	if end > s.sample.RowCount() {
+		end = s.sample.RowCount()
+	}
+	rows := s.rowsFactory.NewRows(end - s.cursor)
+	for ; s.cursor < end; s.cursor++ {
+		rows.AppendRow(s.sample.GetRow(s.cursor))
+	}
+	return rows, nil
+}
+
+// takeSample reads all the rows of the child, keeping a reservoir sample of them
+func (s *PullSample) takeSample() error {
+	child := s.GetChildren()[0]
+	var sampled []common.Row
+	var rowCount int64
+	for {
+		rows, err := child.GetRows(queryBatchSize)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for i := 0; i < rows.RowCount(); i++ {
+			rowCount++
+			pos := len(sampled)
+			if pos >= s.sampleSize {
+				// The row replaces an existing sample with probability sampleSize / rowCount
+				pos = int(s.rnd.Int63n(rowCount))
+				if pos >= s.sampleSize {
+					continue
+				}
+			}
+			// The row is copied so the batch it's in isn't kept
+			copied := common.NewRows(rows.ColumnTypes(), 1)
+			copied.AppendRow(rows.GetRow(i))
+			if pos == len(sampled) {
+				sampled = append(sampled, copied.GetRow(0))
+			} else {
+				sampled[pos] = copied.GetRow(0)
+			}
+		}
+		if rows.RowCount() < queryBatchSize {
+			break
+		}
+	}
+	numCols := len(s.colTypes) - 1
+	s.sample = s.rowsFactory.NewRows(len(sampled))
+	for _, row := range sampled {
+		for i := 0; i < numCols; i++ {
+			appendColumn(s.sample, &row, i, s.colTypes[i])
+		}
+		s.sample.AppendInt64ToColumn(numCols, rowCount)
+	}
+	return nil
+}
+
+// appendColumn appends the value of a column of a row to the same column of rows
+func appendColumn(rows *common.Rows, row *common.Row, colIndex int, colType common.ColumnType) {
+	if row.IsNull(colIndex) {
+		rows.AppendNullToColumn(colIndex)
+		return
+	}
+	switch colType.Type {
+	case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
+		rows.AppendInt64ToColumn(colIndex, row.GetInt64(colIndex))
+	case common.TypeDouble:
+		rows.AppendFloat64ToColumn(colIndex, row.GetFloat64(colIndex))
+	case common.TypeVarchar:
+		rows.AppendStringToColumn(colIndex, row.GetString(colIndex))
+	case common.TypeDecimal:
+		rows.AppendDecimalToColumn(colIndex, row.GetDecimal(colIndex))
+	case common.TypeTimestamp:
+		rows.AppendTimestampToColumn(colIndex, row.GetTimestamp(colIndex))
+	default:
+		panic(fmt.Sprintf("unexpected column type %d", colType.Type))
+	}
+}
@@ -0,0 +1,63 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullSample(t *testing.T) {
+	testPullSample(t, 100, 10, 10)
+	testPullSample(t, 5, 10, 5)
+	testPullSample(t, 0, 10, 0)
+	// More rows than are read from the child in one batch
+	testPullSample(t, queryBatchSize+500, 1000, 1000)
+}
+
+func testPullSample(t *testing.T, numRows int, sampleSize int, expectedRows int) {
+	t.Helper()
+	colTypes := []common.ColumnType{common.BigIntColumnType, common.VarcharColumnType}
+	rows := common.NewRowsFactory(colTypes).NewRows(numRows)
+	for i := 0; i < numRows; i++ {
+		rows.AppendInt64ToColumn(0, int64(i))
+		if i%2 == 0 {
+			rows.AppendNullToColumn(1)
+		} else {
+			rows.AppendStringToColumn(1, "val")
+		}
+	}
+	staticRows, err := NewStaticRows([]string{"col0", "col1"}, rows)
+	require.NoError(t, err)
+	sample := NewPullSample(staticRows, sampleSize)
+	if numRows > 0 {
+		require.Equal(t, []string{"col0", "col1", "sampled_from"}, sample.ColNames())
+	}
+
+	// The sample is returned a page at a time
+	var sampled []common.Row
+	for {
+		page, err := sample.GetRows(3)
+		require.NoError(t, err)
+		for i := 0; i < page.RowCount(); i++ {
+			sampled = append(sampled, page.GetRow(i))
+		}
+		if page.RowCount() < 3 {
+			break
+		}
+	}
+	require.Equal(t, expectedRows, len(sampled))
+	seen := make(map[int64]struct{}, len(sampled))
+	for _, row := range sampled {
+		val := row.GetInt64(0)
+		require.True(t, val >= 0 && val < int64(numRows))
+		_, ok := seen[val]
+		require.False(t, ok)
+		seen[val] = struct{}{}
+		require.Equal(t, val%2 == 0, row.IsNull(1))
+		if val%2 == 1 {
+			require.Equal(t, "val", row.GetString(1))
+		}
+		require.Equal(t, int64(numRows), row.GetInt64(2))
+	}
+}
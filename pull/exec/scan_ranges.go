@@ -1,11 +1,23 @@
 package exec
 
 import (
+	"bytes"
+	"sort"
+
 	"github.com/squareup/pranadb/common"
 	"github.com/squareup/pranadb/table"
 )
 
-func calcScanRangeKeys(scanRanges []*ScanRange, indexID uint64, indexCols []int, tableInfo *common.TableInfo,
+// Index keys have a marker byte before each key element which says whether the element is null or not, for a
+// descending element the marker is inverted along with the element
+const (
+	nullMarker        byte = 0
+	notNullMarker     byte = 1
+	descNullMarker         = ^nullMarker
+	descNotNullMarker      = ^notNullMarker
+)
+
+func calcScanRangeKeys(scanRanges []*ScanRange, indexID uint64, keyColTypes []common.ColumnType, desc []bool, //nolint:gocyclo
 	shardID uint64, isIndex bool) ([]*rangeHolder, error) {
 	keyPrefix := table.EncodeTableKeyPrefix(indexID, shardID, 16)
 	if len(scanRanges) == 1 && scanRanges[0] == nil {
@@ -15,42 +27,53 @@ func calcScanRangeKeys(scanRanges []*ScanRange, indexID uint64, indexCols []int,
 	for i, sr := range scanRanges {
 		rangeStart := append([]byte{}, keyPrefix...)
 		rangeEnd := append([]byte{}, keyPrefix...)
+		// The range holds the low and high values for each key element, but if the element is descending then the high
+		// value is at the start of the key range, and the low value at the end
+		startExcl, endExcl := sr.LowExcl, sr.HighExcl
 		var err error
 		for j := 0; j < len(sr.LowVals); j++ {
-			lv := sr.LowVals[j]
-			hv := sr.HighVals[j]
-			if lv == nil && hv == nil {
+			startVal, endVal := sr.LowVals[j], sr.HighVals[j]
+			isDesc := common.IsDesc(desc, j)
+			nullMark, notNullMark := nullMarker, notNullMarker
+			if isDesc {
+				startVal, endVal = endVal, startVal
+				nullMark, notNullMark = descNullMarker, descNotNullMarker
+				if j == len(sr.LowVals)-1 {
+					startExcl, endExcl = sr.HighExcl, sr.LowExcl
+				}
+			}
+			if startVal == nil && endVal == nil {
 				// This represents a get of a null value from the index, it can't occur for a pk
 				if !isIndex {
 					panic("get of null in pk index")
 				}
-				rangeStart = append(rangeStart, 0)
-				rangeEnd = append(rangeEnd, 0)
-			} else if hv != nil {
+				rangeStart = append(rangeStart, nullMark)
+				rangeEnd = append(rangeEnd, nullMark)
+			} else if endVal != nil {
 				// This is a closed range
 				if isIndex {
 					// Only index keys have a marker byte which says whether the key element is null or not
-					rangeEnd = append(rangeEnd, 1)
+					rangeEnd = append(rangeEnd, notNullMark)
 				}
-				rangeEnd, err = common.EncodeKeyElement(hv, tableInfo.ColumnTypes[indexCols[j]], rangeEnd)
+				rangeEnd, err = common.EncodeOrderedKeyElement(endVal, keyColTypes[j], isDesc, rangeEnd)
 				if err != nil {
 					return nil, err
 				}
 			}
-			if lv != nil {
+			if startVal != nil {
 				if isIndex {
-					rangeStart = append(rangeStart, 1)
+					rangeStart = append(rangeStart, notNullMark)
 				}
-				rangeStart, err = common.EncodeKeyElement(lv, tableInfo.ColumnTypes[indexCols[j]], rangeStart)
+				rangeStart, err = common.EncodeOrderedKeyElement(startVal, keyColTypes[j], isDesc, rangeStart)
 				if err != nil {
 					return nil, err
 				}
 			}
 		}
-		if sr.LowExcl {
+		if startExcl {
 			rangeStart = common.IncrementBytesBigEndian(rangeStart)
 		}
-		if !sr.HighExcl {
+		if !endExcl {
 			if !allBitsSet(rangeEnd) {
 				rangeEnd = common.IncrementBytesBigEndian(rangeEnd)
 			} else {
@@ -65,5 +88,11 @@ func calcScanRangeKeys(scanRanges []*ScanRange, indexID uint64, indexCols []int,
 			rangeEnd:   rangeEnd,
 		}
 	}
+	if desc != nil {
+		// The ranges are in ascending order of value, but we scan them in the order of the keys
+		sort.SliceStable(rangeHolders, func(i, j int) bool {
+			return bytes.Compare(rangeHolders[i].rangeStart, rangeHolders[j].rangeStart) < 0
+		})
+	}
 	return rangeHolders, nil
 }
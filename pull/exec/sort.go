@@ -89,7 +89,6 @@ func (p *PullSort) GetRows(limit int) (*common.Rows, error) { //nolint: gocyclo
 	return res, nil
 }
 
-//nolint:gocyclo
 func (p *PullSort) sortRows(unsorted *common.Rows) (*common.Rows, error) {
 	numRows := unsorted.RowCount()
 	indexes := make([]int, numRows)
@@ -103,150 +102,9 @@ func (p *PullSort) sortRows(unsorted *common.Rows) (*common.Rows, error) {
 		}
 		row1 := unsorted.GetRow(indexes[i])
 		row2 := unsorted.GetRow(indexes[j])
-		for sortColIndex, sortbyExpr := range p.sortByExpressions {
-			descending := p.descending[sortColIndex]
-			colType, errType := sortbyExpr.ReturnType(p.colTypes)
-			if errType != nil {
-				err = errType
-				return false
-			}
-			switch colType.Type {
-			case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
-				val1, null1, err1 := sortbyExpr.EvalInt64(&row1)
-				if err1 != nil {
-					err = err1
-					return false
-				}
-				val2, null2, err2 := sortbyExpr.EvalInt64(&row2)
-				if err2 != nil {
-					err = err2
-					return false
-				}
-				if null1 && !null2 {
-					return !descending
-				}
-				if null2 && !null1 {
-					return descending
-				}
-				if !null1 && !null2 {
-					if val1 < val2 {
-						return !descending
-					}
-					if val2 < val1 {
-						return descending
-					}
-				}
-			case common.TypeDouble:
-				val1, null1, err1 := sortbyExpr.EvalFloat64(&row1)
-				if err1 != nil {
-					err = err1
-					return false
-				}
-				val2, null2, err2 := sortbyExpr.EvalFloat64(&row2)
-				if err2 != nil {
-					err = err2
-					return false
-				}
-				if null1 && !null2 {
-					return !descending
-				}
-				if null2 && !null1 {
-					return descending
-				}
-				if !null1 && !null2 {
-					if val1 < val2 {
-						return !descending
-					}
-					if val2 < val1 {
-						return descending
-					}
-				}
-			case common.TypeDecimal:
-				val1, null1, err1 := sortbyExpr.EvalDecimal(&row1)
-				if err1 != nil {
-					err = err1
-					return false
-				}
-				val2, null2, err2 := sortbyExpr.EvalDecimal(&row2)
-				if err2 != nil {
-					err = err2
-					return false
-				}
-				if null1 && !null2 {
-					return !descending
-				}
-				if null2 && !null1 {
-					return descending
-				}
-				if !null1 && !null2 {
-					diff := val1.CompareTo(&val2)
-					if diff < 0 {
-						return !descending
-					}
-					if diff > 0 {
-						return descending
-					}
-				}
-			case common.TypeVarchar:
-				val1, null1, err1 := sortbyExpr.EvalString(&row1)
-				if err1 != nil {
-					err = err1
-					return false
-				}
-				val2, null2, err2 := sortbyExpr.EvalString(&row2)
-				if err2 != nil {
-					err = err2
-					return false
-				}
-				if null1 && !null2 {
-					return !descending
-				}
-				if null2 && !null1 {
-					return descending
-				}
-				if !null1 && !null2 {
-					diff := strings.Compare(val1, val2)
-					if diff < 0 {
-						return !descending
-					}
-					if diff > 0 {
-						return descending
-					}
-				}
-			case common.TypeTimestamp:
-				val1, null1, err1 := sortbyExpr.EvalTimestamp(&row1)
-				if err1 != nil {
-					err = err1
-					return false
-				}
-				val2, null2, err2 := sortbyExpr.EvalTimestamp(&row2)
-				if err2 != nil {
-					err = err2
-					return false
-				}
-				if null1 && !null2 {
-					return !descending
-				}
-				if null2 && !null1 {
-					return descending
-				}
-				if !null1 && !null2 {
-					diff := val1.Compare(val2)
-					if diff < 0 {
-						return !descending
-					}
-					if diff > 0 {
-						return descending
-					}
-				}
-			default:
-				panic(fmt.Sprintf("unexpected type %d", colType.Type))
-			}
-			if err != nil {
-				break
-			}
-		}
-		return false
+		var diff int
+		diff, err = compareRows(&row1, &row2, p.sortByExpressions, p.descending, p.colTypes)
+		return diff < 0
 	})
 
 	if err != nil {
@@ -259,3 +117,93 @@ func (p *PullSort) sortRows(unsorted *common.Rows) (*common.Rows, error) {
 	}
 	return rows, nil
 }
+
+// compareRows compares two rows by the sort expressions. It returns a negative number if row1 sorts before row2, a
+// positive number if row2 sorts before row1, or zero if they sort the same. Nulls sort before other values, unless
+// descending.
+func compareRows(row1 *common.Row, row2 *common.Row, sortByExpressions []*common.Expression, descending []bool, //nolint:gocyclo
+	colTypes []common.ColumnType) (int, error) {
+	for sortColIndex, sortbyExpr := range sortByExpressions {
+		colType, err := sortbyExpr.ReturnType(colTypes)
+		if err != nil {
+			return 0, err
+		}
+		var null1, null2 bool
+		diff := 0
+		switch colType.Type {
+		case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
+			var val1, val2 int64
+			if val1, null1, err = sortbyExpr.EvalInt64(row1); err != nil {
+				return 0, err
+			}
+			if val2, null2, err = sortbyExpr.EvalInt64(row2); err != nil {
+				return 0, err
+			}
+			if val1 < val2 {
+				diff = -1
+			} else if val2 < val1 {
+				diff = 1
+			}
+		case common.TypeDouble:
+			var val1, val2 float64
+			if val1, null1, err = sortbyExpr.EvalFloat64(row1); err != nil {
+				return 0, err
+			}
+			if val2, null2, err = sortbyExpr.EvalFloat64(row2); err != nil {
+				return 0, err
+			}
+			if val1 < val2 {
+				diff = -1
+			} else if val2 < val1 {
+				diff = 1
+			}
+		case common.TypeDecimal:
+			var val1, val2 common.Decimal
+			if val1, null1, err = sortbyExpr.EvalDecimal(row1); err != nil {
+				return 0, err
+			}
+			if val2, null2, err = sortbyExpr.EvalDecimal(row2); err != nil {
+				return 0, err
+			}
+			if !null1 && !null2 {
+				diff = val1.CompareTo(&val2)
+			}
+		case common.TypeVarchar:
+			var val1, val2 string
+			if val1, null1, err = sortbyExpr.EvalString(row1); err != nil {
+				return 0, err
+			}
+			if val2, null2, err = sortbyExpr.EvalString(row2); err != nil {
+				return 0, err
+			}
+			diff = strings.Compare(val1, val2)
+		case common.TypeTimestamp:
+			var val1, val2 common.Timestamp
+			if val1, null1, err = sortbyExpr.EvalTimestamp(row1); err != nil {
+				return 0, err
+			}
+			if val2, null2, err = sortbyExpr.EvalTimestamp(row2); err != nil {
+				return 0, err
+			}
+			if !null1 && !null2 {
+				diff = val1.Compare(val2)
+			}
+		default:
+			panic(fmt.Sprintf("unexpected type %d", colType.Type))
+		}
+		if null1 && !null2 {
+			diff = -1
+		} else if null2 && !null1 {
+			diff = 1
+		} else if null1 && null2 {
+			diff = 0
+		}
+		if diff != 0 {
+			if descending[sortColIndex] {
+				return -diff, nil
+			}
+			return diff, nil
+		}
+	}
+	return 0, nil
+}
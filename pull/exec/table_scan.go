@@ -9,7 +9,7 @@ import (
 type PullTableScan struct {
 	pullExecutorBase
 	tableInfo     *common.TableInfo
-	storage       cluster.Cluster
+	storage       localScanner
 	shardID       uint64
 	lastRowPrefix []byte
 	rangeHolders  []*rangeHolder
@@ -54,7 +54,11 @@ func NewPullTableScan(tableInfo *common.TableInfo, colIndexes []int, storage clu
 		keyCols:     tableInfo.PrimaryKeyCols,
 	}
 
-	rangeHolders, err := calcScanRangeKeys(scanRanges, tableInfo.ID, tableInfo.PrimaryKeyCols, tableInfo, shardID, false)
+	pkColTypes := make([]common.ColumnType, len(tableInfo.PrimaryKeyCols))
+	for i, pkCol := range tableInfo.PrimaryKeyCols {
+		pkColTypes[i] = tableInfo.ColumnTypes[pkCol]
+	}
+	rangeHolders, err := calcScanRangeKeys(scanRanges, tableInfo.ID, pkColTypes, tableInfo.PrimaryKeyDesc, shardID, false)
 	if err != nil {
 		return nil, err
 	}
@@ -68,6 +72,23 @@ func NewPullTableScan(tableInfo *common.TableInfo, colIndexes []int, storage clu
 	}, nil
 }
 
+// NewPullAsOfTableScan creates a scan of the rows of a table as they were at a time in the past, given in unix micros.
+// The table must keep prior versions of its rows.
+func NewPullAsOfTableScan(tableInfo *common.TableInfo, colIndexes []int, storage cluster.Cluster, shardID uint64,
+	scanRanges []*ScanRange, asOf int64) (*PullTableScan, error) {
+	ts, err := NewPullTableScan(tableInfo, colIndexes, storage, shardID, scanRanges)
+	if err != nil {
+		return nil, err
+	}
+	ts.storage = &asOfScanner{
+		storage:   storage,
+		tableInfo: tableInfo,
+		shardID:   shardID,
+		asOf:      asOf,
+	}
+	return ts, nil
+}
+
 type rangeHolder struct {
 	rangeStart []byte
 	rangeEnd   []byte
@@ -3,6 +3,7 @@ package exec
 import (
 	"github.com/squareup/pranadb/cluster/fake"
 	"testing"
+	"time"
 
 	"github.com/squareup/pranadb/cluster"
 	"github.com/squareup/pranadb/common"
@@ -344,6 +345,145 @@ func testTableScanNaturalOrdering(t *testing.T, inpRows [][]interface{}, expecte
 	commontest.AllRowsEqual(t, exp, provided, colTypes)
 }
 
+func TestAsOfTableScan(t *testing.T) {
+	clust := fake.NewFakeCluster(0, 10)
+	clust.RegisterShardListenerFactory(&cluster.DummyShardListenerFactory{})
+	clust.SetRemoteQueryExecutionCallback(&cluster.DummyRemoteQueryExecutionCallback{})
+	err := clust.Start()
+	require.NoError(t, err)
+	defer stopCluster(t, clust)
+	shardID := clust.GetAllShardIDs()[0]
+
+	tableInfo := &common.TableInfo{
+		ID:                       common.UserTableIDBase + uint64(1),
+		SchemaName:               "test",
+		Name:                     "test_table",
+		PrimaryKeyCols:           []int{0},
+		ColumnNames:              colNames,
+		ColumnTypes:              colTypes,
+		VersionRetentionDuration: time.Hour,
+		VersionTableID:           common.UserTableIDBase + uint64(2),
+	}
+	// Before time 100 the table holds sensors 1, 2 and 3. At time 100 sensor 2 is updated and sensor 3 is deleted. At
+	// time 200 sensor 2 is updated again and sensor 4 is inserted.
+	v1 := toRows(t, [][]interface{}{
+		{1, "wincanton", 25.5, "132.45"},
+		{2, "london", 35.1, "9.32"},
+		{3, "los angeles", 20.6, "11.75"},
+	}, colTypes)
+	v2 := toRows(t, [][]interface{}{
+		{2, "london", 36.2, "9.32"},
+	}, colTypes)
+	current := toRows(t, [][]interface{}{
+		{1, "wincanton", 25.5, "132.45"},
+		{2, "london", 37.3, "9.32"},
+		{4, "sydney", 45.2, "4.99"},
+	}, colTypes)
+	insertRowsIntoTable(t, shardID, tableInfo, current, clust)
+	wb := cluster.NewWriteBatch(shardID)
+	addVersion(t, wb, tableInfo, 100, v1, 1, true)
+	addVersion(t, wb, tableInfo, 100, v1, 2, true)
+	addVersion(t, wb, tableInfo, 200, v2, 0, true)
+	addVersion(t, wb, tableInfo, 200, current, 2, false)
+	err = clust.WriteBatch(wb, false)
+	require.NoError(t, err)
+
+	testAsOfTableScan(t, tableInfo, clust, shardID, nil, 50, [][]interface{}{
+		{1, "wincanton", 25.5, "132.45"},
+		{2, "london", 35.1, "9.32"},
+		{3, "los angeles", 20.6, "11.75"},
+	})
+	testAsOfTableScan(t, tableInfo, clust, shardID, nil, 100, [][]interface{}{
+		{1, "wincanton", 25.5, "132.45"},
+		{2, "london", 36.2, "9.32"},
+	})
+	testAsOfTableScan(t, tableInfo, clust, shardID, nil, 250, [][]interface{}{
+		{1, "wincanton", 25.5, "132.45"},
+		{2, "london", 37.3, "9.32"},
+		{4, "sydney", 45.2, "4.99"},
+	})
+	scanRange := &ScanRange{
+		LowVals:  []interface{}{int64(2)},
+		HighVals: []interface{}{int64(3)},
+	}
+	testAsOfTableScan(t, tableInfo, clust, shardID, scanRange, 50, [][]interface{}{
+		{2, "london", 35.1, "9.32"},
+		{3, "los angeles", 20.6, "11.75"},
+	})
+
+	// A scan stops reading once it has enough rows, and only reads the versions of the rows in its range
+	counting := &countingIterators{clust: clust}
+	scanner := &asOfScanner{storage: counting, tableInfo: tableInfo, shardID: shardID, asOf: 50}
+	pairs, err := scanner.LocalScan(table.EncodeTableKeyPrefix(tableInfo.ID, shardID, 16),
+		table.EncodeTableKeyPrefix(tableInfo.ID+1, shardID, 16), 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pairs))
+	require.Equal(t, 2, counting.read)
+	counting.read = 0
+	key3 := table.EncodeTableKeyPrefix(tableInfo.ID, shardID, 24)
+	key3 = common.KeyEncodeInt64(key3, 3)
+	pairs, err = scanner.LocalScan(key3, common.IncrementBytesBigEndian(common.CopyByteSlice(key3)), -1)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(pairs))
+	require.Equal(t, 1, counting.read)
+}
+
+// countingIterators counts the pairs read by the iterators it creates
+type countingIterators struct {
+	clust cluster.Cluster
+	read  int
+}
+
+func (c *countingIterators) LocalIterator(startKeyPrefix []byte, endKeyPrefix []byte) cluster.KVIterator {
+	return &countingIterator{KVIterator: c.clust.LocalIterator(startKeyPrefix, endKeyPrefix), counting: c}
+}
+
+type countingIterator struct {
+	cluster.KVIterator
+	counting *countingIterators
+}
+
+func (c *countingIterator) Next() cluster.KVPair {
+	c.counting.read++
+	return c.KVIterator.Next()
+}
+
+func testAsOfTableScan(t *testing.T, tableInfo *common.TableInfo, clust cluster.Cluster, shardID uint64,
+	scanRange *ScanRange, asOf int64, expectedRows [][]interface{}) {
+	t.Helper()
+	ts, err := NewPullAsOfTableScan(tableInfo, nil, clust, shardID, []*ScanRange{scanRange}, asOf)
+	require.NoError(t, err)
+	// Get the rows in small batches to make sure we page through them properly
+	provided := ts.RowsFactory().NewRows(len(expectedRows))
+	for {
+		rows, err := ts.GetRows(2)
+		require.NoError(t, err)
+		for i := 0; i < rows.RowCount(); i++ {
+			provided.AppendRow(rows.GetRow(i))
+		}
+		if rows.RowCount() < 2 {
+			break
+		}
+	}
+	commontest.AllRowsEqual(t, toRows(t, expectedRows, colTypes), provided, colTypes)
+}
+
+// addVersion adds the prior version of a row, which was replaced at endTime, to the version table of the table. If
+// exists is false there was no row before.
+func addVersion(t *testing.T, wb *cluster.WriteBatch, tableInfo *common.TableInfo, endTime int64, rows *common.Rows,
+	rowIndex int, exists bool) {
+	t.Helper()
+	row := rows.GetRow(rowIndex)
+	pk, err := common.EncodeKeyCols(&row, tableInfo.PrimaryKeyCols, tableInfo.ColumnTypes, nil)
+	require.NoError(t, err)
+	var prevValue []byte
+	if exists {
+		prevValue, err = common.EncodeRow(&row, tableInfo.ColumnTypes, nil)
+		require.NoError(t, err)
+	}
+	table.AddVersion(wb, tableInfo.VersionTableID, wb.ShardID, pk, endTime, prevValue)
+}
+
 func stopCluster(t *testing.T, clust cluster.Cluster) {
 	t.Helper()
 	err := clust.Stop()
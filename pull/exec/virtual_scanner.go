@@ -0,0 +1,90 @@
+package exec
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/table"
+)
+
+// VirtualTable generates the rows of a virtual table. A virtual table is scanned on the node which leads each shard,
+// like any other table, so it's asked for the rows it holds for that shard.
+type VirtualTable interface {
+	GetRows(shardID uint64) (*common.Rows, error)
+}
+
+// NewPullVirtualTableScan creates a scan of the rows of a virtual table in a shard. The rows are encoded as if they
+// were stored so they're scanned in order of primary key, and ranges on the primary key apply to them as usual.
+func NewPullVirtualTableScan(tableInfo *common.TableInfo, colIndexes []int, virtualTable VirtualTable,
+	shardID uint64, scanRanges []*ScanRange) (*PullTableScan, error) {
+	ts, err := NewPullTableScan(tableInfo, colIndexes, nil, shardID, scanRanges)
+	if err != nil {
+		return nil, err
+	}
+	ts.storage = &virtualScanner{
+		virtualTable: virtualTable,
+		tableInfo:    tableInfo,
+		shardID:      shardID,
+	}
+	return ts, nil
+}
+
+// virtualScanner scans the rows generated by a virtual table. The rows are generated once, on the first scan, so the
+// rows returned for a query are consistent as it pages through them.
+type virtualScanner struct {
+	virtualTable VirtualTable
+	tableInfo    *common.TableInfo
+	shardID      uint64
+	pairs        []cluster.KVPair
+	loaded       bool
+}
+
+func (v *virtualScanner) LocalScan(startKeyPrefix []byte, endKeyPrefix []byte, limit int) ([]cluster.KVPair, error) {
+	if !v.loaded {
+		if err := v.load(); err != nil {
+			return nil, err
+		}
+		v.loaded = true
+	}
+	start := sort.Search(len(v.pairs), func(i int) bool {
+		return bytes.Compare(v.pairs[i].Key, startKeyPrefix) >= 0
+	})
+	var pairs []cluster.KVPair
+	for i := start; i < len(v.pairs) && (limit == -1 || len(pairs) < limit); i++ {
+		if endKeyPrefix != nil && bytes.Compare(v.pairs[i].Key, endKeyPrefix) >= 0 {
+			break
+		}
+		pairs = append(pairs, v.pairs[i])
+	}
+	return pairs, nil
+}
+
+func (v *virtualScanner) load() error {
+	rows, err := v.virtualTable.GetRows(v.shardID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	pairs := make([]cluster.KVPair, rows.RowCount())
+	for i := 0; i < rows.RowCount(); i++ {
+		row := rows.GetRow(i)
+		key := table.EncodeTableKeyPrefix(v.tableInfo.ID, v.shardID, 32)
+		key, err = common.EncodeOrderedKeyCols(&row, v.tableInfo.PrimaryKeyCols, v.tableInfo.PrimaryKeyDesc,
+			v.tableInfo.ColumnTypes, key)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		value, err := common.EncodeRow(&row, v.tableInfo.ColumnTypes, nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		pairs[i] = cluster.KVPair{Key: key, Value: value}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].Key, pairs[j].Key) < 0
+	})
+	v.pairs = pairs
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/squareup/pranadb/tidb/sessionctx"
 	"strings"
+	"time"
 
 	"github.com/pingcap/parser/model"
 	"github.com/squareup/pranadb/common"
@@ -12,6 +13,7 @@ import (
 	"github.com/squareup/pranadb/execctx"
 	"github.com/squareup/pranadb/pull/exec"
 	"github.com/squareup/pranadb/sharder"
+	"github.com/squareup/pranadb/tidb/expression"
 	"github.com/squareup/pranadb/tidb/planner"
 	"github.com/squareup/pranadb/tidb/planner/util"
 	"github.com/squareup/pranadb/tidb/util/ranger"
@@ -66,11 +68,24 @@ func (p *Engine) buildPullDAG(ctx *execctx.ExecutionContext, plan planner.Physic
 	case *planner.PhysicalTableScan:
 		if remote {
 			tableName := op.Table.Name.L
-			executor, err = p.createPullTableScan(ctx.Schema, tableName, op.Ranges, op.Columns, ctx.QueryInfo.ShardID)
+			if op.AsOf != nil {
+				// The time is evaluated on the originating node so all shards read the table as of the same time
+				executor, err = p.createPullAsOfTableScan(ctx.Schema, tableName, op.Ranges, op.Columns,
+					ctx.QueryInfo.ShardID, ctx.QueryInfo.AsOf)
+			} else {
+				executor, err = p.createPullTableScan(ctx.Schema, tableName, op.Ranges, op.Columns, ctx.QueryInfo.ShardID)
+			}
 			if err != nil {
 				return nil, errors.WithStack(err)
 			}
 		} else {
+			if op.AsOf != nil {
+				asOf, err := getAsOfTime(ctx.Schema, op.Table.Name.L, *op.AsOf)
+				if err != nil {
+					return nil, err
+				}
+				ctx.QueryInfo.AsOf = asOf
+			}
 			remoteDag, err := p.buildPullDAG(ctx, op, true, orderByMaxRows)
 			if err != nil {
 				return nil, errors.WithStack(err)
@@ -79,8 +94,11 @@ func (p *Engine) buildPullDAG(ctx *execctx.ExecutionContext, plan planner.Physic
 			if err != nil {
 				return nil, err
 			}
-			executor = exec.NewRemoteExecutor(remoteDag, ctx.QueryInfo, colNames, colTypes, ctx.Schema.Name, p.cluster,
-				pointGetShardIDs)
+			executor, err = p.createRemoteExecutor(ctx, remoteDag, colNames, colTypes, pointGetShardIDs, op.KeepOrder,
+				op.ByItems, op.Schema())
+			if err != nil {
+				return nil, err
+			}
 		}
 	case *planner.PhysicalIndexScan:
 		if remote {
@@ -105,8 +123,11 @@ func (p *Engine) buildPullDAG(ctx *execctx.ExecutionContext, plan planner.Physic
 			if err != nil {
 				return nil, err
 			}
-			executor = exec.NewRemoteExecutor(remoteDag, ctx.QueryInfo, colNames, colTypes, ctx.Schema.Name, p.cluster,
-				nil)
+			executor, err = p.createRemoteExecutor(ctx, remoteDag, colNames, colTypes, nil, op.KeepOrder, op.ByItems,
+				op.Schema())
+			if err != nil {
+				return nil, err
+			}
 		}
 	case *planner.PhysicalSort:
 		desc, sortByExprs := p.byItemsToDescAndSortExpression(op.ByItems, ctx.Planner().SessionContext())
@@ -138,8 +159,41 @@ func (p *Engine) buildPullDAG(ctx *execctx.ExecutionContext, plan planner.Physic
 	return executor, nil
 }
 
+// createRemoteExecutor creates the executor which gets the rows of a scan from the shards. If the scan must keep the
+// order of its rows, the rows from the shards are merged in that order.
+func (p *Engine) createRemoteExecutor(ctx *execctx.ExecutionContext, remoteDag exec.PullExecutor, colNames []string,
+	colTypes []common.ColumnType, pointGetShardIDs []uint64, keepOrder bool, byItems []*util.ByItems,
+	schema *expression.Schema) (exec.PullExecutor, error) {
+	if !keepOrder || len(byItems) == 0 {
+		return exec.NewRemoteExecutor(remoteDag, ctx.QueryInfo, colNames, colTypes, ctx.Schema.Name, p.cluster,
+			pointGetShardIDs), nil
+	}
+	desc := make([]bool, len(byItems))
+	sortByExprs := make([]*common.Expression, len(byItems))
+	for i, byItem := range byItems {
+		col, ok := byItem.Expr.(*expression.Column)
+		if !ok {
+			return nil, errors.Errorf("unexpected scan order by expression %s", byItem.Expr)
+		}
+		colIndex := schema.ColumnIndex(col)
+		if colIndex == -1 {
+			return nil, errors.Errorf("cannot find scan order by column %s", col)
+		}
+		desc[i] = byItem.Desc
+		sortByExprs[i] = common.NewColumnExpression(colIndex, colTypes[colIndex])
+	}
+	return exec.NewOrderedRemoteExecutor(remoteDag, ctx.QueryInfo, colNames, colTypes, ctx.Schema.Name, p.cluster,
+		pointGetShardIDs, desc, sortByExprs), nil
+}
+
 func (p *Engine) getPointGetShardIDs(ctx *execctx.ExecutionContext, ranges []*ranger.Range, tableName string) ([]uint64, error) {
 	var pointGetShardIDs []uint64
+	if table, ok := ctx.Schema.GetTable(tableName); ok {
+		if _, ok := table.(*common.VirtualTableInfo); ok {
+			// The rows of a virtual table are generated by whichever shard they belong to, not the shard of their key
+			return nil, nil
+		}
+	}
 	shardIDMap := map[uint64]struct{}{}
 	// There can be multiple point gets for a single query.
 	// E.g. select * from foo where pk_col in (1, 7, 10, 15)
@@ -188,9 +242,53 @@ func (p *Engine) createPullTableScan(schema *common.Schema, tableName string, ra
 	for _, col := range columns {
 		colIndexes = append(colIndexes, col.Offset)
 	}
+	if _, ok := tbl.(*common.VirtualTableInfo); ok {
+		virtualTable, ok := p.virtualTables.Load(tbl.GetTableInfo().ID)
+		if !ok {
+			return nil, errors.Errorf("virtual table %s is not available", tableName)
+		}
+		return exec.NewPullVirtualTableScan(tbl.GetTableInfo(), colIndexes, virtualTable.(exec.VirtualTable), shardID, //nolint:forcetypeassert
+			scanRanges)
+	}
 	return exec.NewPullTableScan(tbl.GetTableInfo(), colIndexes, p.cluster, shardID, scanRanges)
 }
 
+func (p *Engine) createPullAsOfTableScan(schema *common.Schema, tableName string, ranges []*ranger.Range,
+	columns []*model.ColumnInfo, shardID uint64, asOf int64) (exec.PullExecutor, error) {
+	tbl, ok := schema.GetTable(tableName)
+	if !ok {
+		return nil, errors.Errorf("unknown source or materialized view %s", tableName)
+	}
+	scanRanges := createScanRanges(ranges)
+	var colIndexes []int
+	for _, col := range columns {
+		colIndexes = append(colIndexes, col.Offset)
+	}
+	return exec.NewPullAsOfTableScan(tbl.GetTableInfo(), colIndexes, p.cluster, shardID, scanRanges, asOf)
+}
+
+// getAsOfTime returns the time in unix micros as of which a table is read, checking the table still has the versions
+// of its rows at that time
+func getAsOfTime(schema *common.Schema, tableName string, asOf time.Time) (int64, error) {
+	tbl, ok := schema.GetTable(tableName)
+	if !ok {
+		return 0, errors.Errorf("unknown source or materialized view %s", tableName)
+	}
+	tableInfo := tbl.GetTableInfo()
+	if tableInfo.VersionRetentionDuration == 0 {
+		return 0, errors.NewPranaErrorf(errors.InvalidStatement,
+			"Cannot use AS OF with %s as it does not keep prior versions of rows. Specify VersionRetentionTime when creating it",
+			tableName)
+	}
+	asOfMicros := asOf.UnixMicro()
+	if asOfMicros < time.Now().Add(-tableInfo.VersionRetentionDuration).UnixMicro() {
+		return 0, errors.NewPranaErrorf(errors.InvalidStatement,
+			"Cannot use AS OF with a time more than %s ago as %s only keeps prior versions of rows for that long",
+			tableInfo.VersionRetentionDuration, tableName)
+	}
+	return asOfMicros, nil
+}
+
 func (p *Engine) createPullIndexScan(schema *common.Schema, tableName string, indexName string, ranges []*ranger.Range,
 	columnInfos []*model.ColumnInfo, shardID uint64) (exec.PullExecutor, error) {
 	tbl, ok := schema.GetTable(tableName)
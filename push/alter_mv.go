@@ -0,0 +1,50 @@
+package push
+
+import (
+	"fmt"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/parplan"
+)
+
+// CreateReplacementMaterializedView creates a materialized view with a new query which will replace prev once it has
+// been filled. It keeps prior versions of its rows for as long as prev does. Until it replaces prev it consumes from
+// its feeders under a name of its own, so that both can be fed at the same time.
+func CreateReplacementMaterializedView(pe *Engine, pl *parplan.Planner, schema *common.Schema, prev *MaterializedView,
+	query string, tableID uint64, versionTableID uint64, seqGenerator common.SeqGenerator) (*MaterializedView, error) {
+	mv, err := CreateMaterializedView(pe, pl, schema, prev.Info.Name, query, "", tableID,
+		prev.Info.VersionRetentionDuration, versionTableID, seqGenerator)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if scansTable(mv.tableExecutor, prev.Info.Name) {
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Materialized view %s.%s cannot select from itself",
+			prev.Info.SchemaName, prev.Info.Name)
+	}
+	mv.consumerName = fmt.Sprintf("%s-replacement", prev.Info.Name)
+	return mv, nil
+}
+
+// Replace swaps the materialized view in for prev, which must have no consumers of its own. prev is disconnected from
+// its feeders then the materialized view takes its place, so from then on rows are only processed by the materialized
+// view. The data of prev is not deleted.
+func (m *MaterializedView) Replace(prev *MaterializedView) error {
+	if err := prev.Disconnect(); err != nil {
+		return errors.WithStack(err)
+	}
+	tes, _, err := m.getFeedingExecutors(m.tableExecutor)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// The materialized view is only registered as a consumer on nodes which lead a shard, as it's added at the end of
+	// the fill
+	for _, te := range tes {
+		te.RenameConsumingNode(m.consumerName, m.Info.Name)
+	}
+	m.consumerName = m.Info.Name
+	if err := m.pe.RemoveMV(prev.Info.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	return m.pe.RegisterMV(m)
+}
@@ -0,0 +1,646 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/common/commontest"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/parplan"
+	"github.com/squareup/pranadb/push/exec"
+	"github.com/squareup/pranadb/table"
+)
+
+const receivedRowsTimeout = 10 * time.Second
+
+// ValidateAlteredSource checks that everything which depends on a source can be rebuilt once the source has been
+// altered. The ingest filter, the computed columns and the expressions of any functional or partial indexes must still
+// compile, and the materialized views and sinks which consume the source must still plan to the same columns.
+func (p *Engine) ValidateAlteredSource(sourceInfo *common.SourceInfo) error {
+	if _, err := p.buildIngestExpressions(sourceInfo); err != nil {
+		return err
+	}
+	if _, err := p.buildComputedExpressions(sourceInfo); err != nil {
+		return err
+	}
+	schema, ok := p.meta.GetSchema(sourceInfo.SchemaName)
+	if !ok {
+		return errors.NewUnknownSourceError(sourceInfo.SchemaName, sourceInfo.Name)
+	}
+	// We plan against a copy of the schema in which the source has its new columns
+	altered := schema.CopyWithTable(sourceInfo.Name, sourceInfo)
+	pl := parplan.NewPlanner(altered)
+	for _, indexInfo := range sortedIndexInfos(sourceInfo.TableInfo) {
+		if !indexInfo.HasIndexExprs() && indexInfo.Predicate == "" {
+			continue
+		}
+		_, keyTypes, _, err := pl.BuildIndexExpressions(sourceInfo.Name, indexInfo.IndexExprs, indexInfo.Predicate)
+		if err != nil {
+			return errors.NewPranaErrorf(errors.InvalidStatement, "Cannot alter source %s as index %s depends on it: %s",
+				sourceInfo.Name, indexInfo.Name, errorMessage(err))
+		}
+		for i, indexExpr := range indexInfo.IndexExprs {
+			if indexExpr != "" && keyTypes[i] != indexInfo.IndexExprTypes[i] {
+				return errors.NewPranaErrorf(errors.InvalidStatement,
+					"Cannot alter source %s as it would change the type of expression %s in index %s", sourceInfo.Name,
+					indexExpr, indexInfo.Name)
+			}
+		}
+	}
+	mvs, sinks := p.getSourceDependents(sourceInfo)
+	for _, mv := range mvs {
+		dag, _, err := p.buildPushQueryExecution(parplan.NewPlanner(altered), altered, mv.Info.Query, mv.Info.Name,
+			newReusingSeqGenerator(mv.InternalTables))
+		if err != nil {
+			return errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot alter source %s as materialized view %s depends on it: %s", sourceInfo.Name, mv.Info.Name,
+				errorMessage(err))
+		}
+		// The rows already stored by the materialized view and its aggregations must still be valid
+		if !sameColumns(dag, mv.Info.TableInfo) || !sameAggregations(getAggregators(dag), getAggregators(mv.tableExecutor)) {
+			return errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot alter source %s as it would change the columns of materialized view %s", sourceInfo.Name, mv.Info.Name)
+		}
+	}
+	for _, sink := range sinks {
+		dag, _, err := p.buildPushQueryExecution(parplan.NewPlanner(altered), altered, sink.Info.Query, sink.Info.Name,
+			common.NewPreallocSeqGen([]uint64{sink.Info.ID}))
+		if err != nil {
+			return errors.NewPranaErrorf(errors.InvalidStatement, "Cannot alter source %s as sink %s depends on it: %s",
+				sourceInfo.Name, sink.Info.Name, errorMessage(err))
+		}
+		if !sameColumns(dag, sink.Info.TableInfo) {
+			return errors.NewPranaErrorf(errors.InvalidStatement,
+				"Cannot alter source %s as it would change the columns of sink %s", sourceInfo.Name, sink.Info.Name)
+		}
+	}
+	return nil
+}
+
+// WaitForSourceRowsProcessed waits until the rows which have been forwarded to the local shards by a source have all
+// been processed. The source must have been stopped on every node so no more rows are forwarded.
+func (p *Engine) WaitForSourceRowsProcessed(sourceInfo *common.SourceInfo) error {
+	shardIDs := p.cluster.GetLocalShardIDs()
+	ok, err := commontest.WaitUntilWithError(func() (bool, error) {
+		exist, err := p.existReceivedRows(sourceInfo.ID, shardIDs)
+		return !exist, errors.WithStack(err)
+	}, receivedRowsTimeout, 100*time.Millisecond)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !ok {
+		return errors.NewPranaErrorf(errors.Timeout, "Timed out waiting for rows ingested by source %s.%s to be processed",
+			sourceInfo.SchemaName, sourceInfo.Name)
+	}
+	return nil
+}
+
+// existReceivedRows returns true if there are rows from the remote consumer with the given id in the receiver table of
+// any of the shards
+func (p *Engine) existReceivedRows(remoteConsumerID uint64, shardIDs []uint64) (bool, error) {
+	for _, shardID := range shardIDs {
+		startPrefix := table.EncodeTableKeyPrefix(common.ReceiverTableID, shardID, 16)
+		endPrefix := table.EncodeTableKeyPrefix(common.ReceiverTableID+1, shardID, 16)
+		kvPairs, err := p.cluster.LocalScan(startPrefix, endPrefix, -1)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		for _, kvPair := range kvPairs {
+			id, _ := common.ReadUint64FromBufferBE(kvPair.Key, 24)
+			if id == remoteConsumerID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// AlterSource migrates the rows of a source in the local shards from the columns in prevInfo to the columns in
+// sourceInfo, then changes the source to ingest rows with the new columns. The source must have been stopped, and the
+// rows it forwarded processed, on every node. The migrated rows are backed up, so the alteration can be rolled back with
+// RollbackSourceAlteration until the altered source has been persisted and FinishSourceAlteration called.
+func (p *Engine) AlterSource(prevInfo *common.SourceInfo, sourceInfo *common.SourceInfo) error {
+	if !sourceInfo.OriginInfo.Transient {
+		if err := p.migrateTableRows(prevInfo.TableInfo, sourceInfo.TableInfo); err != nil {
+			return err
+		}
+	}
+	return p.changeSourceColumns(sourceInfo)
+}
+
+// FinishSourceAlteration deletes the backups of the rows of a source which were migrated in the local shards when it
+// was altered, once the altered source has been persisted
+func (p *Engine) FinishSourceAlteration(sourceInfo *common.SourceInfo) error {
+	for _, shardID := range p.cluster.GetLocalShardIDs() {
+		if err := p.deleteMigrationBackups(shardID, sourceInfo.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackSourceAlteration undoes AlterSource after the alteration failed on this or another node. If the altered
+// source was persisted before it failed, the alteration is finished instead, as every node has migrated its rows.
+// Otherwise the rows of the source in the local shards are restored from their backups, and the source and everything
+// which consumes it are changed back to the columns in prevInfo.
+func (p *Engine) RollbackSourceAlteration(prevInfo *common.SourceInfo, sourceInfo *common.SourceInfo) error {
+	persisted, err := p.meta.GetPersistedSource(sourceInfo.ID)
+	if err != nil {
+		return err
+	}
+	if persisted != nil && sameMigratedColumns(persisted.TableInfo, sourceInfo.TableInfo) {
+		return p.FinishSourceAlteration(sourceInfo)
+	}
+	if err := p.RecoverSourceAlteration(prevInfo); err != nil {
+		return err
+	}
+	if err := p.changeSourceColumns(prevInfo); err != nil {
+		return err
+	}
+	if err := p.meta.AlterSource(prevInfo); err != nil {
+		return errors.WithStack(err)
+	}
+	return p.RebuildSourceDependents(prevInfo)
+}
+
+// RecoverSourceAlteration finishes or rolls back an alteration of a source which didn't complete on this node, for
+// example because it failed while the node was stopped, according to the source as it is persisted. If the rows of the
+// source in a local shard were migrated to its persisted columns their backups are deleted, otherwise the rows are
+// restored from them.
+func (p *Engine) RecoverSourceAlteration(sourceInfo *common.SourceInfo) error {
+	for _, shardID := range p.cluster.GetLocalShardIDs() {
+		if err := p.recoverMigratedRows(shardID, sourceInfo.TableInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeSourceColumns changes a source to ingest, and its remote consumer to receive, rows with the columns of
+// sourceInfo
+func (p *Engine) changeSourceColumns(sourceInfo *common.SourceInfo) error {
+	src, err := p.GetSource(sourceInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ingestExpressions, err := p.buildIngestExpressions(sourceInfo)
+	if err != nil {
+		return err
+	}
+	computedExpressions, err := p.buildComputedExpressions(sourceInfo)
+	if err != nil {
+		return err
+	}
+	// The row caches hold rows encoded with the old columns
+	if err := p.purgeRowCaches(); err != nil {
+		return err
+	}
+	if err := src.Alter(sourceInfo, ingestExpressions, computedExpressions); err != nil {
+		return errors.WithStack(err)
+	}
+	colTypes := sourceInfo.ColumnTypes
+	p.remoteConsumers.Store(sourceInfo.ID, &RemoteConsumer{
+		RowsFactory: common.NewRowsFactory(colTypes),
+		ColTypes:    colTypes,
+		RowsHandler: src.TableExecutor(),
+	})
+	return nil
+}
+
+// RebuildSourceDependents rebuilds the indexes, materialized views and sinks which consume a source once it has been
+// altered, so they process rows with its new columns. The altered source must have been registered with the meta
+// controller.
+func (p *Engine) RebuildSourceDependents(sourceInfo *common.SourceInfo) error {
+	src, err := p.GetSource(sourceInfo.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	te := src.TableExecutor()
+	for _, indexInfo := range sortedIndexInfos(sourceInfo.TableInfo) {
+		indexExec, err := p.createIndexExecutor(sourceInfo.TableInfo, indexInfo)
+		if err != nil {
+			return err
+		}
+		te.AddConsumingNode(fmt.Sprintf("%s.%s", sourceInfo.Name, indexInfo.Name), indexExec)
+	}
+	if sourceInfo.RetentionDuration != 0 {
+		// The row_time column may have moved
+		rowTimeIndexName := fmt.Sprintf("%s_row_time_%d", sourceInfo.Name, sourceInfo.RowTimeIndexID)
+		indexInfo := &common.IndexInfo{
+			SchemaName: sourceInfo.SchemaName,
+			ID:         sourceInfo.RowTimeIndexID,
+			TableName:  sourceInfo.Name,
+			Name:       rowTimeIndexName,
+			IndexCols:  []int{columnIndex(sourceInfo.ColumnNames, "row_time")},
+		}
+		te.AddConsumingNode(rowTimeIndexName, exec.NewIndexExecutor(sourceInfo.TableInfo, indexInfo, p.cluster))
+	}
+	mvs, sinks := p.getSourceDependents(sourceInfo)
+	for _, mv := range mvs {
+		if err := mv.rebuild(); err != nil {
+			return err
+		}
+	}
+	for _, sink := range sinks {
+		if err := sink.rebuild(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSourceDependents returns the materialized views and sinks which consume the source directly, in the order they
+// were created
+func (p *Engine) getSourceDependents(sourceInfo *common.SourceInfo) ([]*MaterializedView, []*Sink) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	var mvs []*MaterializedView
+	for _, mv := range p.materializedViews {
+		if mv.schema.Name == sourceInfo.SchemaName && scansTable(mv.tableExecutor, sourceInfo.Name) {
+			mvs = append(mvs, mv)
+		}
+	}
+	sort.Slice(mvs, func(i, j int) bool {
+		return mvs[i].Info.ID < mvs[j].Info.ID
+	})
+	var sinks []*Sink
+	for _, sink := range p.sinks {
+		if sink.schema.Name == sourceInfo.SchemaName && scansTable(sink, sourceInfo.Name) {
+			sinks = append(sinks, sink)
+		}
+	}
+	sort.Slice(sinks, func(i, j int) bool {
+		return sinks[i].Info.ID < sinks[j].Info.ID
+	})
+	return mvs, sinks
+}
+
+// migrateTableRows rewrites the rows of a table, and of its version table, in the local shards so they are encoded with
+// the columns of tableInfo rather than those of prevInfo. The value of each row before it is rewritten is backed up in
+// the same batch, and the columns the rows are migrated to are recorded before any are, so however far the migration
+// got the rows can be restored.
+func (p *Engine) migrateTableRows(prevInfo *common.TableInfo, tableInfo *common.TableInfo) error {
+	migrator := newRowMigrator(prevInfo, tableInfo)
+	for _, shardID := range p.cluster.GetLocalShardIDs() {
+		// A previous alteration of the table may have failed without being rolled back in this shard
+		if err := p.recoverMigratedRows(shardID, prevInfo); err != nil {
+			return err
+		}
+		wb := cluster.NewWriteBatch(shardID)
+		wb.AddPut(encodeMigrationBackupPrefix(shardID, tableInfo.ID), encodeMigratedColumns(tableInfo))
+		if err := p.cluster.WriteBatchLocally(wb); err != nil {
+			return err
+		}
+		if err := p.migrateRowsInTable(shardID, tableInfo.ID, tableInfo.ID, migrator.migrate); err != nil {
+			return err
+		}
+		if tableInfo.VersionRetentionDuration == 0 {
+			continue
+		}
+		err := p.migrateRowsInTable(shardID, tableInfo.ID, tableInfo.VersionTableID, func(value []byte) ([]byte, error) {
+			prevValue, ok := table.DecodeVersionValue(value)
+			if !ok {
+				return value, nil
+			}
+			newValue, err := migrator.migrate(prevValue)
+			if err != nil {
+				return nil, err
+			}
+			return table.EncodeVersionValue(newValue), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return p.cluster.SyncStore()
+}
+
+func (p *Engine) migrateRowsInTable(shardID uint64, sourceID uint64, tableID uint64, migrate func([]byte) ([]byte, error)) error {
+	batchSize := getInitBatchSize()
+	scanStart := table.EncodeTableKeyPrefix(tableID, shardID, 16)
+	scanEnd := table.EncodeTableKeyPrefix(tableID+1, shardID, 16)
+	backupPrefix := encodeMigrationBackupPrefix(shardID, sourceID)
+	for {
+		pairs, err := p.cluster.LocalScan(scanStart, scanEnd, batchSize)
+		if err != nil {
+			return err
+		}
+		wb := cluster.NewWriteBatch(shardID) // Epoch doesn't matter as writing locally
+		for _, kv := range pairs {
+			value, err := migrate(kv.Value)
+			if err != nil {
+				return err
+			}
+			// The backup key is the key of the row without its shard id, after the prefix
+			wb.AddPut(append(common.CopyByteSlice(backupPrefix), kv.Key[8:]...), kv.Value)
+			wb.AddPut(kv.Key, value)
+		}
+		if err := p.cluster.WriteBatchLocally(wb); err != nil {
+			return err
+		}
+		if len(pairs) < batchSize {
+			return nil
+		}
+		// The next scan starts just after the last key
+		lastKey := pairs[len(pairs)-1].Key
+		scanStart = append(append(make([]byte, 0, len(lastKey)+1), lastKey...), 0)
+	}
+}
+
+// recoverMigratedRows deletes the backups of the rows of a table in a shard if the rows were migrated to the columns
+// of tableInfo, and otherwise restores the rows from them
+func (p *Engine) recoverMigratedRows(shardID uint64, tableInfo *common.TableInfo) error {
+	backupPrefix := encodeMigrationBackupPrefix(shardID, tableInfo.ID)
+	migratedColumns, err := p.cluster.LocalGet(backupPrefix)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if migratedColumns == nil {
+		return nil
+	}
+	if !bytes.Equal(migratedColumns, encodeMigratedColumns(tableInfo)) {
+		log.Infof("restoring rows of %s.%s in shard %d which were migrated by an alteration which didn't complete",
+			tableInfo.SchemaName, tableInfo.Name, shardID)
+		batchSize := getInitBatchSize()
+		// The first key after the prefix is the first backup, as the prefix itself holds the migrated columns
+		scanStart := append(common.CopyByteSlice(backupPrefix), 0)
+		scanEnd := common.IncrementBytesBigEndian(backupPrefix)
+		for {
+			pairs, err := p.cluster.LocalScan(scanStart, scanEnd, batchSize)
+			if err != nil {
+				return err
+			}
+			wb := cluster.NewWriteBatch(shardID)
+			for _, kv := range pairs {
+				key := common.AppendUint64ToBufferBE(make([]byte, 0, 8+len(kv.Key)-len(backupPrefix)), shardID)
+				wb.AddPut(append(key, kv.Key[len(backupPrefix):]...), kv.Value)
+			}
+			if err := p.cluster.WriteBatchLocally(wb); err != nil {
+				return err
+			}
+			if len(pairs) < batchSize {
+				break
+			}
+			lastKey := pairs[len(pairs)-1].Key
+			scanStart = append(append(make([]byte, 0, len(lastKey)+1), lastKey...), 0)
+		}
+	}
+	return p.deleteMigrationBackups(shardID, tableInfo.ID)
+}
+
+func (p *Engine) deleteMigrationBackups(shardID uint64, tableID uint64) error {
+	// The range to delete doesn't include the shard id
+	prefix := encodeMigrationBackupPrefix(shardID, tableID)[8:]
+	return p.cluster.DeleteAllDataInRangeForShardLocally(shardID, prefix, common.IncrementBytesBigEndian(prefix))
+}
+
+// encodeMigrationBackupPrefix returns the prefix of the keys of the backups of the rows of a table, and of its version
+// table, in a shard, which were migrated when the table was altered. The columns they were migrated to are held under
+// the prefix itself.
+func encodeMigrationBackupPrefix(shardID uint64, tableID uint64) []byte {
+	prefix := table.EncodeTableKeyPrefix(common.MigrationBackupTableID, shardID, 24)
+	return common.AppendUint64ToBufferBE(prefix, tableID)
+}
+
+// migratedColumns are the columns the rows of a table were migrated to
+type migratedColumns struct {
+	ColumnNames []string
+	ColumnTypes []common.ColumnType
+}
+
+func encodeMigratedColumns(tableInfo *common.TableInfo) []byte {
+	b, err := json.Marshal(&migratedColumns{ColumnNames: tableInfo.ColumnNames, ColumnTypes: tableInfo.ColumnTypes})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func sameMigratedColumns(tableInfo *common.TableInfo, other *common.TableInfo) bool {
+	return bytes.Equal(encodeMigratedColumns(tableInfo), encodeMigratedColumns(other))
+}
+
+// purgeRowCaches clears the row caches of the local schedulers
+func (p *Engine) purgeRowCaches() error {
+	p.lock.RLock()
+	var chans []chan error
+	for _, scheduler := range p.schedulers {
+		sched := scheduler
+		ch, err := sched.AddAction(func() error {
+			sched.RowCache().Purge()
+			return nil
+		}, 1)
+		if err != nil {
+			p.lock.RUnlock()
+			return err
+		}
+		chans = append(chans, ch)
+	}
+	p.lock.RUnlock()
+	for _, ch := range chans {
+		if err := <-ch; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuild replaces the executors of the materialized view with ones built from its query against the current schema,
+// once a source it consumes has been altered. The table executor of the materialized view is kept, so anything
+// consuming the materialized view is unaffected.
+func (m *MaterializedView) rebuild() error {
+	dag, internalTables, err := m.pe.buildPushQueryExecution(parplan.NewPlanner(m.schema), m.schema, m.Info.Query,
+		m.Info.Name, newReusingSeqGenerator(m.InternalTables))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// The aggregations are replaced, so their remote consumers are too
+	for _, agg := range getAggregators(m.tableExecutor) {
+		if err := m.pe.UnregisterRemoteConsumer(agg.AggTableInfo.ID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	m.tableExecutor.ClearChildren()
+	exec.ConnectPushExecutors([]exec.PushExecutor{dag}, m.tableExecutor)
+	m.InternalTables = internalTables
+	log.Debugf("rebuilt materialized view %s.%s", m.Info.SchemaName, m.Info.Name)
+	return m.Connect(true, true)
+}
+
+// rebuild replaces the executors of the sink with ones built from its query against the current schema, once a source
+// it consumes has been altered
+func (s *Sink) rebuild() error {
+	dag, _, err := s.pe.buildPushQueryExecution(parplan.NewPlanner(s.schema), s.schema, s.Info.Query, s.Info.Name,
+		common.NewPreallocSeqGen([]uint64{s.Info.ID}))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.children = nil
+	exec.ConnectPushExecutors([]exec.PushExecutor{dag}, s)
+	return s.Connect()
+}
+
+// reusingSeqGenerator generates the ids of the internal tables of an existing materialized view, in the order in which
+// they were first generated, so the materialized view can be planned again. Once they have all been used it generates
+// zero.
+type reusingSeqGenerator struct {
+	ids []uint64
+}
+
+func newReusingSeqGenerator(internalTables []*common.InternalTableInfo) *reusingSeqGenerator {
+	ids := make([]uint64, len(internalTables))
+	for i, internalTable := range internalTables {
+		ids[i] = internalTable.ID
+	}
+	return &reusingSeqGenerator{ids: ids}
+}
+
+func (r *reusingSeqGenerator) GenerateSequence() uint64 {
+	if len(r.ids) == 0 {
+		return 0
+	}
+	id := r.ids[0]
+	r.ids = r.ids[1:]
+	return id
+}
+
+// rowMigrator converts rows encoded with the columns of a table before it was altered to rows encoded with its columns
+// after. Columns are matched by name - an added column is null in every row.
+type rowMigrator struct {
+	prevTypes   []common.ColumnType
+	colTypes    []common.ColumnType
+	prevIndexes []int
+	prevFactory *common.RowsFactory
+	factory     *common.RowsFactory
+}
+
+func newRowMigrator(prevInfo *common.TableInfo, tableInfo *common.TableInfo) *rowMigrator {
+	prevIndexes := make([]int, len(tableInfo.ColumnNames))
+	for i, colName := range tableInfo.ColumnNames {
+		prevIndexes[i] = columnIndex(prevInfo.ColumnNames, colName)
+	}
+	return &rowMigrator{
+		prevTypes:   prevInfo.ColumnTypes,
+		colTypes:    tableInfo.ColumnTypes,
+		prevIndexes: prevIndexes,
+		prevFactory: common.NewRowsFactory(prevInfo.ColumnTypes),
+		factory:     common.NewRowsFactory(tableInfo.ColumnTypes),
+	}
+}
+
+func (r *rowMigrator) migrate(value []byte) ([]byte, error) {
+	prevRows := r.prevFactory.NewRows(1)
+	if err := common.DecodeRow(value, r.prevTypes, prevRows); err != nil {
+		return nil, err
+	}
+	prevRow := prevRows.GetRow(0)
+	rows := r.factory.NewRows(1)
+	for i, colType := range r.colTypes {
+		prevIndex := r.prevIndexes[i]
+		if prevIndex == -1 || prevRow.IsNull(prevIndex) {
+			rows.AppendNullToColumn(i)
+			continue
+		}
+		switch colType.Type {
+		case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
+			rows.AppendInt64ToColumn(i, prevRow.GetInt64(prevIndex))
+		case common.TypeDouble:
+			rows.AppendFloat64ToColumn(i, prevRow.GetFloat64(prevIndex))
+		case common.TypeVarchar:
+			rows.AppendStringToColumn(i, prevRow.GetString(prevIndex))
+		case common.TypeTimestamp:
+			rows.AppendTimestampToColumn(i, prevRow.GetTimestamp(prevIndex))
+		case common.TypeDecimal:
+			rows.AppendDecimalToColumn(i, prevRow.GetDecimal(prevIndex))
+		default:
+			panic("unexpected column type")
+		}
+	}
+	row := rows.GetRow(0)
+	return common.EncodeRow(&row, r.colTypes, nil)
+}
+
+// scansTable returns true if the executor, or any executor below it, scans the table with the given name
+func scansTable(executor exec.PushExecutor, tableName string) bool {
+	if scan, ok := executor.(*exec.Scan); ok && scan.TableName == tableName {
+		return true
+	}
+	for _, child := range executor.GetChildren() {
+		if scansTable(child, tableName) {
+			return true
+		}
+	}
+	return false
+}
+
+func getAggregators(executor exec.PushExecutor) []*exec.Aggregator {
+	var aggs []*exec.Aggregator
+	for _, child := range executor.GetChildren() {
+		aggs = append(aggs, getAggregators(child)...)
+	}
+	if agg, ok := executor.(*exec.Aggregator); ok {
+		aggs = append(aggs, agg)
+	}
+	return aggs
+}
+
+// sameColumns returns true if the executor outputs the columns of the table
+func sameColumns(executor exec.PushExecutor, tableInfo *common.TableInfo) bool {
+	return reflect.DeepEqual(executor.ColNames(), tableInfo.ColumnNames) &&
+		reflect.DeepEqual(executor.ColTypes(), tableInfo.ColumnTypes) &&
+		reflect.DeepEqual(executor.KeyCols(), tableInfo.PrimaryKeyCols)
+}
+
+// sameAggregations returns true if the aggregations store and receive the same rows in the same tables
+func sameAggregations(aggs []*exec.Aggregator, prevAggs []*exec.Aggregator) bool {
+	if len(aggs) != len(prevAggs) {
+		return false
+	}
+	for i, agg := range aggs {
+		prevAgg := prevAggs[i]
+		if agg.AggTableInfo.ID != prevAgg.AggTableInfo.ID ||
+			!reflect.DeepEqual(agg.ColTypes(), prevAgg.ColTypes()) ||
+			!reflect.DeepEqual(agg.GetChildren()[0].ColTypes(), prevAgg.GetChildren()[0].ColTypes()) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedIndexInfos(tableInfo *common.TableInfo) []*common.IndexInfo {
+	indexInfos := make([]*common.IndexInfo, 0, len(tableInfo.IndexInfos))
+	for _, indexInfo := range tableInfo.IndexInfos {
+		indexInfos = append(indexInfos, indexInfo)
+	}
+	sort.Slice(indexInfos, func(i, j int) bool {
+		return indexInfos[i].ID < indexInfos[j].ID
+	})
+	return indexInfos
+}
+
+// columnIndex returns the index of the column with the given name, or -1 if there is no such column
+func columnIndex(colNames []string, colName string) int {
+	for i, name := range colNames {
+		if name == colName {
+			return i
+		}
+	}
+	return -1
+}
+
+// errorMessage returns the message of an error without its error code, so it can be included in another error
+func errorMessage(err error) string {
+	var perr errors.PranaError
+	if errors.As(err, &perr) {
+		return strings.TrimPrefix(perr.Msg, fmt.Sprintf("PDB%04d - ", perr.Code))
+	}
+	return err.Error()
+}
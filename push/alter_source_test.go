@@ -0,0 +1,104 @@
+package push
+
+import (
+	"testing"
+
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/cluster/fake"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/table"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateAndRestoreTableRows(t *testing.T) {
+	prevInfo := common.NewTableInfo(1000, "test", "source1", []int{0}, []string{"id", "v", "w"},
+		[]common.ColumnType{common.BigIntColumnType, common.VarcharColumnType, common.IntColumnType}, 0, 0)
+	// Drop v and widen w
+	tableInfo := common.NewTableInfo(1000, "test", "source1", []int{0}, []string{"id", "w"},
+		[]common.ColumnType{common.BigIntColumnType, common.BigIntColumnType}, 0, 0)
+
+	clust := fake.NewFakeCluster(0, 3)
+	p := &Engine{cluster: clust}
+	// Migrate in several batches
+	prevBatchSize := getInitBatchSize()
+	SetInitBatchSize(3)
+	defer SetInitBatchSize(prevBatchSize)
+
+	prevRows := writeTestRows(t, clust, prevInfo, 10)
+	require.NoError(t, p.migrateTableRows(prevInfo, tableInfo))
+	requireMigratedRows(t, clust, tableInfo, 10)
+
+	// The alteration wasn't persisted, so the rows are restored
+	require.NoError(t, p.RecoverSourceAlteration(&common.SourceInfo{TableInfo: prevInfo}))
+	require.Equal(t, prevRows, readTableRows(t, clust, prevInfo))
+	requireNoBackups(t, clust, prevInfo.ID)
+	// Recovering again does nothing
+	require.NoError(t, p.RecoverSourceAlteration(&common.SourceInfo{TableInfo: prevInfo}))
+	require.Equal(t, prevRows, readTableRows(t, clust, prevInfo))
+
+	// This time the alteration is persisted, so the backups are deleted and the rows stay migrated
+	require.NoError(t, p.migrateTableRows(prevInfo, tableInfo))
+	migratedRows := readTableRows(t, clust, tableInfo)
+	require.NoError(t, p.RecoverSourceAlteration(&common.SourceInfo{TableInfo: tableInfo}))
+	requireNoBackups(t, clust, prevInfo.ID)
+	require.Equal(t, migratedRows, readTableRows(t, clust, tableInfo))
+	requireMigratedRows(t, clust, tableInfo, 10)
+}
+
+func writeTestRows(t *testing.T, clust cluster.Cluster, tableInfo *common.TableInfo, numRows int) [][]byte {
+	t.Helper()
+	rf := common.NewRowsFactory(tableInfo.ColumnTypes)
+	for _, shardID := range clust.GetLocalShardIDs() {
+		rows := rf.NewRows(numRows)
+		for i := 0; i < numRows; i++ {
+			rows.AppendInt64ToColumn(0, int64(i))
+			rows.AppendStringToColumn(1, "foo")
+			rows.AppendInt64ToColumn(2, int64(i*10))
+		}
+		wb := cluster.NewWriteBatch(shardID)
+		for i := 0; i < numRows; i++ {
+			row := rows.GetRow(i)
+			require.NoError(t, table.Upsert(tableInfo, &row, wb))
+		}
+		require.NoError(t, clust.WriteBatch(wb, false))
+	}
+	return readTableRows(t, clust, tableInfo)
+}
+
+func readTableRows(t *testing.T, clust cluster.Cluster, tableInfo *common.TableInfo) [][]byte {
+	t.Helper()
+	var values [][]byte
+	for _, shardID := range clust.GetLocalShardIDs() {
+		pairs, err := clust.LocalScan(table.EncodeTableKeyPrefix(tableInfo.ID, shardID, 16),
+			table.EncodeTableKeyPrefix(tableInfo.ID+1, shardID, 16), -1)
+		require.NoError(t, err)
+		for _, kv := range pairs {
+			values = append(values, kv.Value)
+		}
+	}
+	return values
+}
+
+// requireMigratedRows checks that every shard has the rows written by writeTestRows, encoded with the columns of tableInfo
+func requireMigratedRows(t *testing.T, clust cluster.Cluster, tableInfo *common.TableInfo, numRows int) {
+	t.Helper()
+	values := readTableRows(t, clust, tableInfo)
+	require.Equal(t, numRows*len(clust.GetLocalShardIDs()), len(values))
+	rf := common.NewRowsFactory(tableInfo.ColumnTypes)
+	for _, value := range values {
+		rows := rf.NewRows(1)
+		require.NoError(t, common.DecodeRow(value, tableInfo.ColumnTypes, rows))
+		row := rows.GetRow(0)
+		require.Equal(t, row.GetInt64(0)*10, row.GetInt64(1))
+	}
+}
+
+func requireNoBackups(t *testing.T, clust cluster.Cluster, tableID uint64) {
+	t.Helper()
+	for _, shardID := range clust.GetLocalShardIDs() {
+		prefix := encodeMigrationBackupPrefix(shardID, tableID)
+		pairs, err := clust.LocalScan(prefix, common.IncrementBytesBigEndian(prefix), -1)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(pairs))
+	}
+}
@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"sync"
+
+	"github.com/hamba/avro/v2"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/schemaregistry"
+)
+
+// AvroCodec decodes Avro messages in the Confluent wire format, resolving the schema each message was written with in
+// the schema registry. If the codec has a subject, messages are read as the latest schema of the subject, so messages
+// written with older or newer versions of the schema are read in the same shape. Otherwise, each message is read as
+// the schema it was written with.
+// Messages can only be encoded if the codec has a subject, and are written with its latest schema.
+type AvroCodec struct {
+	registry schemaregistry.Registry
+	readerID int32
+	reader   avro.Schema
+	lock     sync.Mutex
+	decoders map[int32]*schemaregistry.AvroDecoder
+}
+
+func NewAvroCodec(registry schemaregistry.Registry, subject string) (*AvroCodec, error) {
+	c := &AvroCodec{
+		registry: registry,
+		decoders: map[int32]*schemaregistry.AvroDecoder{},
+	}
+	if subject != "" {
+		schema, err := registry.GetLatestSchema(subject)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := schema.AvroSchema()
+		if err != nil {
+			return nil, err
+		}
+		c.readerID = schema.ID
+		c.reader = reader
+	}
+	return c, nil
+}
+
+func (a *AvroCodec) Decode(bytes []byte) (interface{}, error) {
+	id, payload, err := schemaregistry.DecodeWireFormat(bytes)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := a.getDecoder(id)
+	if err != nil {
+		return nil, err
+	}
+	return decoder.Decode(payload)
+}
+
+func (a *AvroCodec) getDecoder(id int32) (*schemaregistry.AvroDecoder, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if decoder, ok := a.decoders[id]; ok {
+		return decoder, nil
+	}
+	schema, err := a.registry.GetSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	writer, err := schema.AvroSchema()
+	if err != nil {
+		return nil, err
+	}
+	reader := a.reader
+	if reader == nil {
+		reader = writer
+	}
+	decoder, err := schemaregistry.NewAvroDecoder(writer, reader)
+	if err != nil {
+		return nil, err
+	}
+	a.decoders[id] = decoder
+	return decoder, nil
+}
+
+func (a *AvroCodec) Encode(val interface{}) ([]byte, error) {
+	if a.reader == nil {
+		return nil, errors.Error("avro encoding must specify the subject to encode with, as avro:<subject>")
+	}
+	buff := schemaregistry.AppendWireFormat(nil, a.readerID)
+	return schemaregistry.EncodeAvro(buff, a.reader, val)
+}
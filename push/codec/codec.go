@@ -7,6 +7,7 @@ import (
 	"github.com/squareup/pranadb/common"
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 	"google.golang.org/protobuf/reflect/protoreflect"
 	"google.golang.org/protobuf/types/dynamicpb"
 	"math"
@@ -23,9 +24,10 @@ var (
 	kafkaCodecString  = newKafkaCodec(common.KafkaEncodingStringBytes)
 )
 
-func GetCodec(registry protolib.Resolver, encoding common.KafkaEncoding) (Codec, error) {
+func GetCodec(registry protolib.Resolver, schemaRegistry schemaregistry.Registry, encoding common.KafkaEncoding) (Codec, error) {
 	var decoder Codec
-	switch encoding.Encoding {
+	// Change events are decoded with the codec of the encoding of the rows they hold, then unwrapped
+	switch encoding.Encoding.RowEncoding() {
 	case common.EncodingJSON:
 		decoder = jsonCodec
 	case common.EncodingFloat64BE:
@@ -40,6 +42,12 @@ func GetCodec(registry protolib.Resolver, encoding common.KafkaEncoding) (Codec,
 		decoder = kafkaCodecShort
 	case common.EncodingStringBytes:
 		decoder = kafkaCodecString
+	case common.EncodingAvro:
+		avroCodec, err := NewAvroCodec(schemaRegistry, encoding.SchemaName)
+		if err != nil {
+			return nil, err
+		}
+		decoder = avroCodec
 	case common.EncodingProtobuf:
 		desc, err := registry.FindDescriptorByName(protoreflect.FullName(encoding.SchemaName))
 		if err != nil {
@@ -50,9 +58,18 @@ func GetCodec(registry protolib.Resolver, encoding common.KafkaEncoding) (Codec,
 			return nil, errors.Errorf("expected to find MessageDescriptor at %q, but was %q", encoding.SchemaName, reflect.TypeOf(msgDesc))
 		}
 		decoder = &ProtobufDecoder{desc: msgDesc}
+	case common.EncodingProtobufConfluent:
+		protobufCodec, err := NewProtobufConfluentCodec(registry, schemaRegistry, encoding.SchemaName)
+		if err != nil {
+			return nil, err
+		}
+		decoder = protobufCodec
 	default:
 		panic(fmt.Sprintf("unsupported encoding %+v", encoding))
 	}
+	if encoding.Encoding.IsChangeEvent() {
+		decoder = NewDebeziumCodec(decoder)
+	}
 	return decoder, nil
 }
 
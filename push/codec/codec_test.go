@@ -1,9 +1,21 @@
 package codec
 
 import (
+	"fmt"
 	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/protos/squareup/cash/pranadb/v1/testproto"
+	"github.com/squareup/pranadb/schemaregistry"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
 	"gotest.tools/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -25,6 +37,33 @@ func TestJSONCodec(t *testing.T) {
 	assert.DeepEqual(t, obj, obj2)
 }
 
+func TestDebeziumJSONCodec(t *testing.T) {
+	codec, err := GetCodec(nil, nil, common.KafkaEncodingDebeziumJSON)
+	require.NoError(t, err)
+	after := map[string]interface{}{"id": float64(1), "name": "foo"}
+	for _, op := range []string{"c", "u", "r"} {
+		v, err := codec.Decode([]byte(`{"before":null,"after":{"id":1,"name":"foo"},"op":"` + op + `","ts_ms":1}`))
+		require.NoError(t, err)
+		assert.DeepEqual(t, &ChangeEvent{Row: after}, v)
+	}
+
+	v, err := codec.Decode([]byte(`{"before":{"id":1,"name":null},"after":null,"op":"d"}`))
+	require.NoError(t, err)
+	assert.DeepEqual(t, &ChangeEvent{Row: map[string]interface{}{"id": float64(1), "name": nil}, Delete: true}, v)
+
+	// With the schema envelope
+	v, err = codec.Decode([]byte(`{"schema":{"type":"struct"},"payload":{"before":null,"after":{"id":1,"name":"foo"},"op":"c"}}`))
+	require.NoError(t, err)
+	assert.DeepEqual(t, &ChangeEvent{Row: after}, v)
+
+	_, err = codec.Decode([]byte(`{"op":"t"}`))
+	require.Error(t, err)
+	_, err = codec.Decode([]byte(`{"after":{"id":1}}`))
+	require.Error(t, err)
+	_, err = codec.Encode(after)
+	require.Error(t, err)
+}
+
 func TestKafkaCodecFloat32BE(t *testing.T) {
 	testKafkaCodecFloat32BE(t, float32(25.5), 25.5)
 	testKafkaCodecFloat32BE(t, float32(0), 0)
@@ -48,3 +87,246 @@ func testKafkaCodecFloat32BE(t *testing.T, val interface{}, expected float32) {
 	v2 := v.(float32) //nolint:forcetypeassert
 	require.Equal(t, expected, v2)
 }
+
+func TestDebeziumAvroCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debezium-avro-codec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "customers-value"), 0700))
+	envelope := `{"type":"record","name":"Envelope","fields":[
+		{"name":"before","type":["null",{"type":"record","name":"Value","fields":[
+			{"name":"id","type":"long"},{"name":"name","type":["null","string"]}]}],"default":null},
+		{"name":"after","type":["null","Value"],"default":null},
+		{"name":"op","type":"string"}]}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "customers-value", "1.avsc"), []byte(envelope), 0600))
+	registry, err := schemaregistry.NewDirBackedRegistry(dir)
+	require.NoError(t, err)
+	registered, err := registry.GetSchema(1)
+	require.NoError(t, err)
+	writer, err := registered.AvroSchema()
+	require.NoError(t, err)
+	encode := func(event map[string]interface{}) []byte {
+		msg, err := schemaregistry.EncodeAvro(schemaregistry.AppendWireFormat(nil, 1), writer, event)
+		require.NoError(t, err)
+		return msg
+	}
+
+	codec, err := GetCodec(nil, registry, common.KafkaEncodingFromString("debezium-avro"))
+	require.NoError(t, err)
+	row := map[string]interface{}{"id": int64(1), "name": "foo"}
+	for _, op := range []string{"c", "u", "r"} {
+		v, err := codec.Decode(encode(map[string]interface{}{"before": nil, "after": row, "op": op}))
+		require.NoError(t, err)
+		assert.DeepEqual(t, &ChangeEvent{Row: row}, v)
+	}
+	v, err := codec.Decode(encode(map[string]interface{}{"before": map[string]interface{}{"id": int64(1), "name": nil}, "after": nil, "op": "d"}))
+	require.NoError(t, err)
+	assert.DeepEqual(t, &ChangeEvent{Row: map[string]interface{}{"id": int64(1), "name": nil}, Delete: true}, v)
+	_, err = codec.Decode(encode(map[string]interface{}{"before": nil, "after": nil, "op": "t"}))
+	require.Error(t, err)
+	_, err = codec.Encode(row)
+	require.Error(t, err)
+
+	// With a subject, change events are read as its latest schema
+	codec, err = GetCodec(nil, registry, common.KafkaEncodingFromString("debezium-avro:customers-value"))
+	require.NoError(t, err)
+	v, err = codec.Decode(encode(map[string]interface{}{"before": nil, "after": row, "op": "c"}))
+	require.NoError(t, err)
+	assert.DeepEqual(t, &ChangeEvent{Row: row}, v)
+}
+
+func TestDebeziumProtobufConfluentCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "debezium-protobuf-confluent-codec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("debezium.proto"),
+		Package: proto.String("test.debezium"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Value"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, ""),
+				protoField("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			},
+		}, {
+			Name: proto.String("Envelope"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				protoField("before", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.debezium.Value"),
+				protoField("after", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.debezium.Value"),
+				protoField("op", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			},
+		}},
+	}, protolib.EmptyRegistry)
+	require.NoError(t, err)
+	writeFileDescriptorSet(t, dir, "test.debezium.Envelope", 1, fd)
+	registry, err := schemaregistry.NewDirBackedRegistry(dir)
+	require.NoError(t, err)
+	// Change events are written as plain protobuf-confluent messages
+	writer, err := GetCodec(protolib.EmptyRegistry, registry, common.KafkaEncodingFromString("protobuf-confluent:test.debezium.Envelope"))
+	require.NoError(t, err)
+	encode := func(event map[string]interface{}) []byte {
+		msg, err := writer.Encode(event)
+		require.NoError(t, err)
+		return msg
+	}
+	getRow := func(v interface{}) (int64, string) {
+		event, ok := v.(*ChangeEvent)
+		require.True(t, ok)
+		row, ok := event.Row.(protoreflect.Message)
+		require.True(t, ok)
+		fields := row.Descriptor().Fields()
+		return row.Get(fields.ByName("id")).Int(), row.Get(fields.ByName("name")).String()
+	}
+
+	codec, err := GetCodec(protolib.EmptyRegistry, registry, common.KafkaEncodingFromString("debezium-protobuf-confluent:test.debezium.Envelope"))
+	require.NoError(t, err)
+	for _, op := range []string{"c", "u", "r"} {
+		v, err := codec.Decode(encode(map[string]interface{}{"after": map[string]interface{}{"id": 1, "name": "foo"}, "op": op}))
+		require.NoError(t, err)
+		require.False(t, v.(*ChangeEvent).Delete) //nolint:forcetypeassert
+		id, name := getRow(v)
+		require.Equal(t, int64(1), id)
+		require.Equal(t, "foo", name)
+	}
+	v, err := codec.Decode(encode(map[string]interface{}{"before": map[string]interface{}{"id": 2}, "op": "d"}))
+	require.NoError(t, err)
+	require.True(t, v.(*ChangeEvent).Delete) //nolint:forcetypeassert
+	id, _ := getRow(v)
+	require.Equal(t, int64(2), id)
+	// An unset row is nil
+	v, err = codec.Decode(encode(map[string]interface{}{"op": "d"}))
+	require.NoError(t, err)
+	assert.DeepEqual(t, &ChangeEvent{Delete: true}, v)
+	_, err = codec.Decode(encode(map[string]interface{}{"op": "t"}))
+	require.Error(t, err)
+}
+
+func protoField(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     typ.Enum(),
+	}
+	if typeName != "" {
+		field.TypeName = proto.String(typeName)
+	}
+	return field
+}
+
+func TestAvroCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "avro-codec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "test-value"), 0700))
+	v1 := `{"type":"record","name":"R","fields":[{"name":"a","type":"int"}]}`
+	v2 := `{"type":"record","name":"R","fields":[{"name":"a","type":"long"},{"name":"b","type":"string","default":"x"}]}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "test-value", "1.avsc"), []byte(v1), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "test-value", "2.avsc"), []byte(v2), 0600))
+	registry, err := schemaregistry.NewDirBackedRegistry(dir)
+	require.NoError(t, err)
+
+	registered, err := registry.GetSchema(1)
+	require.NoError(t, err)
+	writer, err := registered.AvroSchema()
+	require.NoError(t, err)
+	msg, err := schemaregistry.EncodeAvro(schemaregistry.AppendWireFormat(nil, 1), writer, map[string]interface{}{"a": int32(7)})
+	require.NoError(t, err)
+
+	// Without a subject, messages are read as the schema they were written with, and can't be encoded
+	codec, err := GetCodec(nil, registry, common.KafkaEncodingFromString("avro"))
+	require.NoError(t, err)
+	v, err := codec.Decode(msg)
+	require.NoError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"a": int32(7)}, v)
+	_, err = codec.Encode(v)
+	require.Error(t, err)
+
+	// With a subject, messages are read as its latest schema, and encoded with it
+	codec, err = GetCodec(nil, registry, common.KafkaEncodingFromString("avro:test-value"))
+	require.NoError(t, err)
+	v, err = codec.Decode(msg)
+	require.NoError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"a": int64(7), "b": "x"}, v)
+	encoded, err := codec.Encode(map[string]interface{}{"a": int64(8), "b": "y"})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 0, 2}, encoded[:5])
+	v, err = codec.Decode(encoded)
+	require.NoError(t, err)
+	assert.DeepEqual(t, map[string]interface{}{"a": int64(8), "b": "y"}, v)
+
+	_, err = codec.Decode([]byte{0, 0, 0, 0, 9, 14})
+	require.Error(t, err)
+	_, err = GetCodec(nil, registry, common.KafkaEncodingFromString("avro:unknown"))
+	require.Error(t, err)
+}
+
+func TestProtobufConfluentCodec(t *testing.T) {
+	dir, err := ioutil.TempDir("", "protobuf-confluent-codec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+	writeFileDescriptorSet(t, dir, "squareup.cash.pranadb.testproto.v1.Simple", 3,
+		testproto.File_squareup_cash_pranadb_testproto_v1_simple_proto)
+	writeFileDescriptorSet(t, dir, "squareup.cash.pranadb.testproto.v1.SimpleValue", 4,
+		testproto.File_squareup_cash_pranadb_testproto_v1_imports_proto, testproto.File_squareup_cash_pranadb_testproto_v1_testproto_proto)
+	registry, err := schemaregistry.NewDirBackedRegistry(dir)
+	require.NoError(t, err)
+
+	// A message registered with Prana is decoded with its registered descriptor, and encoded with the schema id of the
+	// subject named after it
+	codec, err := GetCodec(protoregistry.GlobalFiles, registry, common.KafkaEncodingFromString("protobuf-confluent:squareup.cash.pranadb.testproto.v1.Simple"))
+	require.NoError(t, err)
+	encoded, err := codec.Encode(map[string]interface{}{"key": "k1", "val1": int64(1), "val2": int64(2)})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 0, 3, 0}, encoded[:6])
+	v, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&testproto.Simple{Key: "k1", Val1: 1, Val2: 2}, v.(proto.Message))) //nolint:forcetypeassert
+	// The schema id of a message is ignored when its descriptor is registered
+	v, err = codec.Decode(append([]byte{0, 0, 0, 0, 99, 0}, encoded[6:]...))
+	require.NoError(t, err)
+	require.True(t, proto.Equal(&testproto.Simple{Key: "k1", Val1: 1, Val2: 2}, v.(proto.Message))) //nolint:forcetypeassert
+
+	// Otherwise, the message is resolved from the schema it was written with, by its message indexes
+	codec, err = GetCodec(protolib.EmptyRegistry, registry, common.KafkaEncodingFromString("protobuf-confluent:squareup.cash.pranadb.testproto.v1.SimpleValue"))
+	require.NoError(t, err)
+	encoded, err = codec.Encode(map[string]interface{}{"value": "foo"})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 0, 0, 0, 4, 2, 4}, encoded[:7])
+	v, err = codec.Decode(encoded)
+	require.NoError(t, err)
+	msg := v.(proto.Message).ProtoReflect() //nolint:forcetypeassert
+	require.Equal(t, protoreflect.FullName("squareup.cash.pranadb.testproto.v1.SimpleValue"), msg.Descriptor().FullName())
+	require.Equal(t, "foo", msg.Get(msg.Descriptor().Fields().ByName("value")).String())
+	// A message of a different type is an error
+	_, err = codec.Decode(append([]byte{0, 0, 0, 0, 4, 2, 2}, encoded[7:]...))
+	require.Error(t, err)
+	_, err = codec.Decode(append([]byte{0, 0, 0, 0, 99, 0}, encoded[7:]...))
+	require.Error(t, err)
+	_, err = codec.Decode(encoded[:5])
+	require.Error(t, err)
+	_, err = codec.Encode(map[string]interface{}{"unknown": "foo"})
+	require.Error(t, err)
+
+	// Messages can't be encoded without a subject named after the message
+	codec, err = GetCodec(protoregistry.GlobalFiles, registry, common.KafkaEncodingFromString("protobuf-confluent:squareup.cash.pranadb.testproto.v1.TestTypes"))
+	require.NoError(t, err)
+	_, err = codec.Encode(map[string]interface{}{})
+	require.Error(t, err)
+	_, err = GetCodec(protoregistry.GlobalFiles, registry, common.KafkaEncodingFromString("protobuf-confluent"))
+	require.Error(t, err)
+}
+
+func writeFileDescriptorSet(t *testing.T, dir string, subject string, id int, files ...protoreflect.FileDescriptor) {
+	t.Helper()
+	fds := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range files {
+		fds.File = append(fds.File, protodesc.ToFileDescriptorProto(fd))
+	}
+	b, err := proto.Marshal(fds)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, subject), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, subject, fmt.Sprintf("%d.bin", id)), b, 0600))
+}
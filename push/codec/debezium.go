@@ -0,0 +1,73 @@
+package codec
+
+import (
+	"github.com/squareup/pranadb/errors"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ChangeEvent is a change to a row, decoded from a change data capture encoding. Row is the row after the change, or
+// the row before it if the row was deleted, in which case it may only have the key of the row.
+type ChangeEvent struct {
+	Row    interface{}
+	Delete bool
+}
+
+// DebeziumCodec decodes the change events Debezium produces, with the codec of the converter they were produced with -
+// JSON, with or without their schema, Avro or protobuf. Creates, updates and snapshot reads are upserts of the row after
+// the event, and deletes delete the row before it.
+type DebeziumCodec struct {
+	codec Codec
+}
+
+func NewDebeziumCodec(codec Codec) *DebeziumCodec {
+	return &DebeziumCodec{codec: codec}
+}
+
+func (d *DebeziumCodec) Decode(bytes []byte) (interface{}, error) {
+	v, err := d.codec.Decode(bytes)
+	if err != nil {
+		return nil, err
+	}
+	var op string
+	var before, after interface{}
+	switch event := v.(type) {
+	case map[string]interface{}:
+		if _, ok := event["op"]; !ok {
+			// With schemas enabled, a JSON event is the payload of an envelope which also holds its schema
+			if payload, ok := event["payload"].(map[string]interface{}); ok {
+				event = payload
+			}
+		}
+		op, _ = event["op"].(string)
+		before, after = event["before"], event["after"]
+	case protoreflect.ProtoMessage:
+		msg := event.ProtoReflect()
+		fields := msg.Descriptor().Fields()
+		if f := fields.ByName("op"); f != nil && f.Kind() == protoreflect.StringKind {
+			op = msg.Get(f).String()
+		}
+		before, after = getProtoRow(msg, fields.ByName("before")), getProtoRow(msg, fields.ByName("after"))
+	default:
+		return nil, errors.Errorf("expected a Debezium change event, but was %T", v)
+	}
+	switch op {
+	case "c", "u", "r":
+		return &ChangeEvent{Row: after}, nil
+	case "d":
+		return &ChangeEvent{Row: before, Delete: true}, nil
+	default:
+		return nil, errors.Errorf("unsupported Debezium change event op %q", op)
+	}
+}
+
+// getProtoRow returns the row held in the message field of a protobuf change event, or nil if it isn't set
+func getProtoRow(msg protoreflect.Message, f protoreflect.FieldDescriptor) interface{} {
+	if f == nil || f.Message() == nil || f.IsList() || f.IsMap() || !msg.Has(f) {
+		return nil
+	}
+	return msg.Get(f).Message()
+}
+
+func (d *DebeziumCodec) Encode(val interface{}) ([]byte, error) {
+	return nil, errors.Error("encoding Debezium change events is not supported")
+}
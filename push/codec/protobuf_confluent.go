@@ -0,0 +1,156 @@
+package codec
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufConfluentCodec decodes protobuf messages in the Confluent wire format, which prefixes the protobuf encoded
+// message with the id of the schema it was written with, and the message indexes of its message type in the schema.
+// If the message type is registered with Prana, messages are decoded with the registered descriptor. Otherwise, the
+// descriptor is resolved from the schema it was written with in the schema registry.
+// Messages are encoded with the schema id of the latest version of the subject named after the message type, as written
+// by the Confluent serializer with the RecordNameStrategy.
+type ProtobufConfluentCodec struct {
+	name           protoreflect.FullName
+	resolver       protolib.Resolver
+	schemaRegistry schemaregistry.Registry
+	desc           protoreflect.MessageDescriptor
+	lock           sync.Mutex
+	files          map[int32]protoreflect.FileDescriptor
+	encodeID       int32
+	encodeDesc     protoreflect.MessageDescriptor
+}
+
+func NewProtobufConfluentCodec(resolver protolib.Resolver, schemaRegistry schemaregistry.Registry, name string) (*ProtobufConfluentCodec, error) {
+	if name == "" {
+		return nil, errors.Error("protobuf-confluent encoding must specify the message, as protobuf-confluent:<name>")
+	}
+	c := &ProtobufConfluentCodec{
+		name:           protoreflect.FullName(name),
+		resolver:       resolver,
+		schemaRegistry: schemaRegistry,
+		files:          map[int32]protoreflect.FileDescriptor{},
+	}
+	if desc, err := resolver.FindDescriptorByName(c.name); err == nil {
+		msgDesc, ok := desc.(protoreflect.MessageDescriptor)
+		if !ok {
+			return nil, errors.Errorf("expected to find MessageDescriptor at %q, but was %T", name, desc)
+		}
+		c.desc = msgDesc
+	}
+	return c, nil
+}
+
+func (p *ProtobufConfluentCodec) Decode(bytes []byte) (interface{}, error) {
+	id, payload, err := schemaregistry.DecodeWireFormat(bytes)
+	if err != nil {
+		return nil, err
+	}
+	indexes, payload, err := schemaregistry.DecodeMessageIndexes(payload)
+	if err != nil {
+		return nil, err
+	}
+	desc := p.desc
+	if desc == nil {
+		if desc, err = p.getWriterDesc(id, indexes); err != nil {
+			return nil, err
+		}
+	}
+	msg := dynamicpb.NewMessage(desc)
+	err = proto.Unmarshal(payload, msg)
+	return msg, errors.WithStack(err)
+}
+
+func (p *ProtobufConfluentCodec) getWriterDesc(id int32, indexes []int) (protoreflect.MessageDescriptor, error) {
+	fd, err := p.getFile(id)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := schemaregistry.MessageByIndexes(fd, indexes)
+	if err != nil {
+		return nil, err
+	}
+	if desc.FullName() != p.name {
+		return nil, errors.Errorf("message written with schema %d is a %s, not a %s", id, desc.FullName(), p.name)
+	}
+	return desc, nil
+}
+
+func (p *ProtobufConfluentCodec) getFile(id int32) (protoreflect.FileDescriptor, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if fd, ok := p.files[id]; ok {
+		return fd, nil
+	}
+	schema, err := p.schemaRegistry.GetSchema(id)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := schema.ProtobufFile(p.resolver)
+	if err != nil {
+		return nil, err
+	}
+	p.files[id] = fd
+	return fd, nil
+}
+
+// Encode encodes a protobuf message, or a map of the message's fields, which is converted to the message as JSON
+func (p *ProtobufConfluentCodec) Encode(val interface{}) ([]byte, error) {
+	id, desc, err := p.getEncodeDesc()
+	if err != nil {
+		return nil, err
+	}
+	msg, ok := val.(proto.Message)
+	if !ok {
+		dyn := dynamicpb.NewMessage(desc)
+		js, err := json.Marshal(val)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := protojson.Unmarshal(js, dyn); err != nil {
+			return nil, errors.Errorf("cannot encode %s as protobuf %s: %v", js, p.name, err)
+		}
+		msg = dyn
+	}
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	buff := schemaregistry.AppendWireFormat(nil, id)
+	buff = schemaregistry.AppendMessageIndexes(buff, schemaregistry.MessageIndexes(desc))
+	return append(buff, payload...), nil
+}
+
+func (p *ProtobufConfluentCodec) getEncodeDesc() (int32, protoreflect.MessageDescriptor, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.encodeDesc != nil {
+		return p.encodeID, p.encodeDesc, nil
+	}
+	schema, err := p.schemaRegistry.GetLatestSchema(string(p.name))
+	if err != nil {
+		return 0, nil, err
+	}
+	desc := p.desc
+	if desc == nil {
+		fd, err := schema.ProtobufFile(p.resolver)
+		if err != nil {
+			return 0, nil, err
+		}
+		if desc, err = schemaregistry.FindMessage(fd, p.name); err != nil {
+			return 0, nil, err
+		}
+	}
+	p.encodeID = schema.ID
+	p.encodeDesc = desc
+	return p.encodeID, p.encodeDesc, nil
+}
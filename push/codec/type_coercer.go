@@ -117,6 +117,10 @@ func CoerceString(val interface{}) (string, error) {
 		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
 	case common.Decimal:
 		return v.String(), nil
+	case *common.Decimal:
+		return v.String(), nil
+	case []byte:
+		return string(v), nil
 	case protoreflect.Enum:
 		return string(v.Descriptor().Values().ByNumber(v.Number()).Name()), nil
 	default:
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/rand"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	"github.com/squareup/pranadb/protolib"
 	"github.com/squareup/pranadb/push/sched"
 	"github.com/squareup/pranadb/push/source"
+	"github.com/squareup/pranadb/schemaregistry"
 
 	"github.com/squareup/pranadb/meta"
 	"github.com/squareup/pranadb/table"
@@ -52,8 +54,10 @@ type Engine struct {
 	cfg               *conf.Config
 	queryExec         common.SimpleQueryExec
 	protoRegistry     protolib.Resolver
+	schemaRegistry    schemaregistry.Registry
 	failInject        failinject.Injector
 	maxRowCacheSize   int64
+	sourceStatus      *sourceStatusReporter
 }
 
 // RemoteConsumer is a wrapper for something that consumes rows that have arrived remotely from other shards
@@ -75,7 +79,8 @@ type remoteRowsHandler interface {
 }
 
 func NewPushEngine(cluster cluster.Cluster, sharder *sharder.Sharder, meta *meta.Controller, cfg *conf.Config,
-	queryExec common.SimpleQueryExec, registry protolib.Resolver, failInject failinject.Injector) *Engine {
+	queryExec common.SimpleQueryExec, registry protolib.Resolver, schemaRegistry schemaregistry.Registry,
+	failInject failinject.Injector) *Engine {
 	maxRowCacheSize, err := strconv.ParseInt(cfg.MaxRowCacheSize, 10, 64)
 	if err != nil {
 		panic("invalid maxRowCacheSize") // OK, we validate this on startup anyway
@@ -88,9 +93,11 @@ func NewPushEngine(cluster cluster.Cluster, sharder *sharder.Sharder, meta *meta
 		cfg:             cfg,
 		queryExec:       queryExec,
 		protoRegistry:   registry,
+		schemaRegistry:  schemaRegistry,
 		failInject:      failInject,
 		maxRowCacheSize: maxRowCacheSize,
 	}
+	engine.sourceStatus = newSourceStatusReporter(cluster, cluster.GetNodeID(), engine.getSources)
 	engine.clearState()
 	return engine
 }
@@ -108,6 +115,7 @@ func (p *Engine) Start() error {
 		p.reapers[shardID] = reaper
 		reaper.Start()
 	}
+	p.sourceStatus.start()
 	p.started = true
 	return nil
 }
@@ -150,6 +158,8 @@ func (p *Engine) getSources() []*source.Source {
 }
 
 func (p *Engine) Stop() error {
+	// The reporter gets the sources with the lock, so it's stopped outside it
+	p.sourceStatus.stop()
 	sources, ok := p.stop()
 	if !ok {
 		return nil
@@ -206,6 +216,18 @@ func (p *Engine) GetSource(sourceID uint64) (*source.Source, error) {
 }
 
 func (p *Engine) RemoveSource(sourceInfo *common.SourceInfo) (*source.Source, error) {
+	src, err := p.removeSource(sourceInfo)
+	if err != nil {
+		return nil, err
+	}
+	// The reporter gets the sources with the lock, so the report is removed outside it
+	if err := p.sourceStatus.remove(sourceInfo.ID); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return src, nil
+}
+
+func (p *Engine) removeSource(sourceInfo *common.SourceInfo) (*source.Source, error) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
@@ -263,10 +285,15 @@ func (p *Engine) GetMaterializedView(mvID uint64) (*MaterializedView, error) {
 func (p *Engine) RemoveMV(mvID uint64) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	_, ok := p.materializedViews[mvID]
+	mv, ok := p.materializedViews[mvID]
 	if !ok {
 		return errors.Errorf("cannot find materialized view with id %d", mvID)
 	}
+	if mv.Info.VersionRetentionDuration != 0 {
+		for _, rpr := range p.reapers {
+			rpr.RemoveTable(mv.Info.TableInfo)
+		}
+	}
 	delete(p.materializedViews, mvID)
 	return nil
 }
@@ -275,6 +302,11 @@ func (p *Engine) RegisterMV(mv *MaterializedView) error {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	p.materializedViews[mv.Info.TableInfo.ID] = mv
+	if mv.Info.VersionRetentionDuration != 0 {
+		for _, rpr := range p.reapers {
+			rpr.AddVersionedTable(mv.Info.TableInfo)
+		}
+	}
 	return nil
 }
 
@@ -318,7 +350,10 @@ func (p *Engine) CreateIndex(indexInfo *common.IndexInfo, fill bool, shardIDs []
 	}
 
 	// Create an index executor
-	indexExec := exec.NewIndexExecutor(te.TableInfo, indexInfo, p.cluster)
+	indexExec, err := p.createIndexExecutor(te.TableInfo, indexInfo)
+	if err != nil {
+		return err
+	}
 
 	consumerName := fmt.Sprintf("%s.%s", te.TableInfo.Name, indexInfo.Name)
 	if fill {
@@ -343,6 +378,34 @@ func (p *Engine) CreateIndex(indexInfo *common.IndexInfo, fill bool, shardIDs []
 	return nil
 }
 
+func (p *Engine) createIndexExecutor(tableInfo *common.TableInfo, indexInfo *common.IndexInfo) (*exec.IndexExecutor, error) {
+	if !indexInfo.HasIndexExprs() && indexInfo.Predicate == "" {
+		return exec.NewIndexExecutor(tableInfo, indexInfo, p.cluster), nil
+	}
+	// The expressions of a functional or partial index are compiled from their SQL
+	schema, ok := p.meta.GetSchema(indexInfo.SchemaName)
+	if !ok {
+		return nil, errors.NewUnknownTableError(indexInfo.SchemaName, indexInfo.TableName)
+	}
+	pl := parplan.NewPlanner(schema)
+	keyExprs, _, predicate, err := pl.BuildIndexExpressions(indexInfo.TableName, indexInfo.IndexExprs, indexInfo.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	if indexInfo.HasIndexExprs() {
+		// Plain columns in the key are evaluated as column expressions
+		for i, keyExpr := range keyExprs {
+			if keyExpr == nil {
+				colIndex := indexInfo.IndexCols[i]
+				keyExprs[i] = common.NewColumnExpression(colIndex, tableInfo.ColumnTypes[colIndex])
+			}
+		}
+	} else {
+		keyExprs = nil
+	}
+	return exec.NewIndexExecutorWithExprs(tableInfo, indexInfo, keyExprs, predicate, p.cluster), nil
+}
+
 func (p *Engine) UnattachIndex(indexInfo *common.IndexInfo) error {
 	te, err := p.getTableExecutorForIndex(indexInfo)
 	if err != nil {
@@ -357,6 +420,11 @@ func (p *Engine) RemoveIndex(indexInfo *common.IndexInfo) error {
 	if err := p.UnattachIndex(indexInfo); err != nil {
 		return err
 	}
+	return p.DeleteIndexData(indexInfo)
+}
+
+// DeleteIndexData deletes the data of an index which has already been unattached
+func (p *Engine) DeleteIndexData(indexInfo *common.IndexInfo) error {
 	p.cluster.TableDropped(indexInfo.ID)
 	// Delete the index data
 	tableStartPrefix := common.AppendUint64ToBufferBE(nil, indexInfo.ID)
@@ -731,69 +799,17 @@ func (p *Engine) CreateSource(sourceInfo *common.SourceInfo) (*source.Source, er
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
-	ingestFilter := sourceInfo.OriginInfo.IngestFilter
-	var ingestExpressions []*common.Expression
-	if ingestFilter != "" {
-		// To create the ingest filter we create a fake table in the meta-store with the same columns as the real source
-		// then we create a push query plan from that for a query formed from the ingest filter.
-		// We then extract the filter expressions from the select in that physical plan.
-		// The filter expressions are then executed against the row when it's ingested.
-		tmpID, err := p.cluster.GenerateClusterSequence("table")
-		if err != nil {
-			return nil, err
-		}
-		schemaName := fmt.Sprintf("tmp_schema_%d", tmpID)
-		schema := p.meta.GetOrCreateSchema(schemaName)
-		defer func() {
-			p.meta.DeleteSchemaIfEmpty(schema)
-		}()
-		tabName := fmt.Sprintf("tmp_source_filter_%d", tmpID)
-		tabInfo := common.NewTableInfo(
-			tmpID,
-			schemaName,
-			tabName,
-			sourceInfo.PrimaryKeyCols,
-			sourceInfo.ColumnNames,
-			sourceInfo.ColumnTypes,
-			0,
-			0,
-		)
-		tmpSourceInfo := &common.SourceInfo{
-			TableInfo:  tabInfo,
-			OriginInfo: nil,
-		}
-		if err := p.meta.RegisterSource(tmpSourceInfo); err != nil {
-			return nil, err
-		}
-		defer func() {
-			// Make sure we unregister the tmp source
-			if err := p.meta.UnregisterSource(schemaName, tabName); err != nil {
-				log.Errorf("failed to unregister tmp source %v", err)
-			}
-		}()
-		pl := parplan.NewPlanner(schema)
-		query := fmt.Sprintf("select * from %s where %s", tabName, ingestFilter)
-		phys, _, _, err := pl.QueryToPlan(query, false, false)
-		var sel *planner.PhysicalSelection
-		if err == nil {
-			var ok bool
-			sel, ok = phys.(*planner.PhysicalSelection)
-			if !ok {
-				log.Errorf(" ingest filter %s on %s.%s gave invalid physical plan %v", ingestFilter,
-					sourceInfo.SchemaName, sourceInfo.Name, phys)
-			}
-		}
-		if err != nil || sel == nil {
-			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "invalid ingest filter \"%s\"", ingestFilter)
-		}
-		ingestExpressions = make([]*common.Expression, len(sel.Conditions))
-		for i, expr := range sel.Conditions {
-			ingestExpressions[i] = common.NewExpression(expr, sel.SCtx())
-		}
+	ingestExpressions, err := p.buildIngestExpressions(sourceInfo)
+	if err != nil {
+		return nil, err
+	}
+	computedExpressions, err := p.buildComputedExpressions(sourceInfo)
+	if err != nil {
+		return nil, err
 	}
 
 	tableExecutor := exec.NewTableExecutor(sourceInfo.TableInfo, p.cluster, sourceInfo.OriginInfo.Transient,
-		sourceInfo.RetentionDuration, false)
+		sourceInfo.RetentionDuration, false, sourceInfo.OriginInfo.ValueEncoding.Encoding.IsChangeEvent())
 
 	var rowTimeIndexName string
 	if sourceInfo.RetentionDuration != 0 {
@@ -830,11 +846,13 @@ func (p *Engine) CreateSource(sourceInfo *common.SourceInfo) (*source.Source, er
 		sourceInfo,
 		tableExecutor,
 		ingestExpressions,
+		computedExpressions,
 		p.sharder,
 		p.cluster,
 		p.cfg,
 		p.queryExec,
 		p.protoRegistry,
+		p.schemaRegistry,
 		rowTimeIndexName,
 	)
 	if err != nil {
@@ -850,15 +868,166 @@ func (p *Engine) CreateSource(sourceInfo *common.SourceInfo) (*source.Source, er
 	}
 	p.remoteConsumers.Store(sourceInfo.TableInfo.ID, rc)
 	p.sources[sourceInfo.TableInfo.ID] = src
+	src.SetStatusListener(func() {
+		if err := p.sourceStatus.reportSource(src); err != nil {
+			log.Warnf("failed to report status of source %s.%s %+v", sourceInfo.SchemaName, sourceInfo.Name, err)
+		}
+	})
 	if sourceInfo.RetentionDuration != 0 {
 		for _, rpr := range p.reapers {
 			rpr.AddTable(sourceInfo.TableInfo)
 		}
 	}
+	if sourceInfo.VersionRetentionDuration != 0 {
+		for _, rpr := range p.reapers {
+			rpr.AddVersionedTable(sourceInfo.TableInfo)
+		}
+	}
 
 	return src, nil
 }
 
+// buildIngestExpressions compiles the ingest filter of a source, if it has one, into the expressions which ingested
+// rows must match
+func (p *Engine) buildIngestExpressions(sourceInfo *common.SourceInfo) ([]*common.Expression, error) {
+	ingestFilter := sourceInfo.OriginInfo.IngestFilter
+	if ingestFilter == "" {
+		return nil, nil
+	}
+	// To create the ingest filter we create a fake table in the meta-store with the same columns as the real source
+	// then we create a push query plan from that for a query formed from the ingest filter.
+	// We then extract the filter expressions from the select in that physical plan.
+	// The filter expressions are then executed against the row when it's ingested.
+	var ingestExpressions []*common.Expression
+	err := p.withTmpSource(sourceInfo.ColumnNames, sourceInfo.ColumnTypes, sourceInfo.PrimaryKeyCols,
+		func(pl *parplan.Planner, schemaName string, tabName string) error {
+			query := fmt.Sprintf("select * from %s where %s", tabName, ingestFilter)
+			phys, _, _, err := pl.QueryToPlan(query, false, false)
+			var sel *planner.PhysicalSelection
+			if err == nil {
+				var ok bool
+				sel, ok = phys.(*planner.PhysicalSelection)
+				if !ok {
+					log.Errorf(" ingest filter %s on %s.%s gave invalid physical plan %v", ingestFilter,
+						sourceInfo.SchemaName, sourceInfo.Name, phys)
+				}
+			}
+			if err != nil || sel == nil {
+				return errors.NewPranaErrorf(errors.InvalidStatement, "invalid ingest filter \"%s\"", ingestFilter)
+			}
+			ingestExpressions = make([]*common.Expression, len(sel.Conditions))
+			for i, expr := range sel.Conditions {
+				ingestExpressions[i] = common.NewExpression(expr, sel.SCtx())
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return ingestExpressions, nil
+}
+
+// buildComputedExpressions compiles the expressions which evaluate each column of a source from the columns selected
+// from a message, if the source has computed columns. Computed columns are evaluated from the other columns, cast to
+// the type of the column, and the other columns are copied from the selected columns.
+func (p *Engine) buildComputedExpressions(sourceInfo *common.SourceInfo) ([]*common.Expression, error) {
+	origin := sourceInfo.OriginInfo
+	if origin.ComputedColumns == nil {
+		return nil, nil
+	}
+	// Computed columns can only be computed from the selected columns, so they're the only columns of the table the
+	// expressions are compiled against. The generated key of an append only source has no expression.
+	var colNames []string
+	var colTypes []common.ColumnType
+	colExprs := make([]*common.Expression, len(sourceInfo.ColumnNames))
+	for i, colName := range sourceInfo.ColumnNames {
+		if !origin.IsComputed(i) && !sourceInfo.IsAppendKey(i) {
+			colExprs[i] = common.NewColumnExpression(len(colNames), sourceInfo.ColumnTypes[i])
+			colNames = append(colNames, colName)
+			colTypes = append(colTypes, sourceInfo.ColumnTypes[i])
+		}
+	}
+	err := p.withTmpSource(colNames, colTypes, []int{0}, func(pl *parplan.Planner, schemaName string, tabName string) error {
+		for i, expr := range origin.ComputedColumns {
+			if expr == "" {
+				continue
+			}
+			castExpr := fmt.Sprintf("cast((%s) as %s)", expr, castType(sourceInfo.ColumnTypes[i]))
+			exprs, _, _, err := pl.BuildIndexExpressions(tabName, []string{castExpr}, "")
+			if err != nil {
+				// The error shouldn't mention the temporary schema
+				msg := strings.ReplaceAll(errorMessage(err), schemaName+".", "")
+				return errors.NewPranaErrorf(errors.InvalidStatement, "Invalid expression %s for computed column %s: %s",
+					expr, sourceInfo.ColumnNames[i], msg)
+			}
+			colExprs[i] = exprs[0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return colExprs, nil
+}
+
+// castType returns the type which values are cast to in SQL to be stored in a column of the given type
+func castType(colType common.ColumnType) string {
+	switch colType.Type {
+	case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
+		return "signed"
+	case common.TypeDouble:
+		return "double"
+	case common.TypeDecimal:
+		return fmt.Sprintf("decimal(%d, %d)", colType.DecPrecision, colType.DecScale)
+	case common.TypeVarchar:
+		return "char"
+	case common.TypeTimestamp:
+		return fmt.Sprintf("datetime(%d)", colType.FSP)
+	default:
+		panic(fmt.Sprintf("unexpected column type %d", colType.Type))
+	}
+}
+
+// withTmpSource registers a temporary source with the given columns in a schema of its own, so expressions over the
+// columns can be planned, and calls fn with a planner for the schema, and the names of the schema and the source
+func (p *Engine) withTmpSource(colNames []string, colTypes []common.ColumnType, pkCols []int,
+	fn func(pl *parplan.Planner, schemaName string, tabName string) error) error {
+	tmpID, err := p.cluster.GenerateClusterSequence("table")
+	if err != nil {
+		return err
+	}
+	schemaName := fmt.Sprintf("tmp_schema_%d", tmpID)
+	schema := p.meta.GetOrCreateSchema(schemaName)
+	defer func() {
+		p.meta.DeleteSchemaIfEmpty(schema)
+	}()
+	tabName := fmt.Sprintf("tmp_source_filter_%d", tmpID)
+	tabInfo := common.NewTableInfo(
+		tmpID,
+		schemaName,
+		tabName,
+		pkCols,
+		colNames,
+		colTypes,
+		0,
+		0,
+	)
+	tmpSourceInfo := &common.SourceInfo{
+		TableInfo:  tabInfo,
+		OriginInfo: nil,
+	}
+	if err := p.meta.RegisterSource(tmpSourceInfo); err != nil {
+		return err
+	}
+	defer func() {
+		// Make sure we unregister the tmp source
+		if err := p.meta.UnregisterSource(schemaName, tabName); err != nil {
+			log.Errorf("failed to unregister tmp source %v", err)
+		}
+	}()
+	return fn(parplan.NewPlanner(schema), schemaName, tabName)
+}
+
 func (p *Engine) LoadInitialStateForTable(shardIDs []uint64, initTableID uint64, targetTableID uint64,
 	inter *interruptor.Interruptor) error {
 	log.Debugf("loading initial state for table %d from %d", targetTableID, initTableID)
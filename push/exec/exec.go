@@ -35,6 +35,10 @@ type ExecutionContext struct {
 	RemoteBatches map[uint64]*cluster.WriteBatch
 	FillTableID   int64
 	RowCache      sched.RowCache
+	// versionedKeys holds the keys of rows whose prior version has been stored in this batch
+	versionedKeys map[string]struct{}
+	// versionTimes holds the time at which the prior versions of rows were replaced in this batch, by table id
+	versionTimes map[uint64]int64
 }
 
 func (e *ExecutionContext) AddToForwardBatch(shardID uint64, key []byte, value []byte) {
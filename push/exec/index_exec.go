@@ -14,17 +14,37 @@ type IndexExecutor struct {
 	IndexInfo *common.IndexInfo
 	TableInfo *common.TableInfo // The table info of the table (source or MV) that we are creating the index on
 	store     cluster.Cluster
+	// keyExprs evaluates each column of the key of a functional index
+	keyExprs       []*common.Expression
+	keyColTypes    []common.ColumnType
+	keyRowsFactory *common.RowsFactory
+	predicate      []*common.Expression // Only rows which match the predicate are indexed in a partial index
 }
 
 func NewIndexExecutor(tableInfo *common.TableInfo, indexInfo *common.IndexInfo, store cluster.Cluster) *IndexExecutor {
-	return &IndexExecutor{
+	return NewIndexExecutorWithExprs(tableInfo, indexInfo, nil, nil, store)
+}
+
+// NewIndexExecutorWithExprs creates an executor which maintains a functional or partial index. keyExprs evaluates
+// each column of the index key from a row of the table, and is nil if the index has no expressions in its key. Only
+// rows for which all the predicate expressions are true are indexed.
+func NewIndexExecutorWithExprs(tableInfo *common.TableInfo, indexInfo *common.IndexInfo, keyExprs []*common.Expression,
+	predicate []*common.Expression, store cluster.Cluster) *IndexExecutor {
+	ie := &IndexExecutor{
 		pushExecutorBase: pushExecutorBase{
 			rowsFactory: common.NewRowsFactory(tableInfo.ColumnTypes),
 		},
 		TableInfo: tableInfo,
 		IndexInfo: indexInfo,
 		store:     store,
+		keyExprs:  keyExprs,
+		predicate: predicate,
 	}
+	if keyExprs != nil {
+		ie.keyColTypes = indexInfo.KeyColTypes(tableInfo.ColumnTypes)
+		ie.keyRowsFactory = common.NewRowsFactory(ie.keyColTypes)
+	}
+	return ie
 }
 
 func (t *IndexExecutor) ReCalcSchemaFromChildren() error {
@@ -34,12 +54,17 @@ func (t *IndexExecutor) ReCalcSchemaFromChildren() error {
 func (t *IndexExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) error {
 	numEntries := rowsBatch.Len()
 	for i := 0; i < numEntries; i++ {
-		prevRow := rowsBatch.PreviousRow(i)
-		currentRow := rowsBatch.CurrentRow(i)
-		var err error
+		prevRow, err := t.filterRow(rowsBatch.PreviousRow(i))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		currentRow, err := t.filterRow(rowsBatch.CurrentRow(i))
+		if err != nil {
+			return errors.WithStack(err)
+		}
 		var prevKey []byte
 		if prevRow != nil {
-			prevKey, _, err = table.EncodeIndexKeyValue(t.TableInfo, t.IndexInfo, ctx.WriteBatch.ShardID, prevRow)
+			prevKey, _, err = t.encodeKeyValue(ctx.WriteBatch.ShardID, prevRow)
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -47,7 +72,7 @@ func (t *IndexExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 		var currKey []byte
 		var currValue []byte
 		if currentRow != nil {
-			currKey, currValue, err = table.EncodeIndexKeyValue(t.TableInfo, t.IndexInfo, ctx.WriteBatch.ShardID, currentRow)
+			currKey, currValue, err = t.encodeKeyValue(ctx.WriteBatch.ShardID, currentRow)
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -57,6 +82,10 @@ func (t *IndexExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 			// can occur in same ms so key doesn't change, if we don't do nothing then we add a put and a delete for the
 			// same key. But deletes always get processed in the state machine after puts for a batch which will result
 			// in the index entry getting deleted
+			if currentRow != nil && len(t.IndexInfo.IncludeCols) > 0 {
+				// Included cols are stored in the value, which may have changed even though the key hasn't
+				ctx.WriteBatch.AddPut(currKey, currValue)
+			}
 			continue
 		}
 
@@ -71,3 +100,95 @@ func (t *IndexExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 	}
 	return nil
 }
+
+// filterRow returns nil if the row is not indexed because it doesn't match the predicate of a partial index. Rows
+// which move in or out of a partial index are then handled just like inserts and deletes.
+func (t *IndexExecutor) filterRow(row *common.Row) (*common.Row, error) {
+	if row == nil {
+		return nil, nil
+	}
+	for _, predicate := range t.predicate {
+		accept, isNull, err := predicate.EvalBoolean(row)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if isNull || !accept {
+			return nil, nil
+		}
+	}
+	return row, nil
+}
+
+func (t *IndexExecutor) encodeKeyValue(shardID uint64, row *common.Row) ([]byte, []byte, error) {
+	if t.keyExprs == nil {
+		return table.EncodeIndexKeyValue(t.TableInfo, t.IndexInfo, shardID, row)
+	}
+	keyRows := t.keyRowsFactory.NewRows(1)
+	for i, keyExpr := range t.keyExprs {
+		if err := AppendEvaluated(keyExpr, t.keyColTypes[i], row, keyRows, i); err != nil {
+			return nil, nil, err
+		}
+	}
+	keyRow := keyRows.GetRow(0)
+	return table.EncodeFunctionalIndexKeyValue(t.TableInfo, t.IndexInfo, shardID, row, &keyRow)
+}
+
+// AppendEvaluated evaluates the expression against the row and appends the value to the column of result, converting it
+// to the type of the column
+func AppendEvaluated(expr *common.Expression, colType common.ColumnType, row *common.Row, result *common.Rows, colIndex int) error {
+	switch colType.Type {
+	case common.TypeTinyInt, common.TypeInt, common.TypeBigInt:
+		val, null, err := expr.EvalInt64(row)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if null {
+			result.AppendNullToColumn(colIndex)
+		} else {
+			result.AppendInt64ToColumn(colIndex, val)
+		}
+	case common.TypeDecimal:
+		val, null, err := expr.EvalDecimal(row)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if null {
+			result.AppendNullToColumn(colIndex)
+		} else {
+			result.AppendDecimalToColumn(colIndex, val)
+		}
+	case common.TypeVarchar:
+		val, null, err := expr.EvalString(row)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if null {
+			result.AppendNullToColumn(colIndex)
+		} else {
+			result.AppendStringToColumn(colIndex, val)
+		}
+	case common.TypeDouble:
+		val, null, err := expr.EvalFloat64(row)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if null {
+			result.AppendNullToColumn(colIndex)
+		} else {
+			result.AppendFloat64ToColumn(colIndex, val)
+		}
+	case common.TypeTimestamp:
+		val, null, err := expr.EvalTimestamp(row)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if null {
+			result.AppendNullToColumn(colIndex)
+		} else {
+			result.AppendTimestampToColumn(colIndex, val)
+		}
+	default:
+		return errors.Errorf("unexpected column type %d", colType.Type)
+	}
+	return nil
+}
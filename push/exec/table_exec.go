@@ -2,6 +2,7 @@ package exec
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -39,12 +40,17 @@ type TableExecutor struct {
 	delayer           interruptor.InterruptManager
 	transient         bool
 	storeTombstones   bool
+	changeEvents      bool
 	retentionDuration time.Duration
 	rowsFilledCounter prometheus.Counter
+	lastVersionTime   int64
 }
 
+// NewTableExecutor creates a table executor. storeTombstones is true if deleted rows are replaced with a tombstone
+// rather than deleted, and changeEvents is true if the rows of the table are upserted and deleted by change events,
+// whose deletes may be for rows the table doesn't have and only have the key of the deleted row.
 func NewTableExecutor(tableInfo *common.TableInfo, store cluster.Cluster, transient bool, retentionDuration time.Duration,
-	storeTombstones bool) *TableExecutor {
+	storeTombstones bool, changeEvents bool) *TableExecutor {
 	rowsFilledCounter := rowsFilledVec.WithLabelValues(tableInfo.Name)
 	return &TableExecutor{
 		pushExecutorBase: pushExecutorBase{
@@ -62,9 +68,23 @@ func NewTableExecutor(tableInfo *common.TableInfo, store cluster.Cluster, transi
 		retentionDuration: retentionDuration,
 		rowsFilledCounter: rowsFilledCounter,
 		storeTombstones:   storeTombstones,
+		changeEvents:      changeEvents,
 	}
 }
 
+// SetTableInfo changes the columns of the table the executor updates, once the table has been altered and its rows
+// migrated to the new columns
+func (t *TableExecutor) SetTableInfo(tableInfo *common.TableInfo) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.TableInfo = tableInfo
+	t.colNames = tableInfo.ColumnNames
+	t.colTypes = tableInfo.ColumnTypes
+	t.keyCols = tableInfo.PrimaryKeyCols
+	t.colsVisible = tableInfo.ColsVisible
+	t.rowsFactory = common.NewRowsFactory(tableInfo.ColumnTypes)
+}
+
 func (t *TableExecutor) IsTransient() bool {
 	return t.transient
 }
@@ -89,6 +109,19 @@ func (t *TableExecutor) RemoveConsumingNode(consumerName string) {
 	delete(t.consumingNodes, consumerName)
 }
 
+// RenameConsumingNode changes the name a consuming node is registered under, replacing any node already registered
+// under the new name. This is done atomically with respect to the processing of rows.
+func (t *TableExecutor) RenameConsumingNode(consumerName string, newConsumerName string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	node, ok := t.consumingNodes[consumerName]
+	if !ok {
+		return
+	}
+	delete(t.consumingNodes, consumerName)
+	t.consumingNodes[newConsumerName] = node
+}
+
 func (t *TableExecutor) HandleRemoteRows(rowsBatch RowsBatch, ctx *ExecutionContext) error {
 	return t.HandleRows(rowsBatch, ctx)
 }
@@ -110,7 +143,24 @@ func (t *TableExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 	requiresPreviousRow := len(t.consumingNodes) > 0 || t.filling
 	if requiresPreviousRow {
 		outRows = t.rowsFactory.NewRows(numEntries)
-		entries = make([]RowsEntry, numEntries)
+		entries = make([]RowsEntry, 0, numEntries)
+	}
+	// The rows written earlier in the batch aren't in the row cache yet, so a later change to the same row in the
+	// batch must see them
+	var written map[string][]byte
+	getRow := func(key []byte) ([]byte, error) {
+		if v, ok := written[string(key)]; ok {
+			return v, nil
+		}
+		return ctx.RowCache.Get(key)
+	}
+	setRow := func(key []byte, value []byte) {
+		if requiresPreviousRow {
+			if written == nil {
+				written = make(map[string][]byte)
+			}
+			written[string(key)] = value
+		}
 	}
 	for i := 0; i < numEntries; i++ {
 		prevRow := rowsBatch.PreviousRow(i)
@@ -118,15 +168,21 @@ func (t *TableExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 
 		if currentRow != nil {
 			keyBuff := table.EncodeTableKeyPrefix(t.TableInfo.ID, ctx.WriteBatch.ShardID, 32)
-			keyBuff, err := common.EncodeKeyCols(currentRow, t.TableInfo.PrimaryKeyCols, t.TableInfo.ColumnTypes, keyBuff)
+			keyBuff, err := common.EncodeOrderedKeyCols(currentRow, t.TableInfo.PrimaryKeyCols, t.TableInfo.PrimaryKeyDesc, t.TableInfo.ColumnTypes, keyBuff)
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			if requiresPreviousRow {
-				v, err := ctx.RowCache.Get(keyBuff)
+			var v []byte
+			if requiresPreviousRow || t.keepsVersions() {
+				v, err = getRow(keyBuff)
 				if err != nil {
 					return errors.WithStack(err)
 				}
+			}
+			if t.keepsVersions() {
+				t.storePriorVersion(keyBuff, v, ctx)
+			}
+			if requiresPreviousRow {
 				pi := -1
 				if v != nil {
 					// Row already exists in storage - this is the case where a new rows comes into a source for the same key
@@ -140,9 +196,7 @@ func (t *TableExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 				outRows.AppendRow(*currentRow)
 				ci := rc
 				rc++
-				entries[i].prevIndex = pi
-				entries[i].currIndex = ci
-				entries[i].receiverIndex = rowsBatch.ReceiverIndex(i)
+				entries = append(entries, NewRowsEntry(pi, ci, rowsBatch.ReceiverIndex(i)))
 			}
 			var valueBuff []byte
 			valueBuff, err = common.EncodeRow(currentRow, t.colTypes, valueBuff)
@@ -150,19 +204,47 @@ func (t *TableExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 				return errors.WithStack(err)
 			}
 			ctx.WriteBatch.AddPut(keyBuff, valueBuff)
+			setRow(keyBuff, valueBuff)
 		} else {
 			// It's a delete
 			keyBuff := table.EncodeTableKeyPrefix(t.TableInfo.ID, ctx.WriteBatch.ShardID, 32)
-			keyBuff, err := common.EncodeKeyCols(prevRow, t.TableInfo.PrimaryKeyCols, t.colTypes, keyBuff)
+			keyBuff, err := common.EncodeOrderedKeyCols(prevRow, t.TableInfo.PrimaryKeyCols, t.TableInfo.PrimaryKeyDesc, t.colTypes, keyBuff)
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			if requiresPreviousRow {
-				outRows.AppendRow(*prevRow)
-				entries[i].prevIndex = rc
-				entries[i].currIndex = -1
-				entries[i].receiverIndex = rowsBatch.ReceiverIndex(i)
-				rc++
+			if t.changeEvents {
+				// A change event can delete a row the source never had, which there's nothing to do for, and the row
+				// it deletes may only have its key, so the previous row is the one which is stored
+				v, err := getRow(keyBuff)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				if v == nil {
+					continue
+				}
+				if t.keepsVersions() {
+					t.storePriorVersion(keyBuff, v, ctx)
+				}
+				if requiresPreviousRow {
+					if err := common.DecodeRow(v, t.colTypes, outRows); err != nil {
+						return errors.WithStack(err)
+					}
+					entries = append(entries, NewRowsEntry(rc, -1, rowsBatch.ReceiverIndex(i)))
+					rc++
+				}
+			} else {
+				if t.keepsVersions() {
+					v, err := getRow(keyBuff)
+					if err != nil {
+						return errors.WithStack(err)
+					}
+					t.storePriorVersion(keyBuff, v, ctx)
+				}
+				if requiresPreviousRow {
+					outRows.AppendRow(*prevRow)
+					entries = append(entries, NewRowsEntry(rc, -1, rowsBatch.ReceiverIndex(i)))
+					rc++
+				}
 			}
 			if t.storeTombstones {
 				// We don't delete the row, we store a tombstone - this is used in a sink where we need to keep track
@@ -171,11 +253,52 @@ func (t *TableExecutor) HandleRows(rowsBatch RowsBatch, ctx *ExecutionContext) e
 			} else {
 				ctx.WriteBatch.AddDelete(keyBuff)
 			}
+			setRow(keyBuff, nil)
 		}
 	}
 	return t.handleForwardAndCapture(NewRowsBatch(outRows, entries), ctx)
 }
 
+func (t *TableExecutor) keepsVersions() bool {
+	return t.TableInfo.VersionRetentionDuration != 0
+}
+
+// storePriorVersion stores the version of the row with the given key which was current before this batch, so the table
+// can be queried as of a time in the past. The rows in a batch are committed atomically so only the first change to a
+// row in a batch is recorded.
+func (t *TableExecutor) storePriorVersion(key []byte, prevValue []byte, ctx *ExecutionContext) {
+	if ctx.versionedKeys == nil {
+		ctx.versionedKeys = make(map[string]struct{})
+		ctx.versionTimes = make(map[uint64]int64)
+	}
+	sKey := string(key)
+	if _, ok := ctx.versionedKeys[sKey]; ok {
+		return
+	}
+	ctx.versionedKeys[sKey] = struct{}{}
+	versionTime, ok := ctx.versionTimes[t.TableInfo.ID]
+	if !ok {
+		versionTime = t.nextVersionTime()
+		ctx.versionTimes[t.TableInfo.ID] = versionTime
+	}
+	table.AddVersion(ctx.WriteBatch, t.TableInfo.VersionTableID, ctx.WriteBatch.ShardID, key[16:], versionTime, prevValue)
+}
+
+// nextVersionTime returns the current time in unix micros, making sure it's always greater than the last time returned
+// so versions of a row from different batches never have the same key
+func (t *TableExecutor) nextVersionTime() int64 {
+	for {
+		last := atomic.LoadInt64(&t.lastVersionTime)
+		now := time.Now().UnixMicro()
+		if now <= last {
+			now = last + 1
+		}
+		if atomic.CompareAndSwapInt64(&t.lastVersionTime, last, now) {
+			return now
+		}
+	}
+}
+
 func (t *TableExecutor) handleForwardAndCapture(rowsBatch RowsBatch, ctx *ExecutionContext) error {
 	if err := t.ForwardToConsumingNodes(rowsBatch, ctx); err != nil {
 		return errors.WithStack(err)
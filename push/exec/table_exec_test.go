@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"testing"
+
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/cluster/fake"
+	"github.com/squareup/pranadb/common"
+	"github.com/stretchr/testify/require"
+)
+
+var tableExecColTypes = []common.ColumnType{common.BigIntColumnType, common.VarcharColumnType}
+
+func TestTableExecutorDeletes(t *testing.T) {
+	tests := []struct {
+		name            string
+		storeTombstones bool
+		changeEvents    bool
+		// The rows forwarded by deleting a stored row and a missing row, and whether the missing row is deleted
+		expectedForwarded [][]interface{}
+		deletesMissing    bool
+	}{
+		{
+			name:              "table",
+			expectedForwarded: [][]interface{}{{1, nil}, {2, nil}},
+			deletesMissing:    true,
+		},
+		{
+			name:              "tombstones",
+			storeTombstones:   true,
+			expectedForwarded: [][]interface{}{{1, nil}, {2, nil}},
+			deletesMissing:    true,
+		},
+		{
+			// A change event can delete a row which was never stored, and only has the key of the row it deletes
+			name:              "change events",
+			changeEvents:      true,
+			expectedForwarded: [][]interface{}{{1, "foo"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tableInfo := common.NewTableInfo(1000, "test", "table1", []int{0}, []string{"id", "v"}, tableExecColTypes, 0, 0)
+			te := NewTableExecutor(tableInfo, fake.NewFakeCluster(0, 1), false, 0, test.storeTombstones, test.changeEvents)
+			gatherer := &rowGatherer{}
+			te.AddConsumingNode("consumer", gatherer)
+			rowCache := newTestRowCache()
+
+			ctx := NewExecutionContext(cluster.NewWriteBatch(1000), rowCache, -1)
+			rows := toRows(t, [][]interface{}{{1, "foo"}}, tableExecColTypes)
+			require.NoError(t, te.HandleRows(NewRowsBatch(rows, []RowsEntry{NewRowsEntry(-1, 0, 0)}), ctx))
+			require.NoError(t, ctx.WriteBatch.ForEachPut(func(k []byte, v []byte) error {
+				rowCache.Put(k, v)
+				return nil
+			}))
+
+			// Delete the stored row, and a row which isn't stored
+			ctx = NewExecutionContext(cluster.NewWriteBatch(1000), rowCache, -1)
+			rows = toRows(t, [][]interface{}{{1, nil}, {2, nil}}, tableExecColTypes)
+			batch := NewRowsBatch(rows, []RowsEntry{NewRowsEntry(0, -1, 0), NewRowsEntry(1, -1, 1)})
+			require.NoError(t, te.HandleRows(batch, ctx))
+
+			expectedWrites := 1
+			if test.deletesMissing {
+				expectedWrites = 2
+			}
+			if test.storeTombstones {
+				require.Equal(t, expectedWrites, ctx.WriteBatch.NumPuts)
+				require.Equal(t, 0, ctx.WriteBatch.NumDeletes)
+				require.NoError(t, ctx.WriteBatch.ForEachPut(func(k []byte, v []byte) error {
+					require.Equal(t, 0, len(v))
+					return nil
+				}))
+			} else {
+				require.Equal(t, 0, ctx.WriteBatch.NumPuts)
+				require.Equal(t, expectedWrites, ctx.WriteBatch.NumDeletes)
+			}
+			expected := toRows(t, test.expectedForwarded, tableExecColTypes)
+			require.Equal(t, expected.RowCount(), gatherer.Rows.RowCount())
+			for i := 0; i < expected.RowCount(); i++ {
+				expectedRow := expected.GetRow(i)
+				row := gatherer.Rows.GetRow(i)
+				require.Equal(t, expectedRow.String(), row.String())
+			}
+		})
+	}
+}
+
+type testRowCache struct {
+	rows map[string][]byte
+}
+
+func newTestRowCache() *testRowCache {
+	return &testRowCache{rows: make(map[string][]byte)}
+}
+
+func (c *testRowCache) Get(key []byte) ([]byte, error) {
+	return c.rows[string(key)], nil
+}
+
+func (c *testRowCache) Put(key []byte, value []byte) {
+	c.rows[string(key)] = value
+}
+
+func (c *testRowCache) Delete(key []byte) {
+	delete(c.rows, string(key))
+}
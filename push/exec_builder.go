@@ -148,6 +148,9 @@ func (p *Engine) buildPushDAG(plan planner.PhysicalPlan, aggSequence int, schema
 			return nil, nil, errors.WithStack(err)
 		}
 	case *planner.PhysicalTableScan:
+		if op.AsOf != nil {
+			return nil, nil, errors.NewPranaErrorf(errors.InvalidStatement, "AS OF can only be used in pull queries")
+		}
 		tableName := op.Table.Name
 		var scanCols []int
 		for _, col := range op.Columns {
@@ -158,15 +161,15 @@ func (p *Engine) buildPushDAG(plan planner.PhysicalPlan, aggSequence int, schema
 			return nil, nil, errors.WithStack(err)
 		}
 	case *planner.PhysicalIndexScan:
-		// If we create an MV that only selects on index fields the TiDB planner will give us an index reader.
-		// As this is a push query we won't use an index but we'll use a push Scan specifying which columns we want
+		// If we create an MV on a table with an index the TiDB planner may give us an index reader.
+		// As this is a push query we won't use an index but we'll use a push Scan of the columns the index reader
+		// would have output
 		tableName := op.Table.Name
-		table, ok := schema.GetTable(tableName.L)
-		if !ok {
-			return nil, nil, errors.Errorf("cannot find table %s", tableName.L)
+		var scanCols []int
+		for _, col := range op.Columns {
+			scanCols = append(scanCols, col.Offset)
 		}
-		info := table.GetTableInfo()
-		executor, err = exec.NewScan(tableName.L, info.PrimaryKeyCols)
+		executor, err = exec.NewScan(tableName.L, scanCols)
 		if err != nil {
 			return nil, nil, errors.WithStack(err)
 		}
@@ -2,6 +2,7 @@ package push
 
 import (
 	"reflect"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/squareup/pranadb/cluster"
@@ -22,11 +23,15 @@ type MaterializedView struct {
 	cluster        cluster.Cluster
 	InternalTables []*common.InternalTableInfo
 	sharder        *sharder.Sharder
+	// consumerName is the name the materialized view consumes from its feeders under. It's the name of the
+	// materialized view, apart from while it's being built to replace another
+	consumerName string
 }
 
 // CreateMaterializedView creates the materialized view but does not register it in memory
 func CreateMaterializedView(pe *Engine, pl *parplan.Planner, schema *common.Schema, mvName string, query string,
-	initTable string, tableID uint64, seqGenerator common.SeqGenerator) (*MaterializedView, error) {
+	initTable string, tableID uint64, versionRetention time.Duration, versionTableID uint64,
+	seqGenerator common.SeqGenerator) (*MaterializedView, error) {
 
 	mv := MaterializedView{
 		pe:      pe,
@@ -49,13 +54,16 @@ func CreateMaterializedView(pe *Engine, pl *parplan.Planner, schema *common.Sche
 		0,
 	)
 	tableInfo.ColsVisible = dag.ColsVisible()
+	tableInfo.VersionRetentionDuration = versionRetention
+	tableInfo.VersionTableID = versionTableID
 	mvInfo := common.MaterializedViewInfo{
 		Query:      query,
 		TableInfo:  tableInfo,
 		OriginInfo: &common.MaterializedViewOriginInfo{InitialState: initTable},
 	}
 	mv.Info = &mvInfo
-	mv.tableExecutor = exec.NewTableExecutor(tableInfo, pe.cluster, false, 0, false)
+	mv.consumerName = mvName
+	mv.tableExecutor = exec.NewTableExecutor(tableInfo, pe.cluster, false, 0, false, false)
 	mv.InternalTables = internalTables
 	exec.ConnectPushExecutors([]exec.PushExecutor{dag}, mv.tableExecutor)
 	return &mv, nil
@@ -77,6 +85,11 @@ func (m *MaterializedView) Drop() error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	if m.Info.VersionRetentionDuration != 0 {
+		if err := m.deleteTableData(m.Info.VersionTableID); err != nil {
+			return errors.WithStack(err)
+		}
+	}
 	return m.deleteTableData(m.Info.ID)
 }
 
@@ -84,30 +97,29 @@ func (m *MaterializedView) disconnectOrDeleteDataForMV(node exec.PushExecutor, d
 
 	switch op := node.(type) {
 	case *exec.Scan:
-		tableName := op.TableName
-		tbl, ok := m.schema.GetTable(tableName)
-		if !ok {
-			return errors.Errorf("unknown source or materialized view %s", tableName)
-		}
-		switch tbl := tbl.(type) {
-		case *common.SourceInfo:
-			if disconnect {
+		// The table scanned may already have been dropped when the data is deleted, so we only look it up to disconnect
+		if disconnect {
+			tableName := op.TableName
+			tbl, ok := m.schema.GetTable(tableName)
+			if !ok {
+				return errors.Errorf("unknown source or materialized view %s", tableName)
+			}
+			switch tbl := tbl.(type) {
+			case *common.SourceInfo:
 				source, err := m.pe.GetSource(tbl.ID)
 				if err != nil {
 					return errors.WithStack(err)
 				}
-				source.RemoveConsumingNode(m.Info.Name)
-			}
-		case *common.MaterializedViewInfo:
-			if disconnect {
+				source.RemoveConsumingNode(m.consumerName)
+			case *common.MaterializedViewInfo:
 				mv, err := m.pe.GetMaterializedView(tbl.ID)
 				if err != nil {
 					return errors.WithStack(err)
 				}
-				mv.removeConsumingExecutor(m.Info.Name)
+				mv.removeConsumingExecutor(m.consumerName)
+			default:
+				return errors.Errorf("cannot disconnect %s: invalid table type", tbl)
 			}
-		default:
-			return errors.Errorf("cannot disconnect %s: invalid table type", tbl)
 		}
 	case *exec.Aggregator:
 		if disconnect {
@@ -173,13 +185,13 @@ func (m *MaterializedView) connect(executor exec.PushExecutor, addConsuming bool
 				if err != nil {
 					return errors.WithStack(err)
 				}
-				source.AddConsumingNode(m.Info.Name, executor)
+				source.AddConsumingNode(m.consumerName, executor)
 			case *common.MaterializedViewInfo:
 				mv, err := m.pe.GetMaterializedView(tbl.ID)
 				if err != nil {
 					return errors.WithStack(err)
 				}
-				mv.addConsumingExecutor(m.Info.Name, executor)
+				mv.addConsumingExecutor(m.consumerName, executor)
 			default:
 				return errors.Errorf("table scan on %s is not supported", reflect.TypeOf(tbl))
 			}
@@ -248,11 +260,11 @@ func (m *MaterializedView) Fill(shardIDs []uint64, interruptor *interruptor.Inte
 			// Execute in parallel
 			te := tableExec
 			go func() {
-				err := te.FillTo(ts, m.Info.Name, m.Info.ID, schedulers, m.pe.failInject, interruptor)
+				err := te.FillTo(ts, m.consumerName, m.Info.ID, schedulers, m.pe.failInject, interruptor)
 				ch <- err
 			}()
 		} else {
-			tableExec.AddConsumingNode(m.Info.Name, ts)
+			tableExec.AddConsumingNode(m.consumerName, ts)
 		}
 	}
 
@@ -304,6 +316,48 @@ func (m *MaterializedView) getFeedingExecutors(ex exec.PushExecutor) ([]*exec.Ta
 	return tes, tss, nil
 }
 
+// IsRunning returns true if all the sources the materialized view is fed from, directly or through other materialized
+// views, are running. If any of them are stopped the materialized view isn't being updated.
+func (m *MaterializedView) IsRunning() (bool, error) {
+	return m.feedersRunning(m.tableExecutor)
+}
+
+func (m *MaterializedView) feedersRunning(ex exec.PushExecutor) (bool, error) {
+	ts, ok := ex.(*exec.Scan)
+	if ok {
+		tbl, ok := m.schema.GetTable(ts.TableName)
+		if !ok {
+			return false, errors.Errorf("unknown source or materialized view %s", ts.TableName)
+		}
+		switch tbl := tbl.(type) {
+		case *common.SourceInfo:
+			source, err := m.pe.GetSource(tbl.ID)
+			if err != nil {
+				return false, errors.WithStack(err)
+			}
+			if !source.IsRunning() {
+				return false, nil
+			}
+		case *common.MaterializedViewInfo:
+			mv, err := m.pe.GetMaterializedView(tbl.ID)
+			if err != nil {
+				return false, errors.WithStack(err)
+			}
+			running, err := mv.IsRunning()
+			if err != nil || !running {
+				return false, err
+			}
+		}
+	}
+	for _, child := range ex.GetChildren() {
+		running, err := m.feedersRunning(child)
+		if err != nil || !running {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 func (m *MaterializedView) TableExecutor() *exec.TableExecutor {
 	return m.tableExecutor
 }
@@ -86,7 +86,25 @@ func (r *Reaper) AddTable(table *common.TableInfo) {
 	r.scheduleRunWithLock(0)
 }
 
+// AddVersionedTable adds a table whose prior versions of rows are removed once they are older than its version
+// retention time
+func (r *Reaper) AddVersionedTable(table *common.TableInfo) {
+	if table.VersionRetentionDuration < time.Second {
+		panic("version retention must be >= 1 second")
+	}
+	r.addVersionedTableNoSchedule(table)
+	r.scheduleRunWithLock(0)
+}
+
 func (r *Reaper) addTableNoSchedule(table *common.TableInfo) {
+	r.addItemNoSchedule(table, false)
+}
+
+func (r *Reaper) addVersionedTableNoSchedule(table *common.TableInfo) {
+	r.addItemNoSchedule(table, true)
+}
+
+func (r *Reaper) addItemNoSchedule(table *common.TableInfo, versions bool) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
 	if !r.started {
@@ -97,6 +115,7 @@ func (r *Reaper) addTableNoSchedule(table *common.TableInfo) {
 	r.tableHeap.push(itemHolder{
 		Table:     table,
 		CheckTime: 0,
+		Versions:  versions,
 	})
 }
 
@@ -143,7 +162,14 @@ func (r *Reaper) doRun() (time.Duration, error) {
 		nowUnixMicros := time.Now().UnixMicro()
 		if oldest != nil && nowUnixMicros-oldest.CheckTime >= 0 {
 			r.tableHeap.pop()
-			nextTime, numDeleted, err := r.processDeletes(r.shardID, oldest.Table, nowUnixMicros, wb, r.maxDeleteBatchSize-rowsDeleted)
+			var nextTime int64
+			var numDeleted int
+			var err error
+			if oldest.Versions {
+				nextTime, numDeleted, err = r.processVersionDeletes(r.shardID, oldest.Table, nowUnixMicros, wb, r.maxDeleteBatchSize-rowsDeleted)
+			} else {
+				nextTime, numDeleted, err = r.processDeletes(r.shardID, oldest.Table, nowUnixMicros, wb, r.maxDeleteBatchSize-rowsDeleted)
+			}
 			if err != nil {
 				return 0, err
 			}
@@ -151,6 +177,7 @@ func (r *Reaper) doRun() (time.Duration, error) {
 			r.tableHeap.push(itemHolder{
 				CheckTime: nextTime,
 				Table:     oldest.Table,
+				Versions:  oldest.Versions,
 			})
 		} else {
 			break
@@ -235,6 +262,16 @@ func (r *Reaper) processDeletes(shardID uint64, tab *common.TableInfo, nowUnixMi
 		pk := pair.Key[25:]
 		pkKey := pkKeyBase
 		pkKey = append(pkKey, pk...)
+		if tab.VersionRetentionDuration != 0 {
+			// Keep the version of the row we're deleting so the table can still be queried as of a time before now
+			prevValue, err := r.store.LocalGet(pkKey)
+			if err != nil {
+				return 0, 0, err
+			}
+			if prevValue != nil {
+				table.AddVersion(wb, tab.VersionTableID, shardID, pk, nowUnixMicros, prevValue)
+			}
+		}
 		wb.AddDelete(pkKey)
 		rowCount++
 		lastKey = pair.Key
@@ -253,3 +290,47 @@ func (r *Reaper) processDeletes(shardID uint64, tab *common.TableInfo, nowUnixMi
 	// There are no more rows for the table so we schedule the next check in retention time from now
 	return nowUnixMicros + retentionMicros, rowCount, nil
 }
+
+// Process deletes of expired versions of rows of the table, returning time when next check for this table will occur
+func (r *Reaper) processVersionDeletes(shardID uint64, tab *common.TableInfo, nowUnixMicros int64, wb *cluster.WriteBatch, maxRows int) (int64, int, error) {
+	keyStart := table.EncodeVersionExpiryPrefix(tab.VersionTableID, shardID)
+	keyEnd := table.EncodeTableKeyPrefix(tab.VersionTableID+1, shardID, 16)
+
+	retentionMicros := tab.VersionRetentionDuration.Microseconds()
+
+	iter := r.store.LocalIterator(keyStart, keyEnd)
+	defer func() {
+		if err := iter.Close(); err != nil {
+			log.Errorf("failed to close local iterator %v", err)
+		}
+	}()
+	rowCount := 0
+	var lastKey []byte
+	for iter.HasNext() && rowCount < maxRows {
+		pair := iter.Next()
+
+		// The expiry key is shard_id|version_table_id|1|end_time|pk, so versions are in order of the time they were
+		// replaced
+		endTime, pk := table.DecodeVersionExpiryKey(pair.Key)
+		if nowUnixMicros-endTime < retentionMicros {
+			if lastKey != nil {
+				wb.AddDeleteRange(keyStart, common.IncrementBytesBigEndian(lastKey))
+			}
+			// Not ready to delete
+			return endTime + retentionMicros, rowCount, nil
+		}
+		wb.AddDelete(table.EncodeVersionKey(tab.VersionTableID, shardID, pk, endTime))
+		rowCount++
+		lastKey = pair.Key
+	}
+	if lastKey != nil {
+		wb.AddDeleteRange(keyStart, common.IncrementBytesBigEndian(lastKey))
+	}
+
+	if rowCount == maxRows {
+		return 0, rowCount, nil
+	}
+
+	// There are no more versions for the table so we schedule the next check in retention time from now
+	return nowUnixMicros + retentionMicros, rowCount, nil
+}
@@ -417,6 +417,14 @@ func getTableRows(t *testing.T, tableID uint64, store cluster.Cluster) []cluster
 	return tableRows
 }
 
+func getVersionEntries(t *testing.T, versionTableID uint64, store cluster.Cluster) []cluster.KVPair {
+	t.Helper()
+	versions, err := store.LocalScan(table.EncodeVersionKeyPrefix(versionTableID, shardID, nil),
+		table.EncodeVersionEntriesEnd(versionTableID, shardID), -1)
+	require.NoError(t, err)
+	return versions
+}
+
 func getIndexRows(t *testing.T, indexID uint64, store cluster.Cluster) []cluster.KVPair {
 	t.Helper()
 	indexScanPrefixStart := table.EncodeTableKeyPrefix(indexID, shardID, 16)
@@ -472,7 +480,46 @@ func setupTableExecutor(cluster cluster.Cluster, tabInfo *common.TableInfo, rete
 		IndexCols:  []int{1},
 	}
 	indexExecutor := exec.NewIndexExecutor(tabInfo, indexInfo, cluster)
-	te := exec.NewTableExecutor(tabInfo, cluster, false, retentionDuration, false)
+	te := exec.NewTableExecutor(tabInfo, cluster, false, retentionDuration, false, false)
 	te.AddConsumingNode(lastUpdateIndexName, indexExecutor)
 	return te
 }
+
+func TestVersions(t *testing.T) {
+
+	versionRetentionDuration := 1 * time.Second
+	store := fake.NewFakeCluster(0, 10)
+	reaper := NewReaper(store, 10000, shardID)
+	reaper.startNoSchedule()
+	tableID := uint64(23)
+	versionTableID := uint64(24)
+	colTypes := []common.ColumnType{common.BigIntColumnType, TS6ColType}
+	tabInfo := common.NewTableInfo(tableID, "test", "test_table", []int{0}, []string{"col0", "row_time"},
+		colTypes, 0, 0)
+	tabInfo.VersionRetentionDuration = versionRetentionDuration
+	tabInfo.VersionTableID = versionTableID
+	reaper.addVersionedTableNoSchedule(tabInfo)
+	te := exec.NewTableExecutor(tabInfo, store, false, 0, false, false)
+
+	// Insert 5 rows then update 2 of them - each change stores the prior version of the row
+	generateRows(t, 5, 0, time.Now(), 0, te, colTypes, store)
+	generateRows(t, 2, 0, time.Now(), 0, te, colTypes, store)
+	require.Equal(t, 7, len(getVersionEntries(t, versionTableID, store)))
+	// Each version has an expiry entry too
+	require.Equal(t, 14, len(getTableRows(t, versionTableID, store)))
+
+	dur, err := reaper.run(false)
+	require.NoError(t, err)
+	require.LessOrEqual(t, dur, versionRetentionDuration)
+	require.Equal(t, 7, len(getVersionEntries(t, versionTableID, store)))
+
+	time.Sleep(versionRetentionDuration)
+	_, err = reaper.run(false)
+	require.NoError(t, err)
+
+	// The versions should be gone but the rows are still there
+	require.Equal(t, 0, len(getTableRows(t, versionTableID, store)))
+	require.Equal(t, 5, len(getTableRows(t, tableID, store)))
+
+	reaper.Stop()
+}
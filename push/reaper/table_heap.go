@@ -8,6 +8,8 @@ import (
 type itemHolder struct {
 	CheckTime int64
 	Table     *common.TableInfo
+	// Versions is true if the item is for removing expired versions of rows of the table rather than expired rows
+	Versions bool
 }
 
 type inner struct {
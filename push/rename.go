@@ -0,0 +1,148 @@
+package push
+
+import (
+	"fmt"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/push/exec"
+)
+
+// RenameTable changes the name of a source, materialized view or sink once it has been renamed with the meta
+// controller. The materialized views and sinks which scan it do so under its new name, and a renamed materialized
+// view or sink consumes from its feeders under its new name. The columns are unchanged, so nothing has to be rebuilt.
+func (p *Engine) RenameTable(rename *meta.TableRename) error {
+	switch info := rename.Table.(type) {
+	case *common.SourceInfo:
+		src, err := p.GetSource(info.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		src.Rename(info)
+		renameIndexConsumers(src.TableExecutor(), rename.PrevName, info.TableInfo)
+		p.renameScans(info.SchemaName, rename.PrevName, info.Name)
+	case *common.MaterializedViewInfo:
+		mv, err := p.GetMaterializedView(info.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		tes, _, err := mv.getFeedingExecutors(mv.tableExecutor)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, te := range tes {
+			te.RenameConsumingNode(mv.consumerName, info.Name)
+		}
+		mv.consumerName = info.Name
+		mv.Info = info
+		mv.InternalTables = rename.InternalTables
+		mv.tableExecutor.SetTableInfo(info.TableInfo)
+		renameIndexConsumers(mv.tableExecutor, rename.PrevName, info.TableInfo)
+		p.renameScans(info.SchemaName, rename.PrevName, info.Name)
+	case *common.SinkInfo:
+		sink, err := p.GetSink(info.ID)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		tes, err := sink.getFeedingExecutors(sink)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, te := range tes {
+			te.RenameConsumingNode(rename.PrevName, info.Name)
+		}
+		sink.Info = info
+	default:
+		return errors.Errorf("cannot rename %s", rename.Table)
+	}
+	// The queries of the dependents have changed, but they still plan to the same executors
+	for _, dependent := range rename.Dependents {
+		switch info := dependent.(type) {
+		case *common.MaterializedViewInfo:
+			mv, err := p.GetMaterializedView(info.ID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			mv.Info = info
+		case *common.SinkInfo:
+			sink, err := p.GetSink(info.ID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			sink.Info = info
+		}
+	}
+	return nil
+}
+
+// renameIndexConsumers changes the names the indexes on a renamed table are registered under as consumers of it
+func renameIndexConsumers(te *exec.TableExecutor, prevName string, tableInfo *common.TableInfo) {
+	for _, indexInfo := range tableInfo.IndexInfos {
+		te.RenameConsumingNode(fmt.Sprintf("%s.%s", prevName, indexInfo.Name),
+			fmt.Sprintf("%s.%s", tableInfo.Name, indexInfo.Name))
+	}
+	if tableInfo.RetentionDuration != 0 {
+		te.RenameConsumingNode(fmt.Sprintf("%s_row_time_%d", prevName, tableInfo.RowTimeIndexID),
+			fmt.Sprintf("%s_row_time_%d", tableInfo.Name, tableInfo.RowTimeIndexID))
+	}
+}
+
+// renameScans changes the scans of a renamed table by the materialized views and sinks in the schema to use its new
+// name
+func (p *Engine) renameScans(schemaName string, prevName string, name string) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	for _, mv := range p.materializedViews {
+		if mv.schema.Name == schemaName {
+			renameScans(mv.tableExecutor, prevName, name)
+		}
+	}
+	for _, sink := range p.sinks {
+		if sink.schema.Name == schemaName {
+			renameScans(sink, prevName, name)
+		}
+	}
+}
+
+func renameScans(executor exec.PushExecutor, prevName string, name string) {
+	if scan, ok := executor.(*exec.Scan); ok && scan.TableName == prevName {
+		scan.TableName = name
+	}
+	for _, child := range executor.GetChildren() {
+		renameScans(child, prevName, name)
+	}
+}
+
+// getFeedingExecutors returns the table executors of the sources and materialized views the sink consumes from
+func (s *Sink) getFeedingExecutors(executor exec.PushExecutor) ([]*exec.TableExecutor, error) {
+	var tes []*exec.TableExecutor
+	if scan, ok := executor.(*exec.Scan); ok {
+		tbl, ok := s.schema.GetTable(scan.TableName)
+		if !ok {
+			return nil, errors.Errorf("unknown source or materialized view %s", scan.TableName)
+		}
+		switch tbl := tbl.(type) {
+		case *common.SourceInfo:
+			source, err := s.pe.GetSource(tbl.ID)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			tes = append(tes, source.TableExecutor())
+		case *common.MaterializedViewInfo:
+			mv, err := s.pe.GetMaterializedView(tbl.ID)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			tes = append(tes, mv.tableExecutor)
+		}
+	}
+	for _, child := range executor.GetChildren() {
+		childTes, err := s.getFeedingExecutors(child)
+		if err != nil {
+			return nil, err
+		}
+		tes = append(tes, childTes...)
+	}
+	return tes, nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/squareup/pranadb/table"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -33,6 +34,30 @@ type Sink struct {
 	keyCodec      codec.Codec
 	valueCodec    codec.Codec
 	children      []exec.PushExecutor
+	shardStats    sync.Map
+}
+
+// sinkShardStats holds the stats of the rows the sink sends from a shard
+type sinkShardStats struct {
+	bufferedMessages int64
+	messagesSent     int64
+	lastSend         int64 // unix micros, zero if nothing has been sent
+}
+
+// GetShardStats returns the number of messages the sink has buffered to send from the shard, the number it has sent
+// and when it last sent any, in unix micros or zero if it hasn't sent any since it was started on this node
+func (s *Sink) GetShardStats(shardID uint64) (bufferedMessages int64, messagesSent int64, lastSend int64) {
+	stats := s.getShardStats(shardID)
+	return atomic.LoadInt64(&stats.bufferedMessages), atomic.LoadInt64(&stats.messagesSent),
+		atomic.LoadInt64(&stats.lastSend)
+}
+
+func (s *Sink) getShardStats(shardID uint64) *sinkShardStats {
+	stats, ok := s.shardStats.Load(shardID)
+	if !ok {
+		stats, _ = s.shardStats.LoadOrStore(shardID, &sinkShardStats{})
+	}
+	return stats.(*sinkShardStats) //nolint:forcetypeassert
 }
 
 func CreateSink(pe *Engine, pl *parplan.Planner, schema *common.Schema, sinkName string, query string,
@@ -67,7 +92,7 @@ func CreateSink(pe *Engine, pl *parplan.Planner, schema *common.Schema, sinkName
 		return nil, err
 	}
 
-	headerCodec, keyCodec, valueCodec, err := util.GetCodecs(pe.protoRegistry, originInfo.HeaderEncoding,
+	headerCodec, keyCodec, valueCodec, err := util.GetCodecs(pe.protoRegistry, pe.schemaRegistry, originInfo.HeaderEncoding,
 		originInfo.KeyEncoding, originInfo.ValueEncoding, originInfo.Injectors)
 	if err != nil {
 		return nil, err
@@ -113,6 +138,12 @@ func CreateSink(pe *Engine, pl *parplan.Planner, schema *common.Schema, sinkName
 	return &sink, nil
 }
 
+func (s *Sink) IsRunning() bool {
+	s.startStopLock.Lock()
+	defer s.startStopLock.Unlock()
+	return s.started
+}
+
 func (s *Sink) Start() error {
 	s.startStopLock.Lock()
 	defer s.startStopLock.Unlock()
@@ -186,7 +217,7 @@ func (s *Sink) HandleRows(rowsBatch exec.RowsBatch, ctx *exec.ExecutionContext)
 
 		if currentRow != nil {
 			keyBuff := table.EncodeTableKeyPrefix(s.Info.ID, ctx.WriteBatch.ShardID, 32)
-			keyBuff, err := common.EncodeKeyCols(currentRow, s.Info.PrimaryKeyCols, s.Info.ColumnTypes, keyBuff)
+			keyBuff, err := common.EncodeOrderedKeyCols(currentRow, s.Info.PrimaryKeyCols, s.Info.PrimaryKeyDesc, s.Info.ColumnTypes, keyBuff)
 			if err != nil {
 				return err
 			}
@@ -202,7 +233,7 @@ func (s *Sink) HandleRows(rowsBatch exec.RowsBatch, ctx *exec.ExecutionContext)
 		} else {
 			// It's a delete
 			keyBuff := table.EncodeTableKeyPrefix(s.Info.ID, ctx.WriteBatch.ShardID, 32)
-			keyBuff, err := common.EncodeKeyCols(prevRow, s.Info.PrimaryKeyCols, s.Info.ColumnTypes, keyBuff)
+			keyBuff, err := common.EncodeOrderedKeyCols(prevRow, s.Info.PrimaryKeyCols, s.Info.PrimaryKeyDesc, s.Info.ColumnTypes, keyBuff)
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -229,6 +260,7 @@ func (s *Sink) HandleRows(rowsBatch exec.RowsBatch, ctx *exec.ExecutionContext)
 		for k, v := range toPut {
 			ctx.WriteBatch.AddPut(common.StringToByteSliceZeroCopy(k), v)
 		}
+		atomic.StoreInt64(&s.getShardStats(shardID).bufferedMessages, int64(len(rowsToSend)))
 	}
 	return nil
 }
@@ -304,6 +336,12 @@ func (s *Sink) sendMessageBatch(shardID uint64, rows map[string]*common.Row, per
 	if err := s.producer.SendMessages(kmsgs); err != nil {
 		return err
 	}
+	stats := s.getShardStats(shardID)
+	atomic.StoreInt64(&stats.bufferedMessages, 0)
+	if len(kmsgs) > 0 {
+		atomic.AddInt64(&stats.messagesSent, int64(len(kmsgs)))
+		atomic.StoreInt64(&stats.lastSend, time.Now().UnixMicro())
+	}
 	if persistent {
 		return s.pe.cluster.WriteBatch(wb, true)
 	}
@@ -0,0 +1,106 @@
+package source
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/squareup/pranadb/errors"
+)
+
+const (
+	// The key of a row ingested by an append only source is a sequence value in the upper 32 bits and the index of the
+	// row in the block of keys reserved with that value in the lower 32 bits. The sequence value must fit in 31 bits so
+	// the key isn't negative.
+	appendKeySequenceName = "append_key"
+	appendKeyBlockBits    = 32
+	appendKeyBlockSize    = 1 << appendKeyBlockBits
+	maxAppendKeySequence  = 1<<31 - 1
+)
+
+// shardAppendKeys hands out the keys of the rows an append only source ingests into each shard on this node. Each
+// shard has its own blocks of keys, and a batch holds the keys of the shards it writes to until it has been sent, so
+// the keys the node gives the rows of a shard increase in the order they're ingested.
+type shardAppendKeys struct {
+	lock             sync.Mutex
+	generateSequence func() (uint64, error)
+	shards           map[uint64]*appendKeys
+}
+
+func newShardAppendKeys(generateSequence func() (uint64, error)) *shardAppendKeys {
+	return &shardAppendKeys{
+		generateSequence: generateSequence,
+		shards:           make(map[uint64]*appendKeys),
+	}
+}
+
+// lockShards locks the keys of each of the shards and returns them. The shards are locked in order, so batches
+// writing to the same shards concurrently can't deadlock.
+func (s *shardAppendKeys) lockShards(shardIDs []uint64) map[uint64]*appendKeys {
+	sorted := append([]uint64(nil), shardIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	locked := make(map[uint64]*appendKeys, len(sorted))
+	for _, shardID := range sorted {
+		keys := s.getShard(shardID)
+		keys.lock.Lock()
+		locked[shardID] = keys
+	}
+	return locked
+}
+
+func (s *shardAppendKeys) unlockShards(locked map[uint64]*appendKeys) {
+	for _, keys := range locked {
+		keys.lock.Unlock()
+	}
+}
+
+func (s *shardAppendKeys) getShard(shardID uint64) *appendKeys {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	keys, ok := s.shards[shardID]
+	if !ok {
+		keys = newAppendKeys(s.generateSequence)
+		s.shards[shardID] = keys
+	}
+	return keys
+}
+
+// appendKeys hands out the keys of the rows ingested into a shard. The keys are taken from blocks reserved with the
+// cluster sequence generator, so the sequence is only generated when a block runs out rather than for every batch.
+// Keys increase from batch to batch, and are unique across the cluster.
+type appendKeys struct {
+	lock             sync.Mutex
+	generateSequence func() (uint64, error)
+	next             int64
+	remaining        int64
+}
+
+func newAppendKeys(generateSequence func() (uint64, error)) *appendKeys {
+	return &appendKeys{generateSequence: generateSequence}
+}
+
+// reserve returns the next key, reserving a new block first if fewer than n keys are left in the current one, so the
+// keys given to a batch of up to n rows are consecutive
+func (a *appendKeys) reserve(n int64) (int64, error) {
+	if n > appendKeyBlockSize {
+		return 0, errors.Errorf("cannot reserve %d append keys, a block has %d", n, int64(appendKeyBlockSize))
+	}
+	if a.remaining >= n {
+		return a.next, nil
+	}
+	seq, err := a.generateSequence()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if seq > maxAppendKeySequence {
+		return 0, errors.New("append key sequence exhausted")
+	}
+	a.next = int64(seq << appendKeyBlockBits)
+	a.remaining = appendKeyBlockSize
+	return a.next, nil
+}
+
+// used records that the keys before next have been given to rows, so they're never given again
+func (a *appendKeys) used(next int64) {
+	a.remaining -= next - a.next
+	a.next = next
+}
@@ -0,0 +1,75 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendKeys(t *testing.T) {
+	var generated []uint64
+	seq := uint64(5)
+	keys := newAppendKeys(func() (uint64, error) {
+		generated = append(generated, seq)
+		seq++
+		return seq - 1, nil
+	})
+
+	// The sequence is only generated when the block can't fit the batch
+	next, err := keys.reserve(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(5)<<32, next)
+	keys.used(next + 3)
+	next, err = keys.reserve(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(5)<<32+3, next)
+	keys.used(next + appendKeyBlockSize - 13)
+	next, err = keys.reserve(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(5)<<32+appendKeyBlockSize-10, next)
+	require.Equal(t, []uint64{5}, generated)
+
+	keys.used(next + 1)
+	next, err = keys.reserve(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(6)<<32, next)
+	require.Equal(t, []uint64{5, 6}, generated)
+
+	_, err = keys.reserve(appendKeyBlockSize + 1)
+	require.Error(t, err)
+
+	// The last block doesn't overflow the key
+	seq = maxAppendKeySequence
+	keys.used(next + appendKeyBlockSize)
+	next, err = keys.reserve(1)
+	require.NoError(t, err)
+	require.Equal(t, int64(maxAppendKeySequence)<<32, next)
+	require.True(t, next > 0)
+	keys.used(next + appendKeyBlockSize)
+	_, err = keys.reserve(1)
+	require.Error(t, err)
+}
+
+func TestShardAppendKeys(t *testing.T) {
+	seq := uint64(0)
+	shardKeys := newShardAppendKeys(func() (uint64, error) {
+		seq++
+		return seq, nil
+	})
+	// Each shard has its own block
+	keys := shardKeys.lockShards([]uint64{11, 10})
+	next10, err := keys[10].reserve(1)
+	require.NoError(t, err)
+	next11, err := keys[11].reserve(1)
+	require.NoError(t, err)
+	require.NotEqual(t, next10>>appendKeyBlockBits, next11>>appendKeyBlockBits)
+	keys[10].used(next10 + 1)
+	shardKeys.unlockShards(keys)
+
+	// The shard keeps using its block
+	keys = shardKeys.lockShards([]uint64{10})
+	next, err := keys[10].reserve(1)
+	require.NoError(t, err)
+	require.Equal(t, next10+1, next)
+	shardKeys.unlockShards(keys)
+}
@@ -0,0 +1,127 @@
+package source
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/cluster/fake"
+	"github.com/squareup/pranadb/command/parser/selector"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/conf"
+	"github.com/squareup/pranadb/kafka"
+	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
+	"github.com/squareup/pranadb/sharder"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendOnlyIngestRedelivered(t *testing.T) {
+	clust := fake.NewFakeCluster(0, 10)
+	listeners := &forwardedRows{rows: make(map[uint64][][]byte)}
+	clust.RegisterShardListenerFactory(listeners)
+	clust.SetRemoteQueryExecutionCallback(&cluster.DummyRemoteQueryExecutionCallback{})
+	require.NoError(t, clust.Start())
+	defer func() {
+		require.NoError(t, clust.Stop())
+	}()
+	shrder := sharder.NewSharder(clust)
+	require.NoError(t, shrder.Start())
+
+	selectors, err := compileSelectors([]string{"kind"})
+	require.NoError(t, err)
+	selectors = append(selectors, selector.ColumnSelector{})
+	sourceInfo := &common.SourceInfo{
+		TableInfo: common.NewTableInfo(1000, "test", "events", []int{1}, []string{"kind", common.AppendKeyColumnName},
+			[]common.ColumnType{common.VarcharColumnType, common.BigIntColumnType}, 0, 0),
+		OriginInfo: &common.SourceOriginInfo{
+			BrokerName:     "testbroker",
+			TopicName:      "testtopic",
+			HeaderEncoding: common.KafkaEncodingJSON,
+			KeyEncoding:    common.KafkaEncodingJSON,
+			ValueEncoding:  common.KafkaEncodingJSON,
+			ColSelectors:   selectors,
+			AppendOnly:     true,
+		},
+	}
+	fakeKafka := kafka.NewFakeKafka()
+	src, err := NewSource(sourceInfo, nil, nil, nil, shrder, clust, conf.NewTestConfig(fakeKafka.ID), nil,
+		protolib.EmptyRegistry, schemaregistry.EmptyRegistry, "")
+	require.NoError(t, err)
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
+	require.NoError(t, err)
+
+	var messages []*kafka.Message
+	for i := 0; i < 20; i++ {
+		messages = append(messages, &kafka.Message{
+			PartInfo: kafka.PartInfo{PartitionID: int32(i % 4), Offset: int64(i / 4)},
+			Value:    []byte(`{"kind":"click"}`),
+		})
+	}
+	require.NoError(t, src.ingestMessages(messages, mp, 0))
+	require.Equal(t, 20, listeners.count())
+
+	// The redelivered messages are given new keys, but reach the shards which have already seen their offsets
+	require.NoError(t, src.ingestMessages(messages, mp, 0))
+	require.Equal(t, 20, listeners.count())
+
+	// The keys of the rows a shard receives increase in the order they're ingested
+	more := make([]*kafka.Message, len(messages))
+	for i, msg := range messages {
+		more[i] = &kafka.Message{
+			PartInfo: kafka.PartInfo{PartitionID: msg.PartInfo.PartitionID, Offset: msg.PartInfo.Offset + 5},
+			Value:    msg.Value,
+		}
+	}
+	require.NoError(t, src.ingestMessages(more, mp, 0))
+	require.Equal(t, 40, listeners.count())
+	for _, rows := range listeners.rows {
+		var prevKey int64 = -1
+		for _, encoded := range rows {
+			decoded := common.NewRowsFactory(sourceInfo.ColumnTypes).NewRows(1)
+			require.NoError(t, common.DecodeRow(encoded, sourceInfo.ColumnTypes, decoded))
+			row := decoded.GetRow(0)
+			key := row.GetInt64(1)
+			require.Greater(t, key, prevKey)
+			prevKey = key
+		}
+	}
+}
+
+// forwardedRows records the rows forwarded to each shard
+type forwardedRows struct {
+	lock sync.Mutex
+	rows map[uint64][][]byte
+}
+
+func (f *forwardedRows) CreateShardListener(shardID uint64) cluster.ShardListener {
+	return &forwardedRowsListener{shardID: shardID, rows: f}
+}
+
+func (f *forwardedRows) count() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	n := 0
+	for _, rows := range f.rows {
+		n += len(rows)
+	}
+	return n
+}
+
+type forwardedRowsListener struct {
+	shardID uint64
+	rows    *forwardedRows
+}
+
+func (l *forwardedRowsListener) RemoteWriteOccurred(forwardRows []cluster.ForwardRow) {
+	l.rows.lock.Lock()
+	defer l.rows.lock.Unlock()
+	for _, row := range forwardRows {
+		// The value holds the length of the previous row, which is empty, then the current row
+		curr := row.RowBytes[8:]
+		l.rows.rows[l.shardID] = append(l.rows.rows[l.shardID], curr)
+	}
+}
+
+func (l *forwardedRowsListener) Close() {
+}
@@ -12,6 +12,8 @@ import (
 
 type MessageConsumer struct {
 	msgProvider     kafka.MessageProvider
+	topicName       string
+	topicIndex      uint16
 	pollTimeout     time.Duration
 	maxMessages     int32
 	source          *Source
@@ -22,14 +24,17 @@ type MessageConsumer struct {
 	offsetsToCommit map[int32]int64
 }
 
-func NewMessageConsumer(msgProvider kafka.MessageProvider, pollTimeout time.Duration, maxMessages int,
-	source *Source) (*MessageConsumer, error) {
-	messageParser, err := NewMessageParser(source.sourceInfo, source.protoRegistry)
+func NewMessageConsumer(msgProvider kafka.MessageProvider, topicName string, topicIndex uint16, pollTimeout time.Duration,
+	maxMessages int, source *Source) (*MessageConsumer, error) {
+	messageParser, err := NewMessageParser(source.sourceInfo, source.computedExpressions, source.protoRegistry,
+		source.schemaRegistry)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 	mc := &MessageConsumer{
 		msgProvider:     msgProvider,
+		topicName:       topicName,
+		topicIndex:      topicIndex,
 		pollTimeout:     pollTimeout,
 		maxMessages:     int32(maxMessages),
 		source:          source,
@@ -102,7 +107,7 @@ func (m *MessageConsumer) pollLoop() {
 
 		if len(messages) != 0 {
 			// This blocks until messages were actually ingested
-			if err := m.source.ingestMessages(messages, m.messageParser); err != nil {
+			if err := m.source.ingestMessages(messages, m.messageParser, m.topicIndex); err != nil {
 				m.consumerError(err, false)
 				return
 			}
@@ -114,6 +119,7 @@ func (m *MessageConsumer) pollLoop() {
 					m.consumerError(err, true)
 					return
 				}
+				m.source.setCommittedOffsets(m.topicName, offsetsToCommit)
 			}
 			if m.source.enableStats {
 				m.source.addCommittedCount(int64(len(messages)))
@@ -0,0 +1,200 @@
+package source
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/conf"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/kafka"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/table"
+)
+
+// Headers added to the messages sent to a dead letter topic, saying where the message came from and why it was
+// rejected. The key, value and headers of the message are otherwise sent as they were received.
+const (
+	deadLetterErrorHeader     = "prana_error"
+	deadLetterTopicHeader     = "prana_topic"
+	deadLetterPartitionHeader = "prana_partition"
+	deadLetterOffsetHeader    = "prana_offset"
+)
+
+var deadLettersRowsFactory = common.NewRowsFactory(meta.DeadLettersTableInfo.ColumnTypes)
+
+// rejectedMessages holds the messages in a batch which can't be ingested, and why
+type rejectedMessages struct {
+	policy   string
+	messages []*kafka.Message
+	errs     []error
+}
+
+// add records a message which can't be ingested. With the fail policy the error is returned instead, so the source
+// stops.
+func (r *rejectedMessages) add(message *kafka.Message, err error) error {
+	if r.policy == "" || r.policy == common.ErrorPolicyFail {
+		return err
+	}
+	if n := len(r.messages); n > 0 && r.messages[n-1] == message {
+		// A message parsed into a row for each element of an array is only rejected once
+		return nil
+	}
+	r.messages = append(r.messages, message)
+	r.errs = append(r.errs, err)
+	return nil
+}
+
+// rowSource is the message a row was parsed from, and the element of the exploded array it was parsed from, if any.
+// delete is true if the message was a change event which deleted the row.
+type rowSource struct {
+	message *kafka.Message
+	element int
+	delete  bool
+}
+
+// parseMessages parses the messages into rows, returning where each row was parsed from in the same order as the rows
+func (s *Source) parseMessages(messages []*kafka.Message, mp *MessageParser,
+	rejected *rejectedMessages) (*common.Rows, []rowSource, error) {
+	// With the fail policy a message which can't be parsed fails the batch, so it's parsed straight into the batch
+	failOnError := rejected.policy == "" || rejected.policy == common.ErrorPolicyFail
+	rows := mp.rowsFactory.NewRows(len(messages))
+	sources := make([]rowSource, 0, len(messages))
+	for _, msg := range messages {
+		var numRows int
+		var err error
+		if failOnError {
+			numRows, err = mp.parseMessage(msg, rows)
+		} else {
+			numRows, err = mp.ParseMessage(msg, rows)
+		}
+		if err != nil {
+			if err := rejected.add(msg, err); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		for element := 0; element < numRows; element++ {
+			sources = append(sources, rowSource{message: msg, element: element, delete: mp.deleted})
+		}
+	}
+	return rows, sources, nil
+}
+
+// deadLetter records the messages which were rejected, if the policy is deadletter, before the rest of the batch is
+// ingested. As the dead letters are keyed on the topic, partition and offset of the message, recording them again if
+// the batch is redelivered just overwrites them.
+func (s *Source) deadLetter(rejected *rejectedMessages) error {
+	if len(rejected.messages) == 0 {
+		return nil
+	}
+	s.messagesRejectedCounter.Add(float64(len(rejected.messages)))
+	for i, msg := range rejected.messages {
+		log.Debugf("source %s.%s rejected message at partition %d offset %d: %v", s.sourceInfo.SchemaName,
+			s.sourceInfo.Name, msg.PartInfo.PartitionID, msg.PartInfo.Offset, rejected.errs[i])
+	}
+	if rejected.policy != common.ErrorPolicyDeadLetter {
+		return nil
+	}
+	now := common.NewTimestampFromGoTime(time.Now())
+	rows := deadLettersRowsFactory.NewRows(len(rejected.messages))
+	for i, msg := range rejected.messages {
+		rows.AppendInt64ToColumn(0, int64(s.sourceInfo.ID))
+		rows.AppendInt64ToColumn(1, int64(msg.PartInfo.PartitionID))
+		rows.AppendInt64ToColumn(2, msg.PartInfo.Offset)
+		rows.AppendStringToColumn(3, s.sourceInfo.SchemaName)
+		rows.AppendStringToColumn(4, s.sourceInfo.Name)
+		rows.AppendStringToColumn(5, s.messageTopic(msg))
+		appendBytesOrNull(rows, 6, msg.Key)
+		appendBytesOrNull(rows, 7, msg.Value)
+		rows.AppendStringToColumn(8, rejected.errs[i].Error())
+		rows.AppendTimestampToColumn(9, now)
+	}
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	for i := 0; i < rows.RowCount(); i++ {
+		row := rows.GetRow(i)
+		if err := table.Upsert(meta.DeadLettersTableInfo.TableInfo, &row, wb); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := s.cluster.WriteBatch(wb, false); err != nil {
+		return errors.WithStack(err)
+	}
+	if s.deadLetterProducer == nil {
+		return nil
+	}
+	kmsgs := make([]kafka.Message, len(rejected.messages))
+	for i, msg := range rejected.messages {
+		headers := make([]kafka.MessageHeader, 0, len(msg.Headers)+4)
+		headers = append(headers, msg.Headers...)
+		headers = append(headers,
+			kafka.MessageHeader{Key: deadLetterErrorHeader, Value: []byte(rejected.errs[i].Error())},
+			kafka.MessageHeader{Key: deadLetterTopicHeader, Value: []byte(s.messageTopic(msg))},
+			kafka.MessageHeader{Key: deadLetterPartitionHeader, Value: []byte(fmt.Sprintf("%d", msg.PartInfo.PartitionID))},
+			kafka.MessageHeader{Key: deadLetterOffsetHeader, Value: []byte(fmt.Sprintf("%d", msg.PartInfo.Offset))})
+		kmsgs[i] = kafka.Message{
+			TimeStamp: msg.TimeStamp,
+			Key:       msg.Key,
+			Value:     msg.Value,
+			Headers:   headers,
+		}
+	}
+	return errors.WithStack(s.deadLetterProducer.SendMessages(kmsgs))
+}
+
+// messageTopic returns the topic a message was consumed from
+func (s *Source) messageTopic(msg *kafka.Message) string {
+	if msg.PartInfo.Topic != "" {
+		return msg.PartInfo.Topic
+	}
+	return s.sourceInfo.OriginInfo.TopicName
+}
+
+func appendBytesOrNull(rows *common.Rows, colIndex int, bytes []byte) {
+	if bytes == nil {
+		rows.AppendNullToColumn(colIndex)
+	} else {
+		rows.AppendStringToColumn(colIndex, string(bytes))
+	}
+}
+
+// newDeadLetterClient returns the client used to send rejected messages to the dead letter topic, using the same
+// broker as the source
+func newDeadLetterClient(brokerConf conf.BrokerConfig, topicName string, props map[string]string) (kafka.MessageClient, error) {
+	switch brokerConf.ClientType {
+	case conf.BrokerClientFake:
+		return kafka.NewFakeMessageProviderFactory(topicName, props, "")
+	case conf.BrokerClientDefault:
+		return kafka.NewMessageProviderFactory(topicName, props, ""), nil
+	default:
+		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "DeadLetterTopic is not supported for broker client type %d",
+			brokerConf.ClientType)
+	}
+}
+
+// startDeadLetterProducer starts the producer for the dead letter topic, if there is one
+func (s *Source) startDeadLetterProducer() error {
+	if s.deadLetterClient == nil {
+		return nil
+	}
+	producer, err := s.deadLetterClient.NewMessageProducer()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := producer.Start(); err != nil {
+		return errors.WithStack(err)
+	}
+	s.deadLetterProducer = producer
+	return nil
+}
+
+func (s *Source) stopDeadLetterProducer() error {
+	if s.deadLetterProducer == nil {
+		return nil
+	}
+	producer := s.deadLetterProducer
+	s.deadLetterProducer = nil
+	return errors.WithStack(producer.Stop())
+}
@@ -7,7 +7,10 @@ import (
 	"github.com/squareup/pranadb/kafka"
 	"github.com/squareup/pranadb/protolib"
 	"github.com/squareup/pranadb/push/codec"
+	"github.com/squareup/pranadb/push/exec"
 	"github.com/squareup/pranadb/push/util"
+	"github.com/squareup/pranadb/schemaregistry"
+	"google.golang.org/protobuf/reflect/protoreflect"
 )
 
 const (
@@ -19,33 +22,99 @@ const (
 )
 
 type MessageParser struct {
-	sourceInfo       *common.SourceInfo
-	rowsFactory      *common.RowsFactory
-	selectors        []selector.ColumnSelector
-	headerDecoder    codec.Codec
-	keyDecoder       codec.Codec
-	valueDecoder     codec.Codec
-	evalContext      *evalContext
-	protobufRegistry protolib.Resolver
+	sourceInfo  *common.SourceInfo
+	rowsFactory *common.RowsFactory
+	// selectors and colTypes are those of the columns which are selected from the message, rather than computed or
+	// generated, and selectedCols has the index of the column of the parsed rows each of them is appended to
+	selectors    []selector.ColumnSelector
+	colTypes     []common.ColumnType
+	selectedCols []int
+	// appendKeyCol is the index of the generated key column of an append only source, or -1. Each row parsed is given
+	// the next key, starting from the key set with setNextAppendKey.
+	appendKeyCol  int
+	nextAppendKey int64
+	// computedExpressions evaluates every column of the source from the selected columns, if any are computed
+	computedExpressions []*common.Expression
+	selectedRowsFactory *common.RowsFactory
+	explodedArray       *selector.ColumnSelector
+	explodedName        string
+	explodes            []bool
+	headerDecoder       codec.Codec
+	keyDecoder          codec.Codec
+	valueDecoder        codec.Codec
+	evalContext         *evalContext
+	protobufRegistry    protolib.Resolver
+	// changeEvents is true if the messages are change events, in which case deleted is true if the last message parsed
+	// deleted its row rather than upserting it
+	changeEvents bool
+	deleted      bool
 }
 
-func NewMessageParser(sourceInfo *common.SourceInfo, registry protolib.Resolver) (*MessageParser, error) {
-	selectors := sourceInfo.OriginInfo.ColSelectors
+func NewMessageParser(sourceInfo *common.SourceInfo, computedExpressions []*common.Expression,
+	registry protolib.Resolver, schemaRegistry schemaregistry.Registry) (*MessageParser, error) {
+	var selectors []selector.ColumnSelector
+	var colTypes []common.ColumnType
+	var selectedCols []int
+	appendKeyCol := -1
+	for i, sel := range sourceInfo.OriginInfo.ColSelectors {
+		if sourceInfo.IsAppendKey(i) {
+			appendKeyCol = i
+			continue
+		}
+		if !sourceInfo.OriginInfo.IsComputed(i) {
+			selectors = append(selectors, sel)
+			colTypes = append(colTypes, sourceInfo.ColumnTypes[i])
+			selectedCols = append(selectedCols, i)
+		}
+	}
+	var selectedRowsFactory *common.RowsFactory
+	if computedExpressions != nil {
+		// The selected columns are parsed into rows of their own
+		selectedRowsFactory = common.NewRowsFactory(colTypes)
+		for i := range selectedCols {
+			selectedCols[i] = i
+		}
+	}
 	topic := sourceInfo.OriginInfo
-	headerCodec, keyCodec, valueCodec, err := util.GetCodecs(registry, topic.HeaderEncoding, topic.KeyEncoding, topic.ValueEncoding, selectors)
+	headerCodec, keyCodec, valueCodec, err := util.GetCodecs(registry, schemaRegistry, topic.HeaderEncoding, topic.KeyEncoding, topic.ValueEncoding, selectors)
 	if err != nil {
 		return nil, err
 	}
+	// All the selectors which explode an array with [*] explode the same array, so each message is parsed into a row
+	// for each of its elements
+	var explodedArray *selector.ColumnSelector
+	var explodedName string
+	explodes := make([]bool, len(selectors))
+	for i, sel := range selectors {
+		if sel.Explodes() {
+			explodes[i] = true
+			arr := sel.ExplodedArray()
+			explodedArray = &arr
+			explodedName = arr.Selector.String()
+			if arr.MetaKey != nil {
+				explodedName = arr.String()
+			}
+		}
+	}
 	mp := &MessageParser{
-		rowsFactory:      common.NewRowsFactory(sourceInfo.ColumnTypes),
-		protobufRegistry: registry,
-		sourceInfo:       sourceInfo,
-		selectors:        selectors,
-		headerDecoder:    headerCodec,
-		keyDecoder:       keyCodec,
-		valueDecoder:     valueCodec,
+		rowsFactory:         common.NewRowsFactory(sourceInfo.ColumnTypes),
+		protobufRegistry:    registry,
+		sourceInfo:          sourceInfo,
+		selectors:           selectors,
+		colTypes:            colTypes,
+		selectedCols:        selectedCols,
+		appendKeyCol:        appendKeyCol,
+		explodedArray:       explodedArray,
+		explodedName:        explodedName,
+		explodes:            explodes,
+		headerDecoder:       headerCodec,
+		keyDecoder:          keyCodec,
+		valueDecoder:        valueCodec,
+		computedExpressions: computedExpressions,
+		selectedRowsFactory: selectedRowsFactory,
+		changeEvents:        topic.ValueEncoding.Encoding.IsChangeEvent(),
 		evalContext: &evalContext{
-			meta: make(map[string]interface{}, 3),
+			meta: make(map[string]interface{}, 6),
 		},
 	}
 	return mp, nil
@@ -54,16 +123,124 @@ func NewMessageParser(sourceInfo *common.SourceInfo, registry protolib.Resolver)
 func (m *MessageParser) ParseMessages(messages []*kafka.Message) (*common.Rows, error) {
 	rows := m.rowsFactory.NewRows(len(messages))
 	for _, msg := range messages {
-		if err := m.decodeMessage(msg); err != nil {
-			return nil, errors.WithStack(err)
-		}
-		if err := m.evalColumns(rows); err != nil {
+		if _, err := m.parseMessage(msg, rows); err != nil {
 			return nil, errors.WithStack(err)
 		}
 	}
 	return rows, nil
 }
 
+// ParseMessage parses a single message and appends its rows to rows, returning the number of rows appended. Nothing
+// is appended if the message can't be parsed.
+func (m *MessageParser) ParseMessage(message *kafka.Message, rows *common.Rows) (int, error) {
+	// A message can fail part way through its columns, so it's parsed into rows of its own first
+	msgRows := m.rowsFactory.NewRows(1)
+	numRows, err := m.parseMessage(message, msgRows)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for i := 0; i < numRows; i++ {
+		rows.AppendRow(msgRows.GetRow(i))
+	}
+	return numRows, nil
+}
+
+// parseMessage parses a message and appends its rows to rows, returning the number of rows appended. A message is
+// parsed into a row for each element of the exploded array, if there is one, or a single row otherwise.
+func (m *MessageParser) parseMessage(message *kafka.Message, rows *common.Rows) (int, error) {
+	m.deleted = false
+	if m.changeEvents && message.Value == nil {
+		// A tombstone follows the change event which deleted a row, so the row can be compacted away. There's nothing
+		// to ingest.
+		return 0, nil
+	}
+	if err := m.decodeMessage(message); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if m.computedExpressions == nil {
+		numRows, err := m.selectRows(rows)
+		if err != nil || m.appendKeyCol == -1 {
+			return numRows, err
+		}
+		for i := 0; i < numRows; i++ {
+			m.appendKey(rows)
+		}
+		return numRows, nil
+	}
+	// The selected columns are parsed into rows of their own, then the columns of the source are evaluated from them
+	selected := m.selectedRowsFactory.NewRows(1)
+	numRows, err := m.selectRows(selected)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < numRows; i++ {
+		row := selected.GetRow(i)
+		for j, expr := range m.computedExpressions {
+			if j == m.appendKeyCol {
+				m.appendKey(rows)
+				continue
+			}
+			if err := exec.AppendEvaluated(expr, m.sourceInfo.ColumnTypes[j], &row, rows, j); err != nil {
+				return 0, errors.WithStack(err)
+			}
+		}
+	}
+	return numRows, nil
+}
+
+// setNextAppendKey sets the key the next row parsed for an append only source is given
+func (m *MessageParser) setNextAppendKey(key int64) {
+	m.nextAppendKey = key
+}
+
+func (m *MessageParser) appendKey(rows *common.Rows) {
+	rows.AppendInt64ToColumn(m.appendKeyCol, m.nextAppendKey)
+	m.nextAppendKey++
+}
+
+// selectRows appends the rows selected from the decoded message to rows, returning the number of rows appended
+func (m *MessageParser) selectRows(rows *common.Rows) (int, error) {
+	if m.explodedArray == nil {
+		return 1, m.evalColumns(rows, 0)
+	}
+	numElements, err := m.numElements()
+	if err != nil {
+		return 0, err
+	}
+	for element := 0; element < numElements; element++ {
+		if err := m.evalColumns(rows, element); err != nil {
+			return 0, err
+		}
+	}
+	return numElements, nil
+}
+
+// numElements returns the number of elements in the exploded array of the message. A missing array has none.
+func (m *MessageParser) numElements() (int, error) {
+	arr, err := m.explodedArray.Select(m.evalContext.meta, m.evalContext.value)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	var numElements int
+	switch v := arr.(type) {
+	case nil:
+		return 0, nil
+	case []interface{}:
+		numElements = len(v)
+	case protoreflect.List:
+		numElements = v.Len()
+	default:
+		return 0, errors.Errorf("value at %q is not an array in source %s.%s", m.explodedName,
+			m.sourceInfo.SchemaName, m.sourceInfo.Name)
+	}
+	if numElements > maxElementsPerMessage {
+		return 0, errors.NewPranaErrorf(errors.ValueOutOfRange,
+			"array at %q has %d elements, more than the maximum of %d in source %s.%s", m.explodedName,
+			numElements, maxElementsPerMessage, m.sourceInfo.SchemaName, m.sourceInfo.Name)
+	}
+	return numElements, nil
+}
+
 func (m *MessageParser) decodeMessage(message *kafka.Message) error {
 	// Decode headers
 	var hdrs map[string]interface{}
@@ -98,25 +275,40 @@ func (m *MessageParser) decodeMessage(message *kafka.Message) error {
 			return errors.WithStack(err)
 		}
 	}
+	// The columns of a change event are selected from the row it changed
+	if event, ok := vm.(*codec.ChangeEvent); ok {
+		vm = event.Row
+		m.deleted = event.Delete
+	}
 
 	m.evalContext.meta["header"] = hdrs
 	m.evalContext.meta["key"] = km
 	m.evalContext.meta["timestamp"] = message.TimeStamp
+	m.evalContext.meta["topic"] = message.PartInfo.Topic
+	m.evalContext.meta["partition"] = int64(message.PartInfo.PartitionID)
+	m.evalContext.meta["offset"] = message.PartInfo.Offset
 	m.evalContext.value = vm
 
 	return nil
 }
 
-func (m *MessageParser) evalColumns(rows *common.Rows) error { //nolint:gocyclo
+// evalColumns appends a row to rows. Selectors which explode an array select from its element at index element.
+func (m *MessageParser) evalColumns(rows *common.Rows, element int) error { //nolint:gocyclo
 	for i, sel := range m.selectors {
-		colType := m.sourceInfo.ColumnTypes[i]
-		eval := sel.Select
-		val, err := eval(m.evalContext.meta, m.evalContext.value)
+		colType := m.colTypes[i]
+		col := m.selectedCols[i]
+		var val interface{}
+		var err error
+		if m.explodes[i] {
+			val, err = sel.SelectElement(m.evalContext.meta, m.evalContext.value, element)
+		} else {
+			val, err = sel.Select(m.evalContext.meta, m.evalContext.value)
+		}
 		if err != nil {
 			return errors.WithStack(err)
 		}
 		if val == nil {
-			rows.AppendNullToColumn(i)
+			rows.AppendNullToColumn(col)
 			continue
 		}
 		switch colType.Type {
@@ -130,7 +322,7 @@ func (m *MessageParser) evalColumns(rows *common.Rows) error { //nolint:gocyclo
 					"value %d is out of range for TINYINT (%d < x < %d) in source %s.%s",
 					ival, tinyIntMinVal, tinyIntMaxVal, m.sourceInfo.SchemaName, m.sourceInfo.Name)
 			}
-			rows.AppendInt64ToColumn(i, ival)
+			rows.AppendInt64ToColumn(col, ival)
 		case common.TypeInt:
 			ival, err := codec.CoerceInt64(val)
 			if err != nil {
@@ -141,19 +333,19 @@ func (m *MessageParser) evalColumns(rows *common.Rows) error { //nolint:gocyclo
 					"value %d is out of range for INT (%d < x < %d) in source %s.%s",
 					ival, intMinVal, intMaxVal, m.sourceInfo.SchemaName, m.sourceInfo.Name)
 			}
-			rows.AppendInt64ToColumn(i, ival)
+			rows.AppendInt64ToColumn(col, ival)
 		case common.TypeBigInt:
 			ival, err := codec.CoerceInt64(val)
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			rows.AppendInt64ToColumn(i, ival)
+			rows.AppendInt64ToColumn(col, ival)
 		case common.TypeDouble:
 			fval, err := codec.CoerceFloat64(val)
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			rows.AppendFloat64ToColumn(i, fval)
+			rows.AppendFloat64ToColumn(col, fval)
 		case common.TypeVarchar:
 			sval, err := codec.CoerceString(val)
 			if err != nil {
@@ -164,13 +356,13 @@ func (m *MessageParser) evalColumns(rows *common.Rows) error { //nolint:gocyclo
 					"value is too long at %d for varchar (max length %d) in source %s.%s",
 					len(sval), maxVarcharLength, m.sourceInfo.SchemaName, m.sourceInfo.Name)
 			}
-			rows.AppendStringToColumn(i, sval)
+			rows.AppendStringToColumn(col, sval)
 		case common.TypeDecimal:
 			dval, err := codec.CoerceDecimal(val)
 			if err != nil {
 				return errors.WithStack(err)
 			}
-			rows.AppendDecimalToColumn(i, *dval)
+			rows.AppendDecimalToColumn(col, *dval)
 		case common.TypeTimestamp:
 			tsVal, err := codec.CoerceTimestamp(val)
 			if err != nil {
@@ -180,7 +372,7 @@ func (m *MessageParser) evalColumns(rows *common.Rows) error { //nolint:gocyclo
 			if err := common.RoundTimestampToFSP(&tsVal, colType.FSP); err != nil {
 				return err
 			}
-			rows.AppendTimestampToColumn(i, tsVal)
+			rows.AppendTimestampToColumn(col, tsVal)
 		default:
 			return errors.Errorf("unsupported col type %d", colType.Type)
 		}
@@ -7,6 +7,7 @@ import (
 	"github.com/squareup/pranadb/common"
 	"github.com/squareup/pranadb/kafka"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 	"github.com/stretchr/testify/require"
 )
 
@@ -40,7 +41,7 @@ func setupMessageParserJSON() *MessageParser {
 		TableInfo:  tableInfo,
 		OriginInfo: topicInfo,
 	}
-	mp, err := NewMessageParser(sourceInfo, protolib.EmptyRegistry)
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
 	if err != nil {
 		panic(err)
 	}
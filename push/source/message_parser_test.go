@@ -10,6 +10,7 @@ import (
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/kafka"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 	"github.com/stretchr/testify/require"
 )
 
@@ -229,6 +230,21 @@ func TestParseMessageTimestamp(t *testing.T) {
 		vf)
 }
 
+func TestParseMessageKafkaCoordinates(t *testing.T) {
+	theColNames := []string{"col0", "col1", "col2", "col3"}
+	theColTypes := []common.ColumnType{common.VarcharColumnType, common.IntColumnType, common.BigIntColumnType, common.VarcharColumnType}
+	vf := func(t *testing.T, row *common.Row) { //nolint:thelper
+		require.Equal(t, "test_topic", row.GetString(0))
+		require.Equal(t, int64(7), row.GetInt64(1))
+		require.Equal(t, int64(1234), row.GetInt64(2))
+		require.Equal(t, "foo", row.GetString(3))
+	}
+	testParseMessage(t, theColNames, theColTypes, common.KafkaEncodingJSON, common.KafkaEncodingJSON, common.KafkaEncodingJSON,
+		nil, nil, []byte(`{"vf1":"foo"}`),
+		[]string{`meta("topic")`, `meta("partition")`, `meta("offset")`, "vf1"}, time.Now(),
+		vf)
+}
+
 func TestParseMessagesJSONHeaders(t *testing.T) {
 	theColNames := []string{"col0", "col1", "col2", "col3"}
 	theColTypes := []common.ColumnType{common.BigIntColumnType, common.BigIntColumnType, common.VarcharColumnType, common.DoubleColumnType}
@@ -390,6 +406,249 @@ func TestParseMessagesFloat64BEHeaders(t *testing.T) {
 		vf)
 }
 
+func TestParseMessageInvalidAppendsNothing(t *testing.T) {
+	theColTypes := []common.ColumnType{common.BigIntColumnType, common.TinyIntColumnType, common.VarcharColumnType}
+	selectors, err := compileSelectors([]string{"meta(\"key\").k0", "v1", "v2"})
+	require.NoError(t, err)
+	sourceInfo := &common.SourceInfo{
+		TableInfo: &common.TableInfo{
+			SchemaName:     "test",
+			Name:           "test_table",
+			PrimaryKeyCols: []int{0},
+			ColumnNames:    []string{"col0", "col1", "col2"},
+			ColumnTypes:    theColTypes,
+		},
+		OriginInfo: &common.SourceOriginInfo{
+			HeaderEncoding: common.KafkaEncodingJSON,
+			KeyEncoding:    common.KafkaEncodingJSON,
+			ValueEncoding:  common.KafkaEncodingJSON,
+			ColSelectors:   selectors,
+		},
+	}
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
+	require.NoError(t, err)
+	rows := common.NewRowsFactory(theColTypes).NewRows(2)
+
+	// The first column is valid but the second is out of range for a tinyint
+	_, err = mp.ParseMessage(&kafka.Message{Key: []byte(`{"k0":1}`), Value: []byte(`{"v1":1000,"v2":"foo"}`)}, rows)
+	require.Error(t, err)
+	require.Equal(t, 0, rows.RowCount())
+
+	numRows, err := mp.ParseMessage(&kafka.Message{Key: []byte(`{"k0":2}`), Value: []byte(`{"v1":10,"v2":"bar"}`)}, rows)
+	require.NoError(t, err)
+	require.Equal(t, 1, numRows)
+	require.Equal(t, 1, rows.RowCount())
+	row := rows.GetRow(0)
+	require.Equal(t, int64(2), row.GetInt64(0))
+	require.Equal(t, int64(10), row.GetInt64(1))
+	require.Equal(t, "bar", row.GetString(2))
+}
+
+func TestParseMessageExplodesArray(t *testing.T) {
+	theColTypes := []common.ColumnType{common.BigIntColumnType, common.VarcharColumnType, common.BigIntColumnType}
+	selectors, err := compileSelectors([]string{"order_id", "items[*].sku", "items[*].qty"})
+	require.NoError(t, err)
+	sourceInfo := &common.SourceInfo{
+		TableInfo: &common.TableInfo{
+			SchemaName:     "test",
+			Name:           "test_table",
+			PrimaryKeyCols: []int{0, 1},
+			ColumnNames:    []string{"order_id", "sku", "qty"},
+			ColumnTypes:    theColTypes,
+		},
+		OriginInfo: &common.SourceOriginInfo{
+			HeaderEncoding: common.KafkaEncodingJSON,
+			KeyEncoding:    common.KafkaEncodingJSON,
+			ValueEncoding:  common.KafkaEncodingJSON,
+			ColSelectors:   selectors,
+		},
+	}
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
+	require.NoError(t, err)
+
+	rows, err := mp.ParseMessages([]*kafka.Message{
+		{Value: []byte(`{"order_id":1,"items":[{"sku":"a","qty":2},{"sku":"b","qty":3}]}`)},
+		{Value: []byte(`{"order_id":2,"items":[]}`)},
+		{Value: []byte(`{"order_id":3}`)},
+		{Value: []byte(`{"order_id":4,"items":[{"sku":"c"}]}`)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, rows.RowCount())
+	expected := []struct {
+		orderID int64
+		sku     string
+		qty     int64
+	}{{1, "a", 2}, {1, "b", 3}, {4, "c", -1}}
+	for i, exp := range expected {
+		row := rows.GetRow(i)
+		require.Equal(t, exp.orderID, row.GetInt64(0))
+		require.Equal(t, exp.sku, row.GetString(1))
+		if exp.qty == -1 {
+			require.True(t, row.IsNull(2))
+		} else {
+			require.Equal(t, exp.qty, row.GetInt64(2))
+		}
+	}
+
+	_, err = mp.ParseMessages([]*kafka.Message{{Value: []byte(`{"order_id":5,"items":"a"}`)}})
+	require.Error(t, err)
+}
+
+func TestParseMessageComputedColumns(t *testing.T) {
+	theColTypes := []common.ColumnType{common.BigIntColumnType, common.BigIntColumnType, common.BigIntColumnType,
+		common.VarcharColumnType}
+	selectors, err := compileSelectors([]string{"a", "b", "s"})
+	require.NoError(t, err)
+	// The computed column is third, between the selected columns
+	selectors = append(selectors[:2], selector.ColumnSelector{}, selectors[2])
+	sourceInfo := &common.SourceInfo{
+		TableInfo: &common.TableInfo{
+			SchemaName:     "test",
+			Name:           "test_table",
+			PrimaryKeyCols: []int{0},
+			ColumnNames:    []string{"a", "b", "total", "s"},
+			ColumnTypes:    theColTypes,
+		},
+		OriginInfo: &common.SourceOriginInfo{
+			HeaderEncoding:  common.KafkaEncodingJSON,
+			KeyEncoding:     common.KafkaEncodingJSON,
+			ValueEncoding:   common.KafkaEncodingJSON,
+			ColSelectors:    selectors,
+			ComputedColumns: []string{"", "", "a + b", ""},
+		},
+	}
+	// The computed expressions are evaluated against the selected columns a, b and s
+	total, err := common.NewScalarFunctionExpression(common.BigIntColumnType, "plus",
+		common.NewColumnExpression(0, common.BigIntColumnType), common.NewColumnExpression(1, common.BigIntColumnType))
+	require.NoError(t, err)
+	computedExpressions := []*common.Expression{
+		common.NewColumnExpression(0, common.BigIntColumnType),
+		common.NewColumnExpression(1, common.BigIntColumnType),
+		total,
+		common.NewColumnExpression(2, common.VarcharColumnType),
+	}
+	mp, err := NewMessageParser(sourceInfo, computedExpressions, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
+	require.NoError(t, err)
+
+	rows, err := mp.ParseMessages([]*kafka.Message{
+		{Value: []byte(`{"a":1,"b":2,"s":"x"}`)},
+		{Value: []byte(`{"a":3,"s":"y"}`)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, rows.RowCount())
+	row := rows.GetRow(0)
+	require.Equal(t, int64(1), row.GetInt64(0))
+	require.Equal(t, int64(2), row.GetInt64(1))
+	require.Equal(t, int64(3), row.GetInt64(2))
+	require.Equal(t, "x", row.GetString(3))
+	row = rows.GetRow(1)
+	require.True(t, row.IsNull(1))
+	require.True(t, row.IsNull(2))
+	require.Equal(t, "y", row.GetString(3))
+}
+
+func TestParseMessageAppendKeys(t *testing.T) {
+	theColTypes := []common.ColumnType{common.VarcharColumnType, common.BigIntColumnType, common.BigIntColumnType}
+	selectors, err := compileSelectors([]string{"kind", "items[*]"})
+	require.NoError(t, err)
+	// The generated key is the last column, and has no selector
+	selectors = append(selectors, selector.ColumnSelector{})
+	sourceInfo := &common.SourceInfo{
+		TableInfo: &common.TableInfo{
+			SchemaName:     "test",
+			Name:           "test_table",
+			PrimaryKeyCols: []int{2},
+			ColumnNames:    []string{"kind", "item", common.AppendKeyColumnName},
+			ColumnTypes:    theColTypes,
+		},
+		OriginInfo: &common.SourceOriginInfo{
+			HeaderEncoding: common.KafkaEncodingJSON,
+			KeyEncoding:    common.KafkaEncodingJSON,
+			ValueEncoding:  common.KafkaEncodingJSON,
+			ColSelectors:   selectors,
+			AppendOnly:     true,
+		},
+	}
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
+	require.NoError(t, err)
+	mp.setNextAppendKey(100)
+
+	// The same message twice is two rows, and each element of an exploded array is a row with a key of its own
+	msg := &kafka.Message{Value: []byte(`{"kind":"click","items":[7]}`)}
+	rows, err := mp.ParseMessages([]*kafka.Message{msg, msg, {Value: []byte(`{"kind":"view","items":[8,9]}`)}})
+	require.NoError(t, err)
+	require.Equal(t, 4, rows.RowCount())
+	expected := []struct {
+		kind string
+		item int64
+	}{{"click", 7}, {"click", 7}, {"view", 8}, {"view", 9}}
+	for i, exp := range expected {
+		row := rows.GetRow(i)
+		require.Equal(t, exp.kind, row.GetString(0))
+		require.Equal(t, exp.item, row.GetInt64(1))
+		require.Equal(t, int64(100+i), row.GetInt64(2))
+	}
+
+	// A message which can't be parsed isn't given a key
+	rows = mp.rowsFactory.NewRows(1)
+	_, err = mp.ParseMessage(&kafka.Message{Value: []byte(`{"kind":"click","items":["x"]}`)}, rows)
+	require.Error(t, err)
+	_, err = mp.ParseMessage(msg, rows)
+	require.NoError(t, err)
+	require.Equal(t, 1, rows.RowCount())
+	row := rows.GetRow(0)
+	require.Equal(t, int64(104), row.GetInt64(2))
+}
+
+func TestParseMessageChangeEvents(t *testing.T) {
+	theColTypes := []common.ColumnType{common.BigIntColumnType, common.VarcharColumnType}
+	selectors, err := compileSelectors([]string{"id", "name"})
+	require.NoError(t, err)
+	sourceInfo := &common.SourceInfo{
+		TableInfo: &common.TableInfo{
+			SchemaName:     "test",
+			Name:           "test_table",
+			PrimaryKeyCols: []int{0},
+			ColumnNames:    []string{"id", "name"},
+			ColumnTypes:    theColTypes,
+		},
+		OriginInfo: &common.SourceOriginInfo{
+			HeaderEncoding: common.KafkaEncodingJSON,
+			KeyEncoding:    common.KafkaEncodingJSON,
+			ValueEncoding:  common.KafkaEncodingDebeziumJSON,
+			ColSelectors:   selectors,
+		},
+	}
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
+	require.NoError(t, err)
+
+	// An upsert is the row after the change
+	rows := mp.rowsFactory.NewRows(1)
+	numRows, err := mp.ParseMessage(&kafka.Message{Value: []byte(`{"before":{"id":1,"name":"foo"},"after":{"id":1,"name":"bar"},"op":"u"}`)}, rows)
+	require.NoError(t, err)
+	require.Equal(t, 1, numRows)
+	require.False(t, mp.deleted)
+	row := rows.GetRow(0)
+	require.Equal(t, int64(1), row.GetInt64(0))
+	require.Equal(t, "bar", row.GetString(1))
+
+	// A delete is the row before the change, which may only have its key
+	numRows, err = mp.ParseMessage(&kafka.Message{Value: []byte(`{"before":{"id":2},"after":null,"op":"d"}`)}, rows)
+	require.NoError(t, err)
+	require.Equal(t, 1, numRows)
+	require.True(t, mp.deleted)
+	row = rows.GetRow(1)
+	require.Equal(t, int64(2), row.GetInt64(0))
+	require.True(t, row.IsNull(1))
+
+	// A tombstone is nothing
+	numRows, err = mp.ParseMessage(&kafka.Message{Key: []byte(`{"id":2}`)}, rows)
+	require.NoError(t, err)
+	require.Equal(t, 0, numRows)
+	require.False(t, mp.deleted)
+	require.Equal(t, 2, rows.RowCount())
+}
+
 func compileSelectors(raw []string) ([]selector.ColumnSelector, error) {
 	cs := make([]selector.ColumnSelector, len(raw))
 	for i := range raw {
@@ -431,11 +690,11 @@ func testParseMessage(t *testing.T, colNames []string, colTypes []common.ColumnT
 		TableInfo:  tableInfo,
 		OriginInfo: topicInfo,
 	}
-	mp, err := NewMessageParser(sourceInfo, protolib.EmptyRegistry)
+	mp, err := NewMessageParser(sourceInfo, nil, protolib.EmptyRegistry, schemaregistry.EmptyRegistry)
 	require.NoError(t, err)
 
 	msg := &kafka.Message{
-		PartInfo:  kafka.PartInfo{},
+		PartInfo:  kafka.PartInfo{Topic: "test_topic", PartitionID: 7, Offset: 1234},
 		TimeStamp: timestamp,
 		Key:       keyBytes,
 		Value:     valueBytes,
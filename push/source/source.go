@@ -4,6 +4,8 @@ import (
 	"github.com/squareup/pranadb/kafka/load"
 	"github.com/squareup/pranadb/push/util"
 	"go.uber.org/ratelimit"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,6 +24,7 @@ import (
 	"github.com/squareup/pranadb/kafka"
 	"github.com/squareup/pranadb/protolib"
 	"github.com/squareup/pranadb/push/exec"
+	"github.com/squareup/pranadb/schemaregistry"
 	"github.com/squareup/pranadb/sharder"
 )
 
@@ -35,6 +38,11 @@ const (
 	pollTimeoutPropName           = "prana.source.polltimeoutms"
 	maxPollMessagesPropName       = "prana.source.maxpollmessages"
 	maxRatePropName               = "prana.source.maxingestrate"
+	topicRefreshPropName          = "prana.source.topicrefreshms"
+	defaultTopicRefreshMs         = 5000
+	ingestRateInterval            = time.Second
+	// The element of the exploded array a row was parsed from is held in 16 bits of its dedup key
+	maxElementsPerMessage = 1 << 16
 )
 
 type RowProcessor interface {
@@ -46,7 +54,14 @@ type Source struct {
 	sharder                 *sharder.Sharder
 	cluster                 cluster.Cluster
 	protoRegistry           protolib.Resolver
-	msgProvFact             kafka.MessageClient
+	schemaRegistry          schemaregistry.Registry
+	newClient               func(topicName string) (kafka.MessageClient, error)
+	listTopics              func() ([]string, error)
+	topicClients            map[string]kafka.MessageClient
+	topicPatterns           []*regexp.Regexp
+	topicRefreshInterval    time.Duration
+	topicRefreshTimer       *time.Timer
+	consumedTopics          map[string]struct{}
 	msgConsumers            []*MessageConsumer
 	queryExec               common.SimpleQueryExec
 	lock                    sync.Mutex
@@ -65,10 +80,24 @@ type Source struct {
 	ingestDurationHistogram metrics.Observer
 	ingestRowSizeHistogram  metrics.Observer
 	ingestExpressions       []*common.Expression
+	computedExpressions     []*common.Expression
 	cfg                     *conf.Config
 	restartTimer            *time.Timer
 	stopped                 bool // represents a hard stop - not a stop then a restart after delay
+	paused                  bool
 	lastUpdateIndexName     string
+	rowsIngested            int64
+	statsLock               sync.Mutex
+	rateIntervalStart       time.Time
+	rateIntervalRows        int64
+	ingestRate              float64
+	committedOffsets        map[string]map[int32]int64
+	messagesRejectedCounter metrics.Counter
+	deadLetterClient        kafka.MessageClient
+	deadLetterProducer      kafka.MessageProducer
+	statusListener          func()
+	// appendKeys hands out the keys of the rows of an append only source, or is nil
+	appendKeys *shardAppendKeys
 }
 
 var (
@@ -92,11 +121,16 @@ var (
 		Name: "pranadb_ingest_row_size",
 		Help: "histogram measuring size of ingested rows in bytes",
 	}, []string{"source"})
+	messagesRejectedVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pranadb_messages_rejected_total",
+		Help: "counter for number of messages which could not be ingested and were skipped or dead lettered, segmented by source name",
+	}, []string{"source"})
 )
 
-func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ingestExpressions []*common.Expression, sharder *sharder.Sharder,
+func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ingestExpressions []*common.Expression,
+	computedExpressions []*common.Expression, sharder *sharder.Sharder,
 	cluster cluster.Cluster, cfg *conf.Config, queryExec common.SimpleQueryExec, registry protolib.Resolver,
-	lastUpdateIndexName string) (*Source, error) {
+	schemaRegistry schemaregistry.Registry, lastUpdateIndexName string) (*Source, error) {
 	numConsumers, err := common.GetOrDefaultIntProperty(numConsumersPerSourcePropName, sourceInfo.OriginInfo.Properties, defaultNumConsumersPerSource)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -113,6 +147,10 @@ func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ing
 	if err != nil {
 		return nil, err
 	}
+	topicRefreshMs, err := common.GetOrDefaultIntProperty(topicRefreshPropName, sourceInfo.OriginInfo.Properties, defaultTopicRefreshMs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 
 	ti := sourceInfo.OriginInfo
 	var brokerConf conf.BrokerConfig
@@ -125,13 +163,16 @@ func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ing
 	}
 	props := util.CopyAndAddAllProperties(brokerConf.Properties, ti.Properties)
 	groupID := sourceInfo.OriginInfo.ConsumerGroupID
-	var msgProvFact kafka.MessageClient
+	// There's a client for each topic the source consumes from
+	var newClient func(topicName string) (kafka.MessageClient, error)
+	var listTopics func() ([]string, error)
 	switch brokerConf.ClientType {
 	case conf.BrokerClientFake:
-		var err error
-		msgProvFact, err = kafka.NewFakeMessageProviderFactory(ti.TopicName, props, groupID)
-		if err != nil {
-			return nil, errors.WithStack(err)
+		newClient = func(topicName string) (kafka.MessageClient, error) {
+			return kafka.NewFakeMessageProviderFactory(topicName, props, groupID)
+		}
+		listTopics = func() ([]string, error) {
+			return kafka.ListFakeTopics(props)
 		}
 	case conf.BrokerClientDefault:
 		// Remove the Prana properties - the Confluent client will choke on them otherwise
@@ -140,15 +181,52 @@ func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ing
 				delete(props, propName)
 			}
 		}
-		msgProvFact = kafka.NewMessageProviderFactory(ti.TopicName, props, groupID)
+		newClient = func(topicName string) (kafka.MessageClient, error) {
+			return kafka.NewMessageProviderFactory(topicName, props, groupID), nil
+		}
+		listTopics = func() ([]string, error) {
+			return kafka.ListTopics(props)
+		}
 	case conf.BrokerClientGenerator:
-		msgProvFact, err = load.NewMessageProviderFactory(10000, numConsumers, cluster.GetNodeID(), sourceInfo.OriginInfo.Properties)
+		msgProvFact, err := load.NewMessageProviderFactory(10000, numConsumers, cluster.GetNodeID(), sourceInfo.OriginInfo.Properties)
 		if err != nil {
 			return nil, err
 		}
+		// The generator makes up the messages rather than consuming them from a topic
+		newClient = func(topicName string) (kafka.MessageClient, error) {
+			return msgProvFact, nil
+		}
 	default:
 		return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Unsupported broker client type %d", brokerConf.ClientType)
 	}
+	topicClients := make(map[string]kafka.MessageClient)
+	var topicPatterns []*regexp.Regexp
+	for _, topicName := range ti.Topics() {
+		if !common.IsTopicPattern(topicName) {
+			client, err := newClient(topicName)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			topicClients[topicName] = client
+			continue
+		}
+		if listTopics == nil {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Topic patterns are not supported by broker %s", ti.BrokerName)
+		}
+		pattern, err := regexp.Compile(topicName)
+		if err != nil {
+			return nil, errors.NewPranaErrorf(errors.InvalidStatement, "Invalid topic pattern %s: %v", topicName, err)
+		}
+		topicPatterns = append(topicPatterns, pattern)
+	}
+
+	var deadLetterClient kafka.MessageClient
+	if ti.DeadLetterTopic != "" {
+		deadLetterClient, err = newDeadLetterClient(brokerConf, ti.DeadLetterTopic, props)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	rowsIngestedCounter := rowsIngestedVec.WithLabelValues(sourceInfo.Name)
 	batchesIngestedCounter := batchesIngestedVec.WithLabelValues(sourceInfo.Name)
@@ -161,7 +239,12 @@ func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ing
 		sharder:                 sharder,
 		cluster:                 cluster,
 		protoRegistry:           registry,
-		msgProvFact:             msgProvFact,
+		schemaRegistry:          schemaRegistry,
+		newClient:               newClient,
+		listTopics:              listTopics,
+		topicClients:            topicClients,
+		topicPatterns:           topicPatterns,
+		topicRefreshInterval:    time.Duration(topicRefreshMs) * time.Millisecond,
 		queryExec:               queryExec,
 		numConsumersPerSource:   numConsumers,
 		pollTimeoutMs:           pollTimeoutMs,
@@ -173,8 +256,19 @@ func NewSource(sourceInfo *common.SourceInfo, tableExec *exec.TableExecutor, ing
 		ingestDurationHistogram: ingestDurationHistogram,
 		ingestRowSizeHistogram:  ingestRowSizeHistogram,
 		ingestExpressions:       ingestExpressions,
+		computedExpressions:     computedExpressions,
 		cfg:                     cfg,
 		lastUpdateIndexName:     lastUpdateIndexName,
+		rateIntervalStart:       time.Now(),
+		committedOffsets:        make(map[string]map[int32]int64),
+		paused:                  sourceInfo.OriginInfo.Paused,
+		messagesRejectedCounter: messagesRejectedVec.WithLabelValues(sourceInfo.Name),
+		deadLetterClient:        deadLetterClient,
+	}
+	if sourceInfo.OriginInfo.AppendOnly {
+		source.appendKeys = newShardAppendKeys(func() (uint64, error) {
+			return cluster.GenerateClusterSequence(appendKeySequenceName)
+		})
 	}
 	var holder rlHolder
 	var rl ratelimit.Limiter
@@ -193,13 +287,20 @@ type rlHolder struct {
 	rl ratelimit.Limiter
 }
 
+// Start starts consuming from Kafka, unless the source is paused, in which case it is not started until it is resumed
 func (s *Source) Start() error {
+	defer s.statusChanged()
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if s.paused {
+		return nil
+	}
+	s.stopped = false
 	return s.start()
 }
 
 func (s *Source) Stop() error {
+	defer s.statusChanged()
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.stopped = true // hard stop - no restart
@@ -209,6 +310,54 @@ func (s *Source) Stop() error {
 	return s.stop()
 }
 
+// Pause stops the source consuming from Kafka. It won't be started again until Resume is called.
+func (s *Source) Pause() error {
+	defer s.statusChanged()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.paused = true
+	s.stopped = true
+	if s.restartTimer != nil {
+		s.restartTimer.Stop()
+	}
+	return s.stop()
+}
+
+// Resume starts a paused source consuming from Kafka again. A source which starts with the first materialized view
+// is left stopped if it has none yet.
+func (s *Source) Resume() error {
+	defer s.statusChanged()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.paused {
+		return nil
+	}
+	s.paused = false
+	if s.sourceInfo.OriginInfo.StartWithFirstMV && len(s.GetConsumingNodeNames()) == 0 {
+		return nil
+	}
+	s.stopped = false
+	return s.start()
+}
+
+// SetStatusListener sets a function which is called after the source is started, stopped, paused or resumed
+func (s *Source) SetStatusListener(listener func()) {
+	s.statusListener = listener
+}
+
+// statusChanged is deferred before the lock is taken, so the listener is called after it's released
+func (s *Source) statusChanged() {
+	if s.statusListener != nil {
+		s.statusListener()
+	}
+}
+
+func (s *Source) IsPaused() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.paused
+}
+
 func (s *Source) IsRunning() bool {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -231,6 +380,20 @@ func (s *Source) Drop() error {
 	if err := s.cluster.DeleteAllDataInRangeForAllShardsLocally(startPrefix, endPrefix); err != nil {
 		return errors.WithStack(err)
 	}
+	// Delete the indexes of the topics of the source
+	topicsPrefix := common.AppendUint64ToBufferBE(nil, common.SourceTopicsTableID)
+	topicsPrefix = common.KeyEncodeInt64(topicsPrefix, int64(s.sourceInfo.ID))
+	if err := s.cluster.DeleteAllDataInRangeForAllShardsLocally(topicsPrefix,
+		common.IncrementBytesBigEndian(topicsPrefix)); err != nil {
+		return errors.WithStack(err)
+	}
+	// Delete any dead letters, which are keyed on the source id
+	deadLetterPrefix := common.AppendUint64ToBufferBE(nil, common.DeadLettersTableID)
+	deadLetterPrefix = common.KeyEncodeInt64(deadLetterPrefix, int64(s.sourceInfo.ID))
+	if err := s.cluster.DeleteAllDataInRangeForAllShardsLocally(deadLetterPrefix,
+		common.IncrementBytesBigEndian(deadLetterPrefix)); err != nil {
+		return errors.WithStack(err)
+	}
 	if s.sourceInfo.RetentionDuration != 0 {
 		// Delete any last update index data
 		indexStartPrefix := common.AppendUint64ToBufferBE(nil, s.sourceInfo.RowTimeIndexID)
@@ -239,12 +402,85 @@ func (s *Source) Drop() error {
 			return err
 		}
 	}
+	if s.sourceInfo.VersionRetentionDuration != 0 {
+		// Delete any prior versions of rows
+		versionStartPrefix := common.AppendUint64ToBufferBE(nil, s.sourceInfo.VersionTableID)
+		versionEndPrefix := common.AppendUint64ToBufferBE(nil, s.sourceInfo.VersionTableID+1)
+		if err := s.cluster.DeleteAllDataInRangeForAllShardsLocally(versionStartPrefix, versionEndPrefix); err != nil {
+			return err
+		}
+	}
 	// Delete the table data
 	tableStartPrefix := common.AppendUint64ToBufferBE(nil, s.sourceInfo.ID)
 	tableEndPrefix := common.AppendUint64ToBufferBE(nil, s.sourceInfo.ID+1)
 	return s.cluster.DeleteAllDataInRangeForAllShardsLocally(tableStartPrefix, tableEndPrefix)
 }
 
+// Alter changes the columns of the source, once its rows have been migrated to the new columns. The source must not be
+// running.
+func (s *Source) Alter(sourceInfo *common.SourceInfo, ingestExpressions []*common.Expression,
+	computedExpressions []*common.Expression) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.started {
+		return errors.Errorf("cannot alter source %s.%s while it is running", s.sourceInfo.SchemaName, s.sourceInfo.Name)
+	}
+	s.sourceInfo = sourceInfo
+	s.ingestExpressions = ingestExpressions
+	s.computedExpressions = computedExpressions
+	s.tableExecutor.SetTableInfo(sourceInfo.TableInfo)
+	return nil
+}
+
+// Rename changes the name of the source. Its columns are unchanged, so unlike Alter it can be done while the source
+// is running.
+func (s *Source) Rename(sourceInfo *common.SourceInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sourceInfo = sourceInfo
+	s.tableExecutor.SetTableInfo(sourceInfo.TableInfo)
+}
+
+// ResetOffsets resets the offsets of the consumer group of the source in Kafka. The source must be stopped on every
+// node, so nothing in the group is consuming.
+func (s *Source) ResetOffsets(reset *kafka.OffsetsReset) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.started {
+		return errors.Errorf("cannot reset offsets of source %s.%s while it is running", s.sourceInfo.SchemaName, s.sourceInfo.Name)
+	}
+	topicNames, err := s.getTopicNames()
+	if err != nil {
+		return err
+	}
+	for _, topicName := range topicNames {
+		client, err := s.getTopicClient(topicName)
+		if err != nil {
+			return err
+		}
+		msgProvider, err := client.NewMessageProvider()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := msgProvider.ResetOffsets(reset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OffsetsReset is called once the offsets of the source have been reset, with the source info which records the reset.
+// The offsets committed before the reset no longer say where the source is consuming from so they are forgotten.
+func (s *Source) OffsetsReset(sourceInfo *common.SourceInfo) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sourceInfo = sourceInfo
+	s.tableExecutor.SetTableInfo(sourceInfo.TableInfo)
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	s.committedOffsets = make(map[string]map[int32]int64)
+}
+
 func (s *Source) AddConsumingNode(mvName string, executor exec.PushExecutor) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -331,27 +567,148 @@ func (s *Source) start() error {
 		panic("more than zero consumers!")
 	}
 
+	if err := s.startDeadLetterProducer(); err != nil {
+		return err
+	}
+
+	topicNames, err := s.getTopicNames()
+	if err != nil {
+		return err
+	}
+	s.consumedTopics = make(map[string]struct{}, len(topicNames))
+	for _, topicName := range topicNames {
+		if err := s.startConsumers(topicName); err != nil {
+			return err
+		}
+	}
+
+	s.started = true
+	s.scheduleTopicRefresh()
+	return nil
+}
+
+// startConsumers starts the consumers of one of the topics the source consumes from. Each topic has its own consumers,
+// so the offsets they commit, and the partitions assigned to them when the consumer group rebalances, are for one
+// topic.
+func (s *Source) startConsumers(topicName string) error {
+	client, err := s.getTopicClient(topicName)
+	if err != nil {
+		return err
+	}
+	topicIndex, err := s.getTopicIndex(topicName)
+	if err != nil {
+		return err
+	}
 	for i := 0; i < s.numConsumersPerSource; i++ {
-		msgProvider, err := s.msgProvFact.NewMessageProvider()
+		msgProvider, err := client.NewMessageProvider()
 		if err != nil {
 			return errors.WithStack(err)
 		}
-		consumer, err := NewMessageConsumer(msgProvider, time.Duration(s.pollTimeoutMs)*time.Millisecond,
-			s.maxPollMessages, s)
+		consumer, err := NewMessageConsumer(msgProvider, topicName, topicIndex,
+			time.Duration(s.pollTimeoutMs)*time.Millisecond, s.maxPollMessages, s)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 		s.msgConsumers = append(s.msgConsumers, consumer)
 	}
-
-	s.started = true
+	s.consumedTopics[topicName] = struct{}{}
 	return nil
 }
 
+func (s *Source) getTopicClient(topicName string) (kafka.MessageClient, error) {
+	client, ok := s.topicClients[topicName]
+	if !ok {
+		var err error
+		client, err = s.newClient(topicName)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		s.topicClients[topicName] = client
+	}
+	return client, nil
+}
+
+// getTopicNames returns the topics the source consumes from, with its topic patterns replaced by the topics whose names
+// match them
+func (s *Source) getTopicNames() ([]string, error) {
+	var topicNames []string
+	names := make(map[string]struct{})
+	for _, topicName := range s.sourceInfo.OriginInfo.Topics() {
+		if !common.IsTopicPattern(topicName) {
+			topicNames = append(topicNames, topicName)
+			names[topicName] = struct{}{}
+		}
+	}
+	if len(s.topicPatterns) == 0 {
+		return topicNames, nil
+	}
+	allTopics, err := s.listTopics()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sort.Strings(allTopics)
+	for _, topicName := range allTopics {
+		if _, ok := names[topicName]; ok || topicName == s.sourceInfo.OriginInfo.DeadLetterTopic {
+			// The source mustn't consume the messages it dead letters
+			continue
+		}
+		for _, pattern := range s.topicPatterns {
+			if pattern.MatchString(topicName) {
+				topicNames = append(topicNames, topicName)
+				names[topicName] = struct{}{}
+				break
+			}
+		}
+	}
+	return topicNames, nil
+}
+
+// scheduleTopicRefresh schedules looking for topics which have been created since the source started and match its
+// topic patterns, so it starts consuming from them
+func (s *Source) scheduleTopicRefresh() {
+	if len(s.topicPatterns) == 0 {
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(s.topicRefreshInterval, func() {
+		s.refreshTopics(timer)
+	})
+	s.topicRefreshTimer = timer
+}
+
+func (s *Source) refreshTopics(timer *time.Timer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.started || s.topicRefreshTimer != timer {
+		// The source has stopped, and maybe started again with a new timer, since the timer fired
+		return
+	}
+	topicNames, err := s.getTopicNames()
+	if err != nil {
+		log.Warnf("Failed to get topics for source %s.%s: %+v", s.sourceInfo.SchemaName, s.sourceInfo.Name, err)
+	}
+	for _, topicName := range topicNames {
+		if _, ok := s.consumedTopics[topicName]; ok {
+			continue
+		}
+		log.Infof("Source %s.%s starting to consume from topic %s", s.sourceInfo.SchemaName, s.sourceInfo.Name, topicName)
+		if err := s.startConsumers(topicName); err != nil {
+			log.Warnf("Failed to start consuming from topic %s for source %s.%s: %+v", topicName,
+				s.sourceInfo.SchemaName, s.sourceInfo.Name, err)
+			break
+		}
+	}
+	s.scheduleTopicRefresh()
+}
+
 func (s *Source) stop() error {
 	if !s.started {
 		return nil
 	}
+	if s.topicRefreshTimer != nil {
+		s.topicRefreshTimer.Stop()
+		s.topicRefreshTimer = nil
+	}
 	for _, consumer := range s.msgConsumers {
 		if err := consumer.Stop(); err != nil {
 			return errors.WithStack(err)
@@ -364,14 +721,26 @@ func (s *Source) stop() error {
 	}
 	s.msgConsumers = nil
 	s.started = false
-	return nil
+	// The consumers have stopped, so nothing is still sending to the dead letter topic
+	return s.stopDeadLetterProducer()
 }
 
-func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) error {
+func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser, topicIndex uint16) error {
 
 	start := time.Now()
-	rows, err := mp.ParseMessages(messages)
-
+	offsetsResets := s.sourceInfo.OriginInfo.OffsetsResets
+	rejected := &rejectedMessages{policy: s.sourceInfo.OriginInfo.ErrorPolicy}
+	var rows *common.Rows
+	var sources []rowSource
+	var err error
+	if s.appendKeys != nil {
+		var keys map[uint64]*appendKeys
+		keys, rows, sources, err = s.parseAppendOnlyMessages(messages, mp, topicIndex, offsetsResets, rejected)
+		// The keys of the shards are held until the batch has been sent
+		defer s.appendKeys.unlockShards(keys)
+	} else {
+		rows, sources, err = s.parseMessages(messages, mp, rejected)
+	}
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -396,30 +765,19 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 
 		log.Debugf("source %s.%s ingesting row %s", s.sourceInfo.SchemaName, s.sourceInfo.Name, row.String())
 
-		filtered := false
-		if s.ingestExpressions != nil {
-			// We filter out any rows which don't match the optional ingest filter
-			for _, predicate := range s.ingestExpressions {
-				accept, isNull, err := predicate.EvalBoolean(&row)
-				if err != nil {
-					return errors.WithStack(err)
-				}
-				if isNull {
-					return errors.Error("null returned from evaluating select predicate")
-				}
-				if !accept {
-					filtered = true
-					break
+		kMsg := sources[i].message
+		// The row deleted by a change event may only have its key, so the ingest filter can't be evaluated against
+		// it. Deleting a row which was filtered out does nothing.
+		if !sources[i].delete {
+			accept, err := s.acceptRow(&row)
+			if err != nil {
+				if err := rejected.add(kMsg, err); err != nil {
+					return err
 				}
+				continue
 			}
-		}
-		if filtered {
-			continue
-		}
-
-		for _, pkCol := range s.sourceInfo.PrimaryKeyCols {
-			if row.IsNull(pkCol) {
-				return errors.New("cannot ingest message, null value in PK col(s)")
+			if !accept {
+				continue
 			}
 		}
 
@@ -428,14 +786,27 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 		}
 
 		key := make([]byte, 0, 8)
-		key, err := common.EncodeKeyCols(&row, pkCols, colTypes, key)
+		key, err = common.EncodeKeyCols(&row, pkCols, colTypes, key)
 		if err != nil {
 			return errors.WithStack(err)
 		}
 
-		destShardID, err := s.sharder.CalculateShard(sharder.ShardTypeHash, key)
+		dedupID, err := dedupPartitionID(topicIndex, kMsg.PartInfo.PartitionID, offsetsResets, sources[i].element)
 		if err != nil {
-			return errors.WithStack(err)
+			return err
+		}
+
+		var destShardID uint64
+		if s.appendKeys != nil {
+			destShardID, err = s.partitionShardID(topicIndex, kMsg.PartInfo.PartitionID, offsetsResets)
+			if err != nil {
+				return err
+			}
+		} else {
+			destShardID, err = s.sharder.CalculateShard(sharder.ShardTypeHash, key)
+			if err != nil {
+				return errors.WithStack(err)
+			}
 		}
 
 		forwardBatch, ok := forwardBatches[destShardID]
@@ -444,9 +815,7 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 			forwardBatches[destShardID] = forwardBatch
 		}
 
-		kMsg := messages[i]
-		forwardKey := util.EncodeKeyForForwardIngest(tableID, uint64(kMsg.PartInfo.PartitionID),
-			uint64(kMsg.PartInfo.Offset+1), tableID)
+		forwardKey := util.EncodeKeyForForwardIngest(tableID, dedupID, uint64(kMsg.PartInfo.Offset+1), tableID)
 
 		valueBuff := make([]byte, 0, 32)
 		var encodedRow []byte
@@ -455,7 +824,11 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 			return err
 		}
 
-		forwardBatch.AddPut(forwardKey, util.EncodePrevAndCurrentRow(nil, encodedRow))
+		if sources[i].delete {
+			forwardBatch.AddPut(forwardKey, util.EncodePrevAndCurrentRow(encodedRow, nil))
+		} else {
+			forwardBatch.AddPut(forwardKey, util.EncodePrevAndCurrentRow(nil, encodedRow))
+		}
 
 		l := len(valueBuff)
 		totBatchSizeBytes += l
@@ -463,6 +836,10 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 		rowsIngested++
 	}
 
+	if err := s.deadLetter(rejected); err != nil {
+		return err
+	}
+
 	if err := util.SendForwardBatches(forwardBatches, s.cluster, false, false); err != nil {
 		log.Errorf("failed to ingest forward batches %+v", err)
 		return err
@@ -471,6 +848,7 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 	ingestTimeNanos := time.Now().Sub(start).Nanoseconds()
 	s.ingestDurationHistogram.Observe(float64(ingestTimeNanos))
 	s.rowsIngestedCounter.Add(float64(rowsIngested))
+	s.addRowsIngested(int64(rowsIngested))
 	s.batchesIngestedCounter.Add(1)
 	s.bytesIngestedCounter.Add(float64(totBatchSizeBytes))
 
@@ -479,10 +857,173 @@ func (s *Source) ingestMessages(messages []*kafka.Message, mp *MessageParser) er
 	return nil
 }
 
+// parseAppendOnlyMessages parses the messages of an append only source into rows, giving the rows sent to each shard
+// keys from that shard's blocks. It returns the keys of the shards locked, even if parsing fails.
+func (s *Source) parseAppendOnlyMessages(messages []*kafka.Message, mp *MessageParser, topicIndex uint16,
+	offsetsResets uint32, rejected *rejectedMessages) (map[uint64]*appendKeys, *common.Rows, []rowSource, error) {
+	var shardIDs []uint64
+	shardMessages := make(map[uint64][]*kafka.Message)
+	for _, msg := range messages {
+		shardID, err := s.partitionShardID(topicIndex, msg.PartInfo.PartitionID, offsetsResets)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if _, ok := shardMessages[shardID]; !ok {
+			shardIDs = append(shardIDs, shardID)
+		}
+		shardMessages[shardID] = append(shardMessages[shardID], msg)
+	}
+	keys := s.appendKeys.lockShards(shardIDs)
+	rows := mp.rowsFactory.NewRows(len(messages))
+	sources := make([]rowSource, 0, len(messages))
+	for _, shardID := range shardIDs {
+		msgs := shardMessages[shardID]
+		shardKeys := keys[shardID]
+		// Each message is parsed into at most maxElementsPerMessage rows
+		nextKey, err := shardKeys.reserve(int64(len(msgs)) * maxElementsPerMessage)
+		if err != nil {
+			return keys, nil, nil, errors.Errorf("failed to reserve append keys for source %s.%s: %v",
+				s.sourceInfo.SchemaName, s.sourceInfo.Name, err)
+		}
+		mp.setNextAppendKey(nextKey)
+		shardRows, shardSources, err := s.parseMessages(msgs, mp, rejected)
+		// The keys are never given again, even if the batch fails
+		shardKeys.used(mp.nextAppendKey)
+		if err != nil {
+			return keys, nil, nil, err
+		}
+		rows.AppendAll(shardRows)
+		sources = append(sources, shardSources...)
+	}
+	return keys, rows, sources, nil
+}
+
+// partitionShardID returns the shard the rows of an append only source ingested from a partition are sent to. A
+// message which is consumed again is given new keys, so the rows are sharded on the partition they were ingested from
+// rather than on their keys, so duplicates always reach the shard which has already seen their offsets.
+func (s *Source) partitionShardID(topicIndex uint16, partitionID int32, offsetsResets uint32) (uint64, error) {
+	partitionDedupID, err := dedupPartitionID(topicIndex, partitionID, offsetsResets, 0)
+	if err != nil {
+		return 0, err
+	}
+	shardID, err := s.sharder.CalculateShard(sharder.ShardTypeHash, common.AppendUint64ToBufferBE(nil, partitionDedupID))
+	return shardID, errors.WithStack(err)
+}
+
+// dedupPartitionID returns the partition id used in the dedup key of rows ingested from the partition, which includes
+// the index of the topic of the partition, the number of times the offsets of the source have been reset, and the
+// element of the exploded array the row was parsed from. Rows parsed from different elements of the same message have
+// the same offset, so they need different originator ids for none of them to be discarded as duplicates. The partition
+// id and the number of resets must fit in 16 bits, as truncating them would give different partitions, or the same
+// partition before and after a reset, the same originator id.
+func dedupPartitionID(topicIndex uint16, partitionID int32, offsetsResets uint32, element int) (uint64, error) {
+	if partitionID < 0 || partitionID > maxPartitionID {
+		return 0, errors.Errorf("cannot ingest from partition %d, partition ids greater than %d are not supported",
+			partitionID, maxPartitionID)
+	}
+	if offsetsResets > MaxOffsetsResets {
+		return 0, errors.Errorf("cannot ingest after the offsets have been reset %d times, no more than %d resets are supported",
+			offsetsResets, MaxOffsetsResets)
+	}
+	return uint64(element)<<48 | uint64(offsetsResets)<<32 | uint64(topicIndex)<<16 | uint64(partitionID), nil
+}
+
+// acceptRow returns false if the row is filtered out by the ingest filter, or an error if it can't be ingested
+func (s *Source) acceptRow(row *common.Row) (bool, error) {
+	// We filter out any rows which don't match the optional ingest filter
+	for _, predicate := range s.ingestExpressions {
+		accept, isNull, err := predicate.EvalBoolean(row)
+		if err != nil {
+			return false, errors.WithStack(err)
+		}
+		if isNull {
+			return false, errors.Error("null returned from evaluating select predicate")
+		}
+		if !accept {
+			return false, nil
+		}
+	}
+	for _, pkCol := range s.sourceInfo.PrimaryKeyCols {
+		if row.IsNull(pkCol) {
+			return false, errors.New("cannot ingest message, null value in PK col(s)")
+		}
+	}
+	return true, nil
+}
+
 func (s *Source) TableExecutor() *exec.TableExecutor {
 	return s.tableExecutor
 }
 
+// Info returns the current definition of the source, which changes if the source is altered
+func (s *Source) Info() *common.SourceInfo {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.sourceInfo
+}
+
+func (s *Source) addRowsIngested(rows int64) {
+	atomic.AddInt64(&s.rowsIngested, rows)
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	s.updateIngestRate(time.Now())
+	s.rateIntervalRows += rows
+}
+
+// updateIngestRate calculates the ingest rate once the current interval has passed and starts a new one. The rate is
+// over the interval since the last calculation, so after a quiet period it drops to zero.
+func (s *Source) updateIngestRate(now time.Time) {
+	elapsed := now.Sub(s.rateIntervalStart)
+	if elapsed < ingestRateInterval {
+		return
+	}
+	s.ingestRate = float64(s.rateIntervalRows) / elapsed.Seconds()
+	s.rateIntervalStart = now
+	s.rateIntervalRows = 0
+}
+
+// GetRowsIngested returns the number of rows the source has ingested on this node since it was started
+func (s *Source) GetRowsIngested() int64 {
+	return atomic.LoadInt64(&s.rowsIngested)
+}
+
+// GetIngestRate returns the rate at which the source ingested rows on this node, in rows per second, over the most
+// recent interval
+func (s *Source) GetIngestRate() float64 {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	s.updateIngestRate(time.Now())
+	return s.ingestRate
+}
+
+func (s *Source) setCommittedOffsets(topicName string, offsets map[int32]int64) {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	topicOffsets, ok := s.committedOffsets[topicName]
+	if !ok {
+		topicOffsets = make(map[int32]int64, len(offsets))
+		s.committedOffsets[topicName] = topicOffsets
+	}
+	for partID, offset := range offsets {
+		topicOffsets[partID] = offset
+	}
+}
+
+// GetCommittedOffsets returns the offsets last committed by the consumers of the source on this node, for each topic
+// and partition they have consumed from
+func (s *Source) GetCommittedOffsets() map[string]map[int32]int64 {
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	offsets := make(map[string]map[int32]int64, len(s.committedOffsets))
+	for topicName, topicOffsets := range s.committedOffsets {
+		offsets[topicName] = make(map[int32]int64, len(topicOffsets))
+		for partID, offset := range topicOffsets {
+			offsets[topicName][partID] = offset
+		}
+	}
+	return offsets
+}
+
 func (s *Source) addCommittedCount(val int64) {
 	atomic.AddInt64(&s.committedCount, val)
 }
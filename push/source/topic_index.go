@@ -0,0 +1,115 @@
+package source
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/table"
+)
+
+const (
+	// The index of a topic is held in 16 bits of the dedup key of the rows ingested from it, as is its partition id
+	maxTopicsPerSource    = 1 << 16
+	maxPartitionID        = 1<<16 - 1
+	topicIndexLockTimeout = 30 * time.Second
+	topicIndexLockDelay   = 100 * time.Millisecond
+)
+
+// MaxOffsetsResets is the number of times the offsets of a source can be reset, as the number of resets is held in 16
+// bits of the dedup key too. Were it to wrap, rows consumed again would be discarded as duplicates of rows ingested
+// before an earlier reset.
+const MaxOffsetsResets = 1<<16 - 1
+
+// getTopicIndex returns the index of one of the topics of the source, which distinguishes the partitions of its topics
+// in the dedup keys of the rows ingested from them. A source which consumes from one topic doesn't need it, so it's
+// zero. Otherwise the topics are given indexes 0..n-1 in the order the source first consumes from them, which are
+// persisted so every node uses the same index for a topic, before and after a restart, and as the topics which match
+// a topic pattern aren't known up front.
+func (s *Source) getTopicIndex(topicName string) (uint16, error) {
+	if s.sourceInfo.OriginInfo.TopicName != "" {
+		return 0, nil
+	}
+	key := encodeTopicIndexKey(s.sourceInfo.ID, topicName)
+	if index, ok, err := s.getPersistedTopicIndex(key); err != nil || ok {
+		return index, err
+	}
+	// The topic hasn't been given an index yet. Another node may be giving it, or another topic, one at the same time,
+	// so the next index is taken with the lock held.
+	lockName := fmt.Sprintf("/source_topics/%d/", s.sourceInfo.ID)
+	if err := s.getTopicIndexLock(lockName); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if _, err := s.cluster.ReleaseLock(lockName); err != nil {
+			log.Errorf("failed to release topic index lock %+v", err)
+		}
+	}()
+	if index, ok, err := s.getPersistedTopicIndex(key); err != nil || ok {
+		return index, err
+	}
+	countKey := encodeTopicIndexKey(s.sourceInfo.ID, "")
+	count, err := s.cluster.LinearizableGet(cluster.SystemSchemaShardID, countKey)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	var next uint64
+	if count != nil {
+		next, _ = common.ReadUint64FromBufferLE(count, 0)
+	}
+	if next >= maxTopicsPerSource {
+		return 0, errors.Errorf("source %s.%s cannot consume from more than %d topics", s.sourceInfo.SchemaName,
+			s.sourceInfo.Name, maxTopicsPerSource)
+	}
+	wb := cluster.NewWriteBatch(cluster.SystemSchemaShardID)
+	wb.AddPut(key, common.AppendUint64ToBufferLE(nil, next))
+	wb.AddPut(countKey, common.AppendUint64ToBufferLE(nil, next+1))
+	if err := s.cluster.WriteBatch(wb, false); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return uint16(next), nil
+}
+
+func (s *Source) getPersistedTopicIndex(key []byte) (uint16, bool, error) {
+	v, err := s.cluster.LinearizableGet(cluster.SystemSchemaShardID, key)
+	if err != nil {
+		return 0, false, errors.WithStack(err)
+	}
+	if v == nil {
+		return 0, false, nil
+	}
+	index, _ := common.ReadUint64FromBufferLE(v, 0)
+	return uint16(index), true, nil
+}
+
+func (s *Source) getTopicIndexLock(lockName string) error {
+	start := time.Now()
+	for {
+		ok, err := s.cluster.GetLock(lockName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if ok {
+			return nil
+		}
+		if time.Now().Sub(start) > topicIndexLockTimeout {
+			return errors.Errorf("timed out waiting to assign topic indexes for source %s.%s", s.sourceInfo.SchemaName,
+				s.sourceInfo.Name)
+		}
+		time.Sleep(topicIndexLockDelay)
+	}
+}
+
+// encodeTopicIndexKey encodes the key of the index of a topic of a source. The number of topics which have been given
+// an index is held under the key with no topic name, as a topic name can't be empty.
+func encodeTopicIndexKey(sourceID uint64, topicName string) []byte {
+	key := table.EncodeTableKeyPrefix(common.SourceTopicsTableID, cluster.SystemSchemaShardID, 40)
+	key = common.KeyEncodeInt64(key, int64(sourceID))
+	if topicName != "" {
+		key = common.KeyEncodeString(key, topicName)
+	}
+	return key
+}
@@ -0,0 +1,66 @@
+package source
+
+import (
+	"testing"
+
+	"github.com/squareup/pranadb/cluster/fake"
+	"github.com/squareup/pranadb/common"
+	"github.com/stretchr/testify/require"
+)
+
+func newTopicIndexTestSource(clust *fake.FakeCluster, originInfo *common.SourceOriginInfo) *Source {
+	return &Source{
+		sourceInfo: &common.SourceInfo{
+			TableInfo:  &common.TableInfo{ID: 1000, SchemaName: "test", Name: "source1"},
+			OriginInfo: originInfo,
+		},
+		cluster: clust,
+	}
+}
+
+func TestTopicIndexes(t *testing.T) {
+	clust := fake.NewFakeCluster(0, 10)
+	originInfo := &common.SourceOriginInfo{TopicNames: []string{"payments", "^refunds-.*"}}
+	s := newTopicIndexTestSource(clust, originInfo)
+	for i, topicName := range []string{"payments", "refunds-eu", "refunds-us"} {
+		index, err := s.getTopicIndex(topicName)
+		require.NoError(t, err)
+		require.Equal(t, uint16(i), index)
+	}
+	// The indexes are persisted, so another node, or the same node after a restart, sees the same ones
+	other := newTopicIndexTestSource(clust, originInfo)
+	for i, topicName := range []string{"refunds-us", "refunds-uk", "payments", "refunds-eu"} {
+		index, err := other.getTopicIndex(topicName)
+		require.NoError(t, err)
+		require.Equal(t, []uint16{2, 3, 0, 1}[i], index)
+	}
+	// The lock is released once an index has been given
+	ok, err := clust.GetLock("/source_topics/1000/")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// A source with one topic doesn't persist an index
+	single := newTopicIndexTestSource(fake.NewFakeCluster(0, 10), &common.SourceOriginInfo{TopicName: "payments"})
+	index, err := single.getTopicIndex("payments")
+	require.NoError(t, err)
+	require.Equal(t, uint16(0), index)
+}
+
+func TestDedupPartitionID(t *testing.T) {
+	id, err := dedupPartitionID(3, 7, 2, 1)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1)<<48|uint64(2)<<32|uint64(3)<<16|7, id)
+	_, err = dedupPartitionID(0, maxPartitionID, 0, 0)
+	require.NoError(t, err)
+	// Partition ids which don't fit in the dedup key are rejected rather than truncated
+	_, err = dedupPartitionID(0, maxPartitionID+1, 0, 0)
+	require.Error(t, err)
+	_, err = dedupPartitionID(0, -1, 0, 0)
+	require.Error(t, err)
+	// As is a number of resets which would wrap around to the originator id of an earlier reset
+	id, err = dedupPartitionID(0, 7, MaxOffsetsResets, 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(MaxOffsetsResets)<<32|7, id)
+	_, err = dedupPartitionID(0, 7, MaxOffsetsResets+1, 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package push
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/push/source"
+	"github.com/squareup/pranadb/table"
+)
+
+// Every node consumes from every source, so each node reports the status of its own consumers. The reports are
+// written to a shard chosen for the node, keyed on the source and the node, so they're scanned by whichever node leads
+// the shard, and a node reports even if it leads no shards. A report is only rewritten when what it reports has
+// changed, which is checked periodically and when one of its sources is started, stopped, paused or resumed, or when
+// the heartbeat is due, so a node which is still running keeps its reports from expiring.
+
+const (
+	sourceStatusReportInterval = 10 * time.Second
+	sourceStatusHeartbeat      = time.Minute
+	// A report which hasn't been rewritten for this long is from a node which has stopped, so it's ignored
+	sourceStatusExpiry = 3 * sourceStatusHeartbeat
+)
+
+type sourceStatusReport struct {
+	row        []byte
+	reportTime int64
+}
+
+type sourceStatusReporter struct {
+	lock       sync.Mutex
+	started    bool
+	cluster    cluster.Cluster
+	nodeID     int
+	getSources func() []*source.Source
+	// reported has the last report the node wrote for each of its sources
+	reported  map[uint64]sourceStatusReport
+	runTimer  *time.Timer
+	nowMicros func() int64
+}
+
+func newSourceStatusReporter(clust cluster.Cluster, nodeID int, getSources func() []*source.Source) *sourceStatusReporter {
+	return &sourceStatusReporter{
+		cluster:    clust,
+		nodeID:     nodeID,
+		getSources: getSources,
+		reported:   make(map[uint64]sourceStatusReport),
+		nowMicros: func() int64 {
+			return time.Now().UnixMicro()
+		},
+	}
+}
+
+func (r *sourceStatusReporter) start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.started {
+		return
+	}
+	r.started = true
+	r.scheduleRun()
+}
+
+func (r *sourceStatusReporter) stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.started {
+		return
+	}
+	r.started = false
+	if r.runTimer != nil {
+		r.runTimer.Stop()
+		r.runTimer = nil
+	}
+}
+
+func (r *sourceStatusReporter) scheduleRun() {
+	r.runTimer = time.AfterFunc(sourceStatusReportInterval, func() {
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		if !r.started {
+			return
+		}
+		if err := r.reportSources(r.getSources()); err != nil {
+			log.Warnf("failed to report status of sources %+v", err)
+		}
+		r.scheduleRun()
+	})
+}
+
+// reportSource reports the status of a source after it changes, so it's seen without waiting for the next report
+func (r *sourceStatusReporter) reportSource(src *source.Source) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.started {
+		return nil
+	}
+	// The source may have been removed already
+	for _, s := range r.getSources() {
+		if s == src {
+			return r.reportSources([]*source.Source{src})
+		}
+	}
+	return nil
+}
+
+// reportSources rewrites the reports of the sources whose status has changed since they were last reported, or whose
+// heartbeat is due
+func (r *sourceStatusReporter) reportSources(sources []*source.Source) error {
+	shardID := r.reportShardID()
+	wb := cluster.NewWriteBatch(shardID)
+	now := r.nowMicros()
+	rows := sourcesTableRowsFactory.NewRows(len(sources))
+	reports := make(map[uint64]sourceStatusReport, len(sources))
+	for i, src := range sources {
+		appendSourceStatus(src, r.nodeID, rows)
+		row := rows.GetRow(i)
+		encoded, err := common.EncodeRow(&row, meta.SourcesTableInfo.ColumnTypes, nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		prev, ok := r.reported[src.Info().ID]
+		if ok && bytes.Equal(prev.row, encoded) && now-prev.reportTime < sourceStatusHeartbeat.Microseconds() {
+			continue
+		}
+		value := common.AppendUint64ToBufferLE(make([]byte, 0, 8+len(encoded)), uint64(now))
+		wb.AddPut(encodeSourceStatusKey(shardID, src.Info().ID, r.nodeID), append(value, encoded...))
+		reports[src.Info().ID] = sourceStatusReport{row: encoded, reportTime: now}
+	}
+	if len(reports) == 0 {
+		return nil
+	}
+	if err := r.cluster.WriteBatch(wb, false); err != nil {
+		return errors.WithStack(err)
+	}
+	for sourceID, report := range reports {
+		r.reported[sourceID] = report
+	}
+	return nil
+}
+
+// remove deletes the reports for a source which has been removed from the node. Every node removes the source, but a
+// node which has stopped can't delete its own report, so the reports of every node are deleted locally too.
+func (r *sourceStatusReporter) remove(sourceID uint64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if _, ok := r.reported[sourceID]; ok {
+		shardID := r.reportShardID()
+		wb := cluster.NewWriteBatch(shardID)
+		wb.AddDelete(encodeSourceStatusKey(shardID, sourceID, r.nodeID))
+		if err := r.cluster.WriteBatch(wb, false); err != nil {
+			return errors.WithStack(err)
+		}
+		delete(r.reported, sourceID)
+	}
+	startPrefix := common.AppendUint64ToBufferBE(nil, common.SourceStatusTableID)
+	startPrefix = common.AppendUint64ToBufferBE(startPrefix, sourceID)
+	return r.cluster.DeleteAllDataInRangeForAllShardsLocally(startPrefix, common.IncrementBytesBigEndian(startPrefix))
+}
+
+// reportShardID returns the shard the node writes its reports to. The nodes write to different shards, so the
+// reports are spread across the cluster.
+func (r *sourceStatusReporter) reportShardID() uint64 {
+	shardIDs := append([]uint64(nil), r.cluster.GetAllShardIDs()...)
+	sort.Slice(shardIDs, func(i, j int) bool { return shardIDs[i] < shardIDs[j] })
+	return shardIDs[r.nodeID%len(shardIDs)]
+}
+
+// getSourceStatusRows returns the rows of the reports stored in a shard, leaving out any which have expired
+func (r *sourceStatusReporter) getSourceStatusRows(shardID uint64) (*common.Rows, error) {
+	prefix := table.EncodeTableKeyPrefix(common.SourceStatusTableID, shardID, 16)
+	pairs, err := r.cluster.LocalScan(prefix, common.IncrementBytesBigEndian(prefix), -1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	expiry := r.nowMicros() - sourceStatusExpiry.Microseconds()
+	rows := sourcesTableRowsFactory.NewRows(len(pairs))
+	for _, kv := range pairs {
+		reportTime, _ := common.ReadUint64FromBufferLE(kv.Value, 0)
+		if int64(reportTime) < expiry {
+			continue
+		}
+		if err := common.DecodeRow(kv.Value[8:], meta.SourcesTableInfo.ColumnTypes, rows); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return rows, nil
+}
+
+func encodeSourceStatusKey(shardID uint64, sourceID uint64, nodeID int) []byte {
+	key := table.EncodeTableKeyPrefix(common.SourceStatusTableID, shardID, 32)
+	key = common.AppendUint64ToBufferBE(key, sourceID)
+	return common.AppendUint64ToBufferBE(key, uint64(nodeID))
+}
+
+func appendSourceStatus(src *source.Source, nodeID int, rows *common.Rows) {
+	info := src.Info()
+	rows.AppendStringToColumn(0, info.SchemaName)
+	rows.AppendStringToColumn(1, info.Name)
+	rows.AppendInt64ToColumn(2, int64(nodeID))
+	rows.AppendStringToColumn(3, info.OriginInfo.BrokerName)
+	rows.AppendStringToColumn(4, info.OriginInfo.TopicsString())
+	rows.AppendStringToColumn(5, SourceStatus(src))
+	rows.AppendInt64ToColumn(6, src.GetRowsIngested())
+	rows.AppendFloat64ToColumn(7, src.GetIngestRate())
+	offsets := src.GetCommittedOffsets()
+	if len(offsets) == 0 {
+		rows.AppendNullToColumn(8)
+	} else {
+		rows.AppendStringToColumn(8, formatOffsets(offsets, info.OriginInfo.TopicName == ""))
+	}
+}
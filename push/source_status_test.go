@@ -0,0 +1,87 @@
+package push
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squareup/pranadb/cluster/fake"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/conf"
+	"github.com/squareup/pranadb/kafka"
+	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/push/source"
+	"github.com/squareup/pranadb/schemaregistry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceStatusReports(t *testing.T) {
+	clust := fake.NewFakeCluster(0, 10)
+	fakeKafka := kafka.NewFakeKafka()
+	_, err := fakeKafka.CreateTopic("testtopic", 10)
+	require.NoError(t, err)
+	cfg := conf.NewTestConfig(fakeKafka.ID)
+	sourceInfo := &common.SourceInfo{
+		TableInfo: common.NewTableInfo(1000, "test", "source1", []int{0}, []string{"id"},
+			[]common.ColumnType{common.BigIntColumnType}, 0, 0),
+		OriginInfo: &common.SourceOriginInfo{BrokerName: "testbroker", TopicName: "testtopic"},
+	}
+
+	// Each node has its own consumers, so its own source. Node 1 reports even though it leads no shards.
+	reporters := make([]*sourceStatusReporter, 2)
+	for nodeID := range reporters {
+		src, err := source.NewSource(sourceInfo, nil, nil, nil, nil, clust, cfg, nil, protolib.EmptyRegistry,
+			schemaregistry.EmptyRegistry, "")
+		require.NoError(t, err)
+		sources := []*source.Source{src}
+		reporters[nodeID] = newSourceStatusReporter(clust, nodeID, func() []*source.Source {
+			return sources
+		})
+		reporters[nodeID].start()
+		defer reporters[nodeID].stop()
+		require.NoError(t, reporters[nodeID].reportSource(src))
+	}
+	require.NotEqual(t, reporters[0].reportShardID(), reporters[1].reportShardID())
+
+	// The node leading any shard sees the reports stored in it
+	getNodeIDs := func(r *sourceStatusReporter) []int64 {
+		var nodeIDs []int64
+		for _, shardID := range clust.GetAllShardIDs() {
+			rows, err := r.getSourceStatusRows(shardID)
+			require.NoError(t, err)
+			for i := 0; i < rows.RowCount(); i++ {
+				row := rows.GetRow(i)
+				require.Equal(t, "source1", row.GetString(1))
+				require.Equal(t, "stopped", row.GetString(5))
+				nodeIDs = append(nodeIDs, row.GetInt64(2))
+			}
+		}
+		return nodeIDs
+	}
+	require.ElementsMatch(t, []int64{0, 1}, getNodeIDs(reporters[0]))
+
+	// A report is only rewritten when the status has changed or the heartbeat is due
+	reportKey := encodeSourceStatusKey(reporters[0].reportShardID(), sourceInfo.ID, 0)
+	reported, err := clust.LocalGet(reportKey)
+	require.NoError(t, err)
+	require.NoError(t, reporters[0].reportSources(reporters[0].getSources()))
+	unchanged, err := clust.LocalGet(reportKey)
+	require.NoError(t, err)
+	require.Equal(t, reported, unchanged)
+
+	// The reports of a node which stops reporting expire
+	reporters[0].nowMicros = func() int64 {
+		return time.Now().Add(sourceStatusExpiry + time.Second).UnixMicro()
+	}
+	require.Empty(t, getNodeIDs(reporters[0]))
+	require.NoError(t, reporters[0].reportSource(reporters[0].getSources()[0]))
+	require.Equal(t, []int64{0}, getNodeIDs(reporters[0]))
+	reporters[0].nowMicros = func() int64 {
+		return time.Now().UnixMicro()
+	}
+	require.ElementsMatch(t, []int64{0, 1}, getNodeIDs(reporters[0]))
+
+	// Removing the source deletes the reports of every node, including those of a node which has stopped
+	reporters[1].stop()
+	require.NoError(t, reporters[0].remove(sourceInfo.ID))
+	require.Empty(t, getNodeIDs(reporters[0]))
+}
@@ -0,0 +1,141 @@
+package push
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/meta"
+	"github.com/squareup/pranadb/push/source"
+)
+
+// The rows of the virtual system tables are generated here from the state of the push engine on the node which leads
+// the shard being scanned, or for sys.sources, from the status the nodes report for their sources.
+
+var (
+	sourcesTableRowsFactory = common.NewRowsFactory(meta.SourcesTableInfo.ColumnTypes)
+	sinksTableRowsFactory   = common.NewRowsFactory(meta.SinksTableInfo.ColumnTypes)
+	shardsTableRowsFactory  = common.NewRowsFactory(meta.ShardsTableInfo.ColumnTypes)
+)
+
+// SourcesTable generates the rows of sys.sources
+type SourcesTable struct {
+	pe *Engine
+}
+
+func NewSourcesTable(pe *Engine) *SourcesTable {
+	return &SourcesTable{pe: pe}
+}
+
+func (s *SourcesTable) GetRows(shardID uint64) (*common.Rows, error) {
+	return s.pe.sourceStatus.getSourceStatusRows(shardID)
+}
+
+// SinksTable generates the rows of sys.sinks
+type SinksTable struct {
+	pe *Engine
+}
+
+func NewSinksTable(pe *Engine) *SinksTable {
+	return &SinksTable{pe: pe}
+}
+
+func (s *SinksTable) GetRows(shardID uint64) (*common.Rows, error) {
+	s.pe.lock.RLock()
+	sinks := make([]*Sink, 0, len(s.pe.sinks))
+	for _, sink := range s.pe.sinks {
+		sinks = append(sinks, sink)
+	}
+	s.pe.lock.RUnlock()
+	rows := sinksTableRowsFactory.NewRows(len(sinks))
+	for _, sink := range sinks {
+		bufferedMessages, messagesSent, lastSend := sink.GetShardStats(shardID)
+		rows.AppendStringToColumn(0, sink.Info.SchemaName)
+		rows.AppendStringToColumn(1, sink.Info.Name)
+		rows.AppendInt64ToColumn(2, int64(shardID))
+		rows.AppendStringToColumn(3, sink.Info.TargetInfo.TopicName)
+		rows.AppendStringToColumn(4, RunningStatus(sink.IsRunning()))
+		rows.AppendInt64ToColumn(5, bufferedMessages)
+		rows.AppendInt64ToColumn(6, messagesSent)
+		if lastSend == 0 {
+			rows.AppendNullToColumn(7)
+		} else {
+			rows.AppendTimestampToColumn(7, common.NewTimestampFromGoTime(time.UnixMicro(lastSend)))
+		}
+	}
+	return rows, nil
+}
+
+// ShardsTable generates the rows of sys.shards
+type ShardsTable struct {
+	pe *Engine
+}
+
+func NewShardsTable(pe *Engine) *ShardsTable {
+	return &ShardsTable{pe: pe}
+}
+
+func (s *ShardsTable) GetRows(shardID uint64) (*common.Rows, error) {
+	rows := shardsTableRowsFactory.NewRows(1)
+	rows.AppendInt64ToColumn(0, int64(shardID))
+	rows.AppendInt64ToColumn(1, int64(s.pe.cluster.GetNodeID()))
+	replicas := s.pe.cluster.GetReplicaNodeIDs(shardID)
+	sReplicas := make([]string, len(replicas))
+	for i, nodeID := range replicas {
+		sReplicas[i] = strconv.Itoa(nodeID)
+	}
+	rows.AppendStringToColumn(2, strings.Join(sReplicas, ","))
+	sched := s.pe.getScheduler(shardID)
+	if sched == nil {
+		rows.AppendNullToColumn(3)
+	} else {
+		rows.AppendInt64ToColumn(3, sched.GetLag(common.NanoTime()).Milliseconds())
+	}
+	return rows, nil
+}
+
+// RunningStatus returns the status shown for a source, materialized view or sink which is running or not
+func RunningStatus(running bool) string {
+	if running {
+		return "running"
+	}
+	return "stopped"
+}
+
+// SourceStatus returns the status shown for a source, which can also be paused
+func SourceStatus(src *source.Source) string {
+	if src.IsPaused() {
+		return "paused"
+	}
+	return RunningStatus(src.IsRunning())
+}
+
+// formatOffsets formats the committed offsets of a source as partition:offset, prefixed with the topic of the partition
+// if the source consumes from more than one topic
+func formatOffsets(offsets map[string]map[int32]int64, withTopics bool) string {
+	topicNames := make([]string, 0, len(offsets))
+	for topicName := range offsets {
+		topicNames = append(topicNames, topicName)
+	}
+	sort.Strings(topicNames)
+	var sOffsets []string
+	for _, topicName := range topicNames {
+		topicOffsets := offsets[topicName]
+		partIDs := make([]int, 0, len(topicOffsets))
+		for partID := range topicOffsets {
+			partIDs = append(partIDs, int(partID))
+		}
+		sort.Ints(partIDs)
+		for _, partID := range partIDs {
+			sOffset := fmt.Sprintf("%d:%d", partID, topicOffsets[int32(partID)])
+			if withTopics {
+				sOffset = topicName + ":" + sOffset
+			}
+			sOffsets = append(sOffsets, sOffset)
+		}
+	}
+	return strings.Join(sOffsets, ",")
+}
@@ -24,12 +24,15 @@ import (
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/protolib"
 	"github.com/squareup/pranadb/push/codec"
+	"github.com/squareup/pranadb/schemaregistry"
 )
 
 func EncodeKeyForForwardIngest(sourceID uint64, partitionID uint64, offset uint64, remoteConsumerID uint64) []byte {
 	buff := make([]byte, 0, 32)
 	// The first 24 bytes is the dedup key and comprises [originator_id (16 bytes), sequence (8 bytes)]
 	// Originator id for an ingest from Kafka comprises [source_id (8 bytes), partition_id (8 bytes) ]
+	// The high 2 bytes of partition_id are the element of the exploded array the row was parsed from, if any, and the
+	// 2 bytes below them are the number of times the offsets of the source have been reset
 	// The sequence is the offset in the Kafka partition
 	buff = common.AppendUint64ToBufferBE(buff, sourceID)
 	buff = common.AppendUint64ToBufferBE(buff, partitionID)
@@ -107,7 +110,7 @@ func CopyAndAddAllProperties(p1 map[string]string, p2 map[string]string) map[str
 	return m
 }
 
-func GetCodecs(registry protolib.Resolver, headerEncoding common.KafkaEncoding, keyEncoding common.KafkaEncoding, valueEncoding common.KafkaEncoding,
+func GetCodecs(registry protolib.Resolver, schemaRegistry schemaregistry.Registry, headerEncoding common.KafkaEncoding, keyEncoding common.KafkaEncoding, valueEncoding common.KafkaEncoding,
 	selectorInjectors []selector.ColumnSelector) (headerCodec codec.Codec, keyCodec codec.Codec, valueCodec codec.Codec, err error) {
 	// We pre-compute whether the selectors need headers, key and value so we don't unnecessary parse them if they
 	// don't use them
@@ -124,8 +127,8 @@ func GetCodecs(registry protolib.Resolver, headerEncoding common.KafkaEncoding,
 				decodeHeader = true
 			case "key":
 				decodeKey = true
-			case "timestamp":
-				// timestamp selector, no decoding required
+			case "timestamp", "topic", "partition", "offset":
+				// timestamp and Kafka coordinate selectors, no decoding required
 			default:
 				panic(fmt.Sprintf("invalid selector %q", selector))
 			}
@@ -135,19 +138,20 @@ func GetCodecs(registry protolib.Resolver, headerEncoding common.KafkaEncoding,
 		}
 	}
 	if decodeHeader {
-		headerCodec, err = codec.GetCodec(registry, headerEncoding)
+		headerCodec, err = codec.GetCodec(registry, schemaRegistry, headerEncoding)
 		if err != nil {
 			return
 		}
 	}
 	if decodeKey {
-		keyCodec, err = codec.GetCodec(registry, keyEncoding)
+		keyCodec, err = codec.GetCodec(registry, schemaRegistry, keyEncoding)
 		if err != nil {
 			return
 		}
 	}
-	if decodeValue {
-		valueCodec, err = codec.GetCodec(registry, valueEncoding)
+	// A change event must always be decoded, as it decides whether the row is upserted or deleted
+	if decodeValue || valueEncoding.Encoding.IsChangeEvent() {
+		valueCodec, err = codec.GetCodec(registry, schemaRegistry, valueEncoding)
 		if err != nil {
 			return
 		}
@@ -0,0 +1,437 @@
+package schemaregistry
+
+import (
+	"math/big"
+	"reflect"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/squareup/pranadb/common"
+	"github.com/squareup/pranadb/errors"
+)
+
+// AvroSchema parses an Avro schema. Each schema is parsed with its own cache of named types, so different versions of a
+// named type don't replace each other.
+func (s *Schema) AvroSchema() (avro.Schema, error) {
+	if s.Type != SchemaTypeAvro {
+		return nil, errors.Errorf("schema %d is a %s schema, not an avro schema", s.ID, s.Type)
+	}
+	return parseAvroSchema(s.Schema)
+}
+
+func parseAvroSchema(schema string) (avro.Schema, error) {
+	parsed, err := avro.ParseWithCache(schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, errors.Errorf("invalid avro schema: %v", err)
+	}
+	return parsed, nil
+}
+
+// AvroDecoder decodes Avro binary data written with a writer schema into the shape of a reader schema, following the
+// Avro schema resolution rules. Records and maps are decoded to map[string]interface{}, arrays to []interface{}, enums
+// to their symbol and unions to the value of their branch. The decimal logical type is decoded to *common.Decimal, and
+// the date and timestamp logical types to time.Time.
+type AvroDecoder struct {
+	schema avro.Schema
+}
+
+// NewAvroDecoder creates a decoder for data written with the writer schema, to be read as the reader schema. It returns
+// an error if the schemas are not compatible.
+func NewAvroDecoder(writer avro.Schema, reader avro.Schema) (*AvroDecoder, error) {
+	for _, schema := range []avro.Schema{writer, reader} {
+		if name := recursiveRecord(schema, map[string]bool{}); name != "" {
+			return nil, errors.Errorf("avro record %s refers to itself. Recursive avro schemas are not supported", name)
+		}
+	}
+	if writer.Fingerprint() == reader.Fingerprint() {
+		return &AvroDecoder{schema: reader}, nil
+	}
+	resolved, err := avro.NewSchemaCompatibility().Resolve(reader, writer)
+	if err != nil {
+		return nil, errors.Errorf("avro schema %s cannot be read as %s: %v", avroTypeName(writer), avroTypeName(reader), err)
+	}
+	return &AvroDecoder{schema: resolved}, nil
+}
+
+// Decode decodes a single datum
+func (d *AvroDecoder) Decode(data []byte) (interface{}, error) {
+	// avro.Unmarshal doesn't report data that ends early, so the data is read with a reader
+	r := avro.NewReader(nil, 0).Reset(data)
+	var v interface{}
+	r.ReadVal(d.schema, &v)
+	if r.Error != nil {
+		return nil, errors.Errorf("invalid avro data: %v", r.Error)
+	}
+	if r.Read(make([]byte, 1)); r.Error == nil {
+		return nil, errors.Error("invalid avro data: trailing bytes")
+	}
+	return fromAvro(d.schema, v)
+}
+
+// fromAvro converts a value decoded by the avro library to the shape described by AvroDecoder
+func fromAvro(schema avro.Schema, v interface{}) (interface{}, error) { //nolint:gocyclo
+	if v == nil {
+		return nil, nil
+	}
+	switch s := derefAvroSchema(schema).(type) {
+	case *avro.UnionSchema:
+		// Union values are wrapped in a map keyed by the name of their branch, except when the union only has null and
+		// one other branch
+		if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+			for name, value := range m {
+				if branch, _ := s.Types().Get(name); branch != nil {
+					return fromAvro(branch, value)
+				}
+			}
+		}
+		if s.Nullable() {
+			for _, branch := range s.Types() {
+				if branch.Type() != avro.Null {
+					return fromAvro(branch, v)
+				}
+			}
+		}
+		return v, nil
+	case *avro.RecordSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid avro data: expected a record but was %T", v)
+		}
+		for _, f := range s.Fields() {
+			// A resolved record has the fields of the writer that the reader doesn't have too, which aren't decoded
+			value, ok := m[f.Name()]
+			if !ok {
+				continue
+			}
+			value, err := fromAvro(f.Type(), value)
+			if err != nil {
+				return nil, errors.Errorf("field %q: %v", f.Name(), err)
+			}
+			m[f.Name()] = value
+		}
+		return m, nil
+	case *avro.MapSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid avro data: expected a map but was %T", v)
+		}
+		for k, value := range m {
+			var err error
+			if m[k], err = fromAvro(s.Values(), value); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case *avro.ArraySchema:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, errors.Errorf("invalid avro data: expected an array but was %T", v)
+		}
+		for i, item := range arr {
+			var err error
+			if arr[i], err = fromAvro(s.Items(), item); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case *avro.PrimitiveSchema:
+		if s.Logical() != nil {
+			if dec, ok := s.Logical().(*avro.DecimalLogicalSchema); ok {
+				return toDecimal(v, dec.Scale())
+			}
+			return v, nil
+		}
+		switch s.Type() {
+		case avro.Int:
+			if i, ok := v.(int); ok {
+				return int32(i), nil
+			}
+		case avro.Long:
+			if i, ok := v.(int); ok {
+				return int64(i), nil
+			}
+		}
+		return v, nil
+	case *avro.FixedSchema:
+		if s.Logical() != nil {
+			if dec, ok := s.Logical().(*avro.DecimalLogicalSchema); ok {
+				return toDecimal(v, dec.Scale())
+			}
+		}
+		// Fixed values are decoded to byte arrays
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Array {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return b, nil
+		}
+		return v, nil
+	}
+	return v, nil
+}
+
+// recursiveRecord returns the name of a record of the schema that contains itself, if any, as records like this can't be
+// decoded generically by the avro library
+func recursiveRecord(schema avro.Schema, path map[string]bool) string {
+	switch s := derefAvroSchema(schema).(type) {
+	case *avro.RecordSchema:
+		if path[s.FullName()] {
+			return s.FullName()
+		}
+		path[s.FullName()] = true
+		defer delete(path, s.FullName())
+		for _, f := range s.Fields() {
+			if name := recursiveRecord(f.Type(), path); name != "" {
+				return name
+			}
+		}
+	case *avro.UnionSchema:
+		for _, branch := range s.Types() {
+			if name := recursiveRecord(branch, path); name != "" {
+				return name
+			}
+		}
+	case *avro.ArraySchema:
+		return recursiveRecord(s.Items(), path)
+	case *avro.MapSchema:
+		return recursiveRecord(s.Values(), path)
+	}
+	return ""
+}
+
+func toDecimal(v interface{}, scale int) (*common.Decimal, error) {
+	r, ok := v.(*big.Rat)
+	if !ok {
+		return nil, errors.Errorf("invalid avro data: expected a decimal but was %T", v)
+	}
+	return common.NewDecFromString(r.FloatString(scale))
+}
+
+// EncodeAvro appends the Avro binary encoding of the value to the buffer. Values are accepted in the shapes that an
+// AvroDecoder produces. Integers of any size, and strings for decimals, are accepted too. A record field missing from
+// the value is encoded with its default. The first branch of a union that accepts the value is used.
+func EncodeAvro(buff []byte, schema avro.Schema, v interface{}) ([]byte, error) {
+	native, err := toAvro(schema, v)
+	if err != nil {
+		return nil, err
+	}
+	b, err := avro.Marshal(schema, native)
+	if err != nil {
+		return nil, errors.Errorf("cannot encode %v as avro %s: %v", v, avroTypeName(schema), err)
+	}
+	return append(buff, b...), nil
+}
+
+// toAvro converts a value to the Go type that the avro library encodes as the schema
+func toAvro(schema avro.Schema, v interface{}) (interface{}, error) { //nolint:gocyclo
+	schema = derefAvroSchema(schema)
+	switch s := schema.(type) {
+	case *avro.UnionSchema:
+		if v == nil {
+			if !s.Nullable() {
+				return nil, encodeErr(schema, v)
+			}
+			return nil, nil
+		}
+		// The branch is given by wrapping the value in a map keyed by the name of the branch
+		for _, branch := range s.Types() {
+			if branch.Type() == avro.Null {
+				continue
+			}
+			if native, err := toAvro(branch, v); err == nil {
+				return map[string]interface{}{avroTypeName(branch): native}, nil
+			}
+		}
+		return nil, encodeErr(schema, v)
+	case *avro.RecordSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, encodeErr(schema, v)
+		}
+		record := make(map[string]interface{}, len(m))
+		for _, f := range s.Fields() {
+			value, present := m[f.Name()]
+			if !present && f.HasDefault() {
+				continue
+			}
+			native, err := toAvro(f.Type(), value)
+			if err != nil {
+				return nil, errors.Errorf("field %q: %v", f.Name(), err)
+			}
+			record[f.Name()] = native
+		}
+		return record, nil
+	case *avro.MapSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, encodeErr(schema, v)
+		}
+		native := make(map[string]interface{}, len(m))
+		for k, value := range m {
+			var err error
+			if native[k], err = toAvro(s.Values(), value); err != nil {
+				return nil, err
+			}
+		}
+		return native, nil
+	case *avro.ArraySchema:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, encodeErr(schema, v)
+		}
+		native := make([]interface{}, len(arr))
+		for i, item := range arr {
+			var err error
+			if native[i], err = toAvro(s.Items(), item); err != nil {
+				return nil, err
+			}
+		}
+		return native, nil
+	case *avro.EnumSchema:
+		if str, ok := v.(string); ok {
+			for _, symbol := range s.Symbols() {
+				if symbol == str {
+					return str, nil
+				}
+			}
+		}
+		return nil, encodeErr(schema, v)
+	case *avro.FixedSchema:
+		if s.Logical() != nil {
+			if _, ok := s.Logical().(*avro.DecimalLogicalSchema); ok {
+				return toRat(schema, v)
+			}
+		}
+		b, ok := v.([]byte)
+		if !ok || len(b) != s.Size() {
+			return nil, encodeErr(schema, v)
+		}
+		// Fixed values are encoded from byte arrays of the size of the fixed
+		arr := reflect.New(reflect.ArrayOf(s.Size(), reflect.TypeOf(byte(0)))).Elem()
+		reflect.Copy(arr, reflect.ValueOf(b))
+		return arr.Interface(), nil
+	case *avro.PrimitiveSchema:
+		return toAvroPrimitive(s, v)
+	}
+	return nil, encodeErr(schema, v)
+}
+
+func toAvroPrimitive(s *avro.PrimitiveSchema, v interface{}) (interface{}, error) {
+	var ok bool
+	switch s.Type() {
+	case avro.Null:
+		ok = v == nil
+	case avro.Boolean:
+		_, ok = v.(bool)
+	case avro.String:
+		_, ok = v.(string)
+	case avro.Bytes:
+		if s.Logical() != nil && s.Logical().Type() == avro.Decimal {
+			return toRat(s, v)
+		}
+		_, ok = v.([]byte)
+	case avro.Int, avro.Long:
+		if s.Logical() != nil {
+			// The date and timestamp logical types are encoded from time.Time
+			if t, isTime := v.(time.Time); isTime {
+				return t, nil
+			}
+		}
+		l, isInt := toInt64(v)
+		if !isInt {
+			break
+		}
+		if s.Type() == avro.Int {
+			return int(l), nil
+		}
+		return l, nil
+	case avro.Float, avro.Double:
+		f, isFloat := toFloat64(v)
+		if !isFloat {
+			break
+		}
+		if s.Type() == avro.Float {
+			return float32(f), nil
+		}
+		return f, nil
+	}
+	if !ok {
+		return nil, encodeErr(s, v)
+	}
+	return v, nil
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch t := v.(type) {
+	case int64:
+		return t, true
+	case int32:
+		return int64(t), true
+	case int16:
+		return int64(t), true
+	case int8:
+		return int64(t), true
+	case int:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint16:
+		return int64(t), true
+	case uint8:
+		return int64(t), true
+	}
+	return 0, false
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	}
+	if l, ok := toInt64(v); ok {
+		return float64(l), true
+	}
+	return 0, false
+}
+
+func toRat(schema avro.Schema, v interface{}) (*big.Rat, error) {
+	var str string
+	switch t := v.(type) {
+	case *common.Decimal:
+		str = t.String()
+	case string:
+		str = t
+	default:
+		return nil, encodeErr(schema, v)
+	}
+	r, ok := new(big.Rat).SetString(str)
+	if !ok {
+		return nil, encodeErr(schema, v)
+	}
+	return r, nil
+}
+
+func derefAvroSchema(schema avro.Schema) avro.Schema {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		return ref.Schema()
+	}
+	return schema
+}
+
+// avroTypeName returns the name of a schema as the avro library names the branches of a union
+func avroTypeName(schema avro.Schema) string {
+	schema = derefAvroSchema(schema)
+	if named, ok := schema.(avro.NamedSchema); ok {
+		return named.FullName()
+	}
+	if lt, ok := schema.(avro.LogicalTypeSchema); ok && lt.Logical() != nil {
+		return string(schema.Type()) + "." + string(lt.Logical().Type())
+	}
+	return string(schema.Type())
+}
+
+func encodeErr(schema avro.Schema, v interface{}) error {
+	return errors.Errorf("cannot encode %v (%T) as avro %s", v, v, avroTypeName(schema))
+}
@@ -0,0 +1,181 @@
+package schemaregistry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/squareup/pranadb/common"
+	"github.com/stretchr/testify/require"
+)
+
+const writerSchema = `{
+  "type": "record",
+  "name": "Payment",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "int"},
+    {"name": "ratio", "type": "float"},
+    {"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["CARD", "CASH", "BANK"]}},
+    {"name": "note", "type": ["null", "string"]},
+    {"name": "obsolete", "type": {"type": "array", "items": "long"}},
+    {"name": "tags", "type": {"type": "map", "values": "boolean"}},
+    {"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+    {"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "day", "type": {"type": "int", "logicalType": "date"}}
+  ]
+}`
+
+const readerSchema = `{
+  "type": "record",
+  "name": "Payment",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "id", "type": "string"},
+    {"name": "amount", "type": "long"},
+    {"name": "ratio", "type": "double"},
+    {"name": "kind", "type": {"type": "enum", "name": "Kind", "symbols": ["CARD", "CASH", "OTHER"], "default": "OTHER"}},
+    {"name": "comment", "aliases": ["note"], "type": ["null", "string"]},
+    {"name": "tags", "type": {"type": "map", "values": "boolean"}},
+    {"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+    {"name": "at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "day", "type": {"type": "int", "logicalType": "date"}},
+    {"name": "currency", "type": "string", "default": "USD"},
+    {"name": "limits", "type": {"type": "array", "items": "int"}, "default": [1, 2]}
+  ]
+}`
+
+func payment(id string, kind string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       id,
+		"amount":   int32(-150),
+		"ratio":    float32(0.5),
+		"kind":     kind,
+		"note":     "hello",
+		"obsolete": []interface{}{int64(1), int64(2)},
+		"tags":     map[string]interface{}{"a": true},
+		"price":    decimal("-12.34"),
+		"at":       time.Date(2022, 4, 15, 5, 20, 0, 123000000, time.UTC),
+		"day":      time.Date(2022, 4, 15, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func decimal(s string) *common.Decimal {
+	d, err := common.NewDecFromString(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRoundTrip(t *testing.T) {
+	schema, err := parseAvroSchema(writerSchema)
+	require.NoError(t, err)
+	val := payment("p1", "CASH")
+	buff, err := EncodeAvro(nil, schema, val)
+	require.NoError(t, err)
+	decoder, err := NewAvroDecoder(schema, schema)
+	require.NoError(t, err)
+	decoded, err := decoder.Decode(buff)
+	require.NoError(t, err)
+	require.Equal(t, val, decoded)
+}
+
+func TestSchemaResolution(t *testing.T) {
+	writer, err := parseAvroSchema(writerSchema)
+	require.NoError(t, err)
+	reader, err := parseAvroSchema(readerSchema)
+	require.NoError(t, err)
+	buff, err := EncodeAvro(nil, writer, payment("p1", "BANK"))
+	require.NoError(t, err)
+	decoder, err := NewAvroDecoder(writer, reader)
+	require.NoError(t, err)
+	decoded, err := decoder.Decode(buff)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"id":       "p1",
+		"amount":   int64(-150),
+		"ratio":    float64(0.5),
+		"kind":     "OTHER",
+		"comment":  "hello",
+		"tags":     map[string]interface{}{"a": true},
+		"price":    decimal("-12.34"),
+		"at":       time.Date(2022, 4, 15, 5, 20, 0, 123000000, time.UTC),
+		"day":      time.Date(2022, 4, 15, 0, 0, 0, 0, time.UTC),
+		"currency": "USD",
+		"limits":   []interface{}{int32(1), int32(2)},
+	}, decoded)
+}
+
+func TestIncompatibleSchemas(t *testing.T) {
+	writer, err := parseAvroSchema(`{"type":"record","name":"R","fields":[{"name":"a","type":"long"}]}`)
+	require.NoError(t, err)
+	for _, r := range []string{
+		// long can't be read as int
+		`{"type":"record","name":"R","fields":[{"name":"a","type":"int"}]}`,
+		// a new field must have a default
+		`{"type":"record","name":"R","fields":[{"name":"a","type":"long"},{"name":"b","type":"long"}]}`,
+		// records must have the same name
+		`{"type":"record","name":"S","fields":[{"name":"a","type":"long"}]}`,
+		// no branch of the union reads long
+		`{"type":"record","name":"R","fields":[{"name":"a","type":["null","string"]}]}`,
+	} {
+		reader, err := parseAvroSchema(r)
+		require.NoError(t, err)
+		_, err = NewAvroDecoder(writer, reader)
+		require.Error(t, err, r)
+	}
+
+	// The reader must have all the symbols of the writer's enum, unless it has a default
+	writer, err = parseAvroSchema(`{"type":"enum","name":"E","symbols":["A","B"]}`)
+	require.NoError(t, err)
+	reader, err := parseAvroSchema(`{"type":"enum","name":"E","symbols":["A"]}`)
+	require.NoError(t, err)
+	_, err = NewAvroDecoder(writer, reader)
+	require.Error(t, err)
+	reader, err = parseAvroSchema(`{"type":"enum","name":"E","symbols":["A","C"],"default":"C"}`)
+	require.NoError(t, err)
+	decoder, err := NewAvroDecoder(writer, reader)
+	require.NoError(t, err)
+	buff, err := EncodeAvro(nil, writer, "B")
+	require.NoError(t, err)
+	v, err := decoder.Decode(buff)
+	require.NoError(t, err)
+	require.Equal(t, "C", v)
+}
+
+func TestRecursiveSchema(t *testing.T) {
+	schema, err := parseAvroSchema(`{"type":"record","name":"Node","fields":[{"name":"next","type":["null","Node"]}]}`)
+	require.NoError(t, err)
+	_, err = NewAvroDecoder(schema, schema)
+	require.Error(t, err)
+}
+
+func TestInvalidSchemas(t *testing.T) {
+	for _, s := range []string{
+		`{"type":"record","name":"R","fields":[{"name":"a","type":"Unknown"}]}`,
+		`{"type":"record","fields":[]}`,
+		`[["null"],"string"]`,
+		`{"name":"R"}`,
+		`not json`,
+	} {
+		_, err := parseAvroSchema(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestDecodeInvalidData(t *testing.T) {
+	schema, err := parseAvroSchema(`{"type":"record","name":"R","fields":[{"name":"a","type":"string"},{"name":"b","type":["null","long"]}]}`)
+	require.NoError(t, err)
+	decoder, err := NewAvroDecoder(schema, schema)
+	require.NoError(t, err)
+	buff, err := EncodeAvro(nil, schema, map[string]interface{}{"a": "foo", "b": int64(7)})
+	require.NoError(t, err)
+	_, err = decoder.Decode(buff[:len(buff)-1])
+	require.Error(t, err)
+	_, err = decoder.Decode(append(buff, 0))
+	require.Error(t, err)
+	// union branch out of range
+	_, err = decoder.Decode([]byte{6, 'f', 'o', 'o', 4})
+	require.Error(t, err)
+}
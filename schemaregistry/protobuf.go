@@ -0,0 +1,107 @@
+package schemaregistry
+
+import (
+	"github.com/squareup/pranadb/errors"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// ProtobufFile builds the file descriptor of a protobuf schema. Files the schema imports that are not part of the
+// schema are looked up in the resolver, then in the files linked into Prana, such as the well-known types.
+func (s *Schema) ProtobufFile(resolver protodesc.Resolver) (protoreflect.FileDescriptor, error) {
+	if s.Type != SchemaTypeProtobuf {
+		return nil, errors.Errorf("schema %d is a %s schema, not a protobuf schema", s.ID, s.Type)
+	}
+	local := &protoregistry.Files{}
+	chain := &chainResolver{resolvers: []protodesc.Resolver{local, resolver, protoregistry.GlobalFiles}}
+	var fd protoreflect.FileDescriptor
+	for _, file := range s.Files {
+		var err error
+		fd, err = protodesc.NewFile(file, chain)
+		if err != nil {
+			return nil, errors.Errorf("invalid protobuf schema %d: %v", s.ID, err)
+		}
+		if err := local.RegisterFile(fd); err != nil {
+			return nil, errors.Errorf("invalid protobuf schema %d: %v", s.ID, err)
+		}
+	}
+	return fd, nil
+}
+
+type chainResolver struct {
+	resolvers []protodesc.Resolver
+}
+
+func (c *chainResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	for _, r := range c.resolvers {
+		fd, err := r.FindFileByPath(path)
+		if err == nil {
+			return fd, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+func (c *chainResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	for _, r := range c.resolvers {
+		d, err := r.FindDescriptorByName(name)
+		if err == nil {
+			return d, nil
+		}
+	}
+	return nil, protoregistry.NotFound
+}
+
+// MessageByIndexes returns the message type of a file at the message indexes of the Confluent wire format
+func MessageByIndexes(fd protoreflect.FileDescriptor, indexes []int) (protoreflect.MessageDescriptor, error) {
+	var md protoreflect.MessageDescriptor
+	messages := fd.Messages()
+	for _, index := range indexes {
+		if index >= messages.Len() {
+			return nil, errors.Errorf("message indexes %v not found in %s", indexes, fd.Path())
+		}
+		md = messages.Get(index)
+		messages = md.Messages()
+	}
+	if md == nil {
+		return nil, errors.Errorf("message indexes %v not found in %s", indexes, fd.Path())
+	}
+	return md, nil
+}
+
+// MessageIndexes returns the message indexes of the Confluent wire format for a message type
+func MessageIndexes(md protoreflect.MessageDescriptor) []int {
+	var indexes []int
+	var d protoreflect.Descriptor = md
+	for {
+		parent, ok := d.(protoreflect.MessageDescriptor)
+		if !ok {
+			break
+		}
+		indexes = append([]int{parent.Index()}, indexes...)
+		d = parent.Parent()
+	}
+	return indexes
+}
+
+// FindMessage returns the message type with the full name, including nested message types, defined in the file
+func FindMessage(fd protoreflect.FileDescriptor, name protoreflect.FullName) (protoreflect.MessageDescriptor, error) {
+	if md := findMessage(fd.Messages(), name); md != nil {
+		return md, nil
+	}
+	return nil, errors.Errorf("protobuf message %s not found in %s", name, fd.Path())
+}
+
+func findMessage(messages protoreflect.MessageDescriptors, name protoreflect.FullName) protoreflect.MessageDescriptor {
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		if md.FullName() == name {
+			return md
+		}
+		if nested := findMessage(md.Messages(), name); nested != nil {
+			return nested
+		}
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package schemaregistry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/squareup/pranadb/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// SchemaType is the kind of a registered schema
+type SchemaType string
+
+const (
+	SchemaTypeAvro     SchemaType = "AVRO"
+	SchemaTypeProtobuf SchemaType = "PROTOBUF"
+)
+
+// Schema is a schema registered in the schema registry
+type Schema struct {
+	ID   int32
+	Type SchemaType
+	// Schema is the JSON of an Avro schema
+	Schema string
+	// Files are the file descriptors of a protobuf schema. The file that defines the schema is last, preceded by the
+	// files it imports.
+	Files []*descriptorpb.FileDescriptorProto
+}
+
+// Registry resolves the schemas that messages are written with, in the manner of a Confluent schema registry
+type Registry interface {
+	// GetSchema returns the schema registered with the id
+	GetSchema(id int32) (*Schema, error)
+	// GetLatestSchema returns the latest version of the subject
+	GetLatestSchema(subject string) (*Schema, error)
+}
+
+// NewRegistry creates a schema registry for the location, which is either the http(s) URL of a Confluent schema
+// registry, or a directory. See NewDirBackedRegistry for its layout. If location is empty, the registry is empty.
+func NewRegistry(location string) (Registry, error) {
+	if location == "" {
+		return EmptyRegistry, nil
+	}
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return NewHTTPRegistry(location)
+	}
+	return NewDirBackedRegistry(location)
+}
+
+// EmptyRegistry is a registry with no schemas
+var EmptyRegistry Registry = emptyRegistry{}
+
+type emptyRegistry struct{}
+
+func (e emptyRegistry) GetSchema(id int32) (*Schema, error) {
+	return nil, errors.Errorf("schema with id %d not found. No schema registry is configured", id)
+}
+
+func (e emptyRegistry) GetLatestSchema(subject string) (*Schema, error) {
+	return nil, errors.Errorf("subject %q not found. No schema registry is configured", subject)
+}
+
+// NewHTTPRegistry creates a client of the Confluent schema registry at the URL. Schemas are cached by id, as they never
+// change once registered.
+func NewHTTPRegistry(registryURL string) (Registry, error) {
+	u, err := url.Parse(registryURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &httpRegistry{
+		url:     strings.TrimSuffix(u.String(), "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		schemas: map[int32]*Schema{},
+	}, nil
+}
+
+type httpRegistry struct {
+	url     string
+	client  *http.Client
+	lock    sync.Mutex
+	schemas map[int32]*Schema
+}
+
+type registrySchema struct {
+	ID         int32               `json:"id"`
+	SchemaType string              `json:"schemaType"`
+	Schema     string              `json:"schema"`
+	References []registryReference `json:"references"`
+}
+
+type registryReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+func (h *httpRegistry) GetSchema(id int32) (*Schema, error) {
+	h.lock.Lock()
+	s, ok := h.schemas[id]
+	h.lock.Unlock()
+	if ok {
+		return s, nil
+	}
+	var res registrySchema
+	if err := h.get(fmt.Sprintf("/schemas/ids/%d", id), &res); err != nil {
+		return nil, err
+	}
+	res.ID = id
+	s, err := h.toSchema(res)
+	if err != nil {
+		return nil, err
+	}
+	h.lock.Lock()
+	h.schemas[id] = s
+	h.lock.Unlock()
+	return s, nil
+}
+
+func (h *httpRegistry) GetLatestSchema(subject string) (*Schema, error) {
+	var res registrySchema
+	if err := h.get(fmt.Sprintf("/subjects/%s/versions/latest", url.PathEscape(subject)), &res); err != nil {
+		return nil, err
+	}
+	return h.toSchema(res)
+}
+
+func (h *httpRegistry) toSchema(res registrySchema) (*Schema, error) {
+	switch SchemaType(res.SchemaType) {
+	case "", SchemaTypeAvro: // The schema type is omitted for Avro
+		return &Schema{ID: res.ID, Type: SchemaTypeAvro, Schema: res.Schema}, nil
+	case SchemaTypeProtobuf:
+		// The schema is returned as .proto source unless the serialized file descriptor is asked for
+		files, err := h.getProtobufFiles(fmt.Sprintf("/schemas/ids/%d", res.ID), map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{ID: res.ID, Type: SchemaTypeProtobuf, Files: files}, nil
+	default:
+		return nil, errors.Errorf("schema %d has unsupported schema type %s", res.ID, res.SchemaType)
+	}
+}
+
+// getProtobufFiles gets the file descriptor of a protobuf schema, preceded by the file descriptors of the schemas it
+// references
+func (h *httpRegistry) getProtobufFiles(path string, seen map[string]bool) ([]*descriptorpb.FileDescriptorProto, error) {
+	var res registrySchema
+	if err := h.get(path+"?format=serialized", &res); err != nil {
+		return nil, err
+	}
+	var files []*descriptorpb.FileDescriptorProto
+	for _, ref := range res.References {
+		if seen[ref.Name] {
+			continue
+		}
+		seen[ref.Name] = true
+		refFiles, err := h.getProtobufFiles(fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(ref.Subject), ref.Version), seen)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, refFiles...)
+	}
+	b, err := base64.StdEncoding.DecodeString(res.Schema)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	file := &descriptorpb.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, file); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append(files, file), nil
+}
+
+func (h *httpRegistry) get(path string, res interface{}) error {
+	resp, err := h.client.Get(h.url + path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("schema registry request %s failed with status %d: %s", path, resp.StatusCode, body)
+	}
+	return errors.WithStack(json.Unmarshal(body, res))
+}
+
+// NewDirBackedRegistry creates a registry of the schemas in a directory, which stands in for a schema registry, for
+// example in tests. The directory has a sub-directory for each subject, containing the schemas of the subject in files
+// named <id>.avsc for Avro schemas, or <id>.bin for protobuf schemas. A .bin file is a serialized FileDescriptorSet,
+// as written by protoc --include_imports --descriptor_set_out, of the file that defines the schema and its imports.
+// The latest version of a subject is its schema with the highest id. Schema ids must be unique across subjects. The
+// directory is re-read when looking up the latest version of a subject, or a schema id that isn't found, so schemas
+// can be added while Prana runs.
+func NewDirBackedRegistry(dir string) (Registry, error) {
+	d := &dirRegistry{dir: dir}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+type dirRegistry struct {
+	dir      string
+	lock     sync.Mutex
+	schemas  map[int32]*Schema
+	subjects map[string]int32
+}
+
+func (d *dirRegistry) GetSchema(id int32) (*Schema, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if s, ok := d.schemas[id]; ok {
+		return s, nil
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	if s, ok := d.schemas[id]; ok {
+		return s, nil
+	}
+	return nil, errors.Errorf("schema with id %d not found", id)
+}
+
+func (d *dirRegistry) GetLatestSchema(subject string) (*Schema, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	id, ok := d.subjects[subject]
+	if !ok {
+		return nil, errors.Errorf("subject %q not found", subject)
+	}
+	return d.schemas[id], nil
+}
+
+var schemaFileTypes = map[string]SchemaType{
+	".avsc": SchemaTypeAvro,
+	".bin":  SchemaTypeProtobuf,
+}
+
+func (d *dirRegistry) load() error {
+	subjectDirs, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	schemas := map[int32]*Schema{}
+	subjects := map[string]int32{}
+	for _, subjectDir := range subjectDirs {
+		if !subjectDir.IsDir() {
+			continue
+		}
+		subject := subjectDir.Name()
+		files, err := ioutil.ReadDir(filepath.Join(d.dir, subject))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			schemaType, ok := schemaFileTypes[ext]
+			if file.IsDir() || !ok {
+				continue
+			}
+			id, err := strconv.ParseInt(strings.TrimSuffix(file.Name(), ext), 10, 32)
+			if err != nil {
+				return errors.Errorf("invalid schema file %s. Files must be named <id>.avsc or <id>.bin", file.Name())
+			}
+			s, ok := d.schemas[int32(id)]
+			if !ok {
+				if s, err = readSchemaFile(filepath.Join(d.dir, subject, file.Name()), int32(id), schemaType); err != nil {
+					return errors.Errorf("%s/%s: %v", subject, file.Name(), err)
+				}
+			}
+			schemas[int32(id)] = s
+			if latest, ok := subjects[subject]; !ok || int32(id) > latest {
+				subjects[subject] = int32(id)
+			}
+		}
+	}
+	d.schemas = schemas
+	d.subjects = subjects
+	return nil
+}
+
+func readSchemaFile(path string, id int32, schemaType SchemaType) (*Schema, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if schemaType == SchemaTypeAvro {
+		if _, err := parseAvroSchema(string(b)); err != nil {
+			return nil, err
+		}
+		return &Schema{ID: id, Type: schemaType, Schema: string(b)}, nil
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, fds); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(fds.File) == 0 {
+		return nil, errors.Error("file descriptor set is empty")
+	}
+	return &Schema{ID: id, Type: schemaType, Files: fds.File}, nil
+}
@@ -0,0 +1,252 @@
+package schemaregistry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hamba/avro/v2"
+	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/protos/squareup/cash/pranadb/v1/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const recordSchema = `{"type":"record","name":"R","fields":[{"name":"a","type":"long"}]}`
+
+// testProtoFiles returns the file descriptors of testproto.proto, preceded by the file it imports from the same package
+func testProtoFiles() []*descriptorpb.FileDescriptorProto {
+	return []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(testproto.File_squareup_cash_pranadb_testproto_v1_imports_proto),
+		protodesc.ToFileDescriptorProto(testproto.File_squareup_cash_pranadb_testproto_v1_testproto_proto),
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	buff := AppendWireFormat(nil, 258)
+	buff = append(buff, 1, 2, 3)
+	require.Equal(t, []byte{0, 0, 0, 1, 2, 1, 2, 3}, buff)
+	id, payload, err := DecodeWireFormat(buff)
+	require.NoError(t, err)
+	require.Equal(t, int32(258), id)
+	require.Equal(t, []byte{1, 2, 3}, payload)
+	_, _, err = DecodeWireFormat([]byte{1, 0, 0, 1, 2})
+	require.Error(t, err)
+	_, _, err = DecodeWireFormat([]byte{0, 0})
+	require.Error(t, err)
+}
+
+func TestMessageIndexes(t *testing.T) {
+	// The first message is written as a count of 0
+	buff := AppendMessageIndexes(nil, []int{0})
+	require.Equal(t, []byte{0}, buff)
+	buff = AppendMessageIndexes(nil, []int{2, 0, 1})
+	require.Equal(t, []byte{6, 4, 0, 2}, buff)
+
+	for _, indexes := range [][]int{{0}, {1}, {2, 0, 1}, {70}} {
+		buff := AppendMessageIndexes(nil, indexes)
+		decoded, payload, err := DecodeMessageIndexes(append(buff, 9, 9))
+		require.NoError(t, err)
+		require.Equal(t, indexes, decoded)
+		require.Equal(t, []byte{9, 9}, payload)
+	}
+
+	_, _, err := DecodeMessageIndexes(nil)
+	require.Error(t, err)
+	// Count of 2, with only one index
+	_, _, err = DecodeMessageIndexes([]byte{4, 2})
+	require.Error(t, err)
+	// Negative count
+	_, _, err = DecodeMessageIndexes([]byte{1})
+	require.Error(t, err)
+}
+
+func TestProtobufSchema(t *testing.T) {
+	schema := &Schema{ID: 4, Type: SchemaTypeProtobuf, Files: testProtoFiles()}
+	fd, err := schema.ProtobufFile(protolib.EmptyRegistry)
+	require.NoError(t, err)
+	require.Equal(t, "squareup/cash/pranadb/testproto/v1/testproto.proto", fd.Path())
+
+	for name, indexes := range map[protoreflect.FullName][]int{
+		"squareup.cash.pranadb.testproto.v1.TestTypes":        {0},
+		"squareup.cash.pranadb.testproto.v1.TestTypes.Nested": {0, 0},
+		"squareup.cash.pranadb.testproto.v1.SimpleValue":      {2},
+	} {
+		md, err := FindMessage(fd, name)
+		require.NoError(t, err)
+		require.Equal(t, indexes, MessageIndexes(md))
+		md, err = MessageByIndexes(fd, indexes)
+		require.NoError(t, err)
+		require.Equal(t, name, md.FullName())
+	}
+	_, err = FindMessage(fd, "squareup.cash.pranadb.testproto.v1.Imported")
+	require.Error(t, err)
+	_, err = MessageByIndexes(fd, []int{5})
+	require.Error(t, err)
+	_, err = MessageByIndexes(fd, []int{0, 99})
+	require.Error(t, err)
+
+	// Imports that aren't part of the schema are resolved from the files linked into Prana
+	schema = &Schema{ID: 5, Type: SchemaTypeProtobuf, Files: testProtoFiles()[1:]}
+	_, err = schema.ProtobufFile(protolib.EmptyRegistry)
+	require.NoError(t, err)
+	missing := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("missing_import.proto"),
+		Dependency: []string{"missing.proto"},
+	}
+	_, err = (&Schema{ID: 7, Type: SchemaTypeProtobuf, Files: []*descriptorpb.FileDescriptorProto{missing}}).ProtobufFile(protolib.EmptyRegistry)
+	require.Error(t, err)
+
+	_, err = (&Schema{ID: 6, Type: SchemaTypeAvro, Schema: recordSchema}).ProtobufFile(protolib.EmptyRegistry)
+	require.Error(t, err)
+	_, err = schema.AvroSchema()
+	require.Error(t, err)
+}
+
+func TestDirBackedRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schema-registry")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+	writeSchema(t, dir, "payments-value", 3, recordSchema)
+	writeSchema(t, dir, "payments-value", 7, `"string"`)
+	writeSchema(t, dir, "other-value", 5, `"int"`)
+	fds, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: testProtoFiles()})
+	require.NoError(t, err)
+	writeSchemaFile(t, dir, "squareup.cash.pranadb.testproto.v1.TestTypes", "8.bin", fds)
+
+	registry, err := NewRegistry(dir)
+	require.NoError(t, err)
+	schema, err := registry.GetLatestSchema("payments-value")
+	require.NoError(t, err)
+	require.Equal(t, int32(7), schema.ID)
+	require.Equal(t, SchemaTypeAvro, schema.Type)
+	require.Equal(t, `"string"`, schema.Schema)
+	schema, err = registry.GetSchema(3)
+	require.NoError(t, err)
+	avroSchema, err := schema.AvroSchema()
+	require.NoError(t, err)
+	require.Equal(t, avro.Record, avroSchema.Type())
+	_, err = registry.GetSchema(6)
+	require.Error(t, err)
+	_, err = registry.GetLatestSchema("unknown")
+	require.Error(t, err)
+
+	schema, err = registry.GetLatestSchema("squareup.cash.pranadb.testproto.v1.TestTypes")
+	require.NoError(t, err)
+	require.Equal(t, int32(8), schema.ID)
+	require.Equal(t, SchemaTypeProtobuf, schema.Type)
+	fd, err := schema.ProtobufFile(protolib.EmptyRegistry)
+	require.NoError(t, err)
+	_, err = FindMessage(fd, "squareup.cash.pranadb.testproto.v1.TestTypes")
+	require.NoError(t, err)
+
+	// Schemas added later are found
+	writeSchema(t, dir, "payments-value", 9, `"long"`)
+	writeSchema(t, dir, "new-value", 10, `"int"`)
+	schema, err = registry.GetLatestSchema("payments-value")
+	require.NoError(t, err)
+	require.Equal(t, int32(9), schema.ID)
+	schema, err = registry.GetSchema(10)
+	require.NoError(t, err)
+	require.Equal(t, `"int"`, schema.Schema)
+
+	// Invalid schemas are errors
+	writeSchema(t, dir, "invalid-value", 11, `{"type":"unknown"}`)
+	_, err = registry.GetSchema(11)
+	require.Error(t, err)
+}
+
+func writeSchema(t *testing.T, dir string, subject string, id int, schema string) {
+	t.Helper()
+	writeSchemaFile(t, dir, subject, fmt.Sprintf("%d.avsc", id), []byte(schema))
+}
+
+func writeSchemaFile(t *testing.T, dir string, subject string, name string, b []byte) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, subject), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, subject, name), b, 0600))
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	serialized := func(fd *descriptorpb.FileDescriptorProto) string {
+		b, err := proto.Marshal(fd)
+		require.NoError(t, err)
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	files := testProtoFiles()
+	references := fmt.Sprintf(`[{"name":%q,"subject":"imports","version":1}]`, files[0].GetName())
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		serializedFormat := r.URL.Query().Get("format") == "serialized"
+		switch {
+		case r.URL.Path == "/schemas/ids/3":
+			_, _ = w.Write([]byte(`{"schema":"\"string\""}`))
+		case r.URL.Path == "/schemas/ids/4" && serializedFormat:
+			_, _ = fmt.Fprintf(w, `{"schemaType":"PROTOBUF","schema":%q,"references":%s}`, serialized(files[1]), references)
+		case r.URL.Path == "/schemas/ids/4":
+			_, _ = fmt.Fprintf(w, `{"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";","references":%s}`, references)
+		case r.URL.Path == "/subjects/imports/versions/1" && serializedFormat:
+			_, _ = fmt.Fprintf(w, `{"subject":"imports","version":1,"id":2,"schemaType":"PROTOBUF","schema":%q}`, serialized(files[0]))
+		case r.URL.Path == "/schemas/ids/5":
+			_, _ = w.Write([]byte(`{"schemaType":"JSON","schema":"{}"}`))
+		case r.URL.Path == "/subjects/payments-value/versions/latest":
+			_, _ = w.Write([]byte(`{"subject":"payments-value","version":2,"id":3,"schema":"\"string\""}`))
+		case r.URL.Path == "/subjects/squareup.cash.pranadb.testproto.v1.TestTypes/versions/latest":
+			_, _ = fmt.Fprintf(w, `{"version":1,"id":4,"schemaType":"PROTOBUF","schema":"syntax = \"proto3\";","references":%s}`, references)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	registry, err := NewRegistry(server.URL + "/")
+	require.NoError(t, err)
+	schema, err := registry.GetSchema(3)
+	require.NoError(t, err)
+	require.Equal(t, SchemaTypeAvro, schema.Type)
+	require.Equal(t, `"string"`, schema.Schema)
+	// Schemas are cached by id
+	_, err = registry.GetSchema(3)
+	require.NoError(t, err)
+	require.Equal(t, 1, requests)
+
+	schema, err = registry.GetLatestSchema("payments-value")
+	require.NoError(t, err)
+	require.Equal(t, int32(3), schema.ID)
+	require.Equal(t, SchemaTypeAvro, schema.Type)
+
+	// Protobuf schemas are fetched as file descriptors, with the schemas they reference
+	for _, get := range []func() (*Schema, error){
+		func() (*Schema, error) { return registry.GetSchema(4) },
+		func() (*Schema, error) {
+			return registry.GetLatestSchema("squareup.cash.pranadb.testproto.v1.TestTypes")
+		},
+	} {
+		schema, err = get()
+		require.NoError(t, err)
+		require.Equal(t, int32(4), schema.ID)
+		require.Equal(t, SchemaTypeProtobuf, schema.Type)
+		require.Equal(t, 2, len(schema.Files))
+		fd, err := schema.ProtobufFile(protolib.EmptyRegistry)
+		require.NoError(t, err)
+		_, err = FindMessage(fd, "squareup.cash.pranadb.testproto.v1.SimpleValue")
+		require.NoError(t, err)
+	}
+
+	_, err = registry.GetSchema(5)
+	require.Error(t, err)
+	_, err = registry.GetSchema(6)
+	require.Error(t, err)
+	_, err = registry.GetLatestSchema("unknown")
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package schemaregistry
+
+import (
+	"encoding/binary"
+
+	"github.com/squareup/pranadb/errors"
+)
+
+// MagicByte is the first byte of a message in the Confluent wire format
+const MagicByte byte = 0
+
+// DecodeWireFormat splits a message in the Confluent wire format into the id of the schema it was written with and
+// its payload
+func DecodeWireFormat(data []byte) (int32, []byte, error) {
+	if len(data) < 5 || data[0] != MagicByte {
+		return 0, nil, errors.Error("message is not in the schema registry wire format")
+	}
+	return int32(binary.BigEndian.Uint32(data[1:5])), data[5:], nil
+}
+
+// AppendWireFormat appends the Confluent wire format header, for a payload written with the schema id, to the buffer
+func AppendWireFormat(buff []byte, id int32) []byte {
+	buff = append(buff, MagicByte)
+	return append(buff, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+}
+
+// DecodeMessageIndexes splits the payload of a protobuf message in the Confluent wire format into the message indexes
+// that locate its message type in the schema, and the protobuf encoded message. The indexes are the path to the message
+// type through the messages of the schema's file and their nested messages. They're written as a zig-zag varint count
+// followed by a zig-zag varint for each index, except that the first message of the file, [0], is written as a count
+// of 0.
+func DecodeMessageIndexes(payload []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(payload)
+	if n <= 0 || count < 0 || count > int64(len(payload)) {
+		return nil, nil, errors.Error("protobuf message has invalid message indexes")
+	}
+	payload = payload[n:]
+	if count == 0 {
+		return []int{0}, payload, nil
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(payload)
+		if n <= 0 || index < 0 {
+			return nil, nil, errors.Error("protobuf message has invalid message indexes")
+		}
+		indexes[i] = int(index)
+		payload = payload[n:]
+	}
+	return indexes, payload, nil
+}
+
+// AppendMessageIndexes appends the message indexes of a protobuf message type to the buffer, as described in
+// DecodeMessageIndexes
+func AppendMessageIndexes(buff []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(buff, 0)
+	}
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], int64(len(indexes)))
+	buff = append(buff, b[:n]...)
+	for _, index := range indexes {
+		n = binary.PutVarint(b[:], int64(index))
+		buff = append(buff, b[:n]...)
+	}
+	return buff
+}
@@ -26,6 +26,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 
 	phttp "github.com/squareup/pranadb/api/http"
 	"github.com/squareup/pranadb/cluster"
@@ -82,6 +83,10 @@ func NewServer(config conf.Config) (*Server, error) {
 	clus.SetRemoteQueryExecutionCallback(pullEngine)
 	protoRegistry := protolib.NewProtoRegistry(metaController, clus, pullEngine, config.ProtobufDescriptorDir)
 	protoRegistry.SetNotifier(ddlClient)
+	schemaRegistry, err := schemaregistry.NewRegistry(config.SchemaRegistryURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
 	theMetrics := metrics.NewServer(config, !config.MetricsEnabled)
 	var failureInjector failinject.Injector
 	if config.FailureInjectorEnabled {
@@ -89,15 +94,19 @@ func NewServer(config conf.Config) (*Server, error) {
 	} else {
 		failureInjector = failinject.NewDummyInjector()
 	}
-	pushEngine := push.NewPushEngine(clus, shardr, metaController, &config, pullEngine, protoRegistry, failureInjector)
+	pushEngine := push.NewPushEngine(clus, shardr, metaController, &config, pullEngine, protoRegistry, schemaRegistry,
+		failureInjector)
 	clus.RegisterShardListenerFactory(pushEngine)
+	pullEngine.RegisterVirtualTable(common.SourcesTableID, push.NewSourcesTable(pushEngine))
+	pullEngine.RegisterVirtualTable(common.SinksTableID, push.NewSinksTable(pushEngine))
+	pullEngine.RegisterVirtualTable(common.ShardsTableID, push.NewShardsTable(pushEngine))
 	if drag != nil {
 		drag.SetForwardWriteHandler(pushEngine)
 	}
 	remotingServer.RegisterMessageHandler(remoting.ClusterMessageSourceSetMaxRate, pushEngine.GetLoadClientSetRateHandler())
 	remotingServer.RegisterMessageHandler(remoting.ClusterMessageForwardWriteRequest, pushEngine.GetForwardWriteHandler())
 	commandExecutor := command.NewCommandExecutor(metaController, pushEngine, pullEngine, clus, ddlClient, ddlResetClient,
-		protoRegistry, failureInjector, &config)
+		protoRegistry, schemaRegistry, failureInjector, &config)
 	remotingServer.RegisterMessageHandler(remoting.ClusterMessageDDLStatement, commandExecutor.DDlCommandRunner().DdlHandler())
 	remotingServer.RegisterMessageHandler(remoting.ClusterMessageDDLCancel, commandExecutor.DDlCommandRunner().CancelHandler())
 	remotingServer.RegisterMessageHandler(remoting.ClusterMessageReloadProtobuf, protoRegistry)
@@ -29,6 +29,7 @@ import (
 	"github.com/squareup/pranadb/command/parser"
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/protolib"
+	"github.com/squareup/pranadb/schemaregistry"
 	"google.golang.org/protobuf/types/descriptorpb"
 
 	log "github.com/sirupsen/logrus"
@@ -51,6 +52,7 @@ const (
 	ExcludedTestPrefixes = ""
 	TestClusterID        = 12345678
 	ProtoDescriptorDir   = "../protos"
+	SchemaRegistryDir    = "testdata/schemaregistry"
 )
 
 var (
@@ -199,6 +201,7 @@ func (w *sqlTestsuite) setupPranaCluster() {
 		}
 		cnf.SourceStatsEnabled = true
 		cnf.ProtobufDescriptorDir = ProtoDescriptorDir
+		cnf.SchemaRegistryURL = SchemaRegistryDir
 
 		if w.intraClusterTLSEnabled {
 			cnf.IntraClusterTLSConfig.Enabled = true
@@ -252,6 +255,7 @@ func (w *sqlTestsuite) setupPranaCluster() {
 				cnf.GRPCAPIServerListenAddresses = apiServerListenAddresses
 			}
 			cnf.ProtobufDescriptorDir = ProtoDescriptorDir
+			cnf.SchemaRegistryURL = SchemaRegistryDir
 			cnf.FailureInjectorEnabled = true
 			cnf.ScreenDragonLogSpam = true
 			cnf.RaftRTTMs = 25
@@ -290,7 +294,9 @@ func (w *sqlTestsuite) setup(fakeCluster bool, numNodes int, replicationFactor i
 	w.tlsKeysInfo = tlsKeysInfo
 	protoRegistry, err := protolib.NewDirBackedRegistry(ProtoDescriptorDir)
 	require.NoError(w.t, err)
-	w.registerEncoders(protoRegistry)
+	schemaRegistry, err := schemaregistry.NewDirBackedRegistry(SchemaRegistryDir)
+	require.NoError(w.t, err)
+	w.registerEncoders(protoRegistry, schemaRegistry)
 	w.fakeKafka = kafka.NewFakeKafka()
 
 	dataDir, err := ioutil.TempDir("", "sql-test")
@@ -352,7 +358,7 @@ func (w *sqlTestsuite) setup(fakeCluster bool, numNodes int, replicationFactor i
 	}
 }
 
-func (w *sqlTestsuite) registerEncoders(registry protolib.Resolver) {
+func (w *sqlTestsuite) registerEncoders(registry protolib.Resolver, schemaRegistry schemaregistry.Registry) {
 	w.registerEncoder(&kafka.JSONKeyJSONValueEncoder{})
 	w.registerEncoder(&kafka.StringKeyTLJSONValueEncoder{})
 	w.registerEncoder(&kafka.Int64BEKeyTLJSONValueEncoder{})
@@ -362,7 +368,12 @@ func (w *sqlTestsuite) registerEncoders(registry protolib.Resolver) {
 	w.registerEncoder(&kafka.Float32BEKeyTLJSONValueEncoder{})
 	w.registerEncoder(&kafka.NestedJSONKeyNestedJSONValueEncoder{})
 	w.registerEncoder(&kafka.JSONHeadersEncoder{})
+	w.registerEncoder(&kafka.JSONKeyJSONArrayValueEncoder{})
+	w.registerEncoder(&kafka.DebeziumJSONValueEncoder{})
 	w.registerEncoderFactory(kafka.NewStringKeyProtobufValueEncoderFactory(registry), &kafka.StringKeyProtobufValueEncoder{})
+	w.registerEncoderFactory(kafka.NewStringKeyAvroValueEncoderFactory(schemaRegistry), &kafka.StringKeyAvroValueEncoder{})
+	w.registerEncoderFactory(kafka.NewDebeziumAvroValueEncoderFactory(schemaRegistry), &kafka.DebeziumAvroValueEncoder{})
+	w.registerEncoderFactory(kafka.NewStringKeyProtobufConfluentValueEncoderFactory(registry, schemaRegistry), &kafka.StringKeyProtobufConfluentValueEncoder{})
 }
 
 func (w *sqlTestsuite) registerEncoder(encoder kafka.MessageEncoder) {
@@ -434,6 +445,8 @@ type sqlTest struct {
 	cli           *client.Client
 	clientNodeID  int
 	currentSchema string
+	// capturedTimes holds the times captured with --capture time, which statements refer to as ${name}
+	capturedTimes map[string]string
 }
 
 func (st *sqlTest) run() {
@@ -538,6 +551,8 @@ func (st *sqlTest) runTestIteration(require *require.Assertions, commands []stri
 			}
 		} else if strings.HasPrefix(command, "--pause") {
 			st.executePause(require, command)
+		} else if strings.HasPrefix(command, "--capture time") {
+			st.executeCaptureTime(require, command)
 		} else if strings.HasPrefix(command, "--get ddl lock") {
 			st.executeGetDdlLock(require)
 		} else if strings.HasPrefix(command, "--set ddl lock timeout") {
@@ -554,6 +569,9 @@ func (st *sqlTest) runTestIteration(require *require.Assertions, commands []stri
 		if strings.HasPrefix(command, "--") {
 			// Just a normal comment - ignore
 		} else {
+			// The statement is written to the output before the captured times are substituted, so the output
+			// doesn't depend on them
+			command = st.substituteCapturedTimes(command)
 			if strings.HasPrefix(command, "execps ") {
 				st.executePreparedStatement(require, command)
 			} else {
@@ -741,6 +759,7 @@ func (st *sqlTest) tableDataLeft(require *require.Assertions, prana *server.Serv
 type dataset struct {
 	name       string
 	sourceInfo *common.SourceInfo
+	topicName  string
 	colTypes   []common.ColumnType
 	rows       *common.Rows
 }
@@ -771,10 +790,19 @@ func (st *sqlTest) loadDataset(require *require.Assertions, fileName string, dsN
 				}
 				continue
 			}
+			// The source can be followed by @topic_name, for a source which consumes from more than one topic
 			sourceName := parts[1]
+			var topicName string
+			if strings.Contains(sourceName, "@") {
+				srcParts := strings.SplitN(sourceName, "@", 2)
+				sourceName, topicName = srcParts[0], srcParts[1]
+			}
 			require.NotEmpty(st.currentSchema, "no schema selected")
 			sourceInfo, ok := st.prana.GetMetaController().GetSource(st.currentSchema, sourceName)
 			require.True(ok, fmt.Sprintf("unknown source %s", sourceName))
+			if topicName == "" {
+				topicName = sourceInfo.OriginInfo.TopicName
+			}
 			if lp >= 3 {
 				encoderName := parts[2]
 				options := ""
@@ -796,7 +824,7 @@ func (st *sqlTest) loadDataset(require *require.Assertions, fileName string, dsN
 			}
 			rf := common.NewRowsFactory(colTypes)
 			rows := rf.NewRows(100)
-			currDataSet = &dataset{name: dataSetName, sourceInfo: sourceInfo, rows: rows, colTypes: colTypes}
+			currDataSet = &dataset{name: dataSetName, sourceInfo: sourceInfo, topicName: topicName, rows: rows, colTypes: colTypes}
 		} else {
 			if currDataSet == nil {
 				continue
@@ -872,7 +900,7 @@ func (st *sqlTest) doLoadData(require *require.Assertions, command string, noWai
 	if !noWait {
 		initialCommitted = st.getNumCommitted(require, dataset.sourceInfo.ID)
 	}
-	err := kafka.IngestRows(fakeKafka, dataset.sourceInfo, dataset.colTypes, dataset.rows, encoder)
+	err := kafka.IngestRows(fakeKafka, dataset.topicName, dataset.sourceInfo, dataset.colTypes, dataset.rows, encoder)
 	require.NoError(err)
 	if !noWait {
 		st.waitForCommitted(require, dataset.rows.RowCount()+initialCommitted, dataset.sourceInfo.ID)
@@ -1076,6 +1104,29 @@ func (st *sqlTest) executePause(require *require.Assertions, command string) {
 	require.NoError(err)
 }
 
+// executeCaptureTime captures the current time in unix seconds, with microseconds, so a later statement can query as
+// of it with from_unixtime(${name}). Processing is waited for first, and the time is a little after anything already
+// written and a little before anything written next.
+func (st *sqlTest) executeCaptureTime(require *require.Assertions, command string) {
+	name := strings.TrimSpace(command[15:])
+	require.NotEmpty(name, "--capture time requires a name")
+	st.waitForProcessingToComplete(require)
+	time.Sleep(10 * time.Millisecond)
+	now := time.Now().UnixMicro()
+	time.Sleep(10 * time.Millisecond)
+	if st.capturedTimes == nil {
+		st.capturedTimes = make(map[string]string)
+	}
+	st.capturedTimes[name] = fmt.Sprintf("%d.%06d", now/1000000, now%1000000)
+}
+
+func (st *sqlTest) substituteCapturedTimes(statement string) string {
+	for name, captured := range st.capturedTimes {
+		statement = strings.ReplaceAll(statement, "${"+name+"}", captured)
+	}
+	return statement
+}
+
 func (st *sqlTest) executeGetDdlLock(require *require.Assertions) {
 	//choose a random Prana
 	prana := st.choosePrana()
@@ -30,12 +30,28 @@ func EncodeTableKeyPrefix(tableID uint64, shardID uint64, capac int) []byte {
 
 func encodeKeyFromRow(tableInfo *common.TableInfo, row *common.Row, shardID uint64) ([]byte, error) {
 	keyBuff := EncodeTableKeyPrefix(tableInfo.ID, shardID, 32)
-	return common.EncodeKeyCols(row, tableInfo.PrimaryKeyCols, tableInfo.ColumnTypes, keyBuff)
+	return common.EncodeOrderedKeyCols(row, tableInfo.PrimaryKeyCols, tableInfo.PrimaryKeyDesc, tableInfo.ColumnTypes, keyBuff)
 }
 
 func EncodeIndexKeyValue(tableInfo *common.TableInfo, indexInfo *common.IndexInfo, shardID uint64, row *common.Row) ([]byte, []byte, error) {
+	return encodeIndexKeyValue(tableInfo, indexInfo, shardID, row, row, indexInfo.IndexCols, tableInfo.ColumnTypes)
+}
+
+// EncodeFunctionalIndexKeyValue encodes the entry for a row in an index which has expressions in its key. keyRow holds
+// the values of the columns of the index key, which have already been evaluated from the row.
+func EncodeFunctionalIndexKeyValue(tableInfo *common.TableInfo, indexInfo *common.IndexInfo, shardID uint64, row *common.Row,
+	keyRow *common.Row) ([]byte, []byte, error) {
+	keyCols := make([]int, len(indexInfo.IndexCols))
+	for i := range keyCols {
+		keyCols[i] = i
+	}
+	return encodeIndexKeyValue(tableInfo, indexInfo, shardID, row, keyRow, keyCols, indexInfo.KeyColTypes(tableInfo.ColumnTypes))
+}
+
+func encodeIndexKeyValue(tableInfo *common.TableInfo, indexInfo *common.IndexInfo, shardID uint64, row *common.Row,
+	keyRow *common.Row, keyCols []int, keyColTypes []common.ColumnType) ([]byte, []byte, error) {
 	keyBuff := EncodeTableKeyPrefix(indexInfo.ID, shardID, 32)
-	keyBuff, err := common.EncodeIndexKeyCols(row, indexInfo.IndexCols, tableInfo.ColumnTypes, keyBuff)
+	keyBuff, err := common.EncodeIndexKeyCols(keyRow, keyCols, indexInfo.IndexColsDesc, keyColTypes, keyBuff)
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
@@ -44,10 +60,18 @@ func EncodeIndexKeyValue(tableInfo *common.TableInfo, indexInfo *common.IndexInf
 	// It needs to be on the key to make the entry unique (for non unique indexes)
 	// and on the value so we can make looking up the PK easy for non covering indexes without having to parse the
 	// whole key
-	keyBuff, err = common.EncodeKeyCols(row, tableInfo.PrimaryKeyCols, tableInfo.ColumnTypes, keyBuff)
+	keyBuff, err = common.EncodeOrderedKeyCols(row, tableInfo.PrimaryKeyCols, tableInfo.PrimaryKeyDesc, tableInfo.ColumnTypes, keyBuff)
 	if err != nil {
 		return nil, nil, errors.WithStack(err)
 	}
-	valueBuff := keyBuff[pkStart:] // Value is just the PK
+	valueBuff := keyBuff[pkStart:] // Value is the PK
+	if len(indexInfo.IncludeCols) > 0 {
+		// Followed by any included cols, so covering queries don't need to look up the row in the table
+		valueBuff = append([]byte{}, valueBuff...)
+		valueBuff, err = common.EncodeIndexKeyCols(row, indexInfo.IncludeCols, nil, tableInfo.ColumnTypes, valueBuff)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+	}
 	return keyBuff, valueBuff, nil
 }
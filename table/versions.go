@@ -0,0 +1,87 @@
+package table
+
+import (
+	"github.com/squareup/pranadb/cluster"
+	"github.com/squareup/pranadb/common"
+)
+
+// The version table of a table holds two entries for each prior version of a row. The version entry is keyed on the
+// primary key of the row and then the time the version was current until, so the versions of a range of rows can be
+// scanned in the order of their keys. The expiry entry is keyed on the time and then the primary key, so expired
+// versions can be found in the order they expire, in the same way the row time index is used for retention.
+const (
+	versionEntryMarker byte = 0
+	expiryEntryMarker  byte = 1
+)
+
+// AddVersion adds the entries for the version of the row with the given encoded primary key which was current until
+// endTime (in unix micros), where prevValue is the encoded row which was current, or nil if there was no row.
+func AddVersion(wb *cluster.WriteBatch, versionTableID uint64, shardID uint64, pk []byte, endTime int64, prevValue []byte) {
+	wb.AddPut(EncodeVersionKey(versionTableID, shardID, pk, endTime), EncodeVersionValue(prevValue))
+	// The expiry entry holds no row
+	wb.AddPut(EncodeVersionExpiryKey(versionTableID, shardID, endTime, pk), EncodeVersionValue(nil))
+}
+
+// EncodeVersionKey encodes the key of the version entry for the version of the row with the given encoded primary key
+// which was current until endTime.
+func EncodeVersionKey(versionTableID uint64, shardID uint64, pk []byte, endTime int64) []byte {
+	keyBuff := EncodeVersionKeyPrefix(versionTableID, shardID, pk)
+	return common.AppendUint64ToBufferBE(keyBuff, uint64(endTime))
+}
+
+// EncodeVersionKeyPrefix encodes the start of the keys of the version entries for the row with the given encoded
+// primary key, or, if pk is not a whole key, the version entries for rows with keys from pk.
+func EncodeVersionKeyPrefix(versionTableID uint64, shardID uint64, pk []byte) []byte {
+	keyBuff := EncodeTableKeyPrefix(versionTableID, shardID, 25+len(pk))
+	keyBuff = append(keyBuff, versionEntryMarker)
+	return append(keyBuff, pk...)
+}
+
+// EncodeVersionEntriesEnd encodes the end of the range of keys of the version entries of a version table in a shard.
+func EncodeVersionEntriesEnd(versionTableID uint64, shardID uint64) []byte {
+	return append(EncodeTableKeyPrefix(versionTableID, shardID, 17), expiryEntryMarker)
+}
+
+// DecodeVersionKey returns the end time and the encoded primary key of the version entry with the given key.
+func DecodeVersionKey(key []byte) (int64, []byte) {
+	endTime, _ := common.ReadUint64FromBufferBE(key, len(key)-8)
+	return int64(endTime), key[17 : len(key)-8]
+}
+
+// EncodeVersionExpiryKey encodes the key of the expiry entry for the version of the row with the given encoded
+// primary key which was current until endTime.
+func EncodeVersionExpiryKey(versionTableID uint64, shardID uint64, endTime int64, pk []byte) []byte {
+	keyBuff := EncodeVersionExpiryPrefix(versionTableID, shardID)
+	keyBuff = common.AppendUint64ToBufferBE(keyBuff, uint64(endTime))
+	return append(keyBuff, pk...)
+}
+
+// EncodeVersionExpiryPrefix encodes the start of the keys of the expiry entries of a version table in a shard.
+func EncodeVersionExpiryPrefix(versionTableID uint64, shardID uint64) []byte {
+	return append(EncodeTableKeyPrefix(versionTableID, shardID, 33), expiryEntryMarker)
+}
+
+// DecodeVersionExpiryKey returns the end time and the encoded primary key of the expiry entry with the given key.
+func DecodeVersionExpiryKey(key []byte) (int64, []byte) {
+	endTime, _ := common.ReadUint64FromBufferBE(key, 17)
+	return int64(endTime), key[25:]
+}
+
+// EncodeVersionValue encodes the value of an entry in a version table, where prevValue is the encoded row which was
+// current, or nil if there was no row with the key.
+func EncodeVersionValue(prevValue []byte) []byte {
+	if prevValue == nil {
+		return []byte{0}
+	}
+	valueBuff := make([]byte, 0, 1+len(prevValue))
+	valueBuff = append(valueBuff, 1)
+	return append(valueBuff, prevValue...)
+}
+
+// DecodeVersionValue returns the encoded row held in an entry in a version table, or false if there was no row.
+func DecodeVersionValue(value []byte) ([]byte, bool) {
+	if value[0] == 0 {
+		return nil, false
+	}
+	return value[1:], true
+}
@@ -68,6 +68,11 @@ func (impl *IndexScanImpl) CalcCost(outCount float64, children ...Implementation
 		cost = outCount * rowSize * sessVars.GetDescScanFactor(is.Table)
 	}
 	cost += float64(len(is.Ranges)) * sessVars.GetSeekFactor(is.Table)
+	if is.DoubleRead {
+		// Each row found in the index requires a point lookup of the row in the table
+		tableRowSize := impl.tblColHists.GetTableAvgRowSize(is.SCtx(), is.dataSourceSchema.Columns, true)
+		cost += outCount * (tableRowSize*sessVars.GetScanFactor(is.Table) + sessVars.GetSeekFactor(is.Table))
+	}
 	impl.cost = cost
 	return impl.cost
 }
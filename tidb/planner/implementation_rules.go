@@ -97,7 +97,8 @@ type ImplTableScan struct {
 // Match implements ImplementationRule Match interface.
 func (r *ImplTableScan) Match(expr *GroupExpr, prop *property.PhysicalProperty) (matched bool) {
 	ts := expr.ExprNode.(*LogicalTableScan)
-	return prop.IsEmpty() || (len(prop.SortItems) == 1 && ts.HandleCols != nil && prop.SortItems[0].Col.Equal(nil, ts.HandleCols.GetCol(0)))
+	return prop.IsEmpty() || (len(prop.SortItems) == 1 && ts.HandleCols != nil && prop.SortItems[0].Col.Equal(nil, ts.HandleCols.GetCol(0)) &&
+		prop.SortItems[0].Desc == ts.Source.handleColDesc())
 }
 
 // OnImplement implements ImplementationRule OnImplement interface.
@@ -108,6 +109,7 @@ func (r *ImplTableScan) OnImplement(expr *GroupExpr, reqProp *property.PhysicalP
 	if !reqProp.IsEmpty() {
 		ts.KeepOrder = true
 		ts.Desc = reqProp.SortItems[0].Desc
+		ts.ByItems = sortItemsToByItems(reqProp.SortItems)
 	}
 	tblCols, tblColHists := logicalScan.Source.TblCols, logicalScan.Source.TblColHists
 	return []Implementation{NewTableScanImpl(ts, tblCols, tblColHists)}, nil
@@ -120,7 +122,7 @@ type ImplIndexScan struct {
 // Match implements ImplementationRule Match interface.
 func (r *ImplIndexScan) Match(expr *GroupExpr, prop *property.PhysicalProperty) (matched bool) {
 	is := expr.ExprNode.(*LogicalIndexScan)
-	return is.MatchIndexProp(prop)
+	return is.CanReadIndex() && is.MatchIndexProp(prop)
 }
 
 // OnImplement implements ImplementationRule OnImplement interface.
@@ -132,6 +134,7 @@ func (r *ImplIndexScan) OnImplement(expr *GroupExpr, reqProp *property.PhysicalP
 		if reqProp.SortItems[0].Desc {
 			is.Desc = true
 		}
+		is.ByItems = sortItemsToByItems(reqProp.SortItems)
 	}
 	return []Implementation{NewIndexScanImpl(is, logicalScan.Source.TblColHists)}, nil
 }
@@ -316,6 +319,15 @@ func matchItems(p *property.PhysicalProperty, items []*util.ByItems) bool {
 	return true
 }
 
+// sortItemsToByItems converts the sort items of a required property to the order a scan must return its rows in.
+func sortItemsToByItems(sortItems []property.SortItem) []*util.ByItems {
+	byItems := make([]*util.ByItems, len(sortItems))
+	for i, item := range sortItems {
+		byItems[i] = &util.ByItems{Expr: item.Col, Desc: item.Desc}
+	}
+	return byItems
+}
+
 // getPropByOrderByItems will check if this sort property can be pushed or not. In order to simplify the problem, we only
 // consider the case that all expression are columns.
 func getPropByOrderByItems(items []*util.ByItems) (*property.PhysicalProperty, bool) {
@@ -22,6 +22,7 @@ import (
 	"math"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/model"
@@ -75,6 +76,12 @@ type LogicalDataSource struct {
 	TblColHists *statistics.HistColl
 
 	is infoschema.InfoSchema
+
+	// indexExprs holds the key expressions and predicates of functional and partial indexes, keyed by index id
+	indexExprs map[int64]*indexExprs
+
+	// AsOf is the time in the past as of which the rows of the table are read, or nil to read the current rows
+	AsOf *time.Time
 }
 
 // Init initializes LogicalDataSource.
@@ -83,6 +90,12 @@ func (ds LogicalDataSource) Init(ctx sessionctx.Context, offset int) *LogicalDat
 	return &ds
 }
 
+// handleColDesc returns true if the handle column of the table is stored in descending order.
+func (ds *LogicalDataSource) handleColDesc() bool {
+	desc := getPrimaryKeyDesc(ds.is, ds.tableInfo)
+	return desc != nil && desc[0]
+}
+
 // BuildKeyInfo implements LogicalPlan BuildKeyInfo interface.
 func (ds *LogicalDataSource) BuildKeyInfo(selfSchema *expression.Schema, childSchema []*expression.Schema) {
 	selfSchema.Keys = nil
@@ -109,9 +122,77 @@ func (ds *LogicalDataSource) BuildKeyInfo(selfSchema *expression.Schema, childSc
 func (ds *LogicalDataSource) buildTableScan() LogicalPlan {
 	ts := LogicalTableScan{Source: ds, HandleCols: ds.handleCols}.Init(ds.ctx, ds.blockOffset)
 	ts.SetSchema(ds.Schema())
+	// The scan covers the whole table until conditions are pushed down into it. We set the range here as the scan may
+	// share a group with other scans, in which case its stats, and therefore its ranges, may never be derived.
+	isUnsigned := false
+	if ds.tableInfo.PKIsHandle {
+		if pkColInfo := ds.tableInfo.GetPkColInfo(); pkColInfo != nil {
+			isUnsigned = mysql.HasUnsignedFlag(pkColInfo.Flag)
+		}
+	}
+	ts.Ranges = ranger.FullIntRange(isUnsigned)
 	return ts
 }
 
+// indexInfo2Cols is like expression.IndexInfo2Cols but an expression in the key of a functional index is represented by
+// the column which stands in for it.
+func (ds *LogicalDataSource) indexInfo2Cols(colInfos []*model.ColumnInfo, cols []*expression.Column, index *model.IndexInfo) ([]*expression.Column, []int) {
+	idxCols, idxColLens := expression.IndexInfo2Cols(colInfos, cols, index)
+	if ie, ok := ds.indexExprs[index.ID]; ok {
+		for i, keyCol := range ie.keyCols {
+			if keyCol != nil {
+				idxCols[i] = keyCol
+			}
+		}
+	}
+	return idxCols, idxColLens
+}
+
+// indexInfo2PrefixCols is like expression.IndexInfo2PrefixCols but an expression in the key of a functional index is
+// represented by the column which stands in for it.
+func (ds *LogicalDataSource) indexInfo2PrefixCols(colInfos []*model.ColumnInfo, cols []*expression.Column, index *model.IndexInfo) ([]*expression.Column, []int) {
+	if _, ok := ds.indexExprs[index.ID]; !ok {
+		return expression.IndexInfo2PrefixCols(colInfos, cols, index)
+	}
+	idxCols, idxColLens := ds.indexInfo2Cols(colInfos, cols, index)
+	for i, col := range idxCols {
+		if col == nil {
+			return idxCols[:i], idxColLens[:i]
+		}
+	}
+	return idxCols, idxColLens
+}
+
+// indexPredicate returns the conditions a row must satisfy to be in the index, or nil if all rows are indexed.
+func (ds *LogicalDataSource) indexPredicate(index *model.IndexInfo) []expression.Expression {
+	if ie, ok := ds.indexExprs[index.ID]; ok {
+		return ie.predicate
+	}
+	return nil
+}
+
+// indexKeyExprs returns the key expressions of a functional index and the columns which stand in for them, or nil if
+// the index only has plain columns in its key.
+func (ds *LogicalDataSource) indexKeyExprs(index *model.IndexInfo) ([]expression.Expression, []*expression.Column) {
+	if ie, ok := ds.indexExprs[index.ID]; ok && ie.hasKeyExprs() {
+		return ie.keyExprs, ie.keyCols
+	}
+	return nil, nil
+}
+
+// leadingIndexCols returns the index columns to check when deciding whether an index partially covers a query. When
+// the index key starts with an expression we use the table columns the expression is evaluated over.
+func (ds *LogicalDataSource) leadingIndexCols(path *util.AccessPath) []*expression.Column {
+	keyExprs, _ := ds.indexKeyExprs(path.Index)
+	if len(keyExprs) == 0 || keyExprs[0] == nil {
+		return path.FullIdxCols
+	}
+	if cols := expression.ExtractColumns(keyExprs[0]); len(cols) > 0 {
+		return cols
+	}
+	return path.FullIdxCols
+}
+
 func (ds *LogicalDataSource) buildIndexScan(path *util.AccessPath, isDoubleRead bool) LogicalPlan {
 	is := LogicalIndexScan{
 		Source:         ds,
@@ -126,24 +207,46 @@ func (ds *LogicalDataSource) buildIndexScan(path *util.AccessPath, isDoubleRead
 	is.Columns = make([]*model.ColumnInfo, len(ds.Columns))
 	copy(is.Columns, ds.Columns)
 	is.SetSchema(ds.Schema())
-	is.IdxCols, is.IdxColLens = expression.IndexInfo2PrefixCols(is.Columns, is.schema.Columns, is.Index)
+	is.IdxCols, is.IdxColLens = ds.indexInfo2PrefixCols(is.Columns, is.schema.Columns, is.Index)
+	// As with the table scan, the index scan may not be the expression its group's stats are derived from
+	is.Ranges = ranger.FullRange()
 	return is
 }
 
 func (ds *LogicalDataSource) Convert2Scans() (scans []LogicalPlan) {
+	// A partial index can only be used when the query implies its predicate, so we don't count scans of them when
+	// deciding whether a table scan is needed
+	fullScans := 0
 	for _, path := range ds.possibleAccessPaths {
 		if !path.IsIntHandlePath {
-			path.FullIdxCols, path.FullIdxColLens = expression.IndexInfo2Cols(ds.Columns, ds.schema.Columns, path.Index)
-			path.IdxCols, path.IdxColLens = expression.IndexInfo2PrefixCols(ds.Columns, ds.schema.Columns, path.Index)
+			path.FullIdxCols, path.FullIdxColLens = ds.indexInfo2Cols(ds.Columns, ds.schema.Columns, path.Index)
+			path.IdxCols, path.IdxColLens = ds.indexInfo2PrefixCols(ds.Columns, ds.schema.Columns, path.Index)
+			// Any included columns are stored in the index entry so they count towards covering, but they're not
+			// part of the key so can't be used for ranges
+			coveringCols, coveringColLens := path.FullIdxCols, path.FullIdxColLens
+			if includes := getIndexIncludes(ds.is, path.Index); len(includes) > 0 {
+				includeCols, includeColLens := expression.IndexInfo2Cols(ds.Columns, ds.schema.Columns, &model.IndexInfo{Columns: includes})
+				coveringCols = append(append([]*expression.Column{}, coveringCols...), includeCols...)
+				coveringColLens = append(append([]int{}, coveringColLens...), includeColLens...)
+			}
 			// If index columns can cover all of the needed columns, we can use a IndexGather + IndexScan.
-			if ds.isCoveringIndex(ds.schema.Columns, path.FullIdxCols, path.FullIdxColLens, ds.tableInfo) {
-				scans = append(scans, ds.buildIndexScan(path, false))
-			} else if ds.isPartiallyCoveringIndex(ds.schema.Columns, path.FullIdxCols, ds.tableInfo) {
-				scans = append(scans, ds.buildIndexScan(path, true))
+			var scan LogicalPlan
+			if ds.isCoveringIndex(ds.schema.Columns, coveringCols, coveringColLens, ds.tableInfo) {
+				scan = ds.buildIndexScan(path, false)
+			} else if ds.isPartiallyCoveringIndex(ds.schema.Columns, ds.leadingIndexCols(path), ds.tableInfo) {
+				scan = ds.buildIndexScan(path, true)
+			}
+			if scan != nil {
+				scans = append(scans, scan)
+				if ds.indexPredicate(path.Index) == nil {
+					fullScans++
+				}
 			}
 		}
 	}
-	if len(scans) == 0 {
+	// Without collected statistics we can't compare costs meaningfully so we prefer an index scan where one applies.
+	// Once the table has been analyzed we always offer the table scan too, and let the optimizer choose by cost.
+	if fullScans == 0 || (ds.tableInfo.PKIsHandle && ds.hasCollectedStats()) {
 		// If the PK has more than one column we will have created a fake index for the planner which should
 		// result in an index scan being output from the physical plan which we then convert back to a table scan
 		// So we only need to create a table scan here in the case where the PK has one column
@@ -616,15 +719,26 @@ func (ds *LogicalDataSource) getGroupNDVs(colGroups [][]*expression.Column) []pr
 	return ndvs
 }
 
+func (ds *LogicalDataSource) getStatisticTable() *statistics.Table {
+	if ds.statisticTable == nil {
+		ds.statisticTable = getStatsTable(ds.is, ds.tableInfo)
+	}
+	return ds.statisticTable
+}
+
+// hasCollectedStats returns true if statistics have been collected for the table, rather than pseudo statistics
+// being used.
+func (ds *LogicalDataSource) hasCollectedStats() bool {
+	return !ds.getStatisticTable().Pseudo
+}
+
 func (ds *LogicalDataSource) initStats(colGroups [][]*expression.Column) {
 	if ds.tableStats != nil {
 		// Reload GroupNDVs since colGroups may have changed.
 		ds.tableStats.GroupNDVs = ds.getGroupNDVs(colGroups)
 		return
 	}
-	if ds.statisticTable == nil {
-		ds.statisticTable = getStatsTable(ds.tableInfo)
-	}
+	ds.getStatisticTable()
 	tableStats := &property.StatsInfo{
 		RowCount:     float64(ds.statisticTable.Count),
 		ColNDVs:      make(map[int64]float64, ds.schema.Len()),
@@ -47,6 +47,9 @@ type LogicalIndexScan struct {
 	FullIdxColLens []int
 	IdxCols        []*expression.Column
 	IdxColLens     []int
+	// PredicateImplied is true when the conditions pushed down to a partial index imply its predicate, so it holds all
+	// the rows the query needs
+	PredicateImplied bool
 }
 
 // Init initializes LogicalIndexScan.
@@ -55,17 +58,29 @@ func (is LogicalIndexScan) Init(ctx sessionctx.Context, offset int) *LogicalInde
 	return &is
 }
 
+// CanReadIndex checks if the index holds all the rows the scan must return. This is always the case unless it's a
+// partial index whose predicate isn't implied by the conditions pushed down to the scan.
+func (p *LogicalIndexScan) CanReadIndex() bool {
+	return p.PredicateImplied || p.Source.indexPredicate(p.Index) == nil
+}
+
 // MatchIndexProp checks if the indexScan can match the required property.
 func (p *LogicalIndexScan) MatchIndexProp(prop *property.PhysicalProperty) (match bool) {
 	if prop.IsEmpty() {
 		return true
 	}
-	if all, _ := prop.AllSameOrder(); !all {
-		return false
-	}
 	for i, col := range p.IdxCols {
 		if col.Equal(nil, prop.SortItems[0].Col) {
-			return matchIndicesProp(p.IdxCols[i:], p.IdxColLens[i:], prop.SortItems)
+			if !matchIndicesProp(p.IdxCols[i:], p.IdxColLens[i:], prop.SortItems) {
+				return false
+			}
+			// The index is only scanned in the order it's stored in, so each column must be stored in the required order
+			for j, item := range prop.SortItems {
+				if item.Desc != p.idxColDesc(i+j) {
+					return false
+				}
+			}
+			return true
 		} else if i >= p.EqCondCount {
 			break
 		}
@@ -73,6 +88,16 @@ func (p *LogicalIndexScan) MatchIndexProp(prop *property.PhysicalProperty) (matc
 	return false
 }
 
+// idxColDesc returns true if the column at the position in IdxCols is stored in descending order.
+func (p *LogicalIndexScan) idxColDesc(pos int) bool {
+	if pos < len(p.Index.Columns) {
+		desc := getIndexColsDesc(p.Source.is, p.Index)
+		return desc != nil && desc[pos]
+	}
+	// It's the handle column which is appended to the index columns
+	return p.Source.handleColDesc()
+}
+
 // GetPhysicalIndexScan returns PhysicalIndexScan for the logical IndexScan.
 func (s *LogicalIndexScan) GetPhysicalIndexScan(schema *expression.Schema, stats *property.StatsInfo) *PhysicalIndexScan {
 	ds := s.Source
@@ -86,6 +111,7 @@ func (s *LogicalIndexScan) GetPhysicalIndexScan(schema *expression.Schema, stats
 		IdxColLens:       s.IdxColLens,
 		Ranges:           s.Ranges,
 		dataSourceSchema: ds.schema,
+		DoubleRead:       s.IsDoubleRead,
 	}.Init(ds.ctx, ds.blockOffset)
 	is.stats = stats
 	is.initSchema(s.FullIdxCols, s.IsDoubleRead)
@@ -141,8 +167,8 @@ func (is *LogicalIndexScan) DeriveStats(childStats []*property.StatsInfo, selfSc
 	if len(is.AccessConds) == 0 {
 		is.Ranges = ranger.FullRange()
 	}
-	is.IdxCols, is.IdxColLens = expression.IndexInfo2PrefixCols(is.Columns, selfSchema.Columns, is.Index)
-	is.FullIdxCols, is.FullIdxColLens = expression.IndexInfo2Cols(is.Columns, selfSchema.Columns, is.Index)
+	is.IdxCols, is.IdxColLens = is.Source.indexInfo2PrefixCols(is.Columns, selfSchema.Columns, is.Index)
+	is.FullIdxCols, is.FullIdxColLens = is.Source.indexInfo2Cols(is.Columns, selfSchema.Columns, is.Index)
 	if !is.Index.Unique && !is.Index.Primary && len(is.Index.Columns) == len(is.IdxCols) {
 		handleCol := is.getPKIsHandleCol(selfSchema)
 		if handleCol != nil && !mysql.HasUnsignedFlag(handleCol.RetType.Flag) {
@@ -24,6 +24,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/cznic/mathutil"
@@ -35,6 +36,7 @@ import (
 	"github.com/squareup/pranadb/errors"
 	"github.com/squareup/pranadb/tidb/expression"
 	"github.com/squareup/pranadb/tidb/expression/aggregation"
+	"github.com/squareup/pranadb/tidb/infoschema"
 	"github.com/squareup/pranadb/tidb/planner/util"
 	"github.com/squareup/pranadb/tidb/sessionctx"
 	"github.com/squareup/pranadb/tidb/statistics"
@@ -2569,12 +2571,173 @@ func (b *PlanBuilder) buildSelect(sel *ast.SelectStmt) (p LogicalPlan, err error
 	return p, nil
 }
 
-// getStatsTable gets statistics information for a table specified by "tableID".
-// A pseudo statistics table is returned in any of the following scenario:
-// 1. tidb-server started and statistics handle has not been initialized.
-// 2. table row count from statistics is zero.
-// 3. statistics is outdated.
-func getStatsTable(tblInfo *model.TableInfo) *statistics.Table {
+// StatsProvider can be implemented by an InfoSchema to provide statistics which have been collected for a table.
+type StatsProvider interface {
+	// TableStats returns the statistics for the table, or nil if none have been collected.
+	TableStats(tblInfo *model.TableInfo) *statistics.Table
+}
+
+// IndexIncludesProvider can be implemented by an InfoSchema to provide the columns which are stored in an index entry
+// in addition to the index key columns.
+type IndexIncludesProvider interface {
+	// IndexIncludes returns the included columns of the index, or nil if there are none.
+	IndexIncludes(idxInfo *model.IndexInfo) []*model.IndexColumn
+}
+
+// getIndexIncludes gets the included columns of an index, if the info schema provides them.
+func getIndexIncludes(is infoschema.InfoSchema, idxInfo *model.IndexInfo) []*model.IndexColumn {
+	if provider, ok := is.(IndexIncludesProvider); ok {
+		return provider.IndexIncludes(idxInfo)
+	}
+	return nil
+}
+
+// KeyOrderProvider can be implemented by an InfoSchema to provide which columns of primary keys and indexes are
+// stored in descending order.
+type KeyOrderProvider interface {
+	// IndexColsDesc returns true for each column of the index which is descending, or nil if they are all ascending.
+	IndexColsDesc(idxInfo *model.IndexInfo) []bool
+	// PrimaryKeyDesc returns true for each column of the primary key of the table which is descending, or nil if they
+	// are all ascending.
+	PrimaryKeyDesc(tblInfo *model.TableInfo) []bool
+}
+
+// getIndexColsDesc gets which columns of an index are descending, if the info schema provides them.
+func getIndexColsDesc(is infoschema.InfoSchema, idxInfo *model.IndexInfo) []bool {
+	if provider, ok := is.(KeyOrderProvider); ok {
+		return provider.IndexColsDesc(idxInfo)
+	}
+	return nil
+}
+
+// getPrimaryKeyDesc gets which columns of the primary key of a table are descending, if the info schema provides them.
+func getPrimaryKeyDesc(is infoschema.InfoSchema, tblInfo *model.TableInfo) []bool {
+	if provider, ok := is.(KeyOrderProvider); ok {
+		return provider.PrimaryKeyDesc(tblInfo)
+	}
+	return nil
+}
+
+// IndexExprsProvider can be implemented by an InfoSchema to provide the key expressions of a functional index and the
+// predicate of a partial index.
+type IndexExprsProvider interface {
+	// IndexExprs returns the key expression for each column of the index, nil where the column is a plain table column,
+	// and the predicate of the index, nil if it indexes all rows. Fresh nodes must be returned on each call.
+	IndexExprs(idxInfo *model.IndexInfo) ([]ast.ExprNode, ast.ExprNode, error)
+}
+
+// indexExprs holds the key expressions and predicate of an index resolved against a data source.
+type indexExprs struct {
+	// keyExprs holds the expression for each column of the index key, nil where the key column is a table column
+	keyExprs []expression.Expression
+	// keyCols holds the columns which stand in for the key expressions when matching conditions against the index
+	keyCols []*expression.Column
+	// predicate holds the conditions a row must satisfy to be in the index
+	predicate []expression.Expression
+}
+
+func (ie *indexExprs) hasKeyExprs() bool {
+	for _, expr := range ie.keyExprs {
+		if expr != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// buildIndexExprs resolves the key expressions and predicates of the indexes of a data source, if the info schema
+// provides them.
+func (b *PlanBuilder) buildIndexExprs(ds *LogicalDataSource) (map[int64]*indexExprs, error) {
+	provider, ok := b.is.(IndexExprsProvider)
+	if !ok {
+		return nil, nil
+	}
+	var result map[int64]*indexExprs
+	for _, path := range ds.possibleAccessPaths {
+		if path.IsIntHandlePath {
+			continue
+		}
+		keyExprNodes, predicateNode, err := provider.IndexExprs(path.Index)
+		if err != nil {
+			return nil, err
+		}
+		if keyExprNodes == nil && predicateNode == nil {
+			continue
+		}
+		ie := &indexExprs{
+			keyExprs: make([]expression.Expression, len(path.Index.Columns)),
+			keyCols:  make([]*expression.Column, len(path.Index.Columns)),
+		}
+		for i, node := range keyExprNodes {
+			if node == nil {
+				continue
+			}
+			expr, err := b.rewriteIndexExpr(node, ds)
+			if err != nil {
+				return nil, err
+			}
+			ie.keyExprs[i] = expr
+			ie.keyCols[i] = &expression.Column{
+				UniqueID: b.ctx.GetSessionVars().AllocPlanColumnID(),
+				RetType:  expr.GetType().Clone(),
+			}
+		}
+		if predicateNode != nil {
+			predicate, err := b.rewriteIndexExpr(predicateNode, ds)
+			if err != nil {
+				return nil, err
+			}
+			ie.predicate = expression.SplitCNFItems(predicate)
+		}
+		if result == nil {
+			result = make(map[int64]*indexExprs)
+		}
+		result[path.Index.ID] = ie
+	}
+	return result, nil
+}
+
+func (b *PlanBuilder) rewriteIndexExpr(node ast.ExprNode, ds *LogicalDataSource) (expression.Expression, error) {
+	expr, np, err := b.rewrite(node, ds, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	if np != ds {
+		return nil, errors.New("index expressions must be evaluated over a single row")
+	}
+	return expr, nil
+}
+
+// evalAsOfTime evaluates the time in an AS OF TIMESTAMP clause, which can't refer to any columns.
+func (b *PlanBuilder) evalAsOfTime(asOf *ast.AsOfClause) (time.Time, error) {
+	expr, _, err := b.rewrite(asOf.TsExpr, nil, nil, true)
+	if err != nil {
+		return time.Time{}, err
+	}
+	d, err := expr.Eval(chunk.Row{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if d.IsNull() {
+		return time.Time{}, errors.NewPranaErrorf(errors.InvalidStatement, "AS OF TIMESTAMP cannot be null")
+	}
+	tp := types.NewFieldType(mysql.TypeDatetime)
+	tp.Decimal = int(types.MaxFsp)
+	td, err := d.ConvertTo(b.ctx.GetSessionVars().StmtCtx, tp)
+	if err != nil {
+		return time.Time{}, errors.NewPranaErrorf(errors.InvalidStatement, "invalid AS OF TIMESTAMP %s", d.String())
+	}
+	return td.GetMysqlTime().GoTime(b.ctx.GetSessionVars().Location())
+}
+
+// getStatsTable gets statistics information for a table.
+// A pseudo statistics table is returned if the info schema does not provide statistics for the table.
+func getStatsTable(is infoschema.InfoSchema, tblInfo *model.TableInfo) *statistics.Table {
+	if provider, ok := is.(StatsProvider); ok {
+		if statsTbl := provider.TableStats(tblInfo); statsTbl != nil {
+			return statsTbl
+		}
+	}
 	return statistics.PseudoTable(tblInfo)
 }
 
@@ -2598,6 +2761,22 @@ func (b *PlanBuilder) buildDataSource(tn *ast.TableName, asName *model.CIStr) (L
 	if err != nil {
 		return nil, err
 	}
+	var asOf *time.Time
+	if tn.AsOf != nil {
+		t, err := b.evalAsOfTime(tn.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		asOf = &t
+		// Prior versions of rows are only kept for the table, not for its indexes, so we can only scan the table
+		tablePaths := possiblePaths[:0]
+		for _, path := range possiblePaths {
+			if path.IsTablePath() {
+				tablePaths = append(tablePaths, path)
+			}
+		}
+		possiblePaths = tablePaths
+	}
 
 	// Try to substitute generate column only if there is an index on generate column.
 	for _, index := range tableInfo.Indices {
@@ -2605,6 +2784,10 @@ func (b *PlanBuilder) buildDataSource(tn *ast.TableName, asName *model.CIStr) (L
 			continue
 		}
 		for _, indexCol := range index.Columns {
+			if indexCol.Offset < 0 {
+				// The index column is an expression
+				continue
+			}
 			colInfo := tableInfo.Columns[indexCol.Offset]
 			if colInfo.IsGenerated() && !colInfo.GeneratedStored {
 				b.optFlag |= flagGcSubstitute
@@ -2623,6 +2806,7 @@ func (b *PlanBuilder) buildDataSource(tn *ast.TableName, asName *model.CIStr) (L
 		Columns:             make([]*model.ColumnInfo, 0, len(columns)),
 		TblCols:             make([]*expression.Column, 0, len(columns)),
 		is:                  b.is,
+		AsOf:                asOf,
 	}.Init(b.ctx, b.getSelectOffset())
 	var handleCols HandleCols
 	schema := expression.NewSchema(make([]*expression.Column, 0, len(columns))...)
@@ -2682,10 +2866,14 @@ func (b *PlanBuilder) buildDataSource(tn *ast.TableName, asName *model.CIStr) (L
 	if tableInfo.IsCommonHandle {
 		ds.commonHandleCols, ds.commonHandleLens = expression.IndexInfo2Cols(ds.Columns, ds.schema.Columns, findPrimaryIndex(tableInfo))
 	}
+	ds.indexExprs, err = b.buildIndexExprs(ds)
+	if err != nil {
+		return nil, err
+	}
 	// Init FullIdxCols, FullIdxColLens for accessPaths.
 	for _, path := range ds.possibleAccessPaths {
 		if !path.IsIntHandlePath {
-			path.FullIdxCols, path.FullIdxColLens = expression.IndexInfo2Cols(ds.Columns, ds.schema.Columns, path.Index)
+			path.FullIdxCols, path.FullIdxColLens = ds.indexInfo2Cols(ds.Columns, ds.schema.Columns, path.Index)
 		}
 	}
 
@@ -2817,6 +3005,9 @@ func collectGenerateColumn(lp LogicalPlan, exprToColumn ExprColumnMap) {
 	tblInfo := ds.tableInfo
 	for _, idx := range tblInfo.Indices {
 		for _, idxPart := range idx.Columns {
+			if idxPart.Offset < 0 {
+				continue
+			}
 			colInfo := tblInfo.Columns[idxPart.Offset]
 			if colInfo.IsGenerated() && !colInfo.GeneratedStored {
 				s := ds.schema.Columns
@@ -137,7 +137,14 @@ func (p *LogicalSelection) DeriveStats(childStats []*property.StatsInfo, selfSch
 	if p.stats != nil {
 		return p.stats, nil
 	}
-	p.stats = childStats[0].Scale(SelectionFactor)
+	selectivity := SelectionFactor
+	if childStats[0].HistColl != nil && !childStats[0].HistColl.Pseudo {
+		// The table has been analyzed so we can estimate the selectivity of the conditions from the histograms
+		if sel, _, err := childStats[0].HistColl.Selectivity(p.ctx, p.Conditions, nil); err == nil {
+			selectivity = sel
+		}
+	}
+	p.stats = childStats[0].Scale(selectivity)
 	p.stats.GroupNDVs = nil
 	return p.stats, nil
 }
@@ -52,6 +52,7 @@ func (s *LogicalTableScan) GetPhysicalScan(schema *expression.Schema, stats *pro
 		DBName:          ds.DBName,
 		Ranges:          s.Ranges,
 		AccessCondition: s.AccessConds,
+		AsOf:            ds.AsOf,
 	}.Init(s.ctx, s.blockOffset)
 	ts.stats = stats
 	ts.SetSchema(schema.Clone())
@@ -21,6 +21,7 @@ package planner
 import (
 	"github.com/pingcap/parser/model"
 	"github.com/squareup/pranadb/tidb/expression"
+	"github.com/squareup/pranadb/tidb/planner/util"
 	"github.com/squareup/pranadb/tidb/sessionctx"
 	"github.com/squareup/pranadb/tidb/statistics"
 	"github.com/squareup/pranadb/tidb/util/ranger"
@@ -54,6 +55,12 @@ type PhysicalIndexScan struct {
 
 	Desc      bool
 	KeepOrder bool
+	// ByItems holds the order the rows must be returned in when KeepOrder is true
+	ByItems []*util.ByItems
+
+	// DoubleRead is true if the index does not cover all the required columns, so each row must also be looked up
+	// in the table.
+	DoubleRead bool
 
 	NeedCommonHandle bool
 }
@@ -19,8 +19,11 @@
 package planner
 
 import (
+	"time"
+
 	"github.com/pingcap/parser/model"
 	"github.com/squareup/pranadb/tidb/expression"
+	"github.com/squareup/pranadb/tidb/planner/util"
 	"github.com/squareup/pranadb/tidb/sessionctx"
 	"github.com/squareup/pranadb/tidb/statistics"
 	"github.com/squareup/pranadb/tidb/util/ranger"
@@ -58,6 +61,11 @@ type PhysicalTableScan struct {
 	// KeepOrder is true, if sort data by scanning pkcol,
 	KeepOrder bool
 	Desc      bool
+	// ByItems holds the order the rows must be returned in when KeepOrder is true
+	ByItems []*util.ByItems
+
+	// AsOf is the time in the past as of which the rows of the table are read, or nil to read the current rows
+	AsOf *time.Time
 
 	isChildOfIndexLookUp bool
 }
@@ -200,9 +200,12 @@ func (r *PushSelDownTableScan) OnTransform(old *ExprIter) (newExprs []*GroupExpr
 	}.Init(ts.SCtx(), ts.SelectBlockOffset())
 	newTblScan.AccessConds = append(newTblScan.AccessConds, accesses...)
 	tblScanExpr := NewGroupExpr(newTblScan)
+	// If the table has been analyzed we keep the old expression so the optimizer can compare the cost of the table
+	// scan with that of any index scans
+	eraseOld = !ts.Source.hasCollectedStats()
 	if len(remained) == 0 {
 		// `sel -> ts` is transformed to `newTS`.
-		return []*GroupExpr{tblScanExpr}, true, false, nil
+		return []*GroupExpr{tblScanExpr}, eraseOld, false, nil
 	}
 	schema := old.GetExpr().Group.Prop.Schema
 	tblScanGroup := NewGroupWithSchema(tblScanExpr, schema)
@@ -210,7 +213,7 @@ func (r *PushSelDownTableScan) OnTransform(old *ExprIter) (newExprs []*GroupExpr
 	selExpr := NewGroupExpr(newSel)
 	selExpr.Children = append(selExpr.Children, tblScanGroup)
 	// `sel -> ts` is transformed to `newSel ->newTS`.
-	return []*GroupExpr{selExpr}, true, false, nil
+	return []*GroupExpr{selExpr}, eraseOld, false, nil
 }
 
 // PushSelDownIndexScan pushes a Selection down to IndexScan.
@@ -240,8 +243,27 @@ func (r *PushSelDownIndexScan) OnTransform(old *ExprIter) (newExprs []*GroupExpr
 	if len(is.IdxCols) == 0 {
 		return nil, false, false, nil
 	}
+	predicateImplied := is.PredicateImplied
+	if !predicateImplied {
+		if predicate := is.Source.indexPredicate(is.Index); predicate != nil {
+			if !conditionsImply(is.SCtx(), sel.Conditions, predicate) {
+				// The index doesn't hold all the rows the selection needs
+				return nil, false, false, nil
+			}
+			predicateImplied = true
+		}
+	}
 	conditions := sel.Conditions
-	if is.AccessConds != nil {
+	keyExprs, keyCols := is.Source.indexKeyExprs(is.Index)
+	if keyExprs != nil {
+		// The key of a functional index holds the values of expressions, so we match the conditions against them
+		// through the columns which stand in for the expressions. The selection is kept in full as its conditions can
+		// only be evaluated against the columns of the table.
+		conditions = make([]expression.Expression, len(sel.Conditions))
+		for i, cond := range sel.Conditions {
+			conditions[i] = substituteIndexExprs(is.SCtx(), cond, keyExprs, keyCols)
+		}
+	} else if is.AccessConds != nil {
 		// If we have already pushed some conditions down here,
 		// we merge old AccessConds with new conditions,
 		// to make sure this rule can be applied more than once.
@@ -263,34 +285,86 @@ func (r *PushSelDownIndexScan) OnTransform(old *ExprIter) (newExprs []*GroupExpr
 				break
 			}
 		}
-		if sameConds {
+		if sameConds && predicateImplied == is.PredicateImplied {
 			return nil, false, false, nil
 		}
 	}
+	remainedConds := res.RemainedConds
+	if keyExprs != nil {
+		remainedConds = sel.Conditions
+	}
 	// TODO: `res` still has some unused fields: EqOrInCount, IsDNFCond.
 	newIs := LogicalIndexScan{
-		Source:         is.Source,
-		IsDoubleRead:   is.IsDoubleRead,
-		EqCondCount:    res.EqCondCount,
-		AccessConds:    res.AccessConds,
-		Ranges:         res.Ranges,
-		Index:          is.Index,
-		Columns:        is.Columns,
-		FullIdxCols:    is.FullIdxCols,
-		FullIdxColLens: is.FullIdxColLens,
-		IdxCols:        is.IdxCols,
-		IdxColLens:     is.IdxColLens,
+		Source:           is.Source,
+		IsDoubleRead:     is.IsDoubleRead,
+		EqCondCount:      res.EqCondCount,
+		AccessConds:      res.AccessConds,
+		Ranges:           res.Ranges,
+		Index:            is.Index,
+		Columns:          is.Columns,
+		FullIdxCols:      is.FullIdxCols,
+		FullIdxColLens:   is.FullIdxColLens,
+		IdxCols:          is.IdxCols,
+		IdxColLens:       is.IdxColLens,
+		PredicateImplied: predicateImplied,
 	}.Init(is.SCtx(), is.SelectBlockOffset())
 	isExpr := NewGroupExpr(newIs)
+	// If the table has been analyzed we keep the old expression so the optimizer can compare the cost of the index
+	// scan with that of the table scan
+	eraseOld = !is.Source.hasCollectedStats()
 
-	if len(res.RemainedConds) == 0 {
-		return []*GroupExpr{isExpr}, true, false, nil
+	if len(remainedConds) == 0 {
+		return []*GroupExpr{isExpr}, eraseOld, false, nil
 	}
 	isGroup := NewGroupWithSchema(isExpr, old.Children[0].GetExpr().Group.Prop.Schema)
-	newSel := LogicalSelection{Conditions: res.RemainedConds}.Init(sel.SCtx(), sel.SelectBlockOffset())
+	newSel := LogicalSelection{Conditions: remainedConds}.Init(sel.SCtx(), sel.SelectBlockOffset())
 	selExpr := NewGroupExpr(newSel)
 	selExpr.SetChildren(isGroup)
-	return []*GroupExpr{selExpr}, true, false, nil
+	return []*GroupExpr{selExpr}, eraseOld, false, nil
+}
+
+// conditionsImply checks if every one of the predicate conditions is also one of the conditions.
+func conditionsImply(ctx sessionctx.Context, conditions []expression.Expression, predicate []expression.Expression) bool {
+	for _, pred := range predicate {
+		found := false
+		for _, cond := range conditions {
+			if cond.Equal(ctx, pred) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// substituteIndexExprs replaces any occurrences of the key expressions of a functional index in an expression with the
+// columns which stand in for them.
+func substituteIndexExprs(ctx sessionctx.Context, expr expression.Expression, keyExprs []expression.Expression, keyCols []*expression.Column) expression.Expression {
+	for i, keyExpr := range keyExprs {
+		if keyExpr != nil && expr.Equal(ctx, keyExpr) {
+			return keyCols[i]
+		}
+	}
+	sf, ok := expr.(*expression.ScalarFunction)
+	if !ok {
+		return expr
+	}
+	args := sf.GetArgs()
+	newArgs := make([]expression.Expression, len(args))
+	changed := false
+	for i, arg := range args {
+		newArgs[i] = substituteIndexExprs(ctx, arg, keyExprs, keyCols)
+		if newArgs[i] != arg {
+			changed = true
+		}
+	}
+	if !changed {
+		return expr
+	}
+	return expression.NewFunctionInternal(ctx, sf.FuncName.L, sf.RetType, newArgs...)
 }
 
 type DSToScans struct {